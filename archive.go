@@ -0,0 +1,235 @@
+package mhist
+
+import (
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// suffix of archived objects, every data file is archived as a compressed index and a compressed value log
+const archiveSuffix = ".gz"
+
+//Archive is a secondary storage location for rotated data files, e.g. a directory or an S3 compatible object store
+type Archive interface {
+	//Put the object under name
+	Put(name string, r io.Reader) error
+	//Get the object with name, returns an error satisfying os.IsNotExist if it doesn't exist
+	Get(name string) (io.ReadCloser, error)
+	//List the names of all objects
+	List() ([]string, error)
+	//Delete the object with name
+	Delete(name string) error
+}
+
+//DirArchive stores archived files in a local directory, e.g. a mounted network drive
+type DirArchive struct {
+	dir string
+}
+
+//NewDirArchive in dir, dir is created if it doesn't exist
+func NewDirArchive(dir string) (*DirArchive, error) {
+	err := os.MkdirAll(dir, os.ModePerm)
+	if err != nil {
+		return nil, err
+	}
+	return &DirArchive{dir: dir}, nil
+}
+
+//Put the object under name
+func (a *DirArchive) Put(name string, r io.Reader) error {
+	return writeFileAtomically(filepath.Join(a.dir, name), func(w io.Writer) error {
+		_, err := io.Copy(w, r)
+		return err
+	})
+}
+
+//Get the object with name
+func (a *DirArchive) Get(name string) (io.ReadCloser, error) {
+	return os.Open(filepath.Join(a.dir, name))
+}
+
+//List the names of all objects
+func (a *DirArchive) List() ([]string, error) {
+	files, err := ioutil.ReadDir(a.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(files))
+	for _, f := range files {
+		if !f.IsDir() && !strings.HasSuffix(f.Name(), ".tmp") {
+			names = append(names, f.Name())
+		}
+	}
+	return names, nil
+}
+
+//Delete the object with name
+func (a *DirArchive) Delete(name string) error {
+	err := os.Remove(filepath.Join(a.dir, name))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+// archiveIndex lists the data files in the archive, guarded by its own mutex
+// as it is changed by the compactor goroutine and read by the Listen goroutine
+type archiveIndex struct {
+	files FileInfoSlice
+	mutex sync.RWMutex
+}
+
+// loadArchiveIndex from the objects in the archive
+func loadArchiveIndex(archive Archive) (*archiveIndex, error) {
+	names, err := archive.List()
+	if err != nil {
+		return nil, err
+	}
+
+	index := &archiveIndex{}
+	for _, name := range names {
+		if !strings.HasSuffix(name, archiveSuffix) || strings.HasSuffix(name, "_values"+archiveSuffix) {
+			continue
+		}
+		info, err := timestampsFromFileName(strings.TrimSuffix(name, archiveSuffix))
+		if err != nil {
+			continue
+		}
+		info.archived = true
+		index.files = append(index.files, info)
+	}
+	return index, nil
+}
+
+// dropLocallyPresent files from the index, they were archived right before a crash and will be archived again
+func dropLocallyPresent(index *archiveIndex, dir string) {
+	remaining := FileInfoSlice{}
+	for _, info := range index.files {
+		if _, err := os.Stat(filepath.Join(dir, info.name)); err == nil {
+			continue
+		}
+		remaining = append(remaining, info)
+	}
+	index.files = remaining
+}
+
+func (i *archiveIndex) add(info *FileInfo) {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+
+	i.files = append(i.files, info)
+}
+
+func (i *archiveIndex) remove(info *FileInfo) {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+
+	remaining := make(FileInfoSlice, 0, len(i.files))
+	for _, f := range i.files {
+		if f.name != info.name {
+			remaining = append(remaining, f)
+		}
+	}
+	i.files = remaining
+}
+
+func (i *archiveIndex) list() FileInfoSlice {
+	i.mutex.RLock()
+	defer i.mutex.RUnlock()
+
+	files := make(FileInfoSlice, len(i.files))
+	copy(files, i.files)
+	return files
+}
+
+// archiveDataFile compresses the local data file into the archive, the value log is put first,
+// so an index in the archive always has its value log
+func archiveDataFile(archive Archive, file *FileInfo) error {
+	name := filepath.Base(file.indexName())
+	err := putCompressed(archive, name+"_values"+archiveSuffix, file.valueLogName())
+	if err != nil {
+		return err
+	}
+	return putCompressed(archive, name+archiveSuffix, file.indexName())
+}
+
+// deleteArchivedDataFile from the archive, the index is deleted first so no index without value log is left behind
+func deleteArchivedDataFile(archive Archive, file *FileInfo) error {
+	err := archive.Delete(file.indexName() + archiveSuffix)
+	if err != nil {
+		return err
+	}
+	return archive.Delete(file.valueLogName() + archiveSuffix)
+}
+
+// fetchArchivedDataFile decompresses the archived file into unlinked temporary files in dir,
+// they are removed from disk once closed
+func fetchArchivedDataFile(archive Archive, file *FileInfo, dir string) (index, valueLog *os.File, err error) {
+	index, err = getDecompressed(archive, file.indexName()+archiveSuffix, dir)
+	if err != nil {
+		return nil, nil, err
+	}
+	valueLog, err = getDecompressed(archive, file.valueLogName()+archiveSuffix, dir)
+	if err != nil {
+		index.Close()
+		return nil, nil, err
+	}
+	return index, valueLog, nil
+}
+
+func putCompressed(archive Archive, name, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	reader, writer := io.Pipe()
+	go func() {
+		compressor := gzip.NewWriter(writer)
+		_, err := io.Copy(compressor, f)
+		if err == nil {
+			err = compressor.Close()
+		}
+		writer.CloseWithError(err)
+	}()
+
+	err = archive.Put(name, reader)
+	// unblock the compressing goroutine if Put returned early
+	reader.CloseWithError(err)
+	return err
+}
+
+func getDecompressed(archive Archive, name, dir string) (*os.File, error) {
+	compressed, err := archive.Get(name)
+	if err != nil {
+		return nil, err
+	}
+	defer compressed.Close()
+
+	decompressor, err := gzip.NewReader(compressed)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := ioutil.TempFile(dir, "fetching")
+	if err != nil {
+		return nil, err
+	}
+	os.Remove(f.Name())
+
+	_, err = io.Copy(f, decompressor)
+	if err == nil {
+		_, err = f.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return f, nil
+}
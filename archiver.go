@@ -0,0 +1,172 @@
+package mhist
+
+import (
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+type archiveMessage struct {
+	file *FileInfo
+	// restored is the temporary path of an archived file fetched back to be compacted locally, empty when archiving
+	restored   string
+	resultChan chan error
+}
+
+// ArchiveFiles moves rotated files older than ArchiveAfter, and the oldest files exceeding the disk size, to the archive.
+// Does nothing if there is no archive.
+func (s *DiskStore) ArchiveFiles() error {
+	if s.archive == nil {
+		return nil
+	}
+
+	files, err := GetSortedFileList(s.dir)
+	if err != nil {
+		return err
+	}
+
+	size := files.TotalSize()
+	threshold := time.Now().Add(-s.archiveAfter).UnixNano()
+	for _, file := range files {
+		tooOld := s.archiveAfter > 0 && file.latestTs < threshold
+		if !tooOld && size <= s.maxDiskSize {
+			continue
+		}
+
+		// the file is compressed and uploaded outside of the Listen goroutine, rotated files don't change anymore
+		err := archiveDataFile(s.archive, file)
+		if err != nil {
+			return err
+		}
+		err = s.sendArchiveMessage(archiveMessage{file: file})
+		if err != nil {
+			return err
+		}
+		size -= file.size
+	}
+	return nil
+}
+
+// triggerArchiving without waiting for the next compaction interval, e.g. because the disk size is exceeded
+func (s *DiskStore) triggerArchiving() {
+	select {
+	case s.archiveNow <- struct{}{}:
+	default:
+		// archiving is already pending
+	}
+}
+
+// restoreIfDeleted fetches an archived file back to the data directory if it contains deleted measurements,
+// so it is compacted like the local files
+func (s *DiskStore) restoreIfDeleted(file *FileInfo) error {
+	index, valueLog, err := fetchArchivedDataFile(s.archive, file, s.dir)
+	if err != nil {
+		return err
+	}
+	defer index.Close()
+	defer valueLog.Close()
+
+	byteSlice, err := readAllFrom(index)
+	if err != nil {
+		return err
+	}
+	containsDeleted := false
+	for _, measurement := range BlockFromByteSlice(byteSlice) {
+		if s.meta.IsDeleted(measurement.ID, measurement.Ts) {
+			containsDeleted = true
+			break
+		}
+	}
+	if !containsDeleted {
+		return nil
+	}
+
+	restored := s.pathTo("restoring")
+	err = writeFileAtomically(restored+"_values", func(w io.Writer) error {
+		_, err := valueLog.Seek(0, io.SeekStart)
+		if err == nil {
+			_, err = io.Copy(w, valueLog)
+		}
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	err = writeFileAtomically(restored, func(w io.Writer) error {
+		_, err := w.Write(byteSlice)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	err = s.sendArchiveMessage(archiveMessage{file: file, restored: restored})
+	if err != nil {
+		return err
+	}
+	// a crash before the archived file is deleted only duplicates measurements
+	return deleteArchivedDataFile(s.archive, file)
+}
+
+func (s *DiskStore) sendArchiveMessage(message archiveMessage) error {
+	message.resultChan = make(chan error, 1)
+	select {
+	case s.archiveChan <- message:
+	case <-s.doneChan:
+		return nil
+	}
+	return <-message.resultChan
+}
+
+// handleArchive replaces the local file with the archived one or the other way around, in the Listen goroutine
+// so reads see either of them but never both
+func (s *DiskStore) handleArchive(message archiveMessage) error {
+	file := message.file
+	if message.restored != "" {
+		name := s.pathTo(file.indexName())
+		err := os.Rename(message.restored+"_values", name+"_values")
+		if err != nil {
+			return err
+		}
+		err = os.Rename(message.restored, name)
+		if err != nil {
+			return err
+		}
+		s.archived.remove(file)
+		s.cache.invalidate(file.indexName())
+		return nil
+	}
+
+	err := os.Remove(file.indexName())
+	if err != nil {
+		return err
+	}
+	err = os.Remove(file.valueLogName())
+	if err != nil {
+		log.Println(err)
+	}
+	s.cache.invalidate(file.indexName())
+	s.archived.add(&FileInfo{
+		name:     filepath.Base(file.indexName()),
+		oldestTs: file.oldestTs,
+		latestTs: file.latestTs,
+		archived: true,
+	})
+	return nil
+}
+
+func readAllFrom(f *os.File) ([]byte, error) {
+	_, err := f.Seek(0, io.SeekStart)
+	if err != nil {
+		return nil, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	byteSlice := make([]byte, info.Size())
+	_, err = io.ReadFull(f, byteSlice)
+	return byteSlice, err
+}
@@ -0,0 +1,403 @@
+package mhist
+
+import (
+	"bufio"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+//Permission granted by a token scope
+type Permission string
+
+//Permissions of token scopes, admin includes all others
+const (
+	PermissionRead      Permission = "read"
+	PermissionWrite     Permission = "write"
+	PermissionSubscribe Permission = "subscribe"
+	// PermissionAdmin allows administrative changes of series, snapshots, replication and rebalancing
+	PermissionAdmin Permission = "admin"
+)
+
+//ErrUnauthenticated is returned for requests without a valid token
+var ErrUnauthenticated = errors.New("missing or invalid token")
+
+//ErrPermissionDenied is returned if the token doesn't grant the permission for a series
+var ErrPermissionDenied = errors.New("permission denied")
+
+//AuthConfig of the tokens requests need, at least one of TokenFile and SecretFile has to be set
+type AuthConfig struct {
+	// TokenFile lists static tokens, one per line followed by its space separated scopes, e.g. "abc write:kitchen/*"
+	TokenFile string
+	// SecretFile contains the key tokens created by SignToken are verified with
+	SecretFile string
+	// PeerToken authenticates the server at peers, cluster nodes and its leader
+	PeerToken string
+}
+
+// scope grants a permission on all series names matching a pattern, in which * matches any characters
+type scope struct {
+	permission Permission
+	pattern    string
+	matcher    *regexp.Regexp
+}
+
+func parseScope(s string) (scope, error) {
+	parts := strings.SplitN(s, ":", 2)
+	if len(parts) != 2 || parts[1] == "" {
+		return scope{}, fmt.Errorf("scope %q is not of the form permission:pattern", s)
+	}
+	permission := Permission(parts[0])
+	switch permission {
+	case PermissionRead, PermissionWrite, PermissionSubscribe, PermissionAdmin:
+	default:
+		return scope{}, fmt.Errorf("scope %q has an unknown permission", s)
+	}
+
+	quoted := strings.Split(parts[1], "*")
+	for i := range quoted {
+		quoted[i] = regexp.QuoteMeta(quoted[i])
+	}
+	return scope{
+		permission: permission,
+		pattern:    parts[1],
+		matcher:    regexp.MustCompile("^" + strings.Join(quoted, ".*") + "$"),
+	}, nil
+}
+
+func parseScopes(scopes []string) (*grant, error) {
+	g := &grant{}
+	for _, s := range scopes {
+		// tenant:<name> binds the token to a tenant instead of granting a permission
+		if strings.HasPrefix(s, "tenant:") {
+			g.tenant = strings.TrimPrefix(s, "tenant:")
+			if !validTenantName.MatchString(g.tenant) {
+				return nil, fmt.Errorf("invalid tenant name %q", g.tenant)
+			}
+			continue
+		}
+		scope, err := parseScope(s)
+		if err != nil {
+			return nil, err
+		}
+		g.scopes = append(g.scopes, scope)
+	}
+	return g, nil
+}
+
+// grant of an authenticated token, nil grants everything
+type grant struct {
+	scopes []scope
+	// tenant the token is bound to, empty if it can select the tenant with the tenant header
+	tenant string
+}
+
+func (g *grant) allows(permission Permission, name string) bool {
+	if g == nil {
+		return true
+	}
+	for _, scope := range g.scopes {
+		if (scope.permission == permission || scope.permission == PermissionAdmin) && scope.matcher.MatchString(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// allowsAll series, e.g. for snapshots which contain every series
+func (g *grant) allowsAll(permission Permission) bool {
+	if g == nil {
+		return true
+	}
+	for _, scope := range g.scopes {
+		if (scope.permission == permission || scope.permission == PermissionAdmin) && scope.pattern == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// authorize the permission for all names, an empty list of names has to be filtered with allows instead
+func (g *grant) authorize(permission Permission, names ...string) error {
+	for _, name := range names {
+		if !g.allows(permission, name) {
+			return fmt.Errorf("%w: %v on %v", ErrPermissionDenied, permission, name)
+		}
+	}
+	return nil
+}
+
+func (g *grant) authorizeAll(permission Permission) error {
+	if !g.allowsAll(permission) {
+		return fmt.Errorf("%w: %v on all series", ErrPermissionDenied, permission)
+	}
+	return nil
+}
+
+// authenticator checks the tokens of requests
+type authenticator struct {
+	mutex sync.RWMutex
+	// tokens by their sha256 hash, so they aren't compared character by character
+	tokens map[string]*grant
+	secret []byte
+}
+
+func newAuthenticator(config AuthConfig) (*authenticator, error) {
+	a := &authenticator{}
+	err := a.reload(config)
+	if err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// reload the token and secret files, the previous tokens stay valid if they can't be read
+func (a *authenticator) reload(config AuthConfig) error {
+	tokens, secret, err := loadCredentials(config)
+	if err != nil {
+		return err
+	}
+	a.set(tokens, secret)
+	return nil
+}
+
+// set the tokens and the secret, requests authenticated afterwards use them
+func (a *authenticator) set(tokens map[string]*grant, secret []byte) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	a.tokens = tokens
+	a.secret = secret
+}
+
+// loadCredentials from the token and secret files of the config
+func loadCredentials(config AuthConfig) (map[string]*grant, []byte, error) {
+	if config.TokenFile == "" && config.SecretFile == "" {
+		return nil, nil, errors.New("authentication needs a token file or a secret file")
+	}
+
+	tokens := map[string]*grant{}
+	if config.TokenFile != "" {
+		err := readTokenFile(config.TokenFile, tokens)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	var secret []byte
+	if config.SecretFile != "" {
+		b, err := ioutil.ReadFile(config.SecretFile)
+		if err != nil {
+			return nil, nil, err
+		}
+		secret = []byte(strings.TrimSpace(string(b)))
+		if len(secret) == 0 {
+			return nil, nil, fmt.Errorf("the secret file %v is empty", config.SecretFile)
+		}
+	}
+	return tokens, secret, nil
+}
+
+func readTokenFile(path string, tokens map[string]*grant) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		grant, err := parseScopes(fields[1:])
+		if err != nil {
+			return fmt.Errorf("%v: %w", path, err)
+		}
+		tokens[hashToken(fields[0])] = grant
+	}
+	return scanner.Err()
+}
+
+func hashToken(token string) string {
+	hash := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(hash[:])
+}
+
+// signedToken is the payload of tokens created by SignToken
+type signedToken struct {
+	Scopes []string `json:"scopes"`
+	// Expires as unix seconds, 0 for tokens that don't expire
+	Expires int64 `json:"expires,omitempty"`
+}
+
+//SignToken creates a token granting the scopes until it expires, a zero expiry never expires.
+//Scopes are of the form permission:pattern, e.g. write:kitchen/* or read:*
+func SignToken(secret []byte, scopes []string, expires time.Time) (string, error) {
+	_, err := parseScopes(scopes)
+	if err != nil {
+		return "", err
+	}
+	payload := signedToken{Scopes: scopes}
+	if !expires.IsZero() {
+		payload.Expires = expires.Unix()
+	}
+	b, err := json.Marshal(payload)
+	if err != nil {
+		return "", err
+	}
+
+	encoded := base64.RawURLEncoding.EncodeToString(b)
+	return encoded + "." + base64.RawURLEncoding.EncodeToString(sign(secret, encoded)), nil
+}
+
+func sign(secret []byte, payload string) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(payload))
+	return mac.Sum(nil)
+}
+
+func (a *authenticator) authenticate(token string) (*grant, error) {
+	a.mutex.RLock()
+	grant, ok := a.tokens[hashToken(token)]
+	secret := a.secret
+	a.mutex.RUnlock()
+	if ok {
+		return grant, nil
+	}
+	if secret == nil {
+		return nil, ErrUnauthenticated
+	}
+
+	parts := strings.Split(token, ".")
+	if len(parts) != 2 {
+		return nil, ErrUnauthenticated
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil || !hmac.Equal(signature, sign(secret, parts[0])) {
+		return nil, ErrUnauthenticated
+	}
+	b, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, ErrUnauthenticated
+	}
+	payload := signedToken{}
+	err = json.Unmarshal(b, &payload)
+	if err != nil {
+		return nil, ErrUnauthenticated
+	}
+	if payload.Expires != 0 && time.Now().Unix() > payload.Expires {
+		return nil, fmt.Errorf("%w: the token expired", ErrUnauthenticated)
+	}
+	return parseScopes(payload.Scopes)
+}
+
+type grantKey struct{}
+
+// grantFor the request, nil if the server doesn't authenticate requests
+func (s *Server) grantFor(ctx context.Context) (*grant, error) {
+	if s.authenticator == nil {
+		return nil, nil
+	}
+	grant, ok := ctx.Value(grantKey{}).(*grant)
+	if !ok {
+		return nil, ErrUnauthenticated
+	}
+	return grant, nil
+}
+
+// authenticate the token of a request, the grant is added to the returned context
+func (a *authenticator) authenticateContext(ctx context.Context, token string) (context.Context, error) {
+	grant, err := a.authenticate(token)
+	if err != nil {
+		return nil, statusFromError(err)
+	}
+	return context.WithValue(ctx, grantKey{}, grant), nil
+}
+
+func tokenFromMetadata(ctx context.Context) string {
+	md, _ := metadata.FromIncomingContext(ctx)
+	for _, authorization := range md.Get("authorization") {
+		if strings.HasPrefix(authorization, "Bearer ") {
+			return strings.TrimPrefix(authorization, "Bearer ")
+		}
+	}
+	return ""
+}
+
+func (a *authenticator) unaryInterceptor(ctx context.Context, request interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	if isHealthCheck(info.FullMethod) {
+		return handler(ctx, request)
+	}
+	ctx, err := a.authenticateContext(ctx, tokenFromMetadata(ctx))
+	if err != nil {
+		return nil, err
+	}
+	return handler(ctx, request)
+}
+
+func (a *authenticator) streamInterceptor(server interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if isHealthCheck(info.FullMethod) {
+		return handler(server, stream)
+	}
+	ctx, err := a.authenticateContext(stream.Context(), tokenFromMetadata(stream.Context()))
+	if err != nil {
+		return err
+	}
+	return handler(server, &authenticatedStream{ServerStream: stream, ctx: ctx})
+}
+
+// isHealthCheck is true for the methods of the grpc health service, orchestration checks them without a token
+func isHealthCheck(fullMethod string) bool {
+	return strings.HasPrefix(fullMethod, "/grpc.health.v1.Health/")
+}
+
+// authenticatedStream carries the grant in its context
+type authenticatedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *authenticatedStream) Context() context.Context {
+	return s.ctx
+}
+
+// middleware authenticating http requests, the grant is in the context of the request
+func (a *authenticator) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		grant, err := a.authenticate(token)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), grantKey{}, grant)))
+	})
+}
+
+//TokenCredentials send a token with every request, used with grpc.WithPerRPCCredentials
+type TokenCredentials string
+
+//GetRequestMetadata for credentials.PerRPCCredentials
+func (t TokenCredentials) GetRequestMetadata(context.Context, ...string) (map[string]string, error) {
+	return map[string]string{"authorization": "Bearer " + string(t)}, nil
+}
+
+//RequireTransportSecurity for credentials.PerRPCCredentials, tokens are also sent without TLS
+func (t TokenCredentials) RequireTransportSecurity() bool {
+	return false
+}
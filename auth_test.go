@@ -0,0 +1,135 @@
+package mhist
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/alexmorten/mhist/models"
+	"github.com/alexmorten/mhist/proto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func Test_Scopes(t *testing.T) {
+	grant, err := parseScopes([]string{"write:kitchen/*", "read:*"})
+	require.NoError(t, err)
+	assert.True(t, grant.allows(PermissionWrite, "kitchen/temperature"))
+	assert.False(t, grant.allows(PermissionWrite, "garage/temperature"))
+	assert.False(t, grant.allows(PermissionWrite, "kitchen"))
+	assert.True(t, grant.allows(PermissionRead, "garage/temperature"))
+	assert.True(t, grant.allowsAll(PermissionRead))
+	assert.False(t, grant.allowsAll(PermissionWrite))
+
+	admin, err := parseScopes([]string{"admin:*"})
+	require.NoError(t, err)
+	assert.True(t, admin.allows(PermissionSubscribe, "kitchen/temperature"))
+
+	_, err = parseScopes([]string{"delete:*"})
+	assert.Error(t, err)
+}
+
+func Test_Auth(t *testing.T) {
+	dataPath := "test_data"
+	dir := "test_auth"
+	defer os.RemoveAll(dataPath)
+	defer os.RemoveAll(dir)
+	require.NoError(t, os.MkdirAll(dir, 0700))
+
+	tokens := "# static tokens\nproducer write:kitchen/*\ndashboard read:*\nroot admin:*\n"
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "tokens"), []byte(tokens), 0600))
+	secret := []byte("secret")
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "secret"), append(secret, '\n'), 0600))
+
+	server, err := NewServer(ServerConfig{DataPath: dataPath, GrpcPort: 16710, Auth: &AuthConfig{
+		TokenFile:  filepath.Join(dir, "tokens"),
+		SecretFile: filepath.Join(dir, "secret"),
+	}})
+	require.NoError(t, err)
+	go server.grpcHandler.Run()
+	defer server.Shutdown()
+
+	conns := []*grpc.ClientConn{}
+	defer func() {
+		for _, conn := range conns {
+			conn.Close()
+		}
+	}()
+	clientWithToken := func(token string) proto.MhistClient {
+		options := []grpc.DialOption{grpc.WithInsecure()}
+		if token != "" {
+			options = append(options, grpc.WithPerRPCCredentials(TokenCredentials(token)))
+		}
+		conn, err := grpc.Dial("localhost:16710", options...)
+		require.NoError(t, err)
+		conns = append(conns, conn)
+		return proto.NewMhistClient(conn)
+	}
+	ctx := context.Background()
+	store := func(client proto.MhistClient, name string) error {
+		_, err := client.Store(ctx, &proto.MeasurementMessage{
+			Name:        name,
+			Measurement: proto.MeasurementFromModel(&models.Numerical{Ts: 1, Value: 1}),
+		}, grpc.WaitForReady(true))
+		return err
+	}
+
+	t.Run("requests without a valid token are rejected", func(t *testing.T) {
+		assert.Equal(t, codes.Unauthenticated, status.Code(store(clientWithToken(""), "kitchen/temperature")))
+		assert.Equal(t, codes.Unauthenticated, status.Code(store(clientWithToken("guessed"), "kitchen/temperature")))
+	})
+
+	t.Run("producers only write their own series", func(t *testing.T) {
+		producer := clientWithToken("producer")
+		require.NoError(t, store(producer, "kitchen/temperature"))
+		assert.Equal(t, codes.PermissionDenied, status.Code(store(producer, "garage/temperature")))
+
+		_, err := producer.Retrieve(ctx, &proto.RetrieveRequest{Start: 1, End: 10, Filter: &proto.Filter{Names: []string{"kitchen/temperature"}}})
+		assert.Equal(t, codes.PermissionDenied, status.Code(err))
+		response, err := producer.Retrieve(ctx, &proto.RetrieveRequest{Start: 1, End: 10})
+		require.NoError(t, err)
+		assert.Empty(t, response.Histories, "unreadable series are left out")
+	})
+
+	t.Run("dashboards are read-only", func(t *testing.T) {
+		dashboard := clientWithToken("dashboard")
+		assert.Equal(t, codes.PermissionDenied, status.Code(store(dashboard, "kitchen/temperature")))
+
+		response, err := dashboard.Retrieve(ctx, &proto.RetrieveRequest{Start: 1, End: 10})
+		require.NoError(t, err)
+		assert.Contains(t, response.Histories, "kitchen/temperature")
+
+		_, err = dashboard.DeleteSeries(ctx, &proto.DeleteSeriesRequest{Names: []string{"kitchen/temperature"}})
+		assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	})
+
+	t.Run("signed tokens are verified with the secret", func(t *testing.T) {
+		token, err := SignToken(secret, []string{"read:kitchen/*"}, time.Now().Add(time.Hour))
+		require.NoError(t, err)
+		series, err := clientWithToken(token).ListSeries(ctx, &proto.ListSeriesRequest{})
+		require.NoError(t, err)
+		require.Len(t, series.Series, 1)
+		assert.Equal(t, "kitchen/temperature", series.Series[0].Name)
+
+		expired, err := SignToken(secret, []string{"read:*"}, time.Now().Add(-time.Minute))
+		require.NoError(t, err)
+		_, err = clientWithToken(expired).ListSeries(ctx, &proto.ListSeriesRequest{})
+		assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+		forged, err := SignToken([]byte("other secret"), []string{"admin:*"}, time.Time{})
+		require.NoError(t, err)
+		_, err = clientWithToken(forged).ListSeries(ctx, &proto.ListSeriesRequest{})
+		assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	})
+
+	t.Run("admins change series", func(t *testing.T) {
+		_, err := clientWithToken("root").DeleteSeries(ctx, &proto.DeleteSeriesRequest{Names: []string{"kitchen/temperature"}})
+		assert.NoError(t, err)
+	})
+}
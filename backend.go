@@ -0,0 +1,123 @@
+package mhist
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/alexmorten/mhist/models"
+)
+
+const (
+	//BackendDisk stores measurements in data files on disk
+	BackendDisk = "disk"
+	//BackendMemory keeps measurements in memory only, they are lost on shutdown
+	BackendMemory = "memory"
+)
+
+//Backend stores measurements and the meta of their series
+type Backend interface {
+	//Add measurement, fails if the type doesn't match the type of the series
+	Add(name string, measurement models.Measurement) error
+	//GetMeasurementsInTimeRange for all measurement names passing the filter
+	GetMeasurementsInTimeRange(start, end int64, filterDefinition models.FilterDefinition) (map[string][]models.Measurement, error)
+	//GetAllStoredInfos of the stored series
+	GetAllStoredInfos() []MeasurementTypeInfo
+
+	DeleteSeries(names []string) error
+	DeleteRange(names []string, start, end int64) error
+	RenameSeries(name, newName string) error
+	MergeSeries(name, into string) error
+	MigrateSeries(name string, t models.MeasurementType) error
+	//Cardinality of the series with up to top contributors of each kind
+	Cardinality(top int) CardinalityReport
+	//SetCardinalityLimits for new series and categorical values
+	SetCardinalityLimits(limits CardinalityLimits)
+
+	//Flush measurements that are held back
+	Flush() error
+	//Shutdown the backend, returns once everything is persisted
+	Shutdown()
+}
+
+//seriesAdministration implements the parts of a Backend that only concern the meta of series
+type seriesAdministration struct {
+	meta *DiskMeta
+}
+
+//DeleteSeries with all its measurements
+func (a *seriesAdministration) DeleteSeries(names []string) error {
+	return a.meta.DeleteSeries(names)
+}
+
+//DeleteRange of measurements of the named series, an end of 0 deletes everything from start on
+func (a *seriesAdministration) DeleteRange(names []string, start, end int64) error {
+	if end == 0 {
+		end = math.MaxInt64
+	}
+	if start > end {
+		return fmt.Errorf("%w: start %v is after end %v", ErrInvalidArgument, start, end)
+	}
+	return a.meta.DeleteRange(names, TimeRange{Start: start, End: end})
+}
+
+//RenameSeries keeping all its measurements
+func (a *seriesAdministration) RenameSeries(name, newName string) error {
+	return a.meta.RenameSeries(name, newName)
+}
+
+//MergeSeries into another series
+func (a *seriesAdministration) MergeSeries(name, into string) error {
+	return a.meta.MergeSeries(name, into)
+}
+
+//MigrateSeries to another measurement type
+func (a *seriesAdministration) MigrateSeries(name string, t models.MeasurementType) error {
+	if t < models.MeasurementNumerical || t > models.MeasurementRaw {
+		return fmt.Errorf("%w: unknown measurement type %v", ErrInvalidArgument, t)
+	}
+	return a.meta.MigrateSeries(name, t)
+}
+
+//Cardinality from meta
+func (a *seriesAdministration) Cardinality(top int) CardinalityReport {
+	return a.meta.cardinality(top)
+}
+
+//SetCardinalityLimits of meta
+func (a *seriesAdministration) SetCardinalityLimits(limits CardinalityLimits) {
+	a.meta.setCardinalityLimits(limits)
+}
+
+//GetAllStoredInfos from meta
+func (a *seriesAdministration) GetAllStoredInfos() []MeasurementTypeInfo {
+	return a.meta.GetAllStoredInfos()
+}
+
+// serialize the measurement with the ID of its series, fails if the type doesn't match the type of the series
+func (a *seriesAdministration) serialize(name string, measurement models.Measurement) (addMessage, error) {
+	id, err := a.meta.GetOrCreateID(name, measurement.Type())
+	if err != nil {
+		return addMessage{}, err
+	}
+
+	var valueOrValueID float64
+	var rawValue []byte
+
+	switch measurement.(type) {
+	case *models.Numerical:
+		valueOrValueID = measurement.(*models.Numerical).Value
+	case *models.Categorical:
+		valueOrValueID, err = a.meta.GetValueIDForCategoricalValue(id, measurement.(*models.Categorical).Value)
+		if err != nil {
+			return addMessage{}, err
+		}
+	case *models.Raw:
+		rawValue = measurement.(*models.Raw).Value
+	}
+
+	return addMessage{
+		name:        name,
+		measurement: SerializedMeasurement{ID: id, Ts: measurement.Timestamp(), Value: valueOrValueID},
+		rawValue:    rawValue,
+	}, nil
+}
@@ -0,0 +1,208 @@
+package mhist
+
+import (
+	"container/list"
+	"io/ioutil"
+	"os"
+	"sync"
+)
+
+// blockCache keeps the index blocks of recently read rotated files in memory, bounded by maxSize bytes.
+// Rotated files are never written to again, they only get removed, so a cached block never goes stale.
+type blockCache struct {
+	maxSize int64
+	size    int64
+	entries map[string]*list.Element
+	lru     *list.List
+
+	mutex sync.Mutex
+}
+
+// cachedFile holds the index block and an open value log of a rotated file,
+// the value log is closed once the file left the cache and no reader uses it anymore
+type cachedFile struct {
+	info     *FileInfo
+	index    *os.File
+	valueLog *os.File
+	// fetch opens archived files when the index block is loaded
+	fetch func() (index, valueLog *os.File, err error)
+
+	loadOnce sync.Once
+	block    Block
+	loadErr  error
+
+	// size, refs and evicted are guarded by the mutex of the cache
+	size    int64
+	refs    int
+	evicted bool
+}
+
+func newBlockCache(maxSize int) *blockCache {
+	return &blockCache{
+		maxSize: int64(maxSize),
+		entries: map[string]*list.Element{},
+		lru:     list.New(),
+	}
+}
+
+// acquire the file for reading, it has to be released afterwards.
+// The index block is loaded lazily, acquire only opens the files, so they stay readable even if they are removed in the meantime.
+func (c *blockCache) acquire(info *FileInfo) (*cachedFile, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if element, ok := c.entries[info.indexName()]; ok {
+		c.lru.MoveToFront(element)
+		file := element.Value.(*cachedFile)
+		file.refs++
+		return file, nil
+	}
+
+	index, err := os.Open(info.indexName())
+	if err != nil {
+		return nil, err
+	}
+	valueLog, err := os.Open(info.valueLogName())
+	if err != nil {
+		index.Close()
+		return nil, err
+	}
+	file := &cachedFile{info: info, index: index, valueLog: valueLog, refs: 1}
+	c.entries[info.indexName()] = c.lru.PushFront(file)
+	return file, nil
+}
+
+// acquireArchived file for reading, it has to be released afterwards.
+// The file is fetched from the archive when its index block is loaded.
+func (c *blockCache) acquireArchived(info *FileInfo, fetch func() (index, valueLog *os.File, err error)) *cachedFile {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if element, ok := c.entries[info.indexName()]; ok {
+		c.lru.MoveToFront(element)
+		file := element.Value.(*cachedFile)
+		file.refs++
+		return file
+	}
+
+	file := &cachedFile{info: info, fetch: fetch, refs: 1}
+	c.entries[info.indexName()] = c.lru.PushFront(file)
+	return file
+}
+
+// release the file after reading, closes it if it was evicted in the meantime
+func (c *blockCache) release(file *cachedFile) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	file.refs--
+	if file.evicted && file.refs == 0 {
+		file.close()
+	}
+}
+
+// invalidate the file, e.g. because it was removed from disk
+func (c *blockCache) invalidate(name string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if element, ok := c.entries[name]; ok {
+		c.remove(element)
+	}
+}
+
+// close all files that are not in use
+func (c *blockCache) clear() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	for c.lru.Len() > 0 {
+		c.remove(c.lru.Back())
+	}
+}
+
+// indexBlock of the file, read from disk on first use. A file that failed to load is removed from the cache,
+// so it is loaded again by the next reader
+func (c *blockCache) indexBlock(file *cachedFile) (Block, error) {
+	file.loadOnce.Do(func() {
+		// the value logs of archived files are decompressed to temporary files, they count against the size as well
+		var valueLogSize int64
+		if file.fetch != nil {
+			index, valueLog, err := file.fetch()
+			if err == nil {
+				var info os.FileInfo
+				info, err = valueLog.Stat()
+				if err != nil {
+					index.Close()
+					valueLog.Close()
+				} else {
+					valueLogSize = info.Size()
+				}
+			}
+			if err != nil {
+				file.loadErr = err
+				c.discard(file)
+				return
+			}
+			c.mutex.Lock()
+			file.index, file.valueLog = index, valueLog
+			c.mutex.Unlock()
+		}
+
+		byteSlice, err := ioutil.ReadAll(file.index)
+		file.index.Close()
+		if err != nil {
+			file.loadErr = err
+			c.discard(file)
+			return
+		}
+		file.block = BlockFromByteSlice(byteSlice)
+		c.loaded(file, int64(len(byteSlice))+valueLogSize)
+	})
+	return file.block, file.loadErr
+}
+
+// discard the file from the cache if it is still cached
+func (c *blockCache) discard(file *cachedFile) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if element, ok := c.entries[file.info.indexName()]; ok && element.Value == file {
+		c.remove(element)
+	}
+}
+
+func (c *blockCache) loaded(file *cachedFile, size int64) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if file.evicted {
+		return
+	}
+	file.size = size
+	c.size += size
+	for c.size > c.maxSize && c.lru.Len() > 0 {
+		c.remove(c.lru.Back())
+	}
+}
+
+func (c *blockCache) remove(element *list.Element) {
+	file := element.Value.(*cachedFile)
+	c.lru.Remove(element)
+	delete(c.entries, file.info.indexName())
+	c.size -= file.size
+	file.evicted = true
+	if file.refs == 0 {
+		file.close()
+	}
+}
+
+// close the index, in case it was never loaded, and the value log. The caller has to hold the mutex of the cache
+func (f *cachedFile) close() {
+	if f.index != nil {
+		f.index.Close()
+	}
+	if f.valueLog != nil {
+		f.valueLog.Close()
+	}
+}
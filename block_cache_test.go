@@ -0,0 +1,117 @@
+package mhist
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_BlockCache(t *testing.T) {
+	dataPath := "test_data"
+	defer os.RemoveAll(dataPath)
+	require.NoError(t, os.MkdirAll(dataPath, os.ModePerm))
+
+	writeFile := func(name string, measurements int) *FileInfo {
+		f, err := openDataFile(filepath.Join(dataPath, name))
+		require.NoError(t, err)
+		for i := 0; i < measurements; i++ {
+			_, err := f.append(addMessage{measurement: SerializedMeasurement{ID: 1, Ts: int64(i + 1), Value: 1}})
+			require.NoError(t, err)
+		}
+		f.close()
+		return f.fileInfo()
+	}
+
+	first := writeFile("1-10", 10)
+	second := writeFile("11-20", 10)
+	cache := newBlockCache(15 * serializedMeasurementSize)
+
+	t.Run("loads and caches the index block", func(t *testing.T) {
+		file, err := cache.acquire(first)
+		require.NoError(t, err)
+		block, err := cache.indexBlock(file)
+		require.NoError(t, err)
+		assert.Len(t, block, 10)
+		cache.release(file)
+
+		again, err := cache.acquire(first)
+		require.NoError(t, err)
+		assert.True(t, file == again)
+		cache.release(again)
+	})
+
+	t.Run("evicts the least recently used file when full", func(t *testing.T) {
+		file, err := cache.acquire(second)
+		require.NoError(t, err)
+		_, err = cache.indexBlock(file)
+		require.NoError(t, err)
+		cache.release(file)
+
+		assert.Len(t, cache.entries, 1)
+		assert.Contains(t, cache.entries, second.indexName())
+		assert.Equal(t, int64(10*serializedMeasurementSize), cache.size)
+	})
+
+	t.Run("files stay readable until released after being invalidated", func(t *testing.T) {
+		file, err := cache.acquire(second)
+		require.NoError(t, err)
+		cache.invalidate(second.indexName())
+		assert.Empty(t, cache.entries)
+		assert.Equal(t, int64(0), cache.size)
+
+		_, err = file.valueLog.Stat()
+		assert.NoError(t, err)
+		cache.release(file)
+		_, err = file.valueLog.Stat()
+		assert.Error(t, err)
+	})
+
+	t.Run("files evicted before their index block was loaded are closed", func(t *testing.T) {
+		file, err := cache.acquire(first)
+		require.NoError(t, err)
+		cache.release(file)
+		cache.invalidate(first.indexName())
+
+		_, err = file.index.Stat()
+		assert.Error(t, err)
+		_, err = file.valueLog.Stat()
+		assert.Error(t, err)
+	})
+
+	t.Run("archived files that failed to load are loaded again", func(t *testing.T) {
+		fail := true
+		fetch := func() (*os.File, *os.File, error) {
+			if fail {
+				return nil, nil, errors.New("the archive is unreachable")
+			}
+			index, err := os.Open(first.indexName())
+			if err != nil {
+				return nil, nil, err
+			}
+			valueLog, err := os.Open(first.valueLogName())
+			return index, valueLog, err
+		}
+
+		file := cache.acquireArchived(first, fetch)
+		_, err := cache.indexBlock(file)
+		assert.Error(t, err)
+		cache.release(file)
+		assert.NotContains(t, cache.entries, first.indexName())
+
+		fail = false
+		file = cache.acquireArchived(first, fetch)
+		block, err := cache.indexBlock(file)
+		require.NoError(t, err)
+		assert.Len(t, block, 10)
+		cache.release(file)
+
+		// the fetched value log counts against the size as well
+		info, err := file.valueLog.Stat()
+		require.NoError(t, err)
+		assert.Equal(t, int64(10*serializedMeasurementSize)+info.Size(), cache.size)
+	})
+}
@@ -0,0 +1,269 @@
+package mhist
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/alexmorten/mhist/models"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Supported formats for bulk imports and exports
+const (
+	FormatCSV    = "csv"
+	FormatNDJSON = "ndjson"
+)
+
+var csvHeader = []string{"name", "timestamp", "type", "value"}
+
+// exportWindow is the time range ExportInWindows queries at first
+const exportWindow = int64(time.Hour)
+
+// MessageReader reads messages one by one from an encoded source, returns io.EOF when done
+type MessageReader interface {
+	Read() (*models.Message, error)
+}
+
+// MessageWriter writes messages one by one to an encoded destination
+type MessageWriter interface {
+	Write(message *models.Message) error
+	Flush() error
+}
+
+// NewMessageReader for the given format
+func NewMessageReader(format string, r io.Reader) (MessageReader, error) {
+	switch format {
+	case FormatCSV:
+		reader := csv.NewReader(r)
+		reader.FieldsPerRecord = len(csvHeader)
+		return &csvMessageReader{reader: reader}, nil
+	case FormatNDJSON:
+		return &ndjsonMessageReader{decoder: json.NewDecoder(r)}, nil
+	}
+	return nil, fmt.Errorf("unknown format %q", format)
+}
+
+// NewMessageWriter for the given format
+func NewMessageWriter(format string, w io.Writer) (MessageWriter, error) {
+	switch format {
+	case FormatCSV:
+		return &csvMessageWriter{writer: csv.NewWriter(w)}, nil
+	case FormatNDJSON:
+		buffered := bufio.NewWriter(w)
+		return &ndjsonMessageWriter{buffered: buffered, encoder: json.NewEncoder(buffered)}, nil
+	}
+	return nil, fmt.Errorf("unknown format %q", format)
+}
+
+// TransferFilter restricts which measurements are imported or exported
+type TransferFilter struct {
+	Start int64
+	End   int64
+	Names []string
+}
+
+// Passes checks if the named measurement is in the time range and names of the filter
+func (f TransferFilter) Passes(name string, m models.Measurement) bool {
+	if m.Timestamp() < f.Start || m.Timestamp() > f.End {
+		return false
+	}
+	return models.FilterDefinition{Names: f.Names}.IsInNames(name)
+}
+
+// Import all messages from the reader that pass the filter into add, reporting the amount of imported measurements to progress
+func Import(reader MessageReader, filter TransferFilter, add func(name string, m models.Measurement) error, progress func(imported int)) (imported int, err error) {
+	for {
+		message, err := reader.Read()
+		if err == io.EOF {
+			return imported, nil
+		}
+		if err != nil {
+			return imported, err
+		}
+
+		measurement, err := message.ToMeasurement()
+		if err != nil {
+			return imported, fmt.Errorf("measurement %v: %v", imported+1, err)
+		}
+		if !filter.Passes(message.Name, measurement) {
+			continue
+		}
+
+		err = add(message.Name, measurement)
+		if err != nil {
+			return imported, err
+		}
+		imported++
+		progress(imported)
+	}
+}
+
+// Export the measurement map into the writer ordered by name, reporting the amount of exported measurements to progress
+func Export(writer MessageWriter, measurementMap map[string][]models.Measurement, filter TransferFilter, progress func(exported int)) (exported int, err error) {
+	exported, err = exportMap(writer, measurementMap, filter, progress)
+	if err != nil {
+		return exported, err
+	}
+	return exported, writer.Flush()
+}
+
+// ExportInWindows queries the time range of the filter window by window and exports each window into the writer,
+// so no single query has to return everything. Windows grow after each successful query and are split if the query
+// fails with ResourceExhausted, e.g. because the response is too large for grpc
+func ExportInWindows(writer MessageWriter, filter TransferFilter, query func(start, end int64) (map[string][]models.Measurement, error), progress func(exported int)) (exported int, err error) {
+	window := exportWindow
+	start := filter.Start
+	for start <= filter.End {
+		end := filter.End
+		if filter.End-start >= window {
+			end = start + window - 1
+		}
+
+		measurementMap, err := query(start, end)
+		if status.Code(err) == codes.ResourceExhausted && end > start {
+			window = (end-start)/2 + 1
+			continue
+		}
+		if err != nil {
+			return exported, err
+		}
+
+		n, err := exportMap(writer, measurementMap, filter, func(n int) { progress(exported + n) })
+		exported += n
+		if err != nil {
+			return exported, err
+		}
+		if window <= math.MaxInt64/2 {
+			window *= 2
+		}
+		if end == filter.End {
+			break
+		}
+		start = end + 1
+	}
+	return exported, writer.Flush()
+}
+
+func exportMap(writer MessageWriter, measurementMap map[string][]models.Measurement, filter TransferFilter, progress func(exported int)) (exported int, err error) {
+	names := make([]string, 0, len(measurementMap))
+	for name := range measurementMap {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		for _, measurement := range measurementMap[name] {
+			if !filter.Passes(name, measurement) {
+				continue
+			}
+
+			err = writer.Write(models.MessageFromMeasurement(name, measurement))
+			if err != nil {
+				return exported, err
+			}
+			exported++
+			progress(exported)
+		}
+	}
+	return exported, nil
+}
+
+type csvMessageReader struct {
+	reader      *csv.Reader
+	checkHeader bool
+}
+
+func (r *csvMessageReader) Read() (*models.Message, error) {
+	record, err := r.reader.Read()
+	if err != nil {
+		return nil, err
+	}
+
+	if !r.checkHeader {
+		r.checkHeader = true
+		if record[0] == csvHeader[0] && record[1] == csvHeader[1] {
+			return r.Read()
+		}
+	}
+
+	ts, err := strconv.ParseInt(record[1], 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	message := &models.Message{Name: record[0], Timestamp: ts, Type: record[2], Value: record[3]}
+
+	if message.Type == "" || message.Type == models.MeasurementNumerical.String() {
+		value, err := strconv.ParseFloat(record[3], 64)
+		if err == nil {
+			message.Value = value
+		} else if message.Type != "" {
+			return nil, err
+		}
+	}
+
+	return message, nil
+}
+
+type csvMessageWriter struct {
+	writer        *csv.Writer
+	headerWritten bool
+}
+
+func (w *csvMessageWriter) Write(message *models.Message) error {
+	if !w.headerWritten {
+		w.headerWritten = true
+		err := w.writer.Write(csvHeader)
+		if err != nil {
+			return err
+		}
+	}
+
+	var value string
+	switch v := message.Value.(type) {
+	case float64:
+		value = strconv.FormatFloat(v, 'g', -1, 64)
+	default:
+		value = fmt.Sprint(v)
+	}
+
+	return w.writer.Write([]string{message.Name, strconv.FormatInt(message.Timestamp, 10), message.Type, value})
+}
+
+func (w *csvMessageWriter) Flush() error {
+	w.writer.Flush()
+	return w.writer.Error()
+}
+
+type ndjsonMessageReader struct {
+	decoder *json.Decoder
+}
+
+func (r *ndjsonMessageReader) Read() (*models.Message, error) {
+	message := &models.Message{}
+	err := r.decoder.Decode(message)
+	if err != nil {
+		return nil, err
+	}
+	return message, nil
+}
+
+type ndjsonMessageWriter struct {
+	buffered *bufio.Writer
+	encoder  *json.Encoder
+}
+
+func (w *ndjsonMessageWriter) Write(message *models.Message) error {
+	return w.encoder.Encode(message)
+}
+
+func (w *ndjsonMessageWriter) Flush() error {
+	return w.buffered.Flush()
+}
@@ -0,0 +1,122 @@
+package mhist
+
+import (
+	"bytes"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/alexmorten/mhist/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func Test_BulkRoundTrip(t *testing.T) {
+	measurementMap := map[string][]models.Measurement{
+		"numerical":   {&models.Numerical{Ts: 1000, Value: 1.5}, &models.Numerical{Ts: 2000, Value: -3}},
+		"categorical": {&models.Categorical{Ts: 1500, Value: "on, off"}},
+		"raw":         {&models.Raw{Ts: 3000, Value: []byte{0, 1, 2, 255}}},
+	}
+	everything := TransferFilter{Start: 1, End: math.MaxInt64}
+
+	for _, format := range []string{FormatCSV, FormatNDJSON} {
+		t.Run(format, func(t *testing.T) {
+			buffer := &bytes.Buffer{}
+			writer, err := NewMessageWriter(format, buffer)
+			require.NoError(t, err)
+			exported, err := Export(writer, measurementMap, everything, func(int) {})
+			require.NoError(t, err)
+			assert.Equal(t, 4, exported)
+
+			reader, err := NewMessageReader(format, buffer)
+			require.NoError(t, err)
+			imported := map[string][]models.Measurement{}
+			n, err := Import(reader, everything, func(name string, m models.Measurement) error {
+				imported[name] = append(imported[name], m)
+				return nil
+			}, func(int) {})
+			require.NoError(t, err)
+			assert.Equal(t, 4, n)
+
+			for name, measurements := range measurementMap {
+				assert.ElementsMatch(t, measurements, imported[name])
+			}
+		})
+	}
+
+	t.Run("names are exported in order", func(t *testing.T) {
+		buffer := &bytes.Buffer{}
+		writer, err := NewMessageWriter(FormatCSV, buffer)
+		require.NoError(t, err)
+		_, err = Export(writer, measurementMap, everything, func(int) {})
+		require.NoError(t, err)
+
+		reader, err := NewMessageReader(FormatCSV, buffer)
+		require.NoError(t, err)
+		names := []string{}
+		_, err = Import(reader, everything, func(name string, m models.Measurement) error {
+			names = append(names, name)
+			return nil
+		}, func(int) {})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"categorical", "numerical", "numerical", "raw"}, names)
+	})
+
+	t.Run("filters by time range and names", func(t *testing.T) {
+		buffer := &bytes.Buffer{}
+		writer, err := NewMessageWriter(FormatNDJSON, buffer)
+		require.NoError(t, err)
+		exported, err := Export(writer, measurementMap, TransferFilter{Start: 1200, End: 2500, Names: []string{"numerical"}}, func(int) {})
+		require.NoError(t, err)
+		assert.Equal(t, 1, exported)
+	})
+}
+
+func Test_ExportInWindows(t *testing.T) {
+	hour := int64(time.Hour)
+	stored := map[string][]models.Measurement{}
+	for ts := int64(1); ts <= 100; ts++ {
+		// the measurements are spread over years, with a dense burst at the end
+		stored["b"] = append(stored["b"], &models.Numerical{Ts: ts * 100 * hour, Value: float64(ts)})
+		stored["a"] = append(stored["a"], &models.Numerical{Ts: 10000*hour + ts, Value: float64(ts)})
+	}
+
+	queries := 0
+	query := func(start, end int64) (map[string][]models.Measurement, error) {
+		queries++
+		result := map[string][]models.Measurement{}
+		for name, measurements := range stored {
+			for _, measurement := range measurements {
+				if measurement.Timestamp() >= start && measurement.Timestamp() <= end {
+					result[name] = append(result[name], measurement)
+				}
+			}
+		}
+		if len(result["a"])+len(result["b"]) > 10 {
+			return nil, status.Error(codes.ResourceExhausted, "grpc: received message larger than max")
+		}
+		return result, nil
+	}
+
+	buffer := &bytes.Buffer{}
+	writer, err := NewMessageWriter(FormatCSV, buffer)
+	require.NoError(t, err)
+	exported, err := ExportInWindows(writer, TransferFilter{Start: 1, End: math.MaxInt64}, query, func(int) {})
+	require.NoError(t, err)
+	assert.Equal(t, 200, exported)
+	// windows grow again after they were split, so years without measurements only take a few queries
+	assert.True(t, queries < 200, "%v queries", queries)
+
+	reader, err := NewMessageReader(FormatCSV, buffer)
+	require.NoError(t, err)
+	lastTs := map[string]int64{}
+	_, err = Import(reader, TransferFilter{Start: 1, End: math.MaxInt64}, func(name string, m models.Measurement) error {
+		assert.True(t, m.Timestamp() > lastTs[name])
+		lastTs[name] = m.Timestamp()
+		return nil
+	}, func(int) {})
+	require.NoError(t, err)
+	assert.Len(t, lastTs, 2)
+}
@@ -0,0 +1,153 @@
+package mhist
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+//ErrCardinalityLimit is returned for writes that would create more series or categorical values than allowed
+var ErrCardinalityLimit = errors.New("cardinality limit reached")
+
+// DefaultPrefixSeparator ends the prefix of a series name, e.g. kitchen in kitchen/temperature
+const DefaultPrefixSeparator = "/"
+
+// reason in rejectedWrites for writes beyond a cardinality limit
+const rejectedCardinality = "cardinality"
+
+//CardinalityLimits protect against clients creating series or categorical values without bound, e.g. with ids in names.
+//0 doesn't limit
+type CardinalityLimits struct {
+	// MaxSeries bounds the number of series
+	MaxSeries int `json:"max_series"`
+	// MaxSeriesPerPrefix bounds the number of series sharing the part of their name before the PrefixSeparator
+	MaxSeriesPerPrefix int `json:"max_series_per_prefix"`
+	// PrefixSeparator ends the prefix of a series name, DefaultPrefixSeparator if empty
+	PrefixSeparator string `json:"prefix_separator"`
+	// MaxCategoricalValues bounds the distinct values of each categorical series
+	MaxCategoricalValues int `json:"max_categorical_values"`
+}
+
+func (l CardinalityLimits) prefixOf(name string) string {
+	separator := l.PrefixSeparator
+	if separator == "" {
+		separator = DefaultPrefixSeparator
+	}
+	if i := strings.Index(name, separator); i >= 0 {
+		return name[:i]
+	}
+	return name
+}
+
+//CardinalityContributor is a prefix with its number of series or a series with its number of categorical values
+type CardinalityContributor struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+//CardinalityReport lists the largest contributors to the cardinality of a DB
+type CardinalityReport struct {
+	Series int               `json:"series"`
+	Limits CardinalityLimits `json:"limits"`
+	// Prefixes with the most series
+	Prefixes []CardinalityContributor `json:"prefixes"`
+	// CategoricalValues of the categorical series with the most distinct values
+	CategoricalValues []CardinalityContributor `json:"categorical_values"`
+}
+
+// setCardinalityLimits enforced for new series and values, existing ones are kept even if they exceed them
+func (m *DiskMeta) setCardinalityLimits(limits CardinalityLimits) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.limits = limits
+	m.seriesPerPrefix = nil
+	if limits.MaxSeriesPerPrefix > 0 {
+		m.seriesPerPrefix = map[string]int{}
+		for name := range m.NameToID {
+			m.seriesPerPrefix[limits.prefixOf(name)]++
+		}
+	}
+}
+
+// checkSeriesLimits before the series is created, or renamed from another series if from isn't empty.
+// Renames don't add a series, they only count against the prefix if it changes. The caller has to hold the mutex
+func (m *DiskMeta) checkSeriesLimits(name, from string) error {
+	if from == "" && m.limits.MaxSeries > 0 && len(m.NameToID) >= m.limits.MaxSeries {
+		rejectedWrites.Add(rejectedCardinality, 1)
+		return fmt.Errorf("%w: can't create %v, there are already %v series", ErrCardinalityLimit, name, m.limits.MaxSeries)
+	}
+	if m.seriesPerPrefix != nil {
+		prefix := m.limits.prefixOf(name)
+		if (from == "" || m.limits.prefixOf(from) != prefix) && m.seriesPerPrefix[prefix] >= m.limits.MaxSeriesPerPrefix {
+			rejectedWrites.Add(rejectedCardinality, 1)
+			return fmt.Errorf("%w: can't create %v, there are already %v series with the prefix %v", ErrCardinalityLimit, name, m.limits.MaxSeriesPerPrefix, prefix)
+		}
+	}
+	return nil
+}
+
+// checkValueLimits before the value is added to the series, the caller has to hold the mutex
+func (m *DiskMeta) checkValueLimits(id int64, valueIDMap *ValueIDMapping) error {
+	if m.limits.MaxCategoricalValues > 0 && len(valueIDMap.ValueToValueID) >= m.limits.MaxCategoricalValues {
+		rejectedWrites.Add(rejectedCardinality, 1)
+		return fmt.Errorf("%w: %v already has %v distinct values", ErrCardinalityLimit, m.IDToName[id], m.limits.MaxCategoricalValues)
+	}
+	return nil
+}
+
+// countSeries of the prefix of the name, the caller has to hold the mutex
+func (m *DiskMeta) countSeries(name string, delta int) {
+	if m.seriesPerPrefix == nil {
+		return
+	}
+	prefix := m.limits.prefixOf(name)
+	m.seriesPerPrefix[prefix] += delta
+	if m.seriesPerPrefix[prefix] <= 0 {
+		delete(m.seriesPerPrefix, prefix)
+	}
+}
+
+// cardinality report with up to top contributors of each kind
+func (m *DiskMeta) cardinality(top int) CardinalityReport {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	perPrefix := map[string]int{}
+	var values []CardinalityContributor
+	for name, id := range m.NameToID {
+		perPrefix[m.limits.prefixOf(name)]++
+		if valueIDMap := m.CategoricalMapping.IDToValueIDMap[id]; valueIDMap != nil {
+			values = append(values, CardinalityContributor{Name: name, Count: len(valueIDMap.ValueToValueID)})
+		}
+	}
+	prefixes := make([]CardinalityContributor, 0, len(perPrefix))
+	for prefix, count := range perPrefix {
+		prefixes = append(prefixes, CardinalityContributor{Name: prefix, Count: count})
+	}
+
+	return CardinalityReport{
+		Series:            len(m.NameToID),
+		Limits:            m.limits,
+		Prefixes:          topContributors(prefixes, top),
+		CategoricalValues: topContributors(values, top),
+	}
+}
+
+// topContributors by count, ties are ordered by name
+func topContributors(contributors []CardinalityContributor, top int) []CardinalityContributor {
+	sort.Slice(contributors, func(i, j int) bool {
+		if contributors[i].Count != contributors[j].Count {
+			return contributors[i].Count > contributors[j].Count
+		}
+		return contributors[i].Name < contributors[j].Name
+	})
+	if len(contributors) > top {
+		contributors = contributors[:top]
+	}
+	if contributors == nil {
+		return []CardinalityContributor{}
+	}
+	return contributors
+}
@@ -0,0 +1,85 @@
+package mhist
+
+import (
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/alexmorten/mhist/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func Test_Cardinality(t *testing.T) {
+	dataPath := "test_data"
+	defer os.RemoveAll(dataPath)
+
+	limits := CardinalityLimits{MaxSeries: 4, MaxSeriesPerPrefix: 2, MaxCategoricalValues: 2}
+	db, err := Open(dataPath, Options{Cardinality: limits})
+	require.NoError(t, err)
+	defer func() { db.Close() }()
+
+	t.Run("series beyond the limit of a prefix are rejected", func(t *testing.T) {
+		require.NoError(t, db.Write("kitchen/temperature", &models.Numerical{Ts: 1, Value: 21}))
+		require.NoError(t, db.Write("kitchen/humidity", &models.Numerical{Ts: 1, Value: 60}))
+
+		err := db.Write("kitchen/pressure", &models.Numerical{Ts: 1, Value: 1000})
+		assert.True(t, errors.Is(err, ErrCardinalityLimit))
+		assert.Equal(t, codes.ResourceExhausted, status.Code(statusFromError(err)))
+		// existing series can still be written
+		require.NoError(t, db.Write("kitchen/temperature", &models.Numerical{Ts: 2, Value: 22}))
+	})
+
+	t.Run("series beyond the total limit are rejected", func(t *testing.T) {
+		require.NoError(t, db.Write("garden/temperature", &models.Numerical{Ts: 1, Value: 15}))
+		require.NoError(t, db.Write("state", &models.Categorical{Ts: 1, Value: "on"}))
+
+		err := db.Write("garage/temperature", &models.Numerical{Ts: 1, Value: 10})
+		assert.True(t, errors.Is(err, ErrCardinalityLimit))
+	})
+
+	t.Run("categorical values beyond the limit are rejected", func(t *testing.T) {
+		require.NoError(t, db.Write("state", &models.Categorical{Ts: 2, Value: "off"}))
+		require.NoError(t, db.Write("state", &models.Categorical{Ts: 3, Value: "on"}))
+
+		err := db.Write("state", &models.Categorical{Ts: 4, Value: "broken"})
+		assert.True(t, errors.Is(err, ErrCardinalityLimit))
+	})
+
+	t.Run("deleted series free their place", func(t *testing.T) {
+		require.NoError(t, db.DeleteSeries([]string{"kitchen/humidity"}))
+		require.NoError(t, db.Write("kitchen/pressure", &models.Numerical{Ts: 1, Value: 1000}))
+	})
+
+	t.Run("renamed series count against the limit of their new prefix", func(t *testing.T) {
+		err := db.RenameSeries("garden/temperature", "kitchen/light")
+		assert.True(t, errors.Is(err, ErrCardinalityLimit))
+
+		// neither adds a series to a prefix or in total
+		require.NoError(t, db.RenameSeries("kitchen/pressure", "kitchen/barometer"))
+		require.NoError(t, db.RenameSeries("garden/temperature", "garden/soil"))
+	})
+
+	t.Run("the report lists the top contributors", func(t *testing.T) {
+		report, err := db.Cardinality(1)
+		require.NoError(t, err)
+		assert.Equal(t, CardinalityReport{
+			Series:            4,
+			Limits:            limits,
+			Prefixes:          []CardinalityContributor{{Name: "kitchen", Count: 2}},
+			CategoricalValues: []CardinalityContributor{{Name: "state", Count: 2}},
+		}, report)
+	})
+
+	t.Run("the limits apply to the series of a reopened db", func(t *testing.T) {
+		require.NoError(t, db.Close())
+		db, err = Open(dataPath, Options{Cardinality: CardinalityLimits{MaxSeriesPerPrefix: 2}})
+		require.NoError(t, err)
+
+		err := db.Write("kitchen/light", &models.Numerical{Ts: 1, Value: 1})
+		assert.True(t, errors.Is(err, ErrCardinalityLimit))
+		require.NoError(t, db.Write("garden/light", &models.Numerical{Ts: 1, Value: 1}))
+	})
+}
@@ -0,0 +1,274 @@
+package mhist
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/alexmorten/mhist/models"
+	"github.com/alexmorten/mhist/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// points per node on the hash ring, so series are spread evenly
+const clusterVirtualNodes = 128
+
+// cluster shards series by name across nodes, every node owns the series hashed to it.
+// Reads are fanned out to the other nodes by the federation.
+type cluster struct {
+	self string
+	ring hashRing
+	// federation of all other nodes, without prefixes
+	federation *federation
+	nodes      map[string]*federatedPeer
+}
+
+func newCluster(self string, nodes []Peer, federation *federation) (*cluster, error) {
+	names := []string{}
+	found := false
+	for _, node := range nodes {
+		names = append(names, node.Name)
+		found = found || node.Name == self
+	}
+	if !found {
+		return nil, fmt.Errorf("the node %q is not part of the cluster", self)
+	}
+
+	c := &cluster{
+		self:       self,
+		ring:       newHashRing(names),
+		federation: federation,
+		nodes:      map[string]*federatedPeer{},
+	}
+	for _, peer := range federation.peers {
+		c.nodes[peer.Name] = peer
+	}
+	return c, nil
+}
+
+// hashRing assigns series names to nodes by consistent hashing, adding a node only moves the series it takes over
+type hashRing []ringPoint
+
+type ringPoint struct {
+	hash uint32
+	node string
+}
+
+func newHashRing(nodes []string) hashRing {
+	ring := hashRing{}
+	for _, node := range nodes {
+		for i := 0; i < clusterVirtualNodes; i++ {
+			ring = append(ring, ringPoint{hash: hashString(node + "#" + strconv.Itoa(i)), node: node})
+		}
+	}
+	sort.Slice(ring, func(i, j int) bool { return ring[i].hash < ring[j].hash })
+	return ring
+}
+
+// owner of the series, the node of the first point after the hash of its name
+func (r hashRing) owner(name string) string {
+	hash := hashString(name)
+	i := sort.Search(len(r), func(i int) bool { return r[i].hash >= hash })
+	if i == len(r) {
+		i = 0
+	}
+	return r[i].node
+}
+
+func hashString(s string) uint32 {
+	hash := fnv.New32a()
+	hash.Write([]byte(s))
+	return hash.Sum32()
+}
+
+// forwarder sends measurements of series owned by other nodes to them, with one stream per node
+type forwarder struct {
+	cluster *cluster
+	ctx     context.Context
+	streams map[string]proto.Mhist_StoreStreamClient
+}
+
+// newForwarder for the measurements of a request, nil if they don't have to be forwarded
+// because the server isn't part of a cluster or the request was forwarded by another node
+func (c *cluster) newForwarder(ctx context.Context, forwarded bool) *forwarder {
+	if c == nil || forwarded {
+		return nil
+	}
+	return &forwarder{cluster: c, ctx: federatedContext(ctx), streams: map[string]proto.Mhist_StoreStreamClient{}}
+}
+
+// forward the message to its owner, returns false if it is owned by this node
+func (f *forwarder) forward(message *proto.MeasurementMessage) (bool, error) {
+	if f == nil {
+		return false, nil
+	}
+	owner := f.cluster.ring.owner(message.Name)
+	if owner == f.cluster.self {
+		return false, nil
+	}
+
+	stream, ok := f.streams[owner]
+	if !ok {
+		var err error
+		stream, err = f.cluster.nodes[owner].client.StoreStream(f.ctx)
+		if err != nil {
+			return true, fmt.Errorf("couldn't forward to %v: %w", owner, err)
+		}
+		f.streams[owner] = stream
+	}
+
+	err := stream.Send(message)
+	if err != nil {
+		return true, fmt.Errorf("couldn't forward to %v: %w", owner, err)
+	}
+	return true, nil
+}
+
+// close the streams, returns the first error of a node
+func (f *forwarder) close() error {
+	if f == nil {
+		return nil
+	}
+
+	var firstErr error
+	for owner, stream := range f.streams {
+		_, err := stream.CloseAndRecv()
+		if err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("couldn't forward to %v: %w", owner, err)
+		}
+	}
+	return firstErr
+}
+
+// broadcast an administrative change that was applied locally with localErr to all other nodes,
+// as series can be on other nodes than their owner until they are rebalanced. It succeeds if any node had the series.
+func (c *cluster) broadcast(ctx context.Context, localErr error, call func(ctx context.Context, client proto.MhistClient) error) error {
+	if localErr != nil && !errors.Is(localErr, ErrSeriesNotFound) {
+		return localErr
+	}
+
+	found := localErr == nil
+	var nodeErr error
+	mutex := sync.Mutex{}
+	c.federation.forEachPeer(ctx, c.federation.route(nil), func(ctx context.Context, peer *federatedPeer, _ []string) error {
+		err := call(ctx, peer.client)
+
+		mutex.Lock()
+		defer mutex.Unlock()
+		switch {
+		case err == nil:
+			found = true
+		case status.Code(err) != codes.NotFound && nodeErr == nil:
+			nodeErr = fmt.Errorf("node %v: %w", peer.Name, err)
+		}
+		return err
+	})
+
+	if nodeErr != nil {
+		return nodeErr
+	}
+	if !found {
+		return localErr
+	}
+	return nil
+}
+
+// rebalanceWindow is the time range of measurements read at once while rebalancing
+const rebalanceWindow = int64(time.Hour)
+
+// rebalance moves the local series owned by other nodes to them, e.g. after nodes were added.
+// Returns the amount of moved series and measurements. The series are deleted locally once their owners stored them,
+// after a failure they are moved again with the next rebalance, which duplicates the measurements already moved.
+func (c *cluster) rebalance(ctx context.Context, db *DB) (int, int, error) {
+	infos, err := db.Series()
+	if err != nil {
+		return 0, 0, err
+	}
+	names := []string{}
+	for _, info := range infos {
+		if c.ring.owner(info.Name) != c.self {
+			names = append(names, info.Name)
+		}
+	}
+	if len(names) == 0 {
+		return 0, 0, nil
+	}
+
+	windows, err := rebalanceWindows(db)
+	if err != nil {
+		return 0, 0, err
+	}
+	forwarder := c.newForwarder(ctx, false)
+	movedMeasurements := 0
+	for _, window := range windows {
+		result, err := db.Query(window.Start, window.End, models.FilterDefinition{Names: names})
+		if err != nil {
+			forwarder.close()
+			return 0, 0, err
+		}
+		for name, measurements := range result {
+			for _, measurement := range measurements {
+				_, err = forwarder.forward(&proto.MeasurementMessage{Name: name, Measurement: proto.MeasurementFromModel(measurement)})
+				if err != nil {
+					forwarder.close()
+					return 0, 0, err
+				}
+			}
+			movedMeasurements += len(measurements)
+		}
+	}
+	err = forwarder.close()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	err = db.DeleteSeries(names)
+	if err != nil {
+		return 0, 0, err
+	}
+	return len(names), movedMeasurements, nil
+}
+
+// rebalanceWindows covering the time range of all measurements of the DB. Backends without files keep their
+// measurements in memory anyway, they are read at once
+func rebalanceWindows(db *DB) ([]TimeRange, error) {
+	// buffered measurements have to be in the files to be covered by their time range
+	err := db.Flush()
+	if err != nil {
+		return nil, err
+	}
+	files, err := db.DataFiles()
+	if err == ErrMaintenanceNotSupported {
+		return []TimeRange{{Start: 1, End: math.MaxInt64}}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// only the time ranges of files are covered, there is nothing to read in between
+	sort.Slice(files, func(i, j int) bool { return files[i].OldestTs < files[j].OldestTs })
+	windows := []TimeRange{}
+	covered := int64(math.MinInt64)
+	for _, file := range files {
+		if !file.Archived && file.IndexSize == 0 || file.LatestTs <= covered {
+			continue
+		}
+		start := file.OldestTs
+		if start <= covered {
+			start = covered + 1
+		}
+		for ; file.LatestTs-start >= rebalanceWindow; start += rebalanceWindow {
+			windows = append(windows, TimeRange{Start: start, End: start + rebalanceWindow - 1})
+		}
+		windows = append(windows, TimeRange{Start: start, End: file.LatestTs})
+		covered = file.LatestTs
+	}
+	return windows, nil
+}
@@ -0,0 +1,139 @@
+package mhist
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/alexmorten/mhist/models"
+	"github.com/alexmorten/mhist/proto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func Test_HashRing(t *testing.T) {
+	ring := newHashRing([]string{"a", "b", "c"})
+	grown := newHashRing([]string{"a", "b", "c", "d"})
+
+	owned := map[string]int{}
+	for i := 0; i < 1000; i++ {
+		name := fmt.Sprintf("series_%v", i)
+		owner := ring.owner(name)
+		owned[owner]++
+		assert.Equal(t, owner, ring.owner(name), "owners are stable")
+		if newOwner := grown.owner(name); newOwner != owner {
+			assert.Equal(t, "d", newOwner, "series only move to the added node")
+		}
+	}
+	for _, node := range []string{"a", "b", "c"} {
+		assert.True(t, owned[node] > 200, "series are spread evenly, %v owns %v", node, owned[node])
+	}
+}
+
+func Test_Cluster(t *testing.T) {
+	nodes := []Peer{
+		{Name: "a", Address: "localhost:16690"},
+		{Name: "b", Address: "localhost:16691"},
+		{Name: "c", Address: "localhost:16692"},
+	}
+	servers := map[string]*Server{}
+	for i, node := range nodes {
+		dataPath := fmt.Sprintf("test_node_%v_data", node.Name)
+		defer os.RemoveAll(dataPath)
+
+		server, err := NewServer(ServerConfig{DataPath: dataPath, GrpcPort: 16690 + i, ClusterNodes: nodes, ClusterSelf: node.Name})
+		require.NoError(t, err)
+		go server.grpcHandler.Run()
+		defer server.Shutdown()
+		servers[node.Name] = server
+	}
+	// the nodes connect to each other in the background
+	for _, server := range servers {
+		for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+			response, err := server.grpcHandler.ListSeries(context.Background(), &proto.ListSeriesRequest{})
+			require.NoError(t, err)
+			if len(response.PeerErrors) == 0 {
+				break
+			}
+		}
+	}
+	a := servers["a"]
+	names := []string{"temperature", "humidity", "pressure", "wind", "rain", "light"}
+
+	t.Run("measurements are stored on the owner of their series", func(t *testing.T) {
+		for i, name := range names {
+			_, err := a.grpcHandler.Store(context.Background(), &proto.MeasurementMessage{
+				Name:        name,
+				Measurement: proto.MeasurementFromModel(&models.Numerical{Ts: int64(i + 1), Value: float64(i)}),
+			})
+			require.NoError(t, err)
+		}
+
+		for _, name := range names {
+			owner := a.cluster.ring.owner(name)
+			for node, server := range servers {
+				result, err := server.db.Query(1, 100, models.FilterDefinition{Names: []string{name}})
+				require.NoError(t, err)
+				if node == owner {
+					assert.Len(t, result[name], 1, "%v is stored on its owner %v", name, owner)
+				} else {
+					assert.Empty(t, result[name], "%v is only stored on its owner %v", name, owner)
+				}
+			}
+		}
+	})
+
+	t.Run("every node answers queries for all series", func(t *testing.T) {
+		for _, server := range servers {
+			response, err := server.grpcHandler.Retrieve(context.Background(), &proto.RetrieveRequest{Start: 1, End: 100})
+			require.NoError(t, err)
+			assert.Len(t, response.ToMeasurementMap(), len(names))
+			assert.Empty(t, response.PeerErrors)
+
+			latest, err := server.grpcHandler.Latest(context.Background(), &proto.LatestRequest{Names: []string{"wind"}})
+			require.NoError(t, err)
+			assert.Equal(t, &models.Numerical{Ts: 4, Value: 3}, latest.Latest["wind"].ToModelWithDefinedTs())
+		}
+	})
+
+	t.Run("administrative changes reach the node holding the series", func(t *testing.T) {
+		_, err := servers["b"].grpcHandler.DeleteSeries(context.Background(), &proto.DeleteSeriesRequest{Names: []string{"rain"}})
+		require.NoError(t, err)
+		response, err := a.grpcHandler.ListSeries(context.Background(), &proto.ListSeriesRequest{})
+		require.NoError(t, err)
+		assert.Len(t, response.Series, len(names)-1)
+
+		_, err = servers["c"].grpcHandler.DeleteSeries(context.Background(), &proto.DeleteSeriesRequest{Names: []string{"rain"}})
+		assert.Equal(t, codes.NotFound, status.Code(err))
+	})
+
+	t.Run("Rebalance moves series to their owners", func(t *testing.T) {
+		// e.g. written before the node was part of the cluster
+		misplaced := ""
+		for i := 0; misplaced == ""; i++ {
+			if name := fmt.Sprintf("misplaced_%v", i); a.cluster.ring.owner(name) != "a" {
+				misplaced = name
+			}
+		}
+		require.NoError(t, a.db.Write(misplaced, &models.Numerical{Ts: 1, Value: 1}))
+		// measurements hours apart are moved in separate windows
+		later := 2 + 3*rebalanceWindow
+		require.NoError(t, a.db.Write(misplaced, &models.Numerical{Ts: later, Value: 2}))
+
+		response, err := a.grpcHandler.Rebalance(context.Background(), &proto.RebalanceRequest{})
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), response.MovedSeries)
+		assert.Equal(t, int64(2), response.MovedMeasurements)
+
+		local, err := a.db.Query(1, later, models.FilterDefinition{Names: []string{misplaced}})
+		require.NoError(t, err)
+		assert.Empty(t, local[misplaced])
+		owned, err := servers[a.cluster.ring.owner(misplaced)].db.Query(1, later, models.FilterDefinition{Names: []string{misplaced}})
+		require.NoError(t, err)
+		assert.Len(t, owned[misplaced], 2)
+	})
+}
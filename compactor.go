@@ -0,0 +1,260 @@
+package mhist
+
+import (
+	"io/ioutil"
+	"log"
+	"os"
+	"time"
+)
+
+const compactionInterval = 1 * time.Minute
+
+type compactMessage struct {
+	// file to compact, if nil the compaction pass is finished
+	file *FileInfo
+	// rotate the files that are still written to if they contain deleted measurements, instead of compacting a file
+	rotate     bool
+	generation int64
+	resultChan chan error
+}
+
+// runCompactor periodically removes deleted measurements from rotated files and moves old ones to the archive,
+// the actual compaction of each file happens in the Listen goroutine
+func (s *DiskStore) runCompactor() {
+	ticker := time.NewTicker(compactionInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.doneChan:
+			return
+		case <-ticker.C:
+			err := s.Compact()
+			if err != nil {
+				log.Println("compaction failed:", err)
+			}
+			err = s.ArchiveFiles()
+			if err != nil {
+				log.Println("archiving failed:", err)
+			}
+		case <-s.archiveNow:
+			err := s.ArchiveFiles()
+			if err != nil {
+				log.Println("archiving failed:", err)
+			}
+		}
+	}
+}
+
+// Compact all files that contain deleted measurements, does nothing if nothing was deleted.
+// The files that are still written to are rotated first if they contain deleted measurements,
+// archived ones are restored to be compacted locally.
+func (s *DiskStore) Compact() error {
+	s.compactMutex.Lock()
+	defer s.compactMutex.Unlock()
+
+	generation := s.meta.GetDeletionGeneration()
+	if generation == 0 {
+		return nil
+	}
+
+	if s.archive != nil {
+		err := s.restoreTombstonedArchives(generation)
+		if err != nil {
+			return err
+		}
+	}
+
+	err := s.sendCompactMessage(compactMessage{rotate: true, generation: generation})
+	if err != nil {
+		return err
+	}
+	files, err := GetSortedFileList(s.dir)
+	if err != nil {
+		return err
+	}
+
+	for _, file := range files {
+		err := s.sendCompactMessage(compactMessage{file: file, generation: generation})
+		if err != nil {
+			return err
+		}
+	}
+	err = s.sendCompactMessage(compactMessage{generation: generation})
+	if err == nil && s.meta.GetDeletionGeneration() == 0 {
+		s.checkedArchived = map[string]int64{}
+	}
+	return err
+}
+
+// restoreTombstonedArchives restores the archived files whose time range overlaps a tombstone.
+// Files are only fetched once per deletion generation, even if compaction is retried
+func (s *DiskStore) restoreTombstonedArchives(generation int64) error {
+	tombstones := s.meta.TombstonedRanges()
+	for _, file := range s.archived.list() {
+		if s.checkedArchived[file.name] == generation || !overlapsAny(tombstones, file) {
+			continue
+		}
+		err := s.restoreIfDeleted(file)
+		if err != nil {
+			return err
+		}
+		s.checkedArchived[file.name] = generation
+	}
+	return nil
+}
+
+func overlapsAny(ranges []TimeRange, file *FileInfo) bool {
+	for _, timeRange := range ranges {
+		if timeRange.Overlaps(file.oldestTs, file.latestTs) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *DiskStore) sendCompactMessage(message compactMessage) error {
+	message.resultChan = make(chan error, 1)
+	select {
+	case s.compactChan <- message:
+	case <-s.doneChan:
+		return nil
+	}
+	return <-message.resultChan
+}
+
+func (s *DiskStore) handleCompact(message compactMessage) error {
+	switch {
+	case message.rotate:
+		return s.rotateIfDeleted()
+	case message.file == nil:
+		return s.finishCompaction(message.generation)
+	}
+	return s.compactFile(message.file)
+}
+
+// rotateIfDeleted rotates the files that are still written to if they or the reorder buffer contain deleted measurements,
+// so they are compacted like the other rotated files
+func (s *DiskStore) rotateIfDeleted() error {
+	containsDeleted, err := s.currentContainsDeleted()
+	if err != nil || !containsDeleted {
+		return err
+	}
+	err = s.forceRotation()
+	s.check(err)
+	return err
+}
+
+// currentContainsDeleted checks the files that are still written to and the reorder buffer for deleted measurements
+func (s *DiskStore) currentContainsDeleted() (bool, error) {
+	for _, f := range []*dataFile{s.DiskWriter.dataFile, s.DiskWriter.backfill} {
+		byteSlice, err := ioutil.ReadFile(f.indexWriter.Name())
+		if err != nil {
+			return false, err
+		}
+		for _, measurement := range BlockFromByteSlice(byteSlice) {
+			if s.meta.IsDeleted(measurement.ID, measurement.Ts) {
+				return true, nil
+			}
+		}
+	}
+
+	for _, message := range s.DiskWriter.reorderBuffer.messages {
+		if s.meta.IsDeleted(message.measurement.ID, message.measurement.Ts) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// compactFile rewrites the file without deleted measurements, under a new name as the time range might shrink
+func (s *DiskStore) compactFile(file *FileInfo) error {
+	byteSlice, err := ioutil.ReadFile(file.indexName())
+	if os.IsNotExist(err) {
+		// removed by rotation in the meantime
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	block := BlockFromByteSlice(byteSlice)
+
+	deleted := 0
+	for _, measurement := range block {
+		if s.meta.IsDeleted(measurement.ID, measurement.Ts) {
+			deleted++
+		}
+	}
+	if deleted == 0 {
+		return nil
+	}
+	if deleted < len(block) {
+		err = s.rewriteWithoutDeleted(file, block)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = os.Remove(file.indexName())
+	if err != nil {
+		return err
+	}
+	s.cache.invalidate(file.indexName())
+	return os.Remove(file.valueLogName())
+}
+
+func (s *DiskStore) rewriteWithoutDeleted(file *FileInfo, block Block) error {
+	valueFile, err := os.Open(file.valueLogName())
+	if err != nil {
+		return err
+	}
+	defer valueFile.Close()
+
+	tmpPath := s.pathTo("compacting")
+	os.Remove(tmpPath)
+	os.Remove(tmpPath + "_values")
+	compacted, err := openDataFile(tmpPath)
+	if err != nil {
+		return err
+	}
+
+	for _, measurement := range block {
+		if s.meta.IsDeleted(measurement.ID, measurement.Ts) {
+			continue
+		}
+
+		message := addMessage{measurement: measurement}
+		if measurement.Size > 0 {
+			message.rawValue = make([]byte, measurement.Size)
+			_, err = valueFile.ReadAt(message.rawValue, int64(measurement.Value))
+			if err != nil {
+				compacted.close()
+				return err
+			}
+		}
+
+		_, err = compacted.append(message)
+		if err != nil {
+			compacted.close()
+			return err
+		}
+	}
+
+	err = compacted.sync()
+	compacted.close()
+	if err != nil {
+		return err
+	}
+	// a crash between this rename and removing the old file leaves both in place, which only duplicates measurements
+	return compacted.rename(s.pathTo(uniqueFileNameFromTs(compacted.firstWrittenTs, compacted.lastWrittenTs, "compacted")))
+}
+
+// finishCompaction clears the tombstones, if the files that are still written to don't contain deleted measurements.
+// Measurements written to them since they were rotated can be in a deleted range, those are compacted in a later pass.
+func (s *DiskStore) finishCompaction(generation int64) error {
+	containsDeleted, err := s.currentContainsDeleted()
+	if err != nil || containsDeleted {
+		return err
+	}
+	return s.meta.CompactionDone(generation)
+}
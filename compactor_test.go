@@ -0,0 +1,102 @@
+package mhist
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/alexmorten/mhist/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_DeletionAndCompaction(t *testing.T) {
+	dataPath := "test_data"
+	defer os.RemoveAll(dataPath)
+
+	store, err := NewDiskStore(dataPath, Options{MemorySize: 1024, DiskSize: 24 * 1024 * 1024})
+	require.NoError(t, err)
+	defer store.Shutdown()
+
+	for ts := int64(1000); ts <= 100000; ts += 1000 {
+		store.Add("kept", &models.Numerical{Ts: ts, Value: 1})
+		store.Add("leaked", &models.Raw{Ts: ts, Value: []byte("secret")})
+		store.Add("partially_deleted", &models.Categorical{Ts: ts, Value: "a"})
+	}
+	store.Flush()
+
+	require.NoError(t, store.DeleteSeries([]string{"leaked"}))
+	require.NoError(t, store.DeleteRange([]string{"partially_deleted"}, 1000, 50000))
+	assert.Error(t, store.DeleteSeries([]string{"unknown"}))
+	// nothing is deleted if one of the names doesn't exist
+	assert.Error(t, store.DeleteSeries([]string{"kept", "unknown"}))
+	assert.Error(t, store.DeleteRange([]string{"kept", "unknown"}, 1000, 50000))
+
+	assertStored := func(t *testing.T) {
+		result, err := store.GetMeasurementsInTimeRange(1, 200000, models.FilterDefinition{})
+		require.NoError(t, err)
+		assert.Len(t, result["kept"], 100)
+		assert.Empty(t, result["leaked"])
+		assert.Len(t, result["partially_deleted"], 50)
+	}
+
+	t.Run("deleted measurements are not retrieved anymore", assertStored)
+
+	t.Run("compaction removes deleted measurements from rotated files", func(t *testing.T) {
+		require.NoError(t, store.Compact())
+		assertStored(t)
+
+		files, err := GetSortedFileList(dataPath)
+		require.NoError(t, err)
+		require.NotEmpty(t, files)
+		for _, file := range files {
+			byteSlice, err := ioutil.ReadFile(file.indexName())
+			require.NoError(t, err)
+			for _, measurement := range BlockFromByteSlice(byteSlice) {
+				assert.False(t, store.meta.IsDeleted(measurement.ID, measurement.Ts))
+			}
+		}
+	})
+
+	t.Run("a deleted name can be used again", func(t *testing.T) {
+		store.Add("leaked", &models.Numerical{Ts: 200000, Value: 5})
+		result, err := store.GetMeasurementsInTimeRange(1, 300000, models.FilterDefinition{Names: []string{"leaked"}})
+		require.NoError(t, err)
+		assert.Equal(t, []models.Measurement{&models.Numerical{Ts: 200000, Value: 5}}, result["leaked"])
+	})
+}
+
+func Test_CompactionOfCurrentFiles(t *testing.T) {
+	dataPath := "test_data"
+	defer os.RemoveAll(dataPath)
+
+	store, err := NewDiskStore(dataPath, Options{MemorySize: 1024 * 1024, DiskSize: 24 * 1024 * 1024})
+	require.NoError(t, err)
+	defer store.Shutdown()
+
+	future := time.Now().Add(time.Hour).UnixNano()
+	for _, ts := range []int64{1000, 2000, future} {
+		require.NoError(t, store.Add("a", &models.Numerical{Ts: ts, Value: 1}))
+	}
+	require.NoError(t, store.Flush())
+
+	// without an end everything from start on is deleted, even measurements in the future
+	require.NoError(t, store.DeleteRange([]string{"a"}, 2000, 0))
+	require.NoError(t, store.Compact())
+	assert.Equal(t, int64(0), store.meta.GetDeletionGeneration())
+
+	files, err := GetSortedFileList(dataPath)
+	require.NoError(t, err)
+	stored := 0
+	for _, file := range files {
+		byteSlice, err := ioutil.ReadFile(file.indexName())
+		require.NoError(t, err)
+		stored += len(BlockFromByteSlice(byteSlice))
+	}
+	assert.Equal(t, 1, stored)
+
+	result, err := store.GetMeasurementsInTimeRange(1, future+1, models.FilterDefinition{})
+	require.NoError(t, err)
+	assert.Equal(t, []models.Measurement{&models.Numerical{Ts: 1000, Value: 1}}, result["a"])
+}
@@ -0,0 +1,168 @@
+package mhist
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log"
+	"reflect"
+	"time"
+)
+
+// validate the config before anything is opened, so mistakes are reported at startup and rejected on reload
+func (c ServerConfig) validate() error {
+	for name, port := range map[string]int{"grpc port": c.GrpcPort, "debug port": c.DebugPort} {
+		if port < 0 || port > 65535 {
+			return fmt.Errorf("the %v %v is not a valid port", name, port)
+		}
+	}
+	switch c.Backend {
+	case "", BackendDisk, BackendMemory:
+	default:
+		return fmt.Errorf("unknown backend %q", c.Backend)
+	}
+
+	for name, value := range map[string]int{
+		"memory size":            c.MemorySize,
+		"disk size":              c.DiskSize,
+		"commit size":            c.CommitSize,
+		"connection rate":        c.ConnectionRate,
+		"series rate":            c.SeriesRate,
+		"max series":             c.Cardinality.MaxSeries,
+		"max series per prefix":  c.Cardinality.MaxSeriesPerPrefix,
+		"max categorical values": c.Cardinality.MaxCategoricalValues,
+	} {
+		if value < 0 {
+			return fmt.Errorf("the %v can't be negative", name)
+		}
+	}
+	for name, value := range map[string]time.Duration{
+		"reorder window":   c.ReorderWindow,
+		"archive after":    c.ArchiveAfter,
+		"peer timeout":     c.PeerTimeout,
+		"queue timeout":    c.QueueTimeout,
+		"retrieve window":  c.RetrieveWindow,
+		"commit interval":  c.CommitInterval,
+		"shutdown timeout": c.ShutdownTimeout,
+	} {
+		if value < 0 {
+			return fmt.Errorf("the %v can't be negative", name)
+		}
+	}
+
+	if c.TLS != nil && (c.TLS.CertFile == "" || c.TLS.KeyFile == "") {
+		return errors.New("TLS needs a certificate and a key file")
+	}
+	if c.Tenants != nil {
+		for name := range c.Tenants.Quotas {
+			if !validTenantName.MatchString(name) {
+				return fmt.Errorf("invalid tenant name %q", name)
+			}
+		}
+		for _, name := range c.Tenants.Names {
+			if !validTenantName.MatchString(name) {
+				return fmt.Errorf("invalid tenant name %q", name)
+			}
+		}
+	}
+	return nil
+}
+
+// reloadableSettings are the fields of ServerConfig that Reload applies to the running server
+var reloadableSettings = map[string]bool{
+	"ConnectionRate": true,
+	"SeriesRate":     true,
+	"Cardinality":    true,
+	"RetrieveWindow": true,
+	"Auth":           true,
+}
+
+//Reload the settings that can change at runtime: the rate and cardinality limits, the retrieve window,
+//the token files and the certificates. Changes of other settings are logged and only apply after a restart.
+//Everything is loaded before anything is applied, if loading fails the server keeps running as before
+func (s *Server) Reload(config ServerConfig) error {
+	s.reloadMutex.Lock()
+	defer s.reloadMutex.Unlock()
+
+	err := config.validate()
+	if err != nil {
+		return err
+	}
+
+	var certificate *tls.Certificate
+	var clientCAs *x509.CertPool
+	if s.certificates != nil {
+		certificate, clientCAs, err = s.certificates.load()
+		if err != nil {
+			return fmt.Errorf("couldn't reload certificates: %w", err)
+		}
+	}
+	reloadAuth := s.authenticator != nil && config.Auth != nil
+	var tokens map[string]*grant
+	var secret []byte
+	if reloadAuth {
+		tokens, secret, err = loadCredentials(*config.Auth)
+		if err != nil {
+			return fmt.Errorf("couldn't reload tokens: %w", err)
+		}
+	}
+
+	for _, name := range restartRequired(s.config, config) {
+		log.Printf("the changed %v only applies after a restart", name)
+	}
+	if s.authenticator != nil && config.Auth == nil {
+		log.Println("disabling authentication only applies after a restart")
+	} else if s.authenticator == nil && config.Auth != nil {
+		log.Println("enabling authentication only applies after a restart")
+	}
+
+	if s.certificates != nil {
+		s.certificates.set(certificate, clientCAs)
+	}
+	if reloadAuth {
+		s.authenticator.set(tokens, secret)
+	}
+	s.limits.set(config.ConnectionRate, config.SeriesRate)
+	s.grpcHandler.setRetrieveWindow(config.RetrieveWindow)
+	err = s.db.SetCardinalityLimits(config.Cardinality)
+	if err != nil {
+		return err
+	}
+	if s.tenants != nil {
+		err = s.tenants.setCardinalityLimits(config.Cardinality)
+		if err != nil {
+			return err
+		}
+	}
+
+	s.config.ConnectionRate = config.ConnectionRate
+	s.config.SeriesRate = config.SeriesRate
+	s.config.Cardinality = config.Cardinality
+	s.config.RetrieveWindow = config.RetrieveWindow
+	if s.authenticator != nil && config.Auth != nil {
+		s.config.Auth = config.Auth
+	}
+	return nil
+}
+
+// restartRequired lists the settings that differ between the configs but can't be reloaded
+func restartRequired(running, config ServerConfig) []string {
+	names := []string{}
+	runningValue := reflect.ValueOf(running)
+	configValue := reflect.ValueOf(config)
+	for i := 0; i < runningValue.NumField(); i++ {
+		name := runningValue.Type().Field(i).Name
+		if reloadableSettings[name] {
+			continue
+		}
+		if !reflect.DeepEqual(runningValue.Field(i).Interface(), configValue.Field(i).Interface()) {
+			names = append(names, name)
+		}
+	}
+	// the connections to other servers are dialed once with the peer token
+	if running.Auth != nil && config.Auth != nil && running.Auth.PeerToken != config.Auth.PeerToken {
+		names = append(names, "Auth.PeerToken")
+	}
+	return names
+}
@@ -0,0 +1,108 @@
+package mhist
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/alexmorten/mhist/models"
+	"github.com/alexmorten/mhist/proto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func Test_Reload(t *testing.T) {
+	dataPath := "test_data"
+	defer os.RemoveAll(dataPath)
+
+	config := ServerConfig{DataPath: dataPath, GrpcPort: 16740, SeriesRate: 1}
+	server, err := NewServer(config)
+	require.NoError(t, err)
+	go server.grpcHandler.Run()
+	defer server.Shutdown()
+
+	conn, err := grpc.Dial("localhost:16740", grpc.WithInsecure())
+	require.NoError(t, err)
+	defer conn.Close()
+	client := proto.NewMhistClient(conn)
+	store := func(name string) error {
+		_, err := client.Store(context.Background(), &proto.MeasurementMessage{
+			Name:        name,
+			Measurement: proto.MeasurementFromModel(&models.Numerical{Ts: 1, Value: 1}),
+		}, grpc.WaitForReady(true))
+		return err
+	}
+
+	t.Run("invalid configs are rejected", func(t *testing.T) {
+		_, err := NewServer(ServerConfig{DataPath: dataPath, GrpcPort: 70000})
+		assert.Error(t, err)
+
+		invalid := config
+		invalid.SeriesRate = -1
+		assert.Error(t, server.Reload(invalid))
+	})
+
+	t.Run("reloaded limits apply to the following writes", func(t *testing.T) {
+		require.NoError(t, store("temperature"))
+		assert.Equal(t, codes.ResourceExhausted, status.Code(store("temperature")))
+
+		reloaded := config
+		reloaded.SeriesRate = 0
+		reloaded.Cardinality.MaxSeries = 1
+		require.NoError(t, server.Reload(reloaded))
+
+		require.NoError(t, store("temperature"))
+		require.NoError(t, store("temperature"))
+		assert.Equal(t, codes.ResourceExhausted, status.Code(store("humidity")))
+	})
+
+	t.Run("settings that can't be reloaded are reported", func(t *testing.T) {
+		changed := config
+		changed.GrpcPort = 16741
+		changed.SeriesRate = 5
+		changed.RetrieveWindow = time.Minute
+		assert.Equal(t, []string{"GrpcPort"}, restartRequired(config, changed))
+	})
+}
+
+func Test_ReloadAppliesNothingIfLoadingFails(t *testing.T) {
+	dataPath := "test_data"
+	dir := "test_reload"
+	defer os.RemoveAll(dataPath)
+	defer os.RemoveAll(dir)
+	require.NoError(t, os.MkdirAll(dir, 0700))
+
+	ca, caKey := writeCertificate(t, dir, "ca", 1, nil, nil)
+	writeCertificate(t, dir, "server", 2, ca, caKey)
+	tokenFile := filepath.Join(dir, "tokens")
+	require.NoError(t, ioutil.WriteFile(tokenFile, []byte("root admin:*\n"), 0600))
+
+	config := ServerConfig{
+		DataPath: dataPath,
+		GrpcPort: 16742,
+		TLS:      &TLSConfig{CertFile: filepath.Join(dir, "server.crt"), KeyFile: filepath.Join(dir, "server.key")},
+		Auth:     &AuthConfig{TokenFile: tokenFile},
+	}
+	server, err := NewServer(config)
+	require.NoError(t, err)
+	defer server.Shutdown()
+	certificate := server.certificates.certificate
+
+	// the new certificate would be loaded fine, but the tokens can't be
+	writeCertificate(t, dir, "server", 3, ca, caKey)
+	reloaded := config
+	reloaded.SeriesRate = 5
+	reloaded.Auth = &AuthConfig{TokenFile: filepath.Join(dir, "missing")}
+	assert.Error(t, server.Reload(reloaded))
+
+	assert.True(t, certificate == server.certificates.certificate)
+	assert.Equal(t, 0, server.config.SeriesRate)
+	assert.Equal(t, tokenFile, server.config.Auth.TokenFile)
+	assert.Len(t, server.authenticator.tokens, 1)
+}
@@ -0,0 +1,136 @@
+package mhist
+
+import (
+	"io/ioutil"
+	"os"
+)
+
+// dataFile is an index file and its value log that measurements get appended to
+type dataFile struct {
+	indexWriter    *os.File
+	valueLogWriter *os.File
+	// firstWrittenTs and lastWrittenTs are the lowest and highest timestamp in the file,
+	// measurements are not necessarily appended in order
+	firstWrittenTs int64
+	lastWrittenTs  int64
+	currentPos     int64
+	indexSize      int64
+	closed         bool
+}
+
+// openDataFile for appending, the time range is recovered from already existing contents
+func openDataFile(path string) (*dataFile, error) {
+	valueF, err := os.OpenFile(path+"_values", os.O_APPEND|os.O_CREATE|os.O_WRONLY, os.ModePerm)
+	if err != nil {
+		return nil, err
+	}
+	pos, err := valueF.Seek(0, 2)
+	if err != nil {
+		valueF.Close()
+		return nil, err
+	}
+	indexF, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, os.ModePerm)
+	if err != nil {
+		valueF.Close()
+		return nil, err
+	}
+
+	f := &dataFile{
+		indexWriter:    indexF,
+		valueLogWriter: valueF,
+		currentPos:     pos,
+	}
+
+	err = f.recoverTimeRange()
+	if err != nil {
+		f.close()
+		return nil, err
+	}
+	return f, nil
+}
+
+func (f *dataFile) recoverTimeRange() error {
+	byteSlice, err := ioutil.ReadFile(f.indexWriter.Name())
+	if err != nil {
+		return err
+	}
+	// ignore a partially written measurement at the end
+	f.indexSize = int64(len(byteSlice) - len(byteSlice)%serializedMeasurementSize)
+
+	for _, measurement := range BlockFromByteSlice(byteSlice) {
+		f.updateTimeRange(measurement.Ts)
+	}
+	return nil
+}
+
+func (f *dataFile) updateTimeRange(ts int64) {
+	if f.firstWrittenTs == 0 || ts < f.firstWrittenTs {
+		f.firstWrittenTs = ts
+	}
+	if f.lastWrittenTs < ts {
+		f.lastWrittenTs = ts
+	}
+}
+
+// append the measurement (and its raw value) to the file, returns the amount of bytes written to the index.
+// Partially written measurements are truncated, so the file stays readable and can be appended to again
+func (f *dataFile) append(m addMessage) (int, error) {
+	measurement := m.measurement
+	if len(m.rawValue) > 0 {
+		n, err := f.valueLogWriter.Write(m.rawValue)
+		if err != nil {
+			// the values are appended, a partial one would shift the positions of the following ones
+			f.valueLogWriter.Truncate(f.currentPos)
+			return 0, err
+		}
+		measurement.Value = float64(f.currentPos)
+		measurement.Size = int64(n)
+		f.currentPos += measurement.Size
+	}
+
+	b := Block{measurement}.UnderlyingByteSlice()
+	n, err := f.indexWriter.Write(b)
+	if err != nil {
+		f.indexWriter.Truncate(f.indexSize)
+		return 0, err
+	}
+	f.updateTimeRange(measurement.Ts)
+	f.indexSize += int64(n)
+	return n, nil
+}
+
+func (f *dataFile) isEmpty() bool {
+	return f.indexSize == 0
+}
+
+func (f *dataFile) sync() error {
+	err := f.indexWriter.Sync()
+	if err != nil {
+		return err
+	}
+	return f.valueLogWriter.Sync()
+}
+
+func (f *dataFile) close() {
+	f.closed = true
+	f.indexWriter.Close()
+	f.valueLogWriter.Close()
+}
+
+// rename the closed data file, so it can be picked up by GetSortedFileList
+func (f *dataFile) rename(newIndexPath string) error {
+	err := os.Rename(f.indexWriter.Name(), newIndexPath)
+	if err != nil {
+		return err
+	}
+	return os.Rename(f.valueLogWriter.Name(), newIndexPath+"_values")
+}
+
+func (f *dataFile) fileInfo() *FileInfo {
+	return &FileInfo{
+		name:     f.indexWriter.Name(),
+		size:     f.indexSize,
+		oldestTs: f.firstWrittenTs,
+		latestTs: f.lastWrittenTs,
+	}
+}
@@ -0,0 +1,485 @@
+package mhist
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/alexmorten/mhist/models"
+	"github.com/alexmorten/mhist/proto"
+)
+
+// ErrClosed is returned when a closed DB is used
+var ErrClosed = errors.New("db is closed")
+
+// Options for opening a DB, zero values are replaced by the defaults
+type Options struct {
+	// MemorySize bounds the memory used for the current data files and caches, with the disk backend the tail cache
+	// and the cache of read files get half each
+	MemorySize int
+	// DiskSize bounds the disk space used, the oldest data files are removed when it is exceeded
+	DiskSize int
+	// ReorderWindow is how long measurements are held back to be written in order
+	ReorderWindow time.Duration
+	// Backend the measurements are stored in, BackendDisk or BackendMemory.
+	// The memory backend keeps up to DiskSize bytes of measurements and ignores the directory.
+	Backend string
+	// Archive receives rotated files instead of them being removed when DiskSize is exceeded, optional
+	Archive Archive
+	// ArchiveAfter moves rotated files to the Archive once their newest measurement is older, 0 only archives when DiskSize is exceeded
+	ArchiveAfter time.Duration
+	// IngestRate bounds the measurements written per second by the disk backend, writes beyond it fail with ErrQuotaExceeded.
+	// Bursts of up to one second of measurements are accepted, 0 doesn't limit writes
+	IngestRate int
+	// QueueTimeout bounds how long writes wait for the busy disk backend, they fail with ErrOverloaded afterwards.
+	// 0 waits as long as it takes
+	QueueTimeout time.Duration
+	// CommitSize is the amount of bytes written by the disk backend before they are committed, 128KiB by default
+	CommitSize int
+	// CommitInterval in which the disk backend commits written measurements, 20 seconds by default
+	CommitInterval time.Duration
+	// Cardinality limits the number of series and categorical values, writes beyond them fail with ErrCardinalityLimit
+	Cardinality CardinalityLimits
+}
+
+// DefaultOptions are used for every option that isn't set
+var DefaultOptions = Options{
+	MemorySize: 32 * 1024 * 1024,
+	DiskSize:   512 * 1024 * 1024,
+	Backend:    BackendDisk,
+}
+
+func (o Options) withDefaults() Options {
+	if o.MemorySize == 0 {
+		o.MemorySize = DefaultOptions.MemorySize
+	}
+	if o.DiskSize == 0 {
+		o.DiskSize = DefaultOptions.DiskSize
+	}
+	if o.Backend == "" {
+		o.Backend = DefaultOptions.Backend
+	}
+	return o
+}
+
+// DB is a measurement history stored in a directory, it can be embedded in other programs.
+// Only one DB may be opened on a directory at a time.
+type DB struct {
+	store *Store
+	// replication logs all changes for followers
+	replication *replicationLog
+	dir         string
+	options     Options
+
+	subscriptions      map[*Subscription]struct{}
+	subscriptionsMutex sync.Mutex
+
+	closed bool
+	// mutex is held for reading by every operation, so Close waits for running ones
+	mutex sync.RWMutex
+}
+
+// Open the DB stored in dir, dir is created if it doesn't exist (unless the memory backend is used)
+func Open(dir string, options Options) (*DB, error) {
+	options = options.withDefaults()
+
+	var backend Backend
+	switch options.Backend {
+	case BackendDisk:
+		diskStore, err := NewDiskStore(dir, options)
+		if err != nil {
+			return nil, err
+		}
+		backend = diskStore
+	case BackendMemory:
+		backend = NewMemoryStore(options.DiskSize)
+		backend.SetCardinalityLimits(options.Cardinality)
+	default:
+		return nil, fmt.Errorf("unknown backend %q", options.Backend)
+	}
+
+	// measurements of the memory backend are gone after a restart, followers can't resume then
+	replication := newReplicationLog(replicationLogSize, replicationLogBytes)
+	if options.Backend == BackendDisk {
+		var err error
+		replication, err = openReplicationLog(dir, replicationLogSize, replicationLogBytes)
+		if err != nil {
+			backend.Shutdown()
+			return nil, err
+		}
+	}
+
+	// the disk backend uses the other half of the memory for the files it reads
+	tailCacheSize := options.MemorySize
+	if options.Backend == BackendDisk {
+		tailCacheSize /= 2
+	}
+	tailCache := NewTailCache(tailCacheSize)
+	tailCache.Warm(backend)
+
+	store := NewStore(backend, tailCache)
+	store.AddSubscriber(replication)
+
+	return &DB{
+		store:         store,
+		replication:   replication,
+		dir:           dir,
+		options:       options,
+		subscriptions: map[*Subscription]struct{}{},
+	}, nil
+}
+
+// Write a measurement to the named series, fails if the type doesn't match the type of the series
+func (db *DB) Write(name string, measurement models.Measurement) error {
+	db.mutex.RLock()
+	defer db.mutex.RUnlock()
+	if db.closed {
+		return ErrClosed
+	}
+
+	return db.store.Add(name, measurement)
+}
+
+// Query the measurements between start and end (both inclusive) that pass the filter
+func (db *DB) Query(start, end int64, filterDefinition models.FilterDefinition) (map[string][]models.Measurement, error) {
+	db.mutex.RLock()
+	defer db.mutex.RUnlock()
+	if db.closed {
+		return nil, ErrClosed
+	}
+
+	return db.store.GetMeasurementsInTimeRange(start, end, filterDefinition)
+}
+
+// Latest measurement of each of the named series, of all recently written series if no names are given
+func (db *DB) Latest(names []string) (map[string]models.Measurement, error) {
+	db.mutex.RLock()
+	defer db.mutex.RUnlock()
+	if db.closed {
+		return nil, ErrClosed
+	}
+
+	return db.store.Latest(names), nil
+}
+
+// Series returns the name and type of every stored series
+func (db *DB) Series() ([]MeasurementTypeInfo, error) {
+	db.mutex.RLock()
+	defer db.mutex.RUnlock()
+	if db.closed {
+		return nil, ErrClosed
+	}
+
+	return db.store.GetStoredMetaInfo(), nil
+}
+
+// DeleteSeries with all their measurements
+func (db *DB) DeleteSeries(names []string) error {
+	return db.do(func() error {
+		return db.logChange(db.store.DeleteSeries(names), &proto.ReplicatedChange{Change: &proto.ReplicatedChange_DeleteSeries{
+			DeleteSeries: &proto.DeleteSeriesRequest{Names: names},
+		}})
+	})
+}
+
+// DeleteRange of measurements of the named series, an end of 0 deletes everything from start on
+func (db *DB) DeleteRange(names []string, start, end int64) error {
+	// followers have to delete the same range, not up to the time they apply the deletion
+	if end == 0 {
+		end = math.MaxInt64
+	}
+	return db.do(func() error {
+		return db.logChange(db.store.DeleteRange(names, start, end), &proto.ReplicatedChange{Change: &proto.ReplicatedChange_DeleteRange{
+			DeleteRange: &proto.DeleteRangeRequest{Names: names, Start: start, End: end},
+		}})
+	})
+}
+
+// RenameSeries keeping all its measurements
+func (db *DB) RenameSeries(name, newName string) error {
+	return db.do(func() error {
+		return db.logChange(db.store.RenameSeries(name, newName), &proto.ReplicatedChange{Change: &proto.ReplicatedChange_RenameSeries{
+			RenameSeries: &proto.RenameSeriesRequest{Name: name, NewName: newName},
+		}})
+	})
+}
+
+// MergeSeries into another series of the same type
+func (db *DB) MergeSeries(name, into string) error {
+	return db.do(func() error {
+		return db.logChange(db.store.MergeSeries(name, into), &proto.ReplicatedChange{Change: &proto.ReplicatedChange_MergeSeries{
+			MergeSeries: &proto.MergeSeriesRequest{Name: name, Into: into},
+		}})
+	})
+}
+
+// MigrateSeries to another measurement type
+func (db *DB) MigrateSeries(name string, t models.MeasurementType) error {
+	return db.do(func() error {
+		return db.logChange(db.store.MigrateSeries(name, t), &proto.ReplicatedChange{Change: &proto.ReplicatedChange_MigrateSeries{
+			MigrateSeries: &proto.MigrateSeriesRequest{Name: name, Type: proto.MeasurementType(t)},
+		}})
+	})
+}
+
+// logChange for followers if it was applied without error, written measurements are logged by the store
+func (db *DB) logChange(err error, change *proto.ReplicatedChange) error {
+	if err != nil {
+		return err
+	}
+	db.replication.append(change)
+	return nil
+}
+
+// installSnapshot replaces all data of the DB with the tar archive of a snapshot read from r,
+// used by followers that can't resume replication. Reads are served from the old data until the snapshot is complete.
+func (db *DB) installSnapshot(r io.Reader) error {
+	staging := strings.TrimSuffix(db.dir, string(filepath.Separator)) + ".replica"
+	err := os.RemoveAll(staging)
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(staging)
+	err = RestoreSnapshot(r, staging)
+	if err != nil {
+		return err
+	}
+
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+	if db.closed {
+		return ErrClosed
+	}
+
+	db.store.Shutdown()
+	err = os.RemoveAll(db.dir)
+	if err == nil {
+		err = os.Rename(staging, db.dir)
+	}
+	if err != nil {
+		db.closed = true
+		return err
+	}
+	backend, err := NewDiskStore(db.dir, db.options)
+	if err != nil {
+		db.closed = true
+		return err
+	}
+
+	db.store.backend = backend
+	db.store.tailCache.Reset()
+	db.store.tailCache.Warm(backend)
+	// the changes of the old data are meaningless for followers of this DB
+	db.replication.reset()
+	return nil
+}
+
+func (db *DB) do(f func() error) error {
+	db.mutex.RLock()
+	defer db.mutex.RUnlock()
+	if db.closed {
+		return ErrClosed
+	}
+
+	return f()
+}
+
+// Cardinality of the series with up to top prefixes and categorical series with the most series and values
+func (db *DB) Cardinality(top int) (CardinalityReport, error) {
+	var report CardinalityReport
+	err := db.do(func() error {
+		report = db.store.backend.Cardinality(top)
+		return nil
+	})
+	return report, err
+}
+
+// SetCardinalityLimits for new series and categorical values, existing ones are kept even if they exceed them
+func (db *DB) SetCardinalityLimits(limits CardinalityLimits) error {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+	if db.closed {
+		return ErrClosed
+	}
+
+	db.options.Cardinality = limits
+	db.store.backend.SetCardinalityLimits(limits)
+	return nil
+}
+
+// Flush everything written so far to disk
+func (db *DB) Flush() error {
+	return db.do(func() error {
+		return db.store.backend.Flush()
+	})
+}
+
+// snapshotter is implemented by backends storing their measurements in files
+type snapshotter interface {
+	Snapshot(dir string) error
+	WriteSnapshot(w io.Writer, taken func()) error
+}
+
+// Snapshot writes a consistent copy of the DB to dir while it keeps running, dir must not exist or be empty.
+// The copy can be installed with RestoreSnapshotDir.
+func (db *DB) Snapshot(dir string) error {
+	return db.do(func() error {
+		backend, ok := db.store.backend.(snapshotter)
+		if !ok {
+			return ErrSnapshotNotSupported
+		}
+		return backend.Snapshot(dir)
+	})
+}
+
+// WriteSnapshot writes a tar archive of a consistent copy of the DB to w, it can be installed with RestoreSnapshot
+func (db *DB) WriteSnapshot(w io.Writer) error {
+	return db.do(func() error {
+		backend, ok := db.store.backend.(snapshotter)
+		if !ok {
+			return ErrSnapshotNotSupported
+		}
+		return backend.WriteSnapshot(w, nil)
+	})
+}
+
+// writeReplicationSnapshot like WriteSnapshot, returns the position of the replication log the snapshot was taken at.
+// It contains all changes up to the position, changes right after it might be contained as well.
+func (db *DB) writeReplicationSnapshot(w io.Writer) (*proto.ReplicationPosition, error) {
+	var position *proto.ReplicationPosition
+	err := db.do(func() error {
+		backend, ok := db.store.backend.(snapshotter)
+		if !ok {
+			return ErrSnapshotNotSupported
+		}
+		return backend.WriteSnapshot(w, func() {
+			position = db.replication.position()
+		})
+	})
+	return position, err
+}
+
+// Subscribe to the measurements written from now on that pass the filter.
+// Writes block until the subscription received them, so it has to be read from continuously or unsubscribed.
+func (db *DB) Subscribe(filterDefinition models.FilterDefinition) (*Subscription, error) {
+	db.mutex.RLock()
+	defer db.mutex.RUnlock()
+	if db.closed {
+		return nil, ErrClosed
+	}
+
+	c := make(chan Notification)
+	subscription := &Subscription{
+		C:      c,
+		c:      c,
+		filter: models.NewFilterCollection(filterDefinition),
+		done:   make(chan struct{}),
+		db:     db,
+	}
+	db.subscriptionsMutex.Lock()
+	db.subscriptions[subscription] = struct{}{}
+	db.subscriptionsMutex.Unlock()
+	db.store.AddSubscriber(subscription)
+	return subscription, nil
+}
+
+func (db *DB) unsubscribe(subscription *Subscription) {
+	db.subscriptionsMutex.Lock()
+	delete(db.subscriptions, subscription)
+	db.subscriptionsMutex.Unlock()
+	db.store.RemoveSubscriber(subscription)
+}
+
+// addSubscriber that is notified about every written measurement, without filtering
+func (db *DB) addSubscriber(subscriber Subscriber) {
+	db.store.AddSubscriber(subscriber)
+}
+
+// Close the DB after everything written so far is on disk, subscriptions are closed as well
+func (db *DB) Close() error {
+	db.subscriptionsMutex.Lock()
+	subscriptions := make([]*Subscription, 0, len(db.subscriptions))
+	for subscription := range db.subscriptions {
+		subscriptions = append(subscriptions, subscription)
+	}
+	db.subscriptionsMutex.Unlock()
+	// writes blocked on subscriptions have to finish before the DB can be closed
+	for _, subscription := range subscriptions {
+		subscription.stop()
+	}
+	for _, subscription := range subscriptions {
+		subscription.Unsubscribe()
+	}
+
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+	if db.closed {
+		return ErrClosed
+	}
+	db.closed = true
+
+	db.store.Shutdown()
+	// followers can only resume if everything they might have replicated was committed
+	if reporter, ok := db.store.backend.(healthReporter); ok && reporter.health().Writable {
+		return db.replication.close(db.dir)
+	}
+	return nil
+}
+
+// Notification about a measurement written to a series
+type Notification struct {
+	Name        string
+	Measurement models.Measurement
+}
+
+// Subscription receives notifications on C until it is unsubscribed
+type Subscription struct {
+	// C is closed after Unsubscribe
+	C <-chan Notification
+
+	c           chan Notification
+	filter      *models.FilterCollection
+	filterMutex sync.Mutex
+	done        chan struct{}
+	stopOnce    sync.Once
+	once        sync.Once
+	db          *DB
+}
+
+// Notify for the Subscriber interface
+func (s *Subscription) Notify(name string, measurement models.Measurement) {
+	s.filterMutex.Lock()
+	passes := s.filter.Passes(name, measurement)
+	s.filterMutex.Unlock()
+	if !passes {
+		return
+	}
+
+	select {
+	case s.c <- Notification{Name: name, Measurement: measurement}:
+	case <-s.done:
+	}
+}
+
+// Unsubscribe from the DB and close C
+func (s *Subscription) Unsubscribe() {
+	s.once.Do(func() {
+		s.stop()
+		s.db.unsubscribe(s)
+		// no notification can be sent anymore once the store removed the subscription
+		close(s.c)
+	})
+}
+
+// stop waiting for notifications to be received
+func (s *Subscription) stop() {
+	s.stopOnce.Do(func() {
+		close(s.done)
+	})
+}
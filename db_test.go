@@ -0,0 +1,95 @@
+package mhist
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/alexmorten/mhist/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_DB(t *testing.T) {
+	dataPath := "test_data"
+	defer os.RemoveAll(dataPath)
+
+	db, err := Open(dataPath, Options{})
+	require.NoError(t, err)
+
+	t.Run("written measurements can be queried", func(t *testing.T) {
+		require.NoError(t, db.Write("temperature", &models.Numerical{Ts: 1000, Value: 21}))
+		require.NoError(t, db.Write("temperature", &models.Numerical{Ts: 2000, Value: 22}))
+		assert.Error(t, db.Write("temperature", &models.Categorical{Ts: 3000, Value: "warm"}))
+
+		result, err := db.Query(1, 2000, models.FilterDefinition{})
+		require.NoError(t, err)
+		assert.Equal(t, []models.Measurement{
+			&models.Numerical{Ts: 1000, Value: 21},
+			&models.Numerical{Ts: 2000, Value: 22},
+		}, result["temperature"])
+	})
+
+	t.Run("subscriptions receive the measurements passing their filter", func(t *testing.T) {
+		subscription, err := db.Subscribe(models.FilterDefinition{Names: []string{"humidity"}})
+		require.NoError(t, err)
+
+		go func() {
+			db.Write("temperature", &models.Numerical{Ts: 4000, Value: 23})
+			db.Write("humidity", &models.Numerical{Ts: 4000, Value: 60})
+		}()
+
+		notification := <-subscription.C
+		assert.Equal(t, Notification{Name: "humidity", Measurement: &models.Numerical{Ts: 4000, Value: 60}}, notification)
+
+		subscription.Unsubscribe()
+		_, ok := <-subscription.C
+		assert.False(t, ok)
+		require.NoError(t, db.Write("humidity", &models.Numerical{Ts: 5000, Value: 61}))
+	})
+
+	t.Run("deletions keep future ends and delete everything from start on without one", func(t *testing.T) {
+		future := time.Now().Add(time.Hour).UnixNano()
+		for _, ts := range []int64{future, future + 10, future + 20} {
+			require.NoError(t, db.Write("forecast", &models.Numerical{Ts: ts, Value: 1}))
+		}
+
+		require.NoError(t, db.DeleteRange([]string{"forecast"}, future+5, future+15))
+		result, err := db.Query(future, future+20, models.FilterDefinition{})
+		require.NoError(t, err)
+		assert.Equal(t, []models.Measurement{
+			&models.Numerical{Ts: future, Value: 1},
+			&models.Numerical{Ts: future + 20, Value: 1},
+		}, result["forecast"])
+
+		require.NoError(t, db.DeleteRange([]string{"forecast"}, future+1, 0))
+		result, err = db.Query(future, future+20, models.FilterDefinition{})
+		require.NoError(t, err)
+		assert.Equal(t, []models.Measurement{&models.Numerical{Ts: future, Value: 1}}, result["forecast"])
+	})
+
+	t.Run("a closed db returns errors", func(t *testing.T) {
+		subscription, err := db.Subscribe(models.FilterDefinition{})
+		require.NoError(t, err)
+
+		require.NoError(t, db.Close())
+		_, ok := <-subscription.C
+		assert.False(t, ok)
+
+		assert.Equal(t, ErrClosed, db.Write("temperature", &models.Numerical{Ts: 6000, Value: 24}))
+		_, err = db.Query(1, 6000, models.FilterDefinition{})
+		assert.Equal(t, ErrClosed, err)
+		assert.Equal(t, ErrClosed, db.Close())
+	})
+
+	t.Run("measurements are still there after reopening", func(t *testing.T) {
+		db, err := Open(dataPath, Options{})
+		require.NoError(t, err)
+		defer db.Close()
+
+		result, err := db.Query(1, 5000, models.FilterDefinition{})
+		require.NoError(t, err)
+		assert.Len(t, result["temperature"], 3)
+		assert.Len(t, result["humidity"], 2)
+	})
+}
@@ -3,27 +3,57 @@ package mhist
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
+	"strconv"
 	"time"
+
+	"google.golang.org/grpc/metadata"
 )
 
-// DebugHandler exposes a debug port over http, used for pprof
+// DebugHandler exposes a debug port over http, used for pprof, health checks and maintenance
 type DebugHandler struct {
 	Port       int
 	httpServer *http.Server
 	server     *Server
 }
 
-// Run listens on the given port and serves http
-func (h *DebugHandler) Run() {
+// Run listens on the given port and serves http until Shutdown
+func (h *DebugHandler) Run() error {
 	h.httpServer = &http.Server{
-		Addr: fmt.Sprintf(":%v", h.Port),
+		Addr:    fmt.Sprintf(":%v", h.Port),
+		Handler: h.handler(),
 	}
 
-	http.HandleFunc("/meta", func(w http.ResponseWriter, r *http.Request) {
-		infos := h.server.store.diskStore.GetAllStoredInfos()
+	log.Println("debug_handler running on ", h.httpServer.Addr)
+	var err error
+	if h.server.certificates != nil {
+		h.httpServer.TLSConfig = h.server.certificates.serverConfig()
+		err = h.httpServer.ListenAndServeTLS("", "")
+	} else {
+		err = h.httpServer.ListenAndServe()
+	}
+	if err != nil && err != http.ErrServerClosed {
+		return err
+	}
+	return nil
+}
+
+func (h *DebugHandler) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/meta", func(w http.ResponseWriter, r *http.Request) {
+		infos, peerErrors, err := h.server.listSeries(tenantContext(r))
+		if err != nil {
+			log.Println(err)
+			w.WriteHeader(500)
+			return
+		}
+		// the listing is partial if peers didn't respond
+		for _, peerError := range peerErrors {
+			w.Header().Add("Mhist-Peer-Errors", peerError.Peer+": "+peerError.Error)
+		}
 		b, err := json.Marshal(infos)
 		if err != nil {
 			log.Println(err)
@@ -40,16 +70,212 @@ func (h *DebugHandler) Run() {
 		w.WriteHeader(200)
 	})
 
-	log.Println("debug_handler running on ", h.httpServer.Addr)
-	err := h.httpServer.ListenAndServe()
+	// the prefixes and categorical series contributing the most to the cardinality, ?top= of each (10 by default)
+	mux.HandleFunc("/meta/cardinality", func(w http.ResponseWriter, r *http.Request) {
+		top := 10
+		if s := r.URL.Query().Get("top"); s != "" {
+			var err error
+			top, err = strconv.Atoi(s)
+			if err != nil || top < 0 {
+				http.Error(w, "top has to be a non-negative number", http.StatusBadRequest)
+				return
+			}
+		}
+		db, err := h.server.dbFor(tenantContext(r))
+		if err != nil {
+			log.Println(err)
+			w.WriteHeader(500)
+			return
+		}
+		report, err := db.Cardinality(top)
+		if err != nil {
+			log.Println(err)
+			w.WriteHeader(500)
+			return
+		}
+		b, err := json.Marshal(report)
+		if err != nil {
+			log.Println(err)
+			w.WriteHeader(500)
+			return
+		}
+
+		_, err = w.Write(b)
+		if err != nil {
+			log.Println(err)
+		}
+	})
+
+	mux.HandleFunc("/replication", func(w http.ResponseWriter, r *http.Request) {
+		b, err := json.Marshal(h.server.replicationStatus())
+		if err != nil {
+			log.Println(err)
+			w.WriteHeader(500)
+			return
+		}
+
+		_, err = w.Write(b)
+		if err != nil {
+			log.Println(err)
+		}
+	})
+
+	mux.HandleFunc("/admin/flush", h.maintenance(func(db *DB) error { return db.Flush() }))
+	mux.HandleFunc("/admin/rotate", h.maintenance(func(db *DB) error { return db.Rotate() }))
+	mux.HandleFunc("/admin/compact", h.maintenance(func(db *DB) error { return db.Compact() }))
+
+	mux.HandleFunc("/admin/files", func(w http.ResponseWriter, r *http.Request) {
+		db, err := h.server.dbFor(tenantContext(r))
+		if err != nil {
+			maintenanceError(w, err)
+			return
+		}
+		files, err := db.DataFiles()
+		if err != nil {
+			maintenanceError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, files)
+	})
+
+	mux.HandleFunc("/admin/writer", func(w http.ResponseWriter, r *http.Request) {
+		db, err := h.server.dbFor(tenantContext(r))
+		if err != nil {
+			maintenanceError(w, err)
+			return
+		}
+		state, err := db.WriterState()
+		if err != nil {
+			maintenanceError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, state)
+	})
+
+	mux.HandleFunc("/admin/health", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, h.server.Health())
+	})
+
+	mux.HandleFunc("/ui/history", h.handleUIHistory)
+	mux.HandleFunc("/ui/tail", h.handleUITail)
+
+	// pprof and expvar register themselves on the default mux
+	mux.Handle("/debug/", http.DefaultServeMux)
+
+	var handler http.Handler = mux
+	if authenticator := h.server.authenticator; authenticator != nil {
+		handler = authenticator.middleware(requireAdmin(mux))
+	}
+
+	// the probes of orchestration don't have a token
+	probes := http.NewServeMux()
+	probes.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		// the server is alive even while it is read-only, restarting it wouldn't make the disk writable
+		writeJSON(w, http.StatusOK, h.server.Health().withoutDetails())
+	})
+	probes.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
+		readiness := h.server.Readiness()
+		code := http.StatusOK
+		if !readiness.Ready {
+			code = http.StatusServiceUnavailable
+		}
+		writeJSON(w, code, readiness)
+	})
+	// the page of the ui contains no data, the token is entered in it and sent with its requests
+	probes.HandleFunc("/ui", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		_, err := fmt.Fprint(w, uiPage)
+		if err != nil {
+			log.Println(err)
+		}
+	})
+	probes.Handle("/", handler)
+	return probes
+}
+
+// maintenance runs the action on the DB of the tenant of a POST request
+func (h *DebugHandler) maintenance(action func(db *DB) error) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "only POST is allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		db, err := h.server.dbFor(tenantContext(r))
+		if err != nil {
+			maintenanceError(w, err)
+			return
+		}
+		err = action(db)
+		if err != nil {
+			maintenanceError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func maintenanceError(w http.ResponseWriter, err error) {
+	code := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, ErrMaintenanceNotSupported):
+		code = http.StatusNotImplemented
+	case errors.Is(err, ErrClosed), errors.Is(err, ErrDegraded):
+		code = http.StatusServiceUnavailable
+	}
+	http.Error(w, err.Error(), code)
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	b, err := json.Marshal(v)
 	if err != nil {
 		log.Println(err)
+		w.WriteHeader(500)
+		return
 	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_, err = w.Write(b)
+	if err != nil {
+		log.Println(err)
+	}
+}
+
+// tenantContext of the request, the tenant is selected with the Mhist-Tenant header like with grpc
+func tenantContext(r *http.Request) context.Context {
+	ctx := r.Context()
+	if tenant := r.Header.Get("Mhist-Tenant"); tenant != "" {
+		ctx = metadata.NewIncomingContext(ctx, metadata.Pairs(tenantHeader, tenant))
+	}
+	return ctx
 }
 
-// Shutdown the debug listener
-func (h *DebugHandler) Shutdown() {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+// readEndpoints of the debug port check the permissions on the series they read themselves
+var readEndpoints = map[string]bool{
+	"/meta":       true,
+	"/ui/history": true,
+	"/ui/tail":    true,
+}
+
+// requireAdmin for everything but the readEndpoints
+func requireAdmin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		grant, ok := r.Context().Value(grantKey{}).(*grant)
+		if !readEndpoints[r.URL.Path] && (!ok || !grant.allowsAll(PermissionAdmin)) {
+			http.Error(w, ErrPermissionDenied.Error(), http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Shutdown the debug listener, running requests can finish until the timeout
+func (h *DebugHandler) Shutdown(timeout time.Duration) {
+	if h.httpServer == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
 	defer cancel()
 	err := h.httpServer.Shutdown(ctx)
 	if err != nil {
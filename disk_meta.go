@@ -1,16 +1,33 @@
 package mhist
 
 import (
+	"bufio"
+	"encoding/gob"
+	"errors"
 	"fmt"
+	"io"
+	"math"
+	"os"
 	"path/filepath"
 	"sync"
 
 	"github.com/alexmorten/mhist/models"
 )
 
-var metaFilePath = "meta.gob"
+//legacyMetaFilePath is where the whole meta used to be written to on every change, it is migrated to the journal
+var legacyMetaFilePath = "meta.gob"
+
+//ErrSeriesNotFound is returned when a series is referenced by a name that is not known
+var ErrSeriesNotFound = errors.New("series not found")
+
+//ErrSeriesExists is returned when a series would be renamed to a name that is already used
+var ErrSeriesExists = errors.New("series already exists")
+
+//ErrTypeMismatch is returned when a measurement has a different type than its series
+var ErrTypeMismatch = errors.New("measurement type doesn't match the series type")
 
 //DiskMeta holds the meta info for (Un)Marshalization
+//every change is appended to a journal, which is regularly compacted into a snapshot
 type DiskMeta struct {
 	//sync maps would be better here, but are not easy to marshal
 	NameToID           map[string]int64
@@ -18,8 +35,40 @@ type DiskMeta struct {
 	IDToType           map[int64]models.MeasurementType
 	CategoricalMapping *CategoricalMapping
 	HighestID          int64
+	//Tombstones mark deleted time ranges per ID, until the compactor removed them from all files.
+	//Deleted series are tombstoned completely
+	Tombstones map[int64][]TimeRange
+	//DeletionGeneration is increased for every deletion and reset once the deleted measurements were removed from all files
+	DeletionGeneration int64
+	//MergedInto maps IDs of merged or migrated series to the ID their measurements are read as now
+	MergedInto map[int64]int64
+
+	dir     string
+	journal *metaJournal
+	mutex   sync.RWMutex
+
+	limits CardinalityLimits
+	// seriesPerPrefix counts the series by prefix, only while MaxSeriesPerPrefix is limited
+	seriesPerPrefix map[string]int
+}
+
+//TimeRange from Start to End, both inclusive
+type TimeRange struct {
+	Start int64
+	End   int64
+}
 
-	mutex sync.RWMutex
+// allTime covers every timestamp
+var allTime = TimeRange{Start: math.MinInt64, End: math.MaxInt64}
+
+//Contains ts?
+func (r TimeRange) Contains(ts int64) bool {
+	return ts >= r.Start && ts <= r.End
+}
+
+//Overlaps the range from start to end?
+func (r TimeRange) Overlaps(start, end int64) bool {
+	return start <= r.End && end >= r.Start
 }
 
 //MeasurementTypeInfo ...
@@ -28,24 +77,75 @@ type MeasurementTypeInfo struct {
 	Type models.MeasurementType `json:"type"`
 }
 
-//InitMetaFromDisk ...
-func InitMetaFromDisk() *DiskMeta {
-	meta := &DiskMeta{}
-	err := readGob(filepath.Join(dataPath, metaFilePath), meta)
+//InitMetaFromDisk loads the latest snapshot in dir and replays the journal on top of it.
+//A meta.gob from older versions is migrated into a snapshot
+func InitMetaFromDisk(dir string) (*DiskMeta, error) {
+	meta := NewDiskMeta()
+	lastSeq := int64(0)
+	migrateLegacyMeta := false
+
+	snapshot, err := readMetaSnapshot(filepath.Join(dir, metaSnapshotPath))
+	switch {
+	case err == nil:
+		meta = snapshot.Meta
+		meta.initMaps()
+		lastSeq = snapshot.LastSeq
+	case os.IsNotExist(err):
+		err = readGob(filepath.Join(dir, legacyMetaFilePath), meta)
+		if err == nil {
+			meta.initMaps()
+			migrateLegacyMeta = true
+		} else if !os.IsNotExist(err) {
+			return nil, fmt.Errorf("couldn't read %v, refusing to start without the meta of existing data: %w", legacyMetaFilePath, err)
+		}
+	default:
+		return nil, err
+	}
+
+	journal, err := openMetaJournal(filepath.Join(dir, metaJournalPath), meta, lastSeq)
 	if err != nil {
-		//assume no file exists
-		return NewDiskMeta()
+		return nil, err
 	}
-	return meta
+	meta.dir = dir
+	meta.journal = journal
+
+	if migrateLegacyMeta {
+		meta.mutex.Lock()
+		err = meta.writeSnapshot()
+		meta.mutex.Unlock()
+		if err != nil {
+			return nil, err
+		}
+		os.Remove(filepath.Join(dir, legacyMetaFilePath))
+	}
+	return meta, nil
 }
 
-//NewDiskMeta with values initialized
+//NewDiskMeta with values initialized, changes are only kept in memory
 func NewDiskMeta() *DiskMeta {
-	return &DiskMeta{
-		NameToID:           map[string]int64{},
-		IDToName:           map[int64]string{},
-		IDToType:           map[int64]models.MeasurementType{},
-		CategoricalMapping: NewCategoricalMapping(),
+	meta := &DiskMeta{}
+	meta.initMaps()
+	return meta
+}
+
+func (m *DiskMeta) initMaps() {
+	if m.NameToID == nil {
+		m.NameToID = map[string]int64{}
+	}
+	if m.IDToName == nil {
+		m.IDToName = map[int64]string{}
+	}
+	if m.IDToType == nil {
+		m.IDToType = map[int64]models.MeasurementType{}
+	}
+	if m.CategoricalMapping == nil {
+		m.CategoricalMapping = NewCategoricalMapping()
+	}
+	if m.Tombstones == nil {
+		m.Tombstones = map[int64][]TimeRange{}
+	}
+	if m.MergedInto == nil {
+		m.MergedInto = map[int64]int64{}
 	}
 }
 
@@ -53,11 +153,12 @@ func NewDiskMeta() *DiskMeta {
 func (m *DiskMeta) GetOrCreateID(name string, t models.MeasurementType) (int64, error) {
 	m.mutex.RLock()
 	id := m.NameToID[name]
+	savedType := m.IDToType[id]
 	m.mutex.RUnlock()
 
 	if id != 0 {
-		if savedType := m.IDToType[id]; savedType != t {
-			return 0, fmt.Errorf("had type %v but was provided %v", savedType, t)
+		if savedType != t {
+			return 0, fmt.Errorf("%w: %q is stored as %v but a %v measurement was provided, migrate the series to change its type", ErrTypeMismatch, name, savedType, t)
 		}
 		return id, nil
 	}
@@ -70,28 +171,109 @@ func (m *DiskMeta) GetOrCreateID(name string, t models.MeasurementType) (int64,
 	if id != 0 {
 		return id, nil
 	}
-	m.HighestID++
-	m.NameToID[name] = m.HighestID
-	m.IDToName[m.HighestID] = name
-	m.IDToType[m.HighestID] = t
-	m.sync()
-	return m.HighestID, nil
+	err := m.checkSeriesLimits(name, "")
+	if err != nil {
+		return 0, err
+	}
+
+	entry := metaEntry{Op: metaOpCreateSeries, Name: name, ID: m.HighestID + 1, Type: t}
+	err = m.record(entry)
+	if err != nil {
+		return 0, err
+	}
+	return entry.ID, nil
 }
 
-//GetNameForID to translate back form csv to record
+//GetNameForID to translate back form csv to record, measurements of merged series get the name they were merged into
 func (m *DiskMeta) GetNameForID(id int64) string {
 	m.mutex.RLock()
 	defer m.mutex.RUnlock()
-	return m.IDToName[id]
+	return m.IDToName[m.resolveID(id)]
 }
 
-//GetTypeForID to translate back form csv to record
+//GetTypeForID to translate back form csv to record, this is the type the measurement was stored as
 func (m *DiskMeta) GetTypeForID(id int64) models.MeasurementType {
 	m.mutex.RLock()
 	defer m.mutex.RUnlock()
 	return m.IDToType[id]
 }
 
+//GetSeriesTypeForID is the type the measurement has to be converted to, it differs from GetTypeForID for migrated series
+func (m *DiskMeta) GetSeriesTypeForID(id int64) models.MeasurementType {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	return m.IDToType[m.resolveID(id)]
+}
+
+//resolveID follows merges and migrations, the caller has to hold the mutex
+func (m *DiskMeta) resolveID(id int64) int64 {
+	for i := 0; i < len(m.MergedInto); i++ {
+		target, ok := m.MergedInto[id]
+		if !ok {
+			break
+		}
+		id = target
+	}
+	return id
+}
+
+//RenameSeries keeps all measurements, new measurements have to be stored with the new name
+func (m *DiskMeta) RenameSeries(name, newName string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	id := m.NameToID[name]
+	if id == 0 {
+		return fmt.Errorf("%w: %v", ErrSeriesNotFound, name)
+	}
+	if m.NameToID[newName] != 0 {
+		return fmt.Errorf("%w: %v", ErrSeriesExists, newName)
+	}
+	err := m.checkSeriesLimits(newName, name)
+	if err != nil {
+		return err
+	}
+
+	return m.record(metaEntry{Op: metaOpRenameSeries, Name: name, NewName: newName, ID: id})
+}
+
+//MergeSeries makes all measurements of the series appear as measurements of the other series,
+//they are converted if the types of the series differ
+func (m *DiskMeta) MergeSeries(name, into string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	id := m.NameToID[name]
+	if id == 0 {
+		return fmt.Errorf("%w: %v", ErrSeriesNotFound, name)
+	}
+	intoID := m.NameToID[into]
+	if intoID == 0 {
+		return fmt.Errorf("%w: %v", ErrSeriesNotFound, into)
+	}
+	if id == intoID {
+		return fmt.Errorf("%w: can't merge %v into itself", ErrInvalidArgument, name)
+	}
+
+	return m.record(metaEntry{Op: metaOpMergeSeries, Name: name, ID: id, TargetID: intoID})
+}
+
+//MigrateSeries to a new type, existing measurements are converted when they are read
+func (m *DiskMeta) MigrateSeries(name string, t models.MeasurementType) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	id := m.NameToID[name]
+	if id == 0 {
+		return fmt.Errorf("%w: %v", ErrSeriesNotFound, name)
+	}
+	if m.IDToType[id] == t {
+		return nil
+	}
+
+	return m.record(metaEntry{Op: metaOpMigrateSeries, Name: name, ID: id, TargetID: m.HighestID + 1, Type: t})
+}
+
 //GetAllStoredInfos from meta
 func (m *DiskMeta) GetAllStoredInfos() (infos []MeasurementTypeInfo) {
 	m.mutex.RLock()
@@ -106,66 +288,321 @@ func (m *DiskMeta) GetAllStoredInfos() (infos []MeasurementTypeInfo) {
 	return
 }
 
-//GetValueIDForCategoricalValue ... also syncs the current meta to disk, if necessary
-func (m *DiskMeta) GetValueIDForCategoricalValue(id int64, categoricalValue string) float64 {
-	valueID, created := m.CategoricalMapping.GetOrCreateValueIDMap(id).GetOrCreateValueIDForValue(categoricalValue)
-	if created {
-		m.sync()
+//GetValueIDForCategoricalValue ... also journals the new mapping, if necessary
+func (m *DiskMeta) GetValueIDForCategoricalValue(id int64, categoricalValue string) (float64, error) {
+	m.mutex.RLock()
+	var valueID float64
+	if valueIDMap := m.CategoricalMapping.IDToValueIDMap[id]; valueIDMap != nil {
+		valueID = valueIDMap.ValueToValueID[categoricalValue]
 	}
-	return valueID
+	m.mutex.RUnlock()
+
+	if valueID != 0 {
+		return valueID, nil
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	valueIDMap := m.CategoricalMapping.GetOrCreateValueIDMap(id)
+	if valueID := valueIDMap.ValueToValueID[categoricalValue]; valueID != 0 {
+		return valueID, nil
+	}
+	err := m.checkValueLimits(id, valueIDMap)
+	if err != nil {
+		return 0, err
+	}
+
+	entry := metaEntry{Op: metaOpCreateValue, ID: id, Value: categoricalValue, ValueID: valueIDMap.HighestValueID + 1}
+	err = m.record(entry)
+	if err != nil {
+		return 0, err
+	}
+	return entry.ValueID, nil
 }
 
-func (m *DiskMeta) sync() {
-	err := writeGob(filepath.Join(dataPath, metaFilePath), m)
+//GetCategoricalValue for the value ID of a categorical measurement
+func (m *DiskMeta) GetCategoricalValue(id int64, valueID float64) string {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	return m.categoricalValue(id, valueID)
+}
+
+// categoricalValue of the value ID, the caller has to hold the mutex
+func (m *DiskMeta) categoricalValue(id int64, valueID float64) string {
+	valueIDMap := m.CategoricalMapping.IDToValueIDMap[id]
+	if valueIDMap == nil {
+		return ""
+	}
+	return valueIDMap.ValueIDToValue[valueID]
+}
+
+//DeleteSeries removes the names and everything known about their IDs, measurements of the IDs are ignored from now on.
+//Nothing is deleted if one of the series doesn't exist
+func (m *DiskMeta) DeleteSeries(names []string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	entries, err := m.entriesFor(names, func(name string, id int64) metaEntry {
+		return metaEntry{Op: metaOpDeleteSeries, Name: name, ID: id}
+	})
 	if err != nil {
-		panic(fmt.Errorf("%v, couldn't write diskMeta %v, this shouldn't happen ", err, m))
+		return err
 	}
+	return m.recordAll(entries)
 }
 
-//ValueIDMapping is a bi-directional mapping between a categorical value and it's value ID
-type ValueIDMapping struct {
-	ValueToValueID map[string]float64
-	ValueIDToValue map[float64]string
+//DeleteRange of the named series, by adding a tombstone for the range to each of them.
+//Nothing is deleted if one of the series doesn't exist
+func (m *DiskMeta) DeleteRange(names []string, timeRange TimeRange) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
 
-	HighestValueID float64
+	entries, err := m.entriesFor(names, func(name string, id int64) metaEntry {
+		return metaEntry{Op: metaOpDeleteRange, ID: id, Range: timeRange}
+	})
+	if err != nil {
+		return err
+	}
+	return m.recordAll(entries)
+}
 
-	mutex sync.RWMutex
+// entriesFor each of the distinct names, fails if one of them doesn't exist. The caller has to hold the mutex
+func (m *DiskMeta) entriesFor(names []string, entryFor func(name string, id int64) metaEntry) ([]metaEntry, error) {
+	entries := []metaEntry{}
+	seen := map[string]bool{}
+	for _, name := range names {
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+		id := m.NameToID[name]
+		if id == 0 {
+			return nil, fmt.Errorf("%w: %v", ErrSeriesNotFound, name)
+		}
+		entries = append(entries, entryFor(name, id))
+	}
+	return entries, nil
 }
 
-//NewValueIDMapping with initialized maps
-func NewValueIDMapping() *ValueIDMapping {
-	return &ValueIDMapping{
-		ValueToValueID: map[string]float64{},
-		ValueIDToValue: map[float64]string{},
-		HighestValueID: 1,
+//IsDeleted checks if the measurement was deleted, either with its series or by a tombstone
+func (m *DiskMeta) IsDeleted(id, ts int64) bool {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	resolvedID := m.resolveID(id)
+	if m.IDToName[resolvedID] == "" {
+		return true
+	}
+	for _, tombstoneID := range []int64{id, resolvedID} {
+		for _, timeRange := range m.Tombstones[tombstoneID] {
+			if timeRange.Contains(ts) {
+				return true
+			}
+		}
 	}
+	return false
 }
 
-//GetOrCreateValueIDForValue thread-safely
-func (m *ValueIDMapping) GetOrCreateValueIDForValue(value string) (valueID float64, created bool) {
+//TombstonedRanges of all series, the time ranges that still have to be compacted
+func (m *DiskMeta) TombstonedRanges() []TimeRange {
 	m.mutex.RLock()
-	valueID = m.ValueToValueID[value]
-	m.mutex.RUnlock()
+	defer m.mutex.RUnlock()
 
-	if valueID != 0 {
-		return valueID, false
+	ranges := []TimeRange{}
+	for _, tombstones := range m.Tombstones {
+		ranges = append(ranges, tombstones...)
+	}
+	return ranges
+}
+
+//GetDeletionGeneration is 0 if there are no deletions pending compaction
+func (m *DiskMeta) GetDeletionGeneration() int64 {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	return m.DeletionGeneration
+}
+
+//CompactionDone clears all tombstones, as they were removed from all files.
+//Deletions that happened since the compaction started are kept
+func (m *DiskMeta) CompactionDone(generation int64) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if m.DeletionGeneration != generation {
+		return nil
+	}
+	return m.record(metaEntry{Op: metaOpCompactionDone, Generation: generation})
+}
+
+//Sync the journal to disk
+func (m *DiskMeta) Sync() error {
+	if m.journal == nil {
+		return nil
+	}
+	return m.journal.sync()
+}
+
+//Close the journal after writing a final snapshot
+func (m *DiskMeta) Close() error {
+	if m.journal == nil {
+		return nil
 	}
 
 	m.mutex.Lock()
 	defer m.mutex.Unlock()
+	err := m.writeSnapshot()
+	if err != nil {
+		return err
+	}
+	return m.journal.close()
+}
 
-	m.HighestValueID++
-	m.ValueToValueID[value] = m.HighestValueID
-	m.ValueIDToValue[m.HighestValueID] = value
+//record appends the entry to the journal and applies it once it is journaled, the caller has to hold the mutex
+func (m *DiskMeta) record(entry metaEntry) error {
+	if m.journal == nil {
+		m.apply(&entry)
+		return nil
+	}
 
-	return m.HighestValueID, true
+	snapshotDue, err := m.journal.append(entry)
+	if err != nil {
+		return fmt.Errorf("%w: couldn't journal meta change: %v", ErrStorage, err)
+	}
+	m.apply(&entry)
+	if snapshotDue {
+		return m.writeSnapshot()
+	}
+	return nil
+}
+
+// recordAll entries as a single journal entry, so either all or none of them are applied
+func (m *DiskMeta) recordAll(entries []metaEntry) error {
+	switch len(entries) {
+	case 0:
+		return nil
+	case 1:
+		return m.record(entries[0])
+	}
+	return m.record(metaEntry{Op: metaOpBatch, Batch: entries})
+}
+
+//apply the entry to the maps, the caller has to hold the mutex
+func (m *DiskMeta) apply(entry *metaEntry) {
+	switch entry.Op {
+	case metaOpBatch:
+		for i := range entry.Batch {
+			m.apply(&entry.Batch[i])
+		}
+	case metaOpCreateSeries:
+		m.NameToID[entry.Name] = entry.ID
+		m.IDToName[entry.ID] = entry.Name
+		m.IDToType[entry.ID] = entry.Type
+		if entry.ID > m.HighestID {
+			m.HighestID = entry.ID
+		}
+		m.countSeries(entry.Name, 1)
+	case metaOpCreateValue:
+		valueIDMap := m.CategoricalMapping.GetOrCreateValueIDMap(entry.ID)
+		valueIDMap.ValueToValueID[entry.Value] = entry.ValueID
+		valueIDMap.ValueIDToValue[entry.ValueID] = entry.Value
+		if entry.ValueID > valueIDMap.HighestValueID {
+			valueIDMap.HighestValueID = entry.ValueID
+		}
+	case metaOpDeleteSeries:
+		delete(m.NameToID, entry.Name)
+		delete(m.IDToName, entry.ID)
+		delete(m.IDToType, entry.ID)
+		// its measurements can be in any file, including archived ones
+		m.Tombstones[entry.ID] = []TimeRange{allTime}
+		delete(m.CategoricalMapping.IDToValueIDMap, entry.ID)
+		m.DeletionGeneration++
+		m.countSeries(entry.Name, -1)
+	case metaOpDeleteRange:
+		m.Tombstones[entry.ID] = append(m.Tombstones[entry.ID], entry.Range)
+		m.DeletionGeneration++
+	case metaOpRenameSeries:
+		delete(m.NameToID, entry.Name)
+		m.NameToID[entry.NewName] = entry.ID
+		m.IDToName[entry.ID] = entry.NewName
+		m.countSeries(entry.Name, -1)
+		m.countSeries(entry.NewName, 1)
+	case metaOpMergeSeries:
+		delete(m.NameToID, entry.Name)
+		delete(m.IDToName, entry.ID)
+		m.MergedInto[entry.ID] = entry.TargetID
+		m.countSeries(entry.Name, -1)
+	case metaOpMigrateSeries:
+		m.NameToID[entry.Name] = entry.TargetID
+		m.IDToName[entry.TargetID] = entry.Name
+		m.IDToType[entry.TargetID] = entry.Type
+		delete(m.IDToName, entry.ID)
+		m.MergedInto[entry.ID] = entry.TargetID
+		if entry.TargetID > m.HighestID {
+			m.HighestID = entry.TargetID
+		}
+	case metaOpCompactionDone:
+		if m.DeletionGeneration == entry.Generation {
+			m.Tombstones = map[int64][]TimeRange{}
+			m.DeletionGeneration = 0
+		}
+	}
+}
+
+//writeSnapshot of everything journaled so far and truncate the journal, the caller has to hold the mutex
+func (m *DiskMeta) writeSnapshot() error {
+	seq := m.journal.lastSeq()
+	err := m.writeSnapshotFile(filepath.Join(m.dir, metaSnapshotPath), seq)
+	if err != nil {
+		return fmt.Errorf("couldn't write meta snapshot: %w", err)
+	}
+	return m.journal.truncate(seq)
+}
+
+//copySnapshotTo dir without truncating the journal, e.g. for backups
+func (m *DiskMeta) copySnapshotTo(dir string) error {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	seq := int64(0)
+	if m.journal != nil {
+		seq = m.journal.lastSeq()
+	}
+	return m.writeSnapshotFile(filepath.Join(dir, metaSnapshotPath), seq)
+}
+
+//writeSnapshotFile with all changes up to seq, the caller has to hold the mutex
+func (m *DiskMeta) writeSnapshotFile(path string, seq int64) error {
+	return writeFileAtomically(path, func(w io.Writer) error {
+		buffered := bufio.NewWriter(w)
+		err := gob.NewEncoder(buffered).Encode(metaSnapshot{LastSeq: seq, Meta: m})
+		if err != nil {
+			return err
+		}
+		return buffered.Flush()
+	})
+}
+
+//ValueIDMapping is a bi-directional mapping between a categorical value and it's value ID
+type ValueIDMapping struct {
+	ValueToValueID map[string]float64
+	ValueIDToValue map[float64]string
+
+	HighestValueID float64
+}
+
+//NewValueIDMapping with initialized maps
+func NewValueIDMapping() *ValueIDMapping {
+	return &ValueIDMapping{
+		ValueToValueID: map[string]float64{},
+		ValueIDToValue: map[float64]string{},
+		HighestValueID: 1,
+	}
 }
 
 //CategoricalMapping enables translating categorical values into a float that is easily dumpable to disk
+//it is guarded by the mutex of the DiskMeta
 type CategoricalMapping struct {
 	IDToValueIDMap map[int64]*ValueIDMapping
-
-	mutex sync.RWMutex
 }
 
 //NewCategoricalMapping with initialized map
@@ -175,19 +612,13 @@ func NewCategoricalMapping() *CategoricalMapping {
 	}
 }
 
-//GetOrCreateValueIDMap thread-safely
+//GetOrCreateValueIDMap, the caller has to hold the mutex of the DiskMeta for writing
 func (m *CategoricalMapping) GetOrCreateValueIDMap(id int64) *ValueIDMapping {
-	m.mutex.RLock()
 	valueIDMap := m.IDToValueIDMap[id]
-	m.mutex.RUnlock()
-
 	if valueIDMap != nil {
 		return valueIDMap
 	}
 
-	m.mutex.Lock()
-	defer m.mutex.Unlock()
-
 	newValueIDMap := NewValueIDMapping()
 	m.IDToValueIDMap[id] = newValueIDMap
 
@@ -0,0 +1,114 @@
+package mhist
+
+import (
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/alexmorten/mhist/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_SeriesAdministration(t *testing.T) {
+	dataPath := "test_data"
+	defer os.RemoveAll(dataPath)
+
+	store, err := NewDiskStore(dataPath, Options{MemorySize: 1024, DiskSize: 24 * 1024 * 1024})
+	require.NoError(t, err)
+	defer store.Shutdown()
+
+	read := func(name string) []models.Measurement {
+		result, err := store.GetMeasurementsInTimeRange(1, 1000, models.FilterDefinition{Names: []string{name}})
+		require.NoError(t, err)
+		return result[name]
+	}
+
+	t.Run("measurements of a different type are rejected", func(t *testing.T) {
+		require.NoError(t, store.Add("temperature", &models.Numerical{Ts: 1, Value: 20}))
+		err := store.Add("temperature", &models.Categorical{Ts: 2, Value: "warm"})
+		assert.True(t, errors.Is(err, ErrTypeMismatch))
+	})
+
+	t.Run("renaming keeps the measurements", func(t *testing.T) {
+		require.NoError(t, store.RenameSeries("temperature", "temp"))
+		assert.Equal(t, []models.Measurement{&models.Numerical{Ts: 1, Value: 20}}, read("temp"))
+		assert.Empty(t, read("temperature"))
+		assert.True(t, errors.Is(store.RenameSeries("temperature", "other"), ErrSeriesNotFound))
+	})
+
+	t.Run("merging shows measurements of both series under one name", func(t *testing.T) {
+		require.NoError(t, store.Add("temp_2", &models.Numerical{Ts: 3, Value: 21}))
+		require.NoError(t, store.MergeSeries("temp_2", "temp"))
+		assert.ElementsMatch(t, []models.Measurement{&models.Numerical{Ts: 1, Value: 20}, &models.Numerical{Ts: 3, Value: 21}}, read("temp"))
+		assert.Empty(t, read("temp_2"))
+	})
+
+	t.Run("migrating converts existing measurements", func(t *testing.T) {
+		require.NoError(t, store.MigrateSeries("temp", models.MeasurementCategorical))
+		require.NoError(t, store.Add("temp", &models.Categorical{Ts: 4, Value: "hot"}))
+		assert.ElementsMatch(t, []models.Measurement{
+			&models.Categorical{Ts: 1, Value: "20"},
+			&models.Categorical{Ts: 3, Value: "21"},
+			&models.Categorical{Ts: 4, Value: "hot"},
+		}, read("temp"))
+
+		err := store.Add("temp", &models.Numerical{Ts: 5, Value: 22})
+		assert.True(t, errors.Is(err, ErrTypeMismatch))
+	})
+}
+
+func Test_MetaView(t *testing.T) {
+	meta := NewDiskMeta()
+	numericalID, err := meta.GetOrCreateID("numerical", models.MeasurementNumerical)
+	require.NoError(t, err)
+	categoricalID, err := meta.GetOrCreateID("categorical", models.MeasurementCategorical)
+	require.NoError(t, err)
+	valueID, err := meta.GetValueIDForCategoricalValue(categoricalID, "on")
+	require.NoError(t, err)
+	mergedID, err := meta.GetOrCreateID("merged", models.MeasurementNumerical)
+	require.NoError(t, err)
+	require.NoError(t, meta.DeleteRange([]string{"numerical"}, TimeRange{Start: 10, End: 20}))
+	require.NoError(t, meta.MergeSeries("merged", "numerical"))
+
+	measurements := []SerializedMeasurement{
+		{ID: numericalID, Ts: 5, Value: 1},
+		{ID: numericalID, Ts: 15, Value: 2},
+		{ID: mergedID, Ts: 15, Value: 3},
+		{ID: mergedID, Ts: 25, Value: 4},
+		{ID: categoricalID, Ts: 5, Value: valueID},
+	}
+	decodeAll := func(view *metaView) map[string][]models.Measurement {
+		result := map[string][]models.Measurement{}
+		for _, serializedMeasurement := range measurements {
+			name, measurement, err := view.decode(serializedMeasurement, nil)
+			require.NoError(t, err)
+			if measurement != nil {
+				result[name] = append(result[name], measurement)
+			}
+		}
+		return result
+	}
+
+	t.Run("measurements are decoded as their current series", func(t *testing.T) {
+		assert.Equal(t, map[string][]models.Measurement{
+			"numerical":   {&models.Numerical{Ts: 5, Value: 1}, &models.Numerical{Ts: 25, Value: 4}},
+			"categorical": {&models.Categorical{Ts: 5, Value: "on"}},
+		}, decodeAll(meta.view(measurements, models.FilterDefinition{})))
+	})
+
+	t.Run("only the series in the filter are looked up", func(t *testing.T) {
+		view := meta.view(measurements, models.FilterDefinition{Names: []string{"numerical"}})
+		assert.Nil(t, view.series[categoricalID].categoricalValues)
+		assert.Equal(t, map[string][]models.Measurement{
+			"numerical": {&models.Numerical{Ts: 5, Value: 1}, &models.Numerical{Ts: 25, Value: 4}},
+		}, decodeAll(view))
+	})
+
+	t.Run("the view doesn't change with the meta", func(t *testing.T) {
+		view := meta.view(measurements, models.FilterDefinition{})
+		require.NoError(t, meta.DeleteSeries([]string{"categorical"}))
+		assert.Len(t, decodeAll(view)["categorical"], 1)
+		assert.Empty(t, decodeAll(meta.view(measurements, models.FilterDefinition{}))["categorical"])
+	})
+}
@@ -0,0 +1,257 @@
+package mhist
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"sort"
+	"sync"
+
+	"github.com/alexmorten/mhist/models"
+)
+
+// readConcurrency limits how many files are read at the same time, across all reads
+var readConcurrency = runtime.NumCPU()
+
+type readMessage struct {
+	fromTs     int64
+	toTs       int64
+	resultChan chan *readSnapshot
+}
+
+// readSnapshot is what a read sees of the files in its time range, taken by the Listen goroutine,
+// so reading the files doesn't block the writes happening in the meantime
+type readSnapshot struct {
+	files []*openedDataFile
+	// buffered measurements are held back by the reorder buffer and not yet written to disk
+	buffered []addMessage
+	// err of opening the files, the read fails instead of returning an incomplete result
+	err error
+}
+
+// openedDataFile stays readable up to size, even if it is rotated, compacted or removed in the meantime.
+// Rotated files are read through the block cache, the files that are still written to are read directly.
+type openedDataFile struct {
+	cached   *cachedFile
+	index    *os.File
+	valueLog *os.File
+	size     int64
+}
+
+func (s *DiskStore) closeSnapshot(snapshot *readSnapshot) {
+	for _, f := range snapshot.files {
+		if f.cached != nil {
+			s.cache.release(f.cached)
+			continue
+		}
+		f.index.Close()
+		f.valueLog.Close()
+	}
+}
+
+//GetMeasurementsInTimeRange for all measurement names
+func (s *DiskStore) GetMeasurementsInTimeRange(start, end int64, filterDefinition models.FilterDefinition) (map[string][]models.Measurement, error) {
+	resultChan := make(chan *readSnapshot, 1)
+	s.readChan <- readMessage{
+		fromTs:     start,
+		toTs:       end,
+		resultChan: resultChan,
+	}
+	snapshot := <-resultChan
+	defer s.closeSnapshot(snapshot)
+	if snapshot.err != nil {
+		return nil, snapshot.err
+	}
+
+	partialResults := make([]readResult, len(snapshot.files))
+	errs := make([]error, len(snapshot.files))
+	wg := &sync.WaitGroup{}
+	for i, f := range snapshot.files {
+		wg.Add(1)
+		s.readSemaphore <- struct{}{}
+		go func(i int, f *openedDataFile) {
+			defer func() {
+				<-s.readSemaphore
+				wg.Done()
+			}()
+			partialResults[i], errs[i] = s.readDataFile(f, start, end, filterDefinition)
+		}(i, f)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	result := readResult{}
+	for _, partialResult := range partialResults {
+		for name, measurements := range partialResult {
+			result[name] = append(result[name], measurements...)
+		}
+	}
+	err := s.appendBufferedMeasurements(snapshot.buffered, start, end, filterDefinition, result)
+	if err != nil {
+		return nil, err
+	}
+
+	return applyFilter(result, filterDefinition), nil
+}
+
+// openFilesForRead is called in the Listen goroutine, no file can be renamed or removed while it runs
+func (s *DiskStore) openFilesForRead(start, end int64) *readSnapshot {
+	snapshot := &readSnapshot{}
+	buffered := s.DiskWriter.reorderBuffer.messages
+	snapshot.buffered = make([]addMessage, len(buffered))
+	copy(snapshot.buffered, buffered)
+
+	files, err := s.DiskWriter.getFilesInTimeRange(start, end)
+	if err != nil {
+		snapshot.err = storageError(err)
+		return snapshot
+	}
+
+	for _, file := range files {
+		if file.archived {
+			file := file
+			cached := s.cache.acquireArchived(file, func() (*os.File, *os.File, error) {
+				return fetchArchivedDataFile(s.archive, file, s.dir)
+			})
+			snapshot.files = append(snapshot.files, &openedDataFile{cached: cached})
+			continue
+		}
+
+		if file.name != s.DiskWriter.dataFile.indexWriter.Name() && file.name != s.DiskWriter.backfill.indexWriter.Name() {
+			cached, err := s.cache.acquire(file)
+			if err != nil {
+				snapshot.err = storageError(err)
+				return snapshot
+			}
+			snapshot.files = append(snapshot.files, &openedDataFile{cached: cached})
+			continue
+		}
+
+		index, err := os.Open(file.indexName())
+		if err != nil {
+			snapshot.err = storageError(err)
+			return snapshot
+		}
+		valueLog, err := os.Open(file.valueLogName())
+		if err != nil {
+			index.Close()
+			snapshot.err = storageError(err)
+			return snapshot
+		}
+
+		snapshot.files = append(snapshot.files, &openedDataFile{index: index, valueLog: valueLog, size: file.size})
+	}
+	return snapshot
+}
+
+func (s *DiskStore) readDataFile(f *openedDataFile, start, end int64, filterDefinition models.FilterDefinition) (readResult, error) {
+	result := readResult{}
+
+	block, err := s.readIndexBlock(f)
+	if err != nil {
+		return nil, storageError(err)
+	}
+
+	valueLog := f.valueLog
+	if f.cached != nil {
+		// archived files are only opened once their index block is loaded
+		valueLog = f.cached.valueLog
+	}
+	readRawValue := func(serializedMeasurement SerializedMeasurement) ([]byte, error) {
+		value := make([]byte, serializedMeasurement.Size)
+		pos := int64(serializedMeasurement.Value)
+		n, err := valueLog.ReadAt(value, pos)
+		if err != nil {
+			return nil, err
+		}
+		if int64(n) != serializedMeasurement.Size {
+			return nil, fmt.Errorf("didn't read the expected amount %v but read %v instead", serializedMeasurement.Size, n)
+		}
+		return value, nil
+	}
+
+	inRange := make([]SerializedMeasurement, 0, len(block))
+	for _, serializedMeasurement := range block {
+		if serializedMeasurement.Ts >= start && serializedMeasurement.Ts <= end {
+			inRange = append(inRange, serializedMeasurement)
+		}
+	}
+
+	view := s.meta.view(inRange, filterDefinition)
+	for _, serializedMeasurement := range inRange {
+		name, measurement, err := view.decode(serializedMeasurement, readRawValue)
+		if err != nil {
+			return nil, err
+		}
+		if measurement != nil {
+			result[name] = append(result[name], measurement)
+		}
+	}
+	return result, nil
+}
+
+func (s *DiskStore) readIndexBlock(f *openedDataFile) (Block, error) {
+	if f.cached != nil {
+		block, err := s.cache.indexBlock(f.cached)
+		if err != nil {
+			// don't keep the failed load around, the next read tries again
+			s.cache.invalidate(f.cached.info.indexName())
+		}
+		return block, err
+	}
+
+	// anything behind size might still be written to, when reading the current file
+	byteSlice := make([]byte, f.size-f.size%int64(serializedMeasurementSize))
+	_, err := io.ReadFull(f.index, byteSlice)
+	if err != nil {
+		return nil, err
+	}
+	return BlockFromByteSlice(byteSlice), nil
+}
+
+func (s *DiskStore) appendBufferedMeasurements(buffered []addMessage, start, end int64, filterDefinition models.FilterDefinition, result readResult) error {
+	inRange := []addMessage{}
+	serialized := []SerializedMeasurement{}
+	for _, message := range buffered {
+		if message.measurement.Ts >= start && message.measurement.Ts <= end {
+			inRange = append(inRange, message)
+			serialized = append(serialized, message.measurement)
+		}
+	}
+
+	view := s.meta.view(serialized, filterDefinition)
+	for _, message := range inRange {
+		rawValue := message.rawValue
+		name, measurement, err := view.decode(message.measurement, func(SerializedMeasurement) ([]byte, error) { return rawValue, nil })
+		if err != nil {
+			return err
+		}
+		if measurement != nil {
+			result[name] = append(result[name], measurement)
+		}
+	}
+	return nil
+}
+
+// applyFilter to the measurements of each name in timestamp order, as they were read from multiple files in parallel
+func applyFilter(result readResult, filterDefinition models.FilterDefinition) readResult {
+	filter := models.NewFilterCollection(filterDefinition)
+	filtered := readResult{}
+	for name, measurements := range result {
+		sort.SliceStable(measurements, func(i, j int) bool {
+			return measurements[i].Timestamp() < measurements[j].Timestamp()
+		})
+
+		for _, measurement := range measurements {
+			if filter.Passes(name, measurement) {
+				filtered[name] = append(filtered[name], measurement)
+			}
+		}
+	}
+	return filtered
+}
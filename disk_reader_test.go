@@ -0,0 +1,78 @@
+package mhist
+
+import (
+	"os"
+	"sync"
+	"testing"
+
+	"github.com/alexmorten/mhist/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_DiskStoreConcurrentReads(t *testing.T) {
+	dataPath := "test_data"
+	defer os.RemoveAll(dataPath)
+
+	store, err := NewDiskStore(dataPath, Options{MemorySize: 1024, DiskSize: 24 * 1024 * 1024})
+	require.NoError(t, err)
+	defer store.Shutdown()
+
+	for ts := int64(1); ts <= 1000; ts++ {
+		require.NoError(t, store.Add("before", &models.Numerical{Ts: ts, Value: 1}))
+	}
+	require.NoError(t, store.Add("raw", &models.Raw{Ts: 500, Value: []byte("value")}))
+
+	t.Run("reads see measurements that are not committed yet", func(t *testing.T) {
+		result, err := store.GetMeasurementsInTimeRange(1, 1000, models.FilterDefinition{})
+		require.NoError(t, err)
+		require.Len(t, result["before"], 1000)
+		for i, measurement := range result["before"] {
+			assert.Equal(t, int64(i+1), measurement.Timestamp())
+		}
+		assert.Equal(t, []models.Measurement{&models.Raw{Ts: 500, Value: []byte("value")}}, result["raw"])
+	})
+
+	t.Run("reads happen while measurements are added", func(t *testing.T) {
+		wg := &sync.WaitGroup{}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for ts := int64(1001); ts <= 5000; ts++ {
+				store.Add("during", &models.Numerical{Ts: ts, Value: 2})
+			}
+		}()
+
+		for i := 0; i < 4; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for j := 0; j < 20; j++ {
+					result, err := store.GetMeasurementsInTimeRange(1, 1000, models.FilterDefinition{Names: []string{"before"}})
+					assert.NoError(t, err)
+					assert.Len(t, result["before"], 1000)
+				}
+			}()
+		}
+		wg.Wait()
+
+		result, err := store.GetMeasurementsInTimeRange(1001, 5000, models.FilterDefinition{})
+		require.NoError(t, err)
+		assert.Len(t, result["during"], 4000)
+	})
+	t.Run("raw values are only read for the names in the filter", func(t *testing.T) {
+		require.NoError(t, store.Rotate())
+		files, err := GetSortedFileList(dataPath)
+		require.NoError(t, err)
+		for _, file := range files {
+			require.NoError(t, os.Truncate(file.valueLogName(), 0))
+		}
+
+		result, err := store.GetMeasurementsInTimeRange(1, 1000, models.FilterDefinition{Names: []string{"before"}})
+		require.NoError(t, err)
+		assert.Len(t, result["before"], 1000)
+
+		_, err = store.GetMeasurementsInTimeRange(1, 1000, models.FilterDefinition{Names: []string{"raw"}})
+		assert.Error(t, err)
+	})
+}
@@ -1,216 +1,291 @@
 package mhist
 
 import (
-	"io/ioutil"
+	"errors"
+	"fmt"
 	"log"
 	"os"
+	"sync"
 	"time"
 	"unsafe"
 
 	"github.com/alexmorten/mhist/models"
 )
 
+// maxBuffer is the default amount of bytes written before they are committed
 const maxBuffer = 128 * 1024
-const timeBetweenWrites = 20 * time.Second
 
-var dataPath = "data"
+// timeBetweenWrites is the default interval in which written measurements are committed
+const timeBetweenWrites = 20 * time.Second
 
 //DiskStore handles buffered writes to and reads from Disk
 type DiskStore struct {
 	*DiskWriter
 
-	meta     *DiskMeta
-	addChan  chan addMessage
-	readChan chan readMessage
-	stopChan chan struct{}
+	seriesAdministration
+	cache *blockCache
+
+	archive      Archive
+	archiveAfter time.Duration
+	archiveChan  chan archiveMessage
+	// archiveNow is nil without archive
+	archiveNow chan struct{}
+
+	addChan chan addMessage
+	// queueTimeout bounds how long Add waits for addChan, 0 waits forever
+	queueTimeout time.Duration
+	// commitInterval in which written measurements are committed
+	commitInterval time.Duration
+	// storageHealth rejects writes while the store is read-only after a storage failure
+	storageHealth storeHealth
+	readChan      chan readMessage
+	compactChan   chan compactMessage
+	flushChan     chan chan error
+	rotateChan    chan chan error
+	inspectChan   chan chan inspection
+	snapshotChan  chan snapshotMessage
+	// readSemaphore bounds the amount of files read concurrently
+	readSemaphore chan struct{}
+	stopChan      chan struct{}
+	doneChan      chan struct{}
+
+	// compactMutex lets only one compaction run at a time
+	compactMutex sync.Mutex
+	// checkedArchived holds the deletion generation at which archived files were last checked for deleted measurements
+	checkedArchived map[string]int64
 }
 
 type addMessage struct {
 	name        string
 	measurement SerializedMeasurement
 	rawValue    []byte
+	// resultChan receives the result of writing the measurement
+	resultChan chan error
 }
 
 type readResult map[string][]models.Measurement
 
-type readMessage struct {
-	fromTs           int64
-	toTs             int64
-	filterDefinition models.FilterDefinition
-	resultChan       chan readResult
-}
+//NewDiskStore initializes the DiskBlockRoutine on the data in dir.
+//MemorySize is used as the size of data files and bounds the memory used for caching read files
+func NewDiskStore(dir string, options Options) (*DiskStore, error) {
+	options = options.withDefaults()
+	err := os.MkdirAll(dir, os.ModePerm)
+	if err != nil {
+		return nil, err
+	}
 
-//NewDiskStore initializes the DiskBlockRoutine
-func NewDiskStore(maxFileSize, maxDiskSize int) (*DiskStore, error) {
-	err := os.MkdirAll(dataPath, os.ModePerm)
+	removeSnapshotLeftovers(dir)
+
+	meta, err := InitMetaFromDisk(dir)
 	if err != nil {
 		return nil, err
 	}
+	meta.setCardinalityLimits(options.Cardinality)
 
-	writer, err := NewDiskWriter(maxFileSize, maxDiskSize)
+	writer, err := NewDiskWriter(dir, options.MemorySize, options.DiskSize, options.ReorderWindow)
 	if err != nil {
 		return nil, err
 	}
+	// meta changes have to be on disk before the measurements referencing them
+	writer.beforeSync = meta.Sync
+	// the other half is used by the tail cache
+	cache := newBlockCache(options.MemorySize / 2)
+	writer.onRemove = cache.invalidate
+	if options.CommitSize > 0 {
+		writer.commitSize = int64(options.CommitSize)
+	}
+	if options.IngestRate > 0 {
+		writer.ingestQuota = newTokenBucket(float64(options.IngestRate), float64(options.IngestRate))
+	}
 
 	store := &DiskStore{
-		meta:       InitMetaFromDisk(),
-		DiskWriter: writer,
-		addChan:    make(chan addMessage),
-		readChan:   make(chan readMessage),
-		stopChan:   make(chan struct{}),
+		archive:              options.Archive,
+		archiveAfter:         options.ArchiveAfter,
+		queueTimeout:         options.QueueTimeout,
+		commitInterval:       timeBetweenWrites,
+		archiveChan:          make(chan archiveMessage),
+		seriesAdministration: seriesAdministration{meta: meta},
+		cache:                cache,
+		DiskWriter:           writer,
+		addChan:              make(chan addMessage),
+		readChan:             make(chan readMessage),
+		compactChan:          make(chan compactMessage),
+		flushChan:            make(chan chan error),
+		rotateChan:           make(chan chan error),
+		inspectChan:          make(chan chan inspection),
+		snapshotChan:         make(chan snapshotMessage),
+		readSemaphore:        make(chan struct{}, readConcurrency),
+		stopChan:             make(chan struct{}),
+		doneChan:             make(chan struct{}),
+		checkedArchived:      map[string]int64{},
+	}
+
+	if options.CommitInterval > 0 {
+		store.commitInterval = options.CommitInterval
+	}
+
+	if options.Archive != nil {
+		archived, err := loadArchiveIndex(options.Archive)
+		if err != nil {
+			writer.close()
+			writer.backfill.close()
+			return nil, err
+		}
+		dropLocallyPresent(archived, dir)
+		writer.archived = archived
+		store.archiveNow = make(chan struct{}, 1)
+		writer.onDiskFull = store.triggerArchiving
 	}
 
 	go store.Listen()
+	go store.runCompactor()
 	return store, nil
 }
 
 //Notify DiskStore about new Measurement
 func (s *DiskStore) Notify(name string, m models.Measurement) {
-	s.Add(name, m)
+	err := s.Add(name, m)
+	if err != nil {
+		log.Println(err)
+	}
 }
 
-//Add measurement to block
-func (s *DiskStore) Add(name string, measurement models.Measurement) {
-	id, err := s.meta.GetOrCreateID(name, measurement.Type())
+//Add measurement to block, fails if the type doesn't match the type of the series or it couldn't be written.
+//Measurements held back by the reorder window succeed once they are buffered, if writing them fails later
+//the error is returned to the Add that triggered the write instead
+func (s *DiskStore) Add(name string, measurement models.Measurement) error {
+	err := s.storageHealth.err()
 	if err != nil {
-		//measurement is probably of different type than it used to be, just ignore for now
-		// should probably trigger some kind of cleanup
-		return
+		return err
 	}
-
-	var valueOrValueID float64
-	var rawValue []byte
-
-	switch measurement.(type) {
-	case *models.Numerical:
-		valueOrValueID = measurement.(*models.Numerical).Value
-	case *models.Categorical:
-		valueOrValueID = s.meta.GetValueIDForCategoricalValue(id, measurement.(*models.Categorical).Value)
-	case *models.Raw:
-		rawValue = measurement.(*models.Raw).Value
+	err = s.admit()
+	if err != nil {
+		return err
 	}
-
-	s.addChan <- addMessage{
-		name:        name,
-		measurement: SerializedMeasurement{ID: id, Ts: measurement.Timestamp(), Value: valueOrValueID},
-		rawValue:    rawValue,
+	message, err := s.serialize(name, measurement)
+	if err != nil {
+		if errors.Is(err, ErrStorage) {
+			s.storageHealth.fail(err)
+		}
+		return err
 	}
-}
+	message.resultChan = make(chan error, 1)
 
-//GetMeasurementsInTimeRange for all measurement names
-func (s *DiskStore) GetMeasurementsInTimeRange(start, end int64, filterDefiniton models.FilterDefinition) map[string][]models.Measurement {
-	resultChan := make(chan readResult)
-	s.readChan <- readMessage{
-		fromTs:           start,
-		toTs:             end,
-		filterDefinition: filterDefiniton,
-		resultChan:       resultChan,
+	if s.queueTimeout == 0 {
+		s.addChan <- message
+		return <-message.resultChan
+	}
+	select {
+	case s.addChan <- message:
+		return <-message.resultChan
+	default:
+	}
+	timer := time.NewTimer(s.queueTimeout)
+	defer timer.Stop()
+	select {
+	case s.addChan <- message:
+		return <-message.resultChan
+	case <-timer.C:
+		rejectedWrites.Add(rejectedQueue, 1)
+		return &retryableError{err: fmt.Errorf("%w: the write wasn't queued within %v", ErrOverloaded, s.queueTimeout), retryAfter: s.queueTimeout}
 	}
-	return <-resultChan
 }
 
-//GetAllStoredInfos from meta
-func (s *DiskStore) GetAllStoredInfos() []MeasurementTypeInfo {
-	return s.meta.GetAllStoredInfos()
+//Flush all measurements, including the ones held back for reordering, and commit them to disk
+func (s *DiskStore) Flush() error {
+	done := make(chan error)
+	s.flushChan <- done
+	return <-done
 }
 
-//Shutdown DiskBlock goroutine
+//Shutdown DiskBlock goroutine, returns after the final commit
 func (s *DiskStore) Shutdown() {
 	s.stopChan <- struct{}{}
+	<-s.doneChan
 }
 
 //Listen for new measurements
 func (s *DiskStore) Listen() {
-	timer := time.NewTimer(timeBetweenWrites)
+	timer := time.NewTimer(s.commitInterval)
 loop:
 	for {
 		select {
 		case <-s.stopChan:
-			s.commit()
+			// the final commit syncs everything written to disk before the files are closed
+			err := s.flush()
+			if err != nil {
+				s.check(err)
+				log.Printf("the final commit failed: %v\n", err)
+			}
+			s.dataFile.close()
+			s.backfill.close()
+			err = s.meta.Close()
+			if err != nil {
+				log.Println(err)
+			}
+			s.cache.clear()
+			close(s.doneChan)
 			break loop
 		case <-timer.C:
-			s.commit()
+			s.commitOrRecover()
 			timer.Stop()
-			timer.Reset(timeBetweenWrites)
+			timer.Reset(s.commitInterval)
 		case message := <-s.readChan:
-			message.resultChan <- s.handleRead(message.fromTs, message.toTs, message.filterDefinition)
+			message.resultChan <- s.openFilesForRead(message.fromTs, message.toTs)
 		case message := <-s.addChan:
-			s.handleAdd(message)
+			err := s.handleAdd(message)
+			s.check(err)
+			message.resultChan <- err
+		case done := <-s.flushChan:
+			err := s.flush()
+			s.check(err)
+			done <- err
+		case done := <-s.rotateChan:
+			err := s.forceRotation()
+			s.check(err)
+			done <- err
+		case resultChan := <-s.inspectChan:
+			resultChan <- s.inspect()
+		case message := <-s.compactChan:
+			message.resultChan <- s.handleCompact(message)
+		case message := <-s.archiveChan:
+			message.resultChan <- s.handleArchive(message)
+		case message := <-s.snapshotChan:
+			message.resultChan <- s.handleSnapshot(message)
 		}
 	}
 }
 
-func (s *DiskStore) handleRead(start, end int64, filterDefinition models.FilterDefinition) readResult {
-	s.DiskWriter.indexWriter.Sync()
-	s.DiskWriter.valueLogWriter.Sync()
-
-	result := readResult{}
-	files, err := s.DiskWriter.getFilesInTimeRange(start, end)
+// check the result of writing to disk, the store is read-only after a storage failure
+func (s *DiskStore) check(err error) {
 	if err != nil {
-		log.Println(err)
-		return readResult{}
+		s.storageHealth.fail(err)
 	}
-	filter := models.NewFilterCollection(filterDefinition)
-	for _, file := range files {
-		byteSlice, err := ioutil.ReadFile(file.name)
-		mustNotBeError(err)
-		block := BlockFromByteSlice(byteSlice)
-		logReader, err := os.Open(file.valueLogName())
-		mustNotBeError(err)
-		s.appendPassingMeasurements(block, logReader, start, end, filter, result)
-	}
-
-	return result
 }
 
-func (s *DiskStore) appendPassingMeasurements(block Block, valueFile *os.File, start, end int64, filter *models.FilterCollection, result readResult) {
-	for _, serializedMeasurement := range block {
-		name := s.meta.GetNameForID(serializedMeasurement.ID)
-		if name == "" {
-			continue
-		}
-
-		if serializedMeasurement.Ts < start || serializedMeasurement.Ts > end {
-			continue
-		}
-
-		measurementType := s.meta.GetTypeForID(serializedMeasurement.ID)
-		if measurementType == 0 {
-			continue
-		}
-
-		var measurement models.Measurement
-		switch measurementType {
-		case models.MeasurementNumerical:
-			measurement = &models.Numerical{Ts: serializedMeasurement.Ts, Value: serializedMeasurement.Value}
-
-		case models.MeasurementCategorical:
-			measurement = &models.Categorical{
-				Ts:    serializedMeasurement.Ts,
-				Value: s.meta.CategoricalMapping.GetOrCreateValueIDMap(serializedMeasurement.ID).ValueIDToValue[serializedMeasurement.Value],
-			}
-		case models.MeasurementRaw:
-			value := make([]byte, serializedMeasurement.Size)
-			pos := int64(serializedMeasurement.Value)
-			n, err := valueFile.ReadAt(value, pos)
-			if err != nil {
-				log.Println(err)
-				continue
-			}
-			if int64(n) != serializedMeasurement.Size {
-				log.Println("didn't read the expected amount", serializedMeasurement.Size, "but read", n, "instead")
-				continue
-			}
+// commitOrRecover commits regularly, while the store is read-only it becomes writable again once the disk is
+func (s *DiskStore) commitOrRecover() {
+	if !s.storageHealth.degraded() {
+		s.check(s.commit())
+		return
+	}
 
-			measurement = &models.Raw{
-				Ts:    serializedMeasurement.Ts,
-				Value: value,
-			}
-		}
-		if filter.Passes(name, measurement) {
-			result[name] = append(result[name], measurement)
-		}
+	err := probeWritable(s.dir)
+	if err == nil {
+		err = s.reopen()
+	}
+	if err == nil {
+		err = s.commit()
 	}
+	if err != nil {
+		s.storageHealth.fail(err)
+		return
+	}
+	s.storageHealth.recover()
+}
+
+func (s *DiskStore) health() Health {
+	return s.storageHealth.status()
 }
 
 //SerializedMeasurement is a numerical measureent extended by ID, can be dumped to disk directly
@@ -12,10 +12,10 @@ import (
 	"strings"
 )
 
-//GetSortedFileList gets the FileInfo list for data files (not the meta file)
-func GetSortedFileList() (FileInfoSlice, error) {
+//GetSortedFileList gets the FileInfo list for data files in dir (not the meta file)
+func GetSortedFileList(dir string) (FileInfoSlice, error) {
 	infoList := FileInfoSlice{}
-	files, err := ioutil.ReadDir(dataPath)
+	files, err := ioutil.ReadDir(dir)
 	if err != nil {
 		return nil, err
 	}
@@ -29,7 +29,7 @@ func GetSortedFileList() (FileInfoSlice, error) {
 		if err != nil {
 			continue
 		}
-		info.name = pathTo(info.name)
+		info.name = filepath.Join(dir, info.name)
 		info.size = f.Size()
 		infoList = append(infoList, info)
 	}
@@ -43,6 +43,8 @@ type FileInfo struct {
 	size     int64
 	oldestTs int64
 	latestTs int64
+	// archived files are only in the archive, their name is the base name of the local file they were
+	archived bool
 }
 
 func (i *FileInfo) indexName() string {
@@ -56,10 +58,6 @@ func (i *FileInfo) valueLogName() string {
 //FileInfoSlice ...
 type FileInfoSlice []*FileInfo
 
-func pathTo(filename string) string {
-	return filepath.Join(dataPath, filename)
-}
-
 func fileNameFromTs(oldestTs, latestTs int64) string {
 	return fmt.Sprintf("%v-%v", oldestTs, latestTs)
 }
@@ -98,17 +96,7 @@ func (s FileInfoSlice) TotalSize() (size int64) {
 }
 
 func (i *FileInfo) isInTimeRange(start, end int64) bool {
-	return (i.latestTs > start && !(i.oldestTs > end))
-}
-
-func writeGob(filePath string, object interface{}) error {
-	file, err := os.Create(filePath)
-	if err == nil {
-		encoder := gob.NewEncoder(file)
-		err = encoder.Encode(object)
-	}
-	file.Close()
-	return err
+	return (i.latestTs >= start && !(i.oldestTs > end))
 }
 
 func readGob(filePath string, object interface{}) error {
@@ -116,6 +104,7 @@ func readGob(filePath string, object interface{}) error {
 	if err == nil {
 		decoder := gob.NewDecoder(file)
 		err = decoder.Decode(object)
+		file.Close()
 	}
 	return err
 }
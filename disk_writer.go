@@ -1,93 +1,135 @@
 package mhist
 
 import (
+	"fmt"
 	"log"
 	"os"
+	"path/filepath"
+	"time"
 )
 
 // DiskWriter handles writing the measurement index and value log
 type DiskWriter struct {
-	indexWriter                 *os.File
-	valueLogWriter              *os.File
-	firstWrittenTs              int64
-	lastWrittenTs               int64
+	*dataFile
+	// backfill receives measurements that arrive after newer measurements were already written to the current file
+	backfill                    *dataFile
+	reorderBuffer               *reorderBuffer
 	bytesWrittenSinceLastCommit int64
-	currentPos                  int64
+	// beforeSync is called before the written measurements are synced to disk
+	beforeSync func() error
+	// onRemove is called with the index name of a rotated file after it was removed
+	onRemove func(name string)
+	// onDiskFull replaces removing the oldest file when the disk size is exceeded, if set
+	onDiskFull func()
+	// archived files are read like local ones, nil if there is no archive
+	archived *archiveIndex
+	// ingestQuota limits the measurements written per second, nil without a limit. It is safe for concurrent use
+	ingestQuota *tokenBucket
 
+	dir         string
 	maxFileSize int64
 	maxDiskSize int64
+	// commitSize is the amount of bytes written before they are committed
+	commitSize int64
 }
 
-// NewDiskWriter returns a fully initialized DiskWriter
-func NewDiskWriter(maxFileSize, maxDiskSize int) (*DiskWriter, error) {
+// NewDiskWriter returns a fully initialized DiskWriter, writing to files in dir
+func NewDiskWriter(dir string, maxFileSize, maxDiskSize int, reorderWindow time.Duration) (*DiskWriter, error) {
 	writer := &DiskWriter{
-		maxFileSize: int64(maxFileSize),
-		maxDiskSize: int64(maxDiskSize),
+		dir:           dir,
+		maxFileSize:   int64(maxFileSize),
+		maxDiskSize:   int64(maxDiskSize),
+		commitSize:    maxBuffer,
+		reorderBuffer: newReorderBuffer(reorderWindow),
 	}
 
-	fileList, err := GetSortedFileList()
+	backfill, err := openDataFile(writer.pathTo("backfill"))
 	if err != nil {
 		return nil, err
 	}
+	writer.backfill = backfill
 
-	if len(fileList) == 0 {
-		err := writer.createWriters(pathTo("current"))
-		if err != nil {
-			return nil, err
-		}
-		return writer, nil
-	}
-
-	latestFile := fileList[len(fileList)-1]
-	if latestFile.size < writer.maxFileSize {
-		err := writer.createWriters(pathTo(latestFile.indexName()))
-		if err != nil {
-			return nil, err
-		}
-		return writer, nil
-	}
-
-	err = writer.createWriters(pathTo("current"))
+	// a current file left over from the last run is continued, it isn't part of the sorted file list
+	err = writer.createWriters(writer.pathTo("current"))
 	if err != nil {
+		backfill.close()
 		return nil, err
 	}
 	return writer, nil
 }
 
+// admit a measurement within the ingest quota, called before it is sent to the writing goroutine
+func (w *DiskWriter) admit() error {
+	if w.ingestQuota == nil {
+		return nil
+	}
+	ok, wait := w.ingestQuota.take(1, time.Now())
+	if !ok {
+		rejectedWrites.Add(rejectedQuota, 1)
+		return &retryableError{err: fmt.Errorf("%w: more than %v measurements per second", ErrQuotaExceeded, w.ingestQuota.rate), retryAfter: wait}
+	}
+	return nil
+}
+
 //Commit the buffered writes to actual disk
-func (w *DiskWriter) commit() {
+func (w *DiskWriter) commit() error {
+	err := w.writeMessages(w.reorderBuffer.release(time.Now()))
+	if err != nil {
+		return err
+	}
 	if w.bytesWrittenSinceLastCommit == 0 {
-		return
+		return nil
 	}
-	w.bytesWrittenSinceLastCommit = 0
 
-	info, err := os.Stat(w.indexWriter.Name())
-	mustNotBeError(err)
-	err = w.indexWriter.Sync()
-	mustNotBeError(err)
-	err = w.valueLogWriter.Sync()
-	mustNotBeError(err)
-
-	if info.Size() < w.maxFileSize {
-		return
+	if w.beforeSync != nil {
+		err := w.beforeSync()
+		if err != nil {
+			return storageError(err)
+		}
 	}
+	err = w.sync()
+	if err != nil {
+		return storageError(err)
+	}
+	err = w.backfill.sync()
+	if err != nil {
+		return storageError(err)
+	}
+	w.bytesWrittenSinceLastCommit = 0
 
-	currentIndexPath := w.indexWriter.Name()
-	w.valueLogWriter.Close()
-	currentLogFilePath := w.valueLogWriter.Name()
-	w.valueLogWriter.Close()
-
-	newIndexPath := pathTo(fileNameFromTs(w.firstWrittenTs, w.lastWrittenTs))
-	os.Rename(currentIndexPath, newIndexPath)
-	os.Rename(currentLogFilePath, newIndexPath+"_values")
+	rotated, err := w.rotate(false)
+	if err != nil || !rotated {
+		return err
+	}
+	return w.removeOldestIfFull()
+}
 
-	err = w.createWriters(pathTo("current"))
-	mustNotBeError(err)
+// forceRotation of the current and backfill files after committing everything, even if they are not full yet
+func (w *DiskWriter) forceRotation() error {
+	err := w.flush()
+	if err != nil {
+		return err
+	}
+	rotated, err := w.rotate(true)
+	if err != nil || !rotated {
+		return err
+	}
+	return w.removeOldestIfFull()
+}
 
-	fileList, err := GetSortedFileList()
-	mustNotBeError(err)
+// removeOldestIfFull removes the oldest rotated file if the files exceed the disk size
+func (w *DiskWriter) removeOldestIfFull() error {
+	fileList, err := GetSortedFileList(w.dir)
+	if err != nil {
+		return storageError(err)
+	}
 
 	if fileList.TotalSize() > w.maxDiskSize {
+		if w.onDiskFull != nil {
+			w.onDiskFull()
+			return nil
+		}
+
 		oldestFile := fileList[0]
 		err = os.Remove(oldestFile.indexName())
 		if err != nil {
@@ -97,70 +139,148 @@ func (w *DiskWriter) commit() {
 		if err != nil {
 			log.Println(err)
 		}
+		if w.onRemove != nil {
+			w.onRemove(oldestFile.indexName())
+		}
 	}
+	return nil
 }
 
-func (w *DiskWriter) handleAdd(m addMessage) {
-	measurement := m.measurement
-	if len(m.rawValue) > 0 {
-		n, err := w.valueLogWriter.Write(m.rawValue)
-		mustNotBeError(err)
-		measurement.Value = float64(w.currentPos)
-		measurement.Size = int64(n)
-		w.currentPos += measurement.Size
-	}
-	if w.lastWrittenTs < measurement.Ts {
-		w.lastWrittenTs = measurement.Ts
+// rotate the current and backfill files once they reached the maximum file size, with force once they are not empty
+func (w *DiskWriter) rotate(force bool) (bool, error) {
+	rotated := false
+	if w.indexSize >= w.maxFileSize || force && !w.dataFile.isEmpty() {
+		w.dataFile.close()
+		err := w.dataFile.rename(w.rotatedName(w.firstWrittenTs, w.lastWrittenTs))
+		if err == nil {
+			err = w.createWriters(w.pathTo("current"))
+		}
+		if err != nil {
+			// keep appending to the file, it is rotated with the next commit
+			w.reopen()
+			return false, storageError(err)
+		}
+		rotated = true
 	}
-	if w.firstWrittenTs == 0 {
-		w.firstWrittenTs = measurement.Ts
+
+	if w.backfill.indexSize >= w.maxFileSize || force && !w.backfill.isEmpty() {
+		w.backfill.close()
+		backfill := w.backfill
+		err := backfill.rename(w.pathTo(uniqueFileNameFromTs(backfill.firstWrittenTs, backfill.lastWrittenTs, "backfill")))
+		if err == nil {
+			w.backfill, err = openDataFile(w.pathTo("backfill"))
+		}
+		if err != nil {
+			w.backfill = backfill
+			w.reopen()
+			return rotated, storageError(err)
+		}
+		rotated = true
 	}
+	return rotated, nil
+}
 
-	b := Block{measurement}.UnderlyingByteSlice()
-	n, err := w.indexWriter.Write(b)
-	mustNotBeError(err)
+// rotatedName of the current file. Forced rotations can end files with the time range of an earlier one,
+// those get a unique name instead of replacing it
+func (w *DiskWriter) rotatedName(oldestTs, latestTs int64) string {
+	name := w.pathTo(fileNameFromTs(oldestTs, latestTs))
+	if _, err := os.Stat(name); os.IsNotExist(err) {
+		return name
+	}
+	return w.pathTo(uniqueFileNameFromTs(oldestTs, latestTs, "rotated"))
+}
 
-	w.bytesWrittenSinceLastCommit += int64(n)
-	if w.bytesWrittenSinceLastCommit > maxBuffer {
-		w.commit()
+// reopen the current and backfill files if they were closed by a failed rotation
+func (w *DiskWriter) reopen() error {
+	for _, f := range []**dataFile{&w.dataFile, &w.backfill} {
+		if !(*f).closed {
+			continue
+		}
+		reopened, err := openDataFile((*f).indexWriter.Name())
+		if err != nil {
+			return storageError(err)
+		}
+		*f = reopened
 	}
+	return nil
 }
 
-func (w *DiskWriter) createWriters(path string) error {
-	valueF, err := os.OpenFile(path+"_values", os.O_APPEND|os.O_CREATE|os.O_WRONLY, os.ModePerm)
+// flush writes everything held back by the reorder buffer and commits
+func (w *DiskWriter) flush() error {
+	err := w.writeMessages(w.reorderBuffer.releaseAll())
 	if err != nil {
 		return err
 	}
-	pos, err := valueF.Seek(0, 2)
+	return w.commit()
+}
+
+// handleAdd writes the measurement, or buffers it within the reorder window. Errors of writing out
+// buffered measurements are returned as well, they belong to earlier calls
+func (w *DiskWriter) handleAdd(m addMessage) error {
+	if !w.reorderBuffer.add(m) {
+		err := w.write(w.backfill, m)
+		if err != nil {
+			return err
+		}
+	}
+	err := w.writeMessages(w.reorderBuffer.release(time.Now()))
 	if err != nil {
 		return err
 	}
-	w.valueLogWriter = valueF
-	w.currentPos = pos
-	w.firstWrittenTs = 0
-	w.lastWrittenTs = 0
-	indexF, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, os.ModePerm)
+
+	if w.bytesWrittenSinceLastCommit > w.commitSize {
+		return w.commit()
+	}
+	return nil
+}
+
+// writeMessages to the current file, the messages after a failed one are lost
+func (w *DiskWriter) writeMessages(messages []addMessage) error {
+	for i, m := range messages {
+		err := w.write(w.dataFile, m)
+		if err != nil {
+			if lost := len(messages) - i; lost > 1 {
+				return fmt.Errorf("%w, %v measurements are lost", err, lost)
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+func (w *DiskWriter) write(f *dataFile, m addMessage) error {
+	n, err := f.append(m)
+	if err != nil {
+		return storageError(err)
+	}
+
+	w.bytesWrittenSinceLastCommit += int64(n)
+	return nil
+}
+
+func (w *DiskWriter) createWriters(path string) error {
+	f, err := openDataFile(path)
 	if err != nil {
 		return err
 	}
-	w.indexWriter = indexF
+	w.dataFile = f
 	return nil
 }
 
 //getFilesInTimeRange gets the FileInfo list for data files in the time range
 func (w *DiskWriter) getFilesInTimeRange(start, end int64) (FileInfoSlice, error) {
-	allFiles, err := GetSortedFileList()
+	allFiles, err := GetSortedFileList(w.dir)
 	if err != nil {
 		return nil, err
 	}
 
-	currentInfo := &FileInfo{
-		name:     w.indexWriter.Name(),
-		oldestTs: w.firstWrittenTs,
-		latestTs: w.lastWrittenTs,
+	if w.archived != nil {
+		allFiles = append(allFiles, w.archived.list()...)
+	}
+	allFiles = append(allFiles, w.dataFile.fileInfo())
+	if !w.backfill.isEmpty() {
+		allFiles = append(allFiles, w.backfill.fileInfo())
 	}
-
-	allFiles = append(allFiles, currentInfo)
 
 	filesInTimeRange := FileInfoSlice{}
 	for _, fileInfo := range allFiles {
@@ -171,8 +291,11 @@ func (w *DiskWriter) getFilesInTimeRange(start, end int64) (FileInfoSlice, error
 	return filesInTimeRange, nil
 }
 
-func mustNotBeError(err error) {
-	if err != nil {
-		panic(err)
-	}
+func (w *DiskWriter) pathTo(filename string) string {
+	return filepath.Join(w.dir, filename)
+}
+
+// uniqueFileNameFromTs for files whose time ranges can overlap with others, like backfilled or compacted files
+func uniqueFileNameFromTs(oldestTs, latestTs int64, kind string) string {
+	return fmt.Sprintf("%s_%s_%v", fileNameFromTs(oldestTs, latestTs), kind, time.Now().UnixNano())
 }
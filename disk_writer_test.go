@@ -0,0 +1,56 @@
+package mhist
+
+import (
+	"os"
+	"testing"
+
+	"github.com/alexmorten/mhist/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_DiskStoreOutOfOrder(t *testing.T) {
+	dataPath := "test_data"
+	defer os.RemoveAll(dataPath)
+
+	store, err := NewDiskStore(dataPath, Options{MemorySize: 1024, DiskSize: 24 * 1024 * 1024})
+	require.NoError(t, err)
+
+	for ts := int64(1000); ts <= 100000; ts += 1000 {
+		store.Add("in_order", &models.Numerical{Ts: ts, Value: 1})
+	}
+	store.Add("late", &models.Numerical{Ts: 500, Value: 2})
+	store.Add("late", &models.Numerical{Ts: 50500, Value: 3})
+	store.Flush()
+
+	files, err := GetSortedFileList(dataPath)
+	require.NoError(t, err)
+	require.NotEmpty(t, files)
+	for _, file := range files {
+		assert.True(t, file.oldestTs <= file.latestTs)
+	}
+
+	result, err := store.GetMeasurementsInTimeRange(1, 1000, models.FilterDefinition{})
+	require.NoError(t, err)
+	assert.Len(t, result["in_order"], 1)
+	assert.Equal(t, []models.Measurement{&models.Numerical{Ts: 500, Value: 2}}, result["late"])
+
+	result, err = store.GetMeasurementsInTimeRange(50000, 51000, models.FilterDefinition{Names: []string{"late"}})
+	require.NoError(t, err)
+	assert.Equal(t, []models.Measurement{&models.Numerical{Ts: 50500, Value: 3}}, result["late"])
+
+	store.Shutdown()
+
+	t.Run("time ranges are recovered after a restart", func(t *testing.T) {
+		store, err := NewDiskStore(dataPath, Options{MemorySize: 1024, DiskSize: 24 * 1024 * 1024})
+		require.NoError(t, err)
+		defer store.Shutdown()
+
+		result, err := store.GetMeasurementsInTimeRange(99000, 100000, models.FilterDefinition{})
+		require.NoError(t, err)
+		assert.Len(t, result["in_order"], 2)
+		result, err = store.GetMeasurementsInTimeRange(1, 600, models.FilterDefinition{})
+		require.NoError(t, err)
+		assert.Len(t, result["late"], 1)
+	})
+}
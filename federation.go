@@ -0,0 +1,340 @@
+package mhist
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/alexmorten/mhist/models"
+	"github.com/alexmorten/mhist/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// federatedHeader marks requests of a federating server, they are answered without fanning out again
+const federatedHeader = "mhist-federated"
+
+// peerErrorsHeader lists the peers a federated subscription couldn't be established to
+const peerErrorsHeader = "mhist-peer-errors"
+
+// separates the peer name from the series name if series are prefixed
+const peerSeparator = "/"
+
+// how long peers are waited for if no timeout is configured
+const defaultPeerTimeout = 5 * time.Second
+
+// time after which a failed subscription to a peer is retried
+const peerRetryInterval = 5 * time.Second
+
+//Peer is an upstream mhist reads are fanned out to
+type Peer struct {
+	Name    string
+	Address string
+}
+
+//ParsePeers from a comma separated list of name=address pairs
+func ParsePeers(s string) ([]Peer, error) {
+	peers := []Peer{}
+	if s == "" {
+		return peers, nil
+	}
+	for _, pair := range strings.Split(s, ",") {
+		parts := strings.SplitN(pair, "=", 2)
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return nil, fmt.Errorf("peer %q is not of the form name=address", pair)
+		}
+		peers = append(peers, Peer{Name: parts[0], Address: parts[1]})
+	}
+	return peers, nil
+}
+
+// federation fans reads out to the peers and merges their results with the local ones
+type federation struct {
+	peers   []*federatedPeer
+	timeout time.Duration
+	// prefix series names of peers with the peer name and the peerSeparator
+	prefix bool
+}
+
+type federatedPeer struct {
+	Peer
+	conn   *grpc.ClientConn
+	client proto.MhistClient
+}
+
+func newFederation(peers []Peer, timeout time.Duration, prefix bool, dialOptions ...grpc.DialOption) (*federation, error) {
+	f := &federation{timeout: timeout, prefix: prefix}
+	for _, peer := range peers {
+		// connections are established lazily, so peers can be down at startup
+		conn, err := grpc.Dial(peer.Address, dialOptions...)
+		if err != nil {
+			f.close()
+			return nil, err
+		}
+		f.peers = append(f.peers, &federatedPeer{Peer: peer, conn: conn, client: proto.NewMhistClient(conn)})
+	}
+	return f, nil
+}
+
+func (f *federation) close() {
+	for _, peer := range f.peers {
+		peer.conn.Close()
+	}
+}
+
+// isFederatedRequest of another federating server, which has to be answered locally
+func isFederatedRequest(ctx context.Context) bool {
+	md, ok := metadata.FromIncomingContext(ctx)
+	return ok && len(md.Get(federatedHeader)) > 0
+}
+
+// federatedContext for requests to other servers, in the name of the tenant of the incoming request
+func federatedContext(ctx context.Context) context.Context {
+	ctx = metadata.AppendToOutgoingContext(ctx, federatedHeader, "true")
+	if tenant, err := tenantOf(ctx); err == nil && tenant != "" {
+		ctx = metadata.AppendToOutgoingContext(ctx, tenantHeader, tenant)
+	}
+	return ctx
+}
+
+// localSource is the key of the local DB in routes
+const localSource = ""
+
+// route the requested names to the local DB and the peers. Sources without any of the names are not in the result,
+// nil names stand for all names
+func (f *federation) route(names []string) map[string][]string {
+	routes := map[string][]string{}
+	if len(names) == 0 || !f.prefix {
+		routes[localSource] = names
+		for _, peer := range f.peers {
+			routes[peer.Name] = names
+		}
+		return routes
+	}
+
+	for _, name := range names {
+		source, name := f.split(name)
+		routes[source] = append(routes[source], name)
+	}
+	return routes
+}
+
+// split a prefixed name into the peer and the name on the peer
+func (f *federation) split(name string) (string, string) {
+	for _, peer := range f.peers {
+		if strings.HasPrefix(name, peer.Name+peerSeparator) {
+			return peer.Name, strings.TrimPrefix(name, peer.Name+peerSeparator)
+		}
+	}
+	return localSource, name
+}
+
+func (f *federation) nameOnPeer(peer *federatedPeer, name string) string {
+	if !f.prefix {
+		return name
+	}
+	return peer.Name + peerSeparator + name
+}
+
+// forEachPeer in parallel, with the timeout applied to ctx. Returns the errors of the failed peers
+func (f *federation) forEachPeer(ctx context.Context, routes map[string][]string, call func(ctx context.Context, peer *federatedPeer, names []string) error) []*proto.PeerError {
+	peerErrors := []*proto.PeerError{}
+	mutex := sync.Mutex{}
+	wg := sync.WaitGroup{}
+	for _, peer := range f.peers {
+		names, ok := routes[peer.Name]
+		if !ok {
+			continue
+		}
+
+		wg.Add(1)
+		go func(peer *federatedPeer) {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(federatedContext(ctx), f.timeout)
+			defer cancel()
+
+			err := call(ctx, peer, names)
+			if err != nil {
+				mutex.Lock()
+				peerErrors = append(peerErrors, &proto.PeerError{Peer: peer.Name, Error: err.Error()})
+				mutex.Unlock()
+			}
+		}(peer)
+	}
+	wg.Wait()
+	return peerErrors
+}
+
+// retrieve the measurements of the peers and merge them into result, which contains the local measurements
+func (f *federation) retrieve(ctx context.Context, request *proto.RetrieveRequest, result map[string][]models.Measurement) []*proto.PeerError {
+	routes := f.route(request.GetFilter().GetNames())
+	mutex := sync.Mutex{}
+	merged := map[string]bool{}
+
+	peerErrors := f.forEachPeer(ctx, routes, func(ctx context.Context, peer *federatedPeer, names []string) error {
+		response, err := peer.client.Retrieve(ctx, &proto.RetrieveRequest{
+			Start:  request.Start,
+			End:    request.End,
+			Filter: &proto.Filter{Names: names, GranularityNanos: request.GetFilter().GetGranularityNanos()},
+		})
+		if err != nil {
+			return err
+		}
+
+		mutex.Lock()
+		defer mutex.Unlock()
+		for name, measurements := range response.ToMeasurementMap() {
+			name = f.nameOnPeer(peer, name)
+			if _, ok := result[name]; ok {
+				merged[name] = true
+			}
+			result[name] = append(result[name], measurements...)
+		}
+		return nil
+	})
+
+	// series of several sources are interleaved, and each source only applied the granularity on its own
+	granularity := models.FilterDefinition{Granularity: time.Duration(request.GetFilter().GetGranularityNanos())}
+	for name := range merged {
+		result[name] = applyFilter(readResult{name: result[name]}, granularity)[name]
+	}
+	return peerErrors
+}
+
+// listSeries of the peers merged with the local series
+func (f *federation) listSeries(ctx context.Context, local []MeasurementTypeInfo) ([]MeasurementTypeInfo, []*proto.PeerError) {
+	seen := map[string]bool{}
+	series := []MeasurementTypeInfo{}
+	add := func(info MeasurementTypeInfo) {
+		if !seen[info.Name] {
+			seen[info.Name] = true
+			series = append(series, info)
+		}
+	}
+	for _, info := range local {
+		add(info)
+	}
+
+	mutex := sync.Mutex{}
+	peerErrors := f.forEachPeer(ctx, f.route(nil), func(ctx context.Context, peer *federatedPeer, _ []string) error {
+		response, err := peer.client.ListSeries(ctx, &proto.ListSeriesRequest{})
+		if err != nil {
+			return err
+		}
+
+		mutex.Lock()
+		defer mutex.Unlock()
+		for _, info := range response.Series {
+			add(MeasurementTypeInfo{Name: f.nameOnPeer(peer, info.Name), Type: models.MeasurementType(info.Type)})
+		}
+		return nil
+	})
+	return series, peerErrors
+}
+
+// latest measurements of the peers merged into result, which contains the local ones. The newest measurement of a series wins
+func (f *federation) latest(ctx context.Context, names []string, result map[string]models.Measurement) []*proto.PeerError {
+	mutex := sync.Mutex{}
+	return f.forEachPeer(ctx, f.route(names), func(ctx context.Context, peer *federatedPeer, names []string) error {
+		response, err := peer.client.Latest(ctx, &proto.LatestRequest{Names: names})
+		if err != nil {
+			return err
+		}
+
+		mutex.Lock()
+		defer mutex.Unlock()
+		for name, pM := range response.Latest {
+			measurement := pM.ToModelWithDefinedTs()
+			if measurement == nil {
+				continue
+			}
+			name = f.nameOnPeer(peer, name)
+			if existing, ok := result[name]; !ok || existing.Timestamp() < measurement.Timestamp() {
+				result[name] = measurement
+			}
+		}
+		return nil
+	})
+}
+
+// subscribe to the peers until ctx is done, their measurements are passed to notify.
+// Returns the errors of the peers that couldn't be subscribed to within the timeout, they are retried in the background.
+func (f *federation) subscribe(ctx context.Context, filter *proto.Filter, wg *sync.WaitGroup, notify func(name string, measurement models.Measurement)) []*proto.PeerError {
+	routes := f.route(filter.GetNames())
+
+	subscribed := map[*federatedPeer]chan error{}
+	for _, peer := range f.peers {
+		names, ok := routes[peer.Name]
+		if !ok {
+			continue
+		}
+
+		result := make(chan error, 1)
+		subscribed[peer] = result
+		wg.Add(1)
+		go func(peer *federatedPeer) {
+			defer wg.Done()
+			f.subscribePeer(ctx, peer, &proto.Filter{Names: names, GranularityNanos: filter.GetGranularityNanos()}, result, notify)
+		}(peer)
+	}
+
+	peerErrors := []*proto.PeerError{}
+	deadline := time.Now().Add(f.timeout)
+	for _, peer := range f.peers {
+		result, ok := subscribed[peer]
+		if !ok {
+			continue
+		}
+
+		var err error
+		select {
+		case err = <-result:
+		case <-time.After(time.Until(deadline)):
+			err = context.DeadlineExceeded
+		}
+		if err != nil {
+			peerErrors = append(peerErrors, &proto.PeerError{Peer: peer.Name, Error: err.Error()})
+		}
+	}
+	return peerErrors
+}
+
+// subscribePeer until ctx is done, the result of the first attempt is sent to subscribed
+func (f *federation) subscribePeer(ctx context.Context, peer *federatedPeer, filter *proto.Filter, subscribed chan<- error, notify func(name string, measurement models.Measurement)) {
+	for {
+		stream, err := peer.client.Subscribe(federatedContext(ctx), filter)
+		if err == nil {
+			// the header is sent as soon as the peer subscribed
+			_, err = stream.Header()
+		}
+		if subscribed != nil {
+			subscribed <- err
+			subscribed = nil
+		}
+
+		for err == nil {
+			var message *proto.MeasurementMessage
+			message, err = stream.Recv()
+			if err != nil {
+				break
+			}
+			measurement := message.Measurement.ToModelWithDefinedTs()
+			if measurement != nil {
+				notify(f.nameOnPeer(peer, message.Name), measurement)
+			}
+		}
+
+		if ctx.Err() != nil {
+			return
+		}
+		log.Printf("subscription to peer %v failed: %v", peer.Name, err)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(peerRetryInterval):
+		}
+	}
+}
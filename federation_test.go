@@ -0,0 +1,139 @@
+package mhist
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/alexmorten/mhist/models"
+	"github.com/alexmorten/mhist/proto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+)
+
+func Test_Federation(t *testing.T) {
+	defer os.RemoveAll("test_data")
+	defer os.RemoveAll("test_peer_a_data")
+	defer os.RemoveAll("test_peer_b_data")
+
+	startPeer := func(dataPath string, port int) *Server {
+		peer, err := NewServer(ServerConfig{DataPath: dataPath, GrpcPort: port})
+		require.NoError(t, err)
+		go peer.grpcHandler.Run()
+		return peer
+	}
+	peerA := startPeer("test_peer_a_data", 16681)
+	defer peerA.Shutdown()
+	peerB := startPeer("test_peer_b_data", 16682)
+	defer peerB.Shutdown()
+
+	server, err := NewServer(ServerConfig{
+		DataPath: "test_data",
+		GrpcPort: 16680,
+		Peers: []Peer{
+			{Name: "a", Address: "localhost:16681"},
+			{Name: "b", Address: "localhost:16682"},
+			// nothing listens here
+			{Name: "down", Address: "localhost:16689"},
+		},
+		PeerTimeout: 500 * time.Millisecond,
+		PrefixPeers: true,
+	})
+	require.NoError(t, err)
+	go server.grpcHandler.Run()
+	defer server.Shutdown()
+
+	require.NoError(t, server.db.Write("temperature", &models.Numerical{Ts: 1, Value: 20}))
+	require.NoError(t, peerA.db.Write("temperature", &models.Numerical{Ts: 2, Value: 21}))
+	require.NoError(t, peerB.db.Write("temperature", &models.Numerical{Ts: 3, Value: 22}))
+
+	t.Run("Retrieve merges the series of all peers", func(t *testing.T) {
+		response, err := server.grpcHandler.Retrieve(context.Background(), &proto.RetrieveRequest{Start: 1, End: 10})
+		require.NoError(t, err)
+
+		assert.Equal(t, map[string][]models.Measurement{
+			"temperature":   {&models.Numerical{Ts: 1, Value: 20}},
+			"a/temperature": {&models.Numerical{Ts: 2, Value: 21}},
+			"b/temperature": {&models.Numerical{Ts: 3, Value: 22}},
+		}, response.ToMeasurementMap())
+		require.Len(t, response.PeerErrors, 1)
+		assert.Equal(t, "down", response.PeerErrors[0].Peer)
+	})
+
+	t.Run("prefixed names are only requested from their peer", func(t *testing.T) {
+		response, err := server.grpcHandler.Retrieve(context.Background(), &proto.RetrieveRequest{
+			Start:  1,
+			End:    10,
+			Filter: &proto.Filter{Names: []string{"a/temperature"}},
+		})
+		require.NoError(t, err)
+
+		assert.Equal(t, map[string][]models.Measurement{
+			"a/temperature": {&models.Numerical{Ts: 2, Value: 21}},
+		}, response.ToMeasurementMap())
+		assert.Empty(t, response.PeerErrors)
+	})
+
+	t.Run("series of the same name are merged without prefixes", func(t *testing.T) {
+		unprefixed, err := newFederation([]Peer{{Name: "a", Address: "localhost:16681"}, {Name: "b", Address: "localhost:16682"}}, time.Second, false, grpc.WithInsecure())
+		require.NoError(t, err)
+		defer unprefixed.close()
+
+		result := map[string][]models.Measurement{"temperature": {&models.Numerical{Ts: 4, Value: 23}}}
+		peerErrors := unprefixed.retrieve(context.Background(), &proto.RetrieveRequest{Start: 1, End: 10}, result)
+		assert.Empty(t, peerErrors)
+		assert.Equal(t, map[string][]models.Measurement{"temperature": {
+			&models.Numerical{Ts: 2, Value: 21},
+			&models.Numerical{Ts: 3, Value: 22},
+			&models.Numerical{Ts: 4, Value: 23},
+		}}, result)
+	})
+
+	t.Run("merged series are thinned out to the granularity", func(t *testing.T) {
+		unprefixed, err := newFederation([]Peer{{Name: "a", Address: "localhost:16681"}, {Name: "b", Address: "localhost:16682"}}, time.Second, false, grpc.WithInsecure())
+		require.NoError(t, err)
+		defer unprefixed.close()
+
+		result := map[string][]models.Measurement{"temperature": {&models.Numerical{Ts: 4, Value: 23}}}
+		request := &proto.RetrieveRequest{Start: 1, End: 10, Filter: &proto.Filter{GranularityNanos: 2}}
+		peerErrors := unprefixed.retrieve(context.Background(), request, result)
+		assert.Empty(t, peerErrors)
+		assert.Equal(t, map[string][]models.Measurement{"temperature": {
+			&models.Numerical{Ts: 2, Value: 21},
+			&models.Numerical{Ts: 4, Value: 23},
+		}}, result)
+	})
+
+	t.Run("ListSeries includes the series of the peers", func(t *testing.T) {
+		response, err := server.grpcHandler.ListSeries(context.Background(), &proto.ListSeriesRequest{})
+		require.NoError(t, err)
+
+		names := []string{}
+		for _, info := range response.Series {
+			names = append(names, info.Name)
+		}
+		assert.ElementsMatch(t, []string{"temperature", "a/temperature", "b/temperature"}, names)
+		require.Len(t, response.PeerErrors, 1)
+	})
+
+	t.Run("Subscribe receives the measurements of the peers", func(t *testing.T) {
+		conn, err := grpc.Dial("localhost:16680", grpc.WithInsecure())
+		require.NoError(t, err)
+		defer conn.Close()
+
+		stream, err := proto.NewMhistClient(conn).Subscribe(context.Background(), &proto.Filter{Names: []string{"b/temperature"}})
+		require.NoError(t, err)
+		header, err := stream.Header()
+		require.NoError(t, err)
+		assert.Empty(t, header.Get(peerErrorsHeader), "only peer b is subscribed to")
+
+		require.NoError(t, peerA.db.Write("temperature", &models.Numerical{Ts: 5, Value: 24}))
+		require.NoError(t, peerB.db.Write("temperature", &models.Numerical{Ts: 6, Value: 25}))
+		message, err := stream.Recv()
+		require.NoError(t, err)
+		assert.Equal(t, "b/temperature", message.Name)
+		assert.Equal(t, &models.Numerical{Ts: 6, Value: 25}, message.Measurement.ToModelWithDefinedTs())
+	})
+}
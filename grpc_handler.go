@@ -1,54 +1,109 @@
 package mhist
 
 import (
+	"bufio"
 	"context"
 	"errors"
 	"fmt"
 	"io"
 	"log"
 	"net"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/alexmorten/mhist/models"
 	"github.com/alexmorten/mhist/proto"
+	"github.com/golang/protobuf/ptypes"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
 )
 
 // ErrMeasurementMissingType is returned when one of the Store endpoints is called without a necessary type
 var ErrMeasurementMissingType = errors.New("measurement is not categorical or numerical")
 
+// ErrInvalidArgument is wrapped by the errors of requests that can't succeed as they are
+var ErrInvalidArgument = errors.New("invalid argument")
+
 // GrpcHandler handles the grpc endpoints for the MhistServer interface
 type GrpcHandler struct {
 	server     *Server
 	port       int
 	grpcServer *grpc.Server
+	// health service, the mhist service is NOT_SERVING while writes are rejected after a storage failure
+	health *health.Server
 
 	subs *grpcSubscribers
+	// retrieveWindow in nanoseconds is how far back Retrieve reads without a start, accessed atomically
+	retrieveWindow int64
+	// draining is closed on shutdown to end the open streams
+	draining     chan struct{}
+	drainingOnce sync.Once
+	// listening is 1 while the grpc port is listened on, accessed atomically
+	listening int32
 }
 
+// mhistServiceName is the name of the mhist service in the grpc health service
+const mhistServiceName = "proto.Mhist"
+
+// defaultRetrieveWindow is how far back Retrieve reads without a start by default
+const defaultRetrieveWindow = time.Hour
+
 // NewGrpcHandler returns a fully initialized GrpcHandler
 func NewGrpcHandler(server *Server, port int) *GrpcHandler {
-	return &GrpcHandler{
-		server: server,
-		port:   port,
-		subs:   newGrpcSubscribers(),
+	h := &GrpcHandler{
+		server:         server,
+		port:           port,
+		subs:           newGrpcSubscribers(),
+		retrieveWindow: defaultRetrieveWindow.Nanoseconds(),
+		draining:       make(chan struct{}),
+		health:         health.NewServer(),
+	}
+
+	options := []grpc.ServerOption{}
+	if server.certificates != nil {
+		options = append(options, grpc.Creds(credentials.NewTLS(server.certificates.serverConfig())))
+	}
+	if authenticator := server.authenticator; authenticator != nil {
+		options = append(options, grpc.UnaryInterceptor(authenticator.unaryInterceptor), grpc.StreamInterceptor(authenticator.streamInterceptor))
+	}
+	h.grpcServer = grpc.NewServer(options...)
+	proto.RegisterMhistServer(h.grpcServer, h)
+	healthpb.RegisterHealthServer(h.grpcServer, h.health)
+	return h
+}
+
+// setRetrieveWindow used by requests without a start, 0 restores the default
+func (h *GrpcHandler) setRetrieveWindow(window time.Duration) {
+	if window == 0 {
+		window = defaultRetrieveWindow
 	}
+	atomic.StoreInt64(&h.retrieveWindow, window.Nanoseconds())
 }
 
-// Run listens on the given port and handles grpc calls
-func (h *GrpcHandler) Run() {
+// Run listens on the given port and handles grpc calls until Shutdown
+func (h *GrpcHandler) Run() error {
 	lisAddr := fmt.Sprintf(":%v", h.port)
 	log.Println("grpc_handler running on ", lisAddr)
 	lis, err := net.Listen("tcp", lisAddr)
 	if err != nil {
-		log.Fatalf("failed to listen: %v", err)
+		return fmt.Errorf("failed to listen: %w", err)
 	}
-	h.grpcServer = grpc.NewServer()
-
-	proto.RegisterMhistServer(h.grpcServer, h)
-	if err := h.grpcServer.Serve(lis); err != nil {
-		log.Fatalf("failed to serve: %v", err)
+	h.updateHealth()
+	go h.watchHealth()
+	atomic.StoreInt32(&h.listening, 1)
+	err = h.grpcServer.Serve(lis)
+	atomic.StoreInt32(&h.listening, 0)
+	if err != nil && err != grpc.ErrServerStopped {
+		return fmt.Errorf("failed to serve: %w", err)
 	}
+	return nil
 }
 
 // Notify for the Subscriber interface
@@ -59,46 +114,192 @@ func (h *GrpcHandler) Notify(name string, measurement models.Measurement) {
 	})
 }
 
-// Shutdown the GrpcHandler
-func (h *GrpcHandler) Shutdown() {
-	if h.grpcServer == nil {
-		return
+// isDraining once the handler is shut down
+func (h *GrpcHandler) isDraining() bool {
+	select {
+	case <-h.draining:
+		return true
+	default:
+		return false
 	}
+}
 
-	h.grpcServer.Stop()
+// watchHealth updates the status of the health service until the handler is shut down
+func (h *GrpcHandler) watchHealth() {
+	ticker := time.NewTicker(healthCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			h.updateHealth()
+		case <-h.draining:
+			return
+		}
+	}
+}
+
+func (h *GrpcHandler) updateHealth() {
+	status := healthpb.HealthCheckResponse_SERVING
+	if !h.server.Health().Writable {
+		status = healthpb.HealthCheckResponse_NOT_SERVING
+	}
+	h.health.SetServingStatus(mhistServiceName, status)
+}
+
+// Shutdown the GrpcHandler, subscriptions and store streams are ended and running requests can finish until the timeout
+func (h *GrpcHandler) Shutdown(timeout time.Duration) {
+	h.drainingOnce.Do(func() {
+		close(h.draining)
+	})
+	// health checks report NOT_SERVING while the running requests finish
+	h.health.Shutdown()
+
+	stopped := make(chan struct{})
+	go func() {
+		h.grpcServer.GracefulStop()
+		close(stopped)
+	}()
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-stopped:
+	case <-timer.C:
+		log.Printf("requests didn't finish within %v, cancelling them", timeout)
+		h.grpcServer.Stop()
+		<-stopped
+	}
 }
 
 // Store the given measurement in mhist
-func (h *GrpcHandler) Store(_ context.Context, message *proto.MeasurementMessage) (*proto.Nothing, error) {
-	err := h.handleNewMessage(message)
+func (h *GrpcHandler) Store(ctx context.Context, message *proto.MeasurementMessage) (*proto.Nothing, error) {
+	err := h.checkWritable()
 	if err != nil {
 		return nil, err
 	}
+	grant, err := h.server.grantFor(ctx)
+	if err != nil {
+		return nil, statusFromError(err)
+	}
+	db, err := h.server.dbFor(ctx)
+	if err != nil {
+		return nil, statusFromError(err)
+	}
+	forwarder := h.server.cluster.newForwarder(ctx, h.server.isForwardedWrite(ctx, grant))
+	err = h.handleNewMessage(ctx, db, grant, forwarder, message)
+	if err == nil {
+		err = forwarder.close()
+	}
+	if err != nil {
+		return nil, statusFromError(err)
+	}
 
 	return &proto.Nothing{}, nil
 }
 
 // StoreStream 'ed measurements in mhist
 func (h *GrpcHandler) StoreStream(stream proto.Mhist_StoreStreamServer) error {
+	err := h.checkWritable()
+	if err != nil {
+		return err
+	}
+	grant, err := h.server.grantFor(stream.Context())
+	if err != nil {
+		return statusFromError(err)
+	}
+	db, err := h.server.dbFor(stream.Context())
+	if err != nil {
+		return statusFromError(err)
+	}
+	// measurements of series owned by other cluster nodes are streamed on to them
+	forwarder := h.server.cluster.newForwarder(stream.Context(), h.server.isForwardedWrite(stream.Context(), grant))
+
+	// messages are received in their own goroutine, so the stream can be ended on shutdown while the client is idle.
+	// pending is set while a received message isn't handled yet, once stopped nothing is received anymore
+	messages := make(chan *proto.MeasurementMessage)
+	recvErrs := make(chan error, 1)
+	var receiveMutex sync.Mutex
+	stopped, pending := false, false
+	go func() {
+		for {
+			m, err := stream.Recv()
+			if err != nil {
+				recvErrs <- err
+				return
+			}
+			receiveMutex.Lock()
+			if stopped {
+				receiveMutex.Unlock()
+				return
+			}
+			pending = true
+			receiveMutex.Unlock()
+			select {
+			case messages <- m:
+			case <-stream.Context().Done():
+				return
+			}
+		}
+	}()
+
 	for {
-		m, err := stream.Recv()
-		if err != nil {
+		var m *proto.MeasurementMessage
+		select {
+		case m = <-messages:
+			receiveMutex.Lock()
+			pending = false
+			receiveMutex.Unlock()
+		case err := <-recvErrs:
 			if err == io.EOF {
-				return nil
+				err = forwarder.close()
+				if err != nil {
+					return statusFromError(err)
+				}
+				return stream.SendAndClose(&proto.Nothing{})
 			}
 			log.Println(err)
+			forwarder.close()
 			return err
+		case <-h.draining:
+			// the measurements received so far are stored, the client can continue on another server or after the restart
+			receiveMutex.Lock()
+			stopped = true
+			received := pending
+			receiveMutex.Unlock()
+			if received {
+				err := h.handleNewMessage(stream.Context(), db, grant, forwarder, <-messages)
+				if err != nil {
+					forwarder.close()
+					return statusFromError(err)
+				}
+			}
+			err := forwarder.close()
+			if err != nil {
+				return statusFromError(err)
+			}
+			return status.Error(codes.Unavailable, "the server is shutting down")
 		}
 
-		err = h.handleNewMessage(m)
+		err = h.handleNewMessage(stream.Context(), db, grant, forwarder, m)
 		if err != nil {
-			return err
+			forwarder.close()
+			return statusFromError(err)
 		}
 	}
 }
 
 // Retrieve the requested measurements
-func (h *GrpcHandler) Retrieve(_ context.Context, request *proto.RetrieveRequest) (*proto.RetrieveResponse, error) {
+func (h *GrpcHandler) Retrieve(ctx context.Context, request *proto.RetrieveRequest) (*proto.RetrieveResponse, error) {
+	grant, err := h.server.grantFor(ctx)
+	if err == nil {
+		err = grant.authorize(PermissionRead, request.GetFilter().GetNames()...)
+	}
+	if err != nil {
+		return nil, statusFromError(err)
+	}
+	db, err := h.server.dbFor(ctx)
+	if err != nil {
+		return nil, statusFromError(err)
+	}
 	filterDefinition := models.FilterDefinition{}
 
 	if request.Filter != nil {
@@ -113,20 +314,182 @@ func (h *GrpcHandler) Retrieve(_ context.Context, request *proto.RetrieveRequest
 
 	startTs := request.Start
 	if startTs == 0 {
-		startTs = endTs - (1 * time.Hour).Nanoseconds()
+		startTs = endTs - atomic.LoadInt64(&h.retrieveWindow)
 	}
-	responseMap := h.server.store.GetMeasurementsInTimeRange(startTs, endTs, filterDefinition)
 
-	return proto.RetrieveResponseFromMeasurementMap(responseMap), nil
+	federation := h.server.federationFor(ctx)
+	queryLocally := true
+	if federation != nil {
+		filterDefinition.Names, queryLocally = federation.route(filterDefinition.Names)[localSource]
+	}
+
+	responseMap := map[string][]models.Measurement{}
+	if queryLocally {
+		responseMap, err = db.Query(startTs, endTs, filterDefinition)
+		if err != nil {
+			return nil, statusFromError(err)
+		}
+	}
+
+	var peerErrors []*proto.PeerError
+	if federation != nil {
+		peerErrors = federation.retrieve(ctx, &proto.RetrieveRequest{Start: startTs, End: endTs, Filter: request.Filter}, responseMap)
+	}
+	// without requested names all series are retrieved, including the ones the request may not read
+	for name := range responseMap {
+		if !grant.allows(PermissionRead, name) {
+			delete(responseMap, name)
+		}
+	}
+
+	response := proto.RetrieveResponseFromMeasurementMap(responseMap)
+	response.PeerErrors = peerErrors
+	return response, nil
+}
+
+// ListSeries with their types, including the series of federated peers
+func (h *GrpcHandler) ListSeries(ctx context.Context, _ *proto.ListSeriesRequest) (*proto.ListSeriesResponse, error) {
+	infos, peerErrors, err := h.server.listSeries(ctx)
+	if err != nil {
+		return nil, statusFromError(err)
+	}
+
+	response := &proto.ListSeriesResponse{PeerErrors: peerErrors}
+	for _, info := range infos {
+		response.Series = append(response.Series, &proto.SeriesInfo{Name: info.Name, Type: proto.MeasurementType(info.Type)})
+	}
+	return response, nil
+}
+
+// Latest measurement of each of the requested series, served from memory
+func (h *GrpcHandler) Latest(ctx context.Context, request *proto.LatestRequest) (*proto.LatestResponse, error) {
+	grant, err := h.server.grantFor(ctx)
+	if err == nil {
+		err = grant.authorize(PermissionRead, request.Names...)
+	}
+	if err != nil {
+		return nil, statusFromError(err)
+	}
+	db, err := h.server.dbFor(ctx)
+	if err != nil {
+		return nil, statusFromError(err)
+	}
+	names := request.Names
+	federation := h.server.federationFor(ctx)
+	queryLocally := true
+	if federation != nil {
+		names, queryLocally = federation.route(names)[localSource]
+	}
+
+	measurements := map[string]models.Measurement{}
+	if queryLocally {
+		measurements, err = db.Latest(names)
+		if err != nil {
+			return nil, statusFromError(err)
+		}
+	}
+
+	var peerErrors []*proto.PeerError
+	if federation != nil {
+		peerErrors = federation.latest(ctx, request.Names, measurements)
+	}
+
+	latest := map[string]*proto.Measurement{}
+	for name, measurement := range measurements {
+		if !grant.allows(PermissionRead, name) {
+			continue
+		}
+		pM := proto.MeasurementFromModel(measurement)
+		if pM == nil {
+			continue
+		}
+		latest[name] = pM
+	}
+	return &proto.LatestResponse{Latest: latest, PeerErrors: peerErrors}, nil
 }
 
 // Subscribe to measurements
 func (h *GrpcHandler) Subscribe(protoFilter *proto.Filter, stream proto.Mhist_SubscribeServer) error {
-	subscription := h.subs.newSubscriber()
+	grant, err := h.server.grantFor(stream.Context())
+	if err == nil {
+		err = grant.authorize(PermissionSubscribe, protoFilter.GetNames()...)
+	}
+	if err != nil {
+		return statusFromError(err)
+	}
+	db, err := h.server.dbFor(stream.Context())
+	if err != nil {
+		return statusFromError(err)
+	}
+	header := metadata.MD{}
+	// peerMessages stays nil without federation
+	var peerMessages chan notifyMessage
+	if federation := h.server.federationFor(stream.Context()); federation != nil {
+		ctx, cancel := context.WithCancel(stream.Context())
+		peers := &sync.WaitGroup{}
+		defer func() {
+			cancel()
+			peers.Wait()
+		}()
+
+		peerMessages = make(chan notifyMessage)
+		peerErrors := federation.subscribe(ctx, protoFilter, peers, func(name string, measurement models.Measurement) {
+			select {
+			case peerMessages <- notifyMessage{name: name, measurement: measurement}:
+			case <-ctx.Done():
+			}
+		})
+		for _, peerError := range peerErrors {
+			header.Append(peerErrorsHeader, peerError.Peer+": "+peerError.Error)
+		}
+	}
+	// federating servers wait for the header to know the subscription is established
+	err = stream.SendHeader(header)
+	if err != nil {
+		return err
+	}
+
+	// measurements of the default tenant are relayed by the handler, other tenants are subscribed to directly
+	var subscription *grpcSubscriber
+	var localMessages chan notifyMessage
+	var tenantNotifications <-chan Notification
+	if db == h.server.db {
+		subscription = h.subs.newSubscriber()
+		// writes notifying the subscriber must not block once the stream ended, e.g. by draining
+		defer h.subs.removeSubscriber(subscription)
+		localMessages = subscription.notifyChan
+	} else {
+		tenantSubscription, err := db.Subscribe(protoFilter.ToModel())
+		if err != nil {
+			return statusFromError(err)
+		}
+		defer tenantSubscription.Unsubscribe()
+		tenantNotifications = tenantSubscription.C
+	}
 	filter := models.NewFilterCollection(protoFilter.ToModel())
 
-	for m := range subscription.notifyChan {
-		if !filter.Passes(m.name, m.measurement) {
+	for {
+		var m notifyMessage
+		select {
+		case message, ok := <-localMessages:
+			if !ok {
+				return nil
+			}
+			m = message
+		case notification, ok := <-tenantNotifications:
+			if !ok {
+				return nil
+			}
+			m = notifyMessage{name: notification.Name, measurement: notification.Measurement}
+		case m = <-peerMessages:
+		case <-h.draining:
+			// the stream ends without an error, so clients know they received everything until the shutdown
+			return nil
+		case <-stream.Context().Done():
+			return stream.Context().Err()
+		}
+
+		if !filter.Passes(m.name, m.measurement) || !grant.allows(PermissionSubscribe, m.name) {
 			continue
 		}
 
@@ -139,22 +502,313 @@ func (h *GrpcHandler) Subscribe(protoFilter *proto.Filter, stream proto.Mhist_Su
 		if err != nil {
 			log.Println(err)
 			log.Println("removing subscription")
+			return err
+		}
+	}
+}
+
+// DeleteSeries removes the named series with all their measurements
+func (h *GrpcHandler) DeleteSeries(ctx context.Context, request *proto.DeleteSeriesRequest) (*proto.Nothing, error) {
+	err := h.checkWritable()
+	if err != nil {
+		return nil, err
+	}
+	err = h.authorize(ctx, PermissionAdmin, request.Names...)
+	if err != nil {
+		return nil, err
+	}
+	db, err := h.server.dbFor(ctx)
+	if err != nil {
+		return nil, statusFromError(err)
+	}
+	err = db.DeleteSeries(request.Names)
+	if cluster := h.server.clusterFor(ctx); cluster != nil {
+		err = cluster.broadcast(ctx, err, func(ctx context.Context, client proto.MhistClient) error {
+			_, err := client.DeleteSeries(ctx, request)
+			return err
+		})
+	}
+	if err != nil {
+		return nil, statusFromError(err)
+	}
+	return &proto.Nothing{}, nil
+}
 
-			h.subs.removeSubscriber(subscription)
+// DeleteRange removes the measurements of the named series in the time range
+func (h *GrpcHandler) DeleteRange(ctx context.Context, request *proto.DeleteRangeRequest) (*proto.Nothing, error) {
+	err := h.checkWritable()
+	if err != nil {
+		return nil, err
+	}
+	err = h.authorize(ctx, PermissionAdmin, request.Names...)
+	if err != nil {
+		return nil, err
+	}
+	db, err := h.server.dbFor(ctx)
+	if err != nil {
+		return nil, statusFromError(err)
+	}
+	err = db.DeleteRange(request.Names, request.Start, request.End)
+	if cluster := h.server.clusterFor(ctx); cluster != nil {
+		err = cluster.broadcast(ctx, err, func(ctx context.Context, client proto.MhistClient) error {
+			_, err := client.DeleteRange(ctx, request)
+			return err
+		})
+	}
+	if err != nil {
+		return nil, statusFromError(err)
+	}
+	return &proto.Nothing{}, nil
+}
 
+// RenameSeries keeping all its measurements
+func (h *GrpcHandler) RenameSeries(ctx context.Context, request *proto.RenameSeriesRequest) (*proto.Nothing, error) {
+	err := h.checkWritable()
+	if err != nil {
+		return nil, err
+	}
+	err = h.authorize(ctx, PermissionAdmin, request.Name, request.NewName)
+	if err != nil {
+		return nil, err
+	}
+	db, err := h.server.dbFor(ctx)
+	if err != nil {
+		return nil, statusFromError(err)
+	}
+	err = db.RenameSeries(request.Name, request.NewName)
+	if cluster := h.server.clusterFor(ctx); cluster != nil {
+		err = cluster.broadcast(ctx, err, func(ctx context.Context, client proto.MhistClient) error {
+			_, err := client.RenameSeries(ctx, request)
+			return err
+		})
+	}
+	if err != nil {
+		return nil, statusFromError(err)
+	}
+	return &proto.Nothing{}, nil
+}
+
+// MergeSeries into another series, measurements are converted to the type of the other series
+func (h *GrpcHandler) MergeSeries(ctx context.Context, request *proto.MergeSeriesRequest) (*proto.Nothing, error) {
+	err := h.checkWritable()
+	if err != nil {
+		return nil, err
+	}
+	err = h.authorize(ctx, PermissionAdmin, request.Name, request.Into)
+	if err != nil {
+		return nil, err
+	}
+	db, err := h.server.dbFor(ctx)
+	if err != nil {
+		return nil, statusFromError(err)
+	}
+	err = db.MergeSeries(request.Name, request.Into)
+	if cluster := h.server.clusterFor(ctx); cluster != nil {
+		err = cluster.broadcast(ctx, err, func(ctx context.Context, client proto.MhistClient) error {
+			_, err := client.MergeSeries(ctx, request)
 			return err
+		})
+	}
+	if err != nil {
+		return nil, statusFromError(err)
+	}
+	return &proto.Nothing{}, nil
+}
+
+// MigrateSeries to another type, existing measurements are converted
+func (h *GrpcHandler) MigrateSeries(ctx context.Context, request *proto.MigrateSeriesRequest) (*proto.Nothing, error) {
+	err := h.checkWritable()
+	if err != nil {
+		return nil, err
+	}
+	err = h.authorize(ctx, PermissionAdmin, request.Name)
+	if err != nil {
+		return nil, err
+	}
+	db, err := h.server.dbFor(ctx)
+	if err != nil {
+		return nil, statusFromError(err)
+	}
+	err = db.MigrateSeries(request.Name, models.MeasurementType(request.Type))
+	if cluster := h.server.clusterFor(ctx); cluster != nil {
+		err = cluster.broadcast(ctx, err, func(ctx context.Context, client proto.MhistClient) error {
+			_, err := client.MigrateSeries(ctx, request)
+			return err
+		})
+	}
+	if err != nil {
+		return nil, statusFromError(err)
+	}
+	return &proto.Nothing{}, nil
+}
+
+// Snapshot streams a tar archive of a consistent copy of the data, or writes the copy to a directory on the server
+func (h *GrpcHandler) Snapshot(request *proto.SnapshotRequest, stream proto.Mhist_SnapshotServer) error {
+	err := h.authorizeAll(stream.Context(), PermissionAdmin)
+	if err != nil {
+		return err
+	}
+	if request.Dir != "" {
+		dir, err := snapshotDirIn(h.server.config.DataPath, request.Dir)
+		if err == nil {
+			err = h.server.db.Snapshot(dir)
+		}
+		if err != nil {
+			return statusFromError(err)
 		}
+		return nil
 	}
 
+	writer := bufio.NewWriterSize(&chunkWriter{send: func(chunk []byte) error {
+		return stream.Send(&proto.SnapshotChunk{Data: chunk})
+	}}, snapshotChunkSize)
+	err = h.server.db.WriteSnapshot(writer)
+	if err == nil {
+		err = writer.Flush()
+	}
+	if err != nil {
+		return statusFromError(err)
+	}
 	return nil
 }
 
-func (h *GrpcHandler) handleNewMessage(message *proto.MeasurementMessage) error {
+// Rebalance moves the series this cluster node doesn't own to their owners
+func (h *GrpcHandler) Rebalance(ctx context.Context, _ *proto.RebalanceRequest) (*proto.RebalanceResponse, error) {
+	if h.server.cluster == nil {
+		return nil, status.Error(codes.FailedPrecondition, "the server is not part of a cluster")
+	}
+	err := h.checkWritable()
+	if err != nil {
+		return nil, err
+	}
+	err = h.authorizeAll(ctx, PermissionAdmin)
+	if err != nil {
+		return nil, err
+	}
+	series, measurements, err := h.server.cluster.rebalance(ctx, h.server.db)
+	if err != nil {
+		return nil, statusFromError(err)
+	}
+	return &proto.RebalanceResponse{MovedSeries: int64(series), MovedMeasurements: int64(measurements)}, nil
+}
+
+// size of the chunks snapshots are streamed in
+const snapshotChunkSize = 64 * 1024
+
+// chunkWriter sends everything written to it as chunks of a stream
+type chunkWriter struct {
+	send func(chunk []byte) error
+}
+
+func (w *chunkWriter) Write(p []byte) (int, error) {
+	// p is reused by the bufio.Writer, but the message might still be referenced after it was sent
+	chunk := make([]byte, len(p))
+	copy(chunk, p)
+	err := w.send(chunk)
+	if err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func (h *GrpcHandler) handleNewMessage(ctx context.Context, db *DB, grant *grant, forwarder *forwarder, message *proto.MeasurementMessage) error {
 	m := message.Measurement.ToModelWithDefinedTs()
 
 	if m == nil {
 		return ErrMeasurementMissingType
 	}
-	h.server.store.Add(message.Name, m)
+	err := grant.authorize(PermissionWrite, message.Name)
+	if err != nil {
+		return err
+	}
+	// writes forwarded by other cluster nodes were admitted by them
+	if !h.server.isForwardedWrite(ctx, grant) {
+		err = h.server.limits.admit(ctx, message.Name)
+		if err != nil {
+			return err
+		}
+	}
+	forwarded, err := forwarder.forward(message)
+	if forwarded {
+		return err
+	}
+	return db.Write(message.Name, m)
+}
+
+// authorize the permission on the names for the request
+func (h *GrpcHandler) authorize(ctx context.Context, permission Permission, names ...string) error {
+	grant, err := h.server.grantFor(ctx)
+	if err == nil {
+		err = grant.authorize(permission, names...)
+	}
+	if err != nil {
+		return statusFromError(err)
+	}
+	return nil
+}
+
+// authorizeAll series for requests that affect all of them
+func (h *GrpcHandler) authorizeAll(ctx context.Context, permission Permission) error {
+	grant, err := h.server.grantFor(ctx)
+	if err == nil {
+		err = grant.authorizeAll(permission)
+	}
+	if err != nil {
+		return statusFromError(err)
+	}
 	return nil
 }
+
+// checkWritable fails on followers, only their leader accepts writes
+func (h *GrpcHandler) checkWritable() error {
+	if h.server.follower != nil {
+		return statusFromError(ErrReadOnly)
+	}
+	return nil
+}
+
+func statusFromError(err error) error {
+	var retryable *retryableError
+	if errors.As(err, &retryable) {
+		// clients back off for the time it takes until the write would be accepted
+		s, detailsErr := status.New(codes.ResourceExhausted, err.Error()).WithDetails(&errdetails.RetryInfo{
+			RetryDelay: ptypes.DurationProto(retryable.retryAfter),
+		})
+		if detailsErr != nil {
+			return status.Error(codes.ResourceExhausted, err.Error())
+		}
+		return s.Err()
+	}
+	var remote interface{ GRPCStatus() *status.Status }
+	if errors.As(err, &remote) {
+		// errors of other cluster nodes keep their code and details
+		forwarded := remote.GRPCStatus().Proto()
+		forwarded.Message = err.Error()
+		return status.ErrorProto(forwarded)
+	}
+	switch {
+	case errors.Is(err, ErrSeriesNotFound), errors.Is(err, ErrUnknownTenant):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, ErrSeriesExists):
+		return status.Error(codes.AlreadyExists, err.Error())
+	case errors.Is(err, ErrTypeMismatch), errors.Is(err, ErrReadOnly):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	case errors.Is(err, ErrInvalidArgument), errors.Is(err, ErrMeasurementMissingType), errors.Is(err, ErrInvalidSnapshot):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, ErrSnapshotNotSupported), errors.Is(err, ErrMaintenanceNotSupported):
+		return status.Error(codes.Unimplemented, err.Error())
+	case errors.Is(err, ErrUnauthenticated):
+		return status.Error(codes.Unauthenticated, err.Error())
+	case errors.Is(err, ErrPermissionDenied):
+		return status.Error(codes.PermissionDenied, err.Error())
+	case errors.Is(err, ErrQuotaExceeded), errors.Is(err, ErrRateLimited), errors.Is(err, ErrOverloaded), errors.Is(err, ErrCardinalityLimit):
+		return status.Error(codes.ResourceExhausted, err.Error())
+	case errors.Is(err, ErrTenantsDisabled):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	case errors.Is(err, ErrDegraded), errors.Is(err, ErrClosed):
+		// the server only accepts writes again once the disk is writable, closed DBs are shutting down
+		return status.Error(codes.Unavailable, err.Error())
+	}
+	// e.g. storage failures, the request itself might be fine
+	return status.Error(codes.Internal, err.Error())
+}
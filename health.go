@@ -0,0 +1,204 @@
+package mhist
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+//ErrStorage is returned if reading or writing the files of the disk backend failed
+var ErrStorage = errors.New("storage failure")
+
+//ErrDegraded is returned for writes while the disk backend is read-only after writing to disk failed, e.g. because the disk is full
+var ErrDegraded = errors.New("read-only after a storage failure")
+
+// the disk is probed with a file of this size to find out whether it is writable again
+const writableProbeSize = 64 * 1024
+
+//Health of a DB
+type Health struct {
+	// Writable is false while the DB is read-only after a storage failure, or if it is closed
+	Writable bool `json:"writable"`
+	// Error that made the DB read-only
+	Error string `json:"error,omitempty"`
+	// DegradedSince is when the DB became read-only
+	DegradedSince *time.Time `json:"degraded_since,omitempty"`
+}
+
+// healthReporter is implemented by backends that can become read-only
+type healthReporter interface {
+	health() Health
+}
+
+//ServerHealth of the DBs of a server
+type ServerHealth struct {
+	// Writable is false while any DB is read-only
+	Writable bool              `json:"writable"`
+	DB       Health            `json:"db"`
+	Tenants  map[string]Health `json:"tenants,omitempty"`
+}
+
+// healthCheckInterval in which the status of the grpc health service is updated
+const healthCheckInterval = time.Second
+
+// Health of the DB, a closed DB isn't writable
+func (db *DB) Health() Health {
+	db.mutex.RLock()
+	defer db.mutex.RUnlock()
+	if db.closed {
+		return Health{Error: ErrClosed.Error()}
+	}
+	if reporter, ok := db.store.backend.(healthReporter); ok {
+		return reporter.health()
+	}
+	return Health{Writable: true}
+}
+
+// health of the DBs of all tenants that are opened
+func (t *tenants) health() map[string]Health {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	result := make(map[string]Health, len(t.dbs))
+	for name, db := range t.dbs {
+		result[name] = db.Health()
+	}
+	return result
+}
+
+//Readiness of a server to serve requests
+type Readiness struct {
+	Ready  bool             `json:"ready"`
+	Checks []ReadinessCheck `json:"checks"`
+}
+
+//ReadinessCheck failed if it has an Error
+type ReadinessCheck struct {
+	Name  string `json:"name"`
+	Error string `json:"error,omitempty"`
+}
+
+//Readiness of the server: the grpc port is listened on, the meta is loaded, the disk is writable and it isn't shut down
+func (s *Server) Readiness() Readiness {
+	checks := []ReadinessCheck{{Name: "grpc_listener"}, {Name: "meta"}, {Name: "storage"}, {Name: "shutdown"}}
+	if atomic.LoadInt32(&s.grpcHandler.listening) == 0 {
+		checks[0].Error = "the grpc port isn't listened on"
+	}
+	// the meta is loaded while the DB is open
+	err := s.db.do(func() error { return nil })
+	if err != nil {
+		checks[1].Error = err.Error()
+	}
+	// the probe doesn't need a token, the failure and the tenants are only shown by /admin/health
+	if !s.Health().Writable {
+		checks[2].Error = "the storage is read-only"
+	}
+	if s.grpcHandler.isDraining() {
+		checks[3].Error = "the server is shutting down"
+	}
+
+	readiness := Readiness{Ready: true, Checks: checks}
+	for _, check := range checks {
+		readiness.Ready = readiness.Ready && check.Error == ""
+	}
+	return readiness
+}
+
+//Health of the DB and the DBs of the tenants of the server
+func (s *Server) Health() ServerHealth {
+	health := ServerHealth{DB: s.db.Health()}
+	health.Writable = health.DB.Writable
+	if s.tenants != nil {
+		health.Tenants = s.tenants.health()
+		for _, tenantHealth := range health.Tenants {
+			health.Writable = health.Writable && tenantHealth.Writable
+		}
+	}
+	return health
+}
+
+// withoutDetails leaves out the tenants and the errors, for probes that don't need a token
+func (h ServerHealth) withoutDetails() ServerHealth {
+	return ServerHealth{Writable: h.Writable, DB: Health{Writable: h.DB.Writable, DegradedSince: h.DB.DegradedSince}}
+}
+
+// storeHealth tracks the storage failure the disk backend is read-only after
+type storeHealth struct {
+	mutex   sync.RWMutex
+	failure error
+	since   time.Time
+}
+
+// fail makes the store read-only until it recovered
+func (h *storeHealth) fail(err error) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	if h.failure == nil {
+		log.Printf("the store is read-only until writing to disk works again: %v\n", err)
+		h.since = time.Now()
+	}
+	h.failure = err
+}
+
+func (h *storeHealth) recover() {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	if h.failure != nil {
+		log.Printf("the store is writable again after %v\n", time.Since(h.since).Round(time.Second))
+	}
+	h.failure = nil
+}
+
+func (h *storeHealth) degraded() bool {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+	return h.failure != nil
+}
+
+// err rejecting writes while the store is read-only
+func (h *storeHealth) err() error {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+	if h.failure == nil {
+		return nil
+	}
+	return fmt.Errorf("%w: %v", ErrDegraded, h.failure)
+}
+
+func (h *storeHealth) status() Health {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+	if h.failure == nil {
+		return Health{Writable: true}
+	}
+	since := h.since
+	return Health{Error: h.failure.Error(), DegradedSince: &since}
+}
+
+func storageError(err error) error {
+	return fmt.Errorf("%w: %v", ErrStorage, err)
+}
+
+// probeWritable by writing and removing a file in dir, it fails while the disk is full
+func probeWritable(dir string) error {
+	f, err := ioutil.TempFile(dir, "probe")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	_, err = f.Write(make([]byte, writableProbeSize))
+	if err == nil {
+		err = f.Sync()
+	}
+	closeErr := f.Close()
+	if err == nil {
+		err = closeErr
+	}
+	return err
+}
@@ -0,0 +1,126 @@
+package mhist
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alexmorten/mhist/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+func Test_DegradedMode(t *testing.T) {
+	dataPath := "test_data"
+	defer os.RemoveAll(dataPath)
+
+	store, err := NewDiskStore(dataPath, Options{MemorySize: 1024, DiskSize: 24 * 1024 * 1024, CommitInterval: 10 * time.Millisecond})
+	require.NoError(t, err)
+	defer store.Shutdown()
+
+	// the disk is full until failing is reset
+	failing := int32(1)
+	store.DiskWriter.beforeSync = func() error {
+		if atomic.LoadInt32(&failing) == 1 {
+			return errors.New("no space left on device")
+		}
+		return store.meta.Sync()
+	}
+
+	require.NoError(t, store.Add("a", &models.Numerical{Ts: 1000, Value: 1}))
+	err = store.Flush()
+	assert.True(t, errors.Is(err, ErrStorage))
+	assert.Equal(t, codes.Internal, status.Code(statusFromError(err)))
+
+	t.Run("writes are rejected while the store is read-only", func(t *testing.T) {
+		assert.False(t, store.health().Writable)
+		assert.NotNil(t, store.health().DegradedSince)
+
+		err := store.Add("a", &models.Numerical{Ts: 2000, Value: 2})
+		assert.True(t, errors.Is(err, ErrDegraded))
+		assert.Equal(t, codes.Unavailable, status.Code(statusFromError(err)))
+	})
+
+	t.Run("reads still work", func(t *testing.T) {
+		result, err := store.GetMeasurementsInTimeRange(1, 5000, models.FilterDefinition{})
+		require.NoError(t, err)
+		assert.Equal(t, []models.Measurement{&models.Numerical{Ts: 1000, Value: 1}}, result["a"])
+	})
+
+	t.Run("the store recovers once the disk is writable again", func(t *testing.T) {
+		atomic.StoreInt32(&failing, 0)
+		for i := 0; i < 100 && !store.health().Writable; i++ {
+			time.Sleep(10 * time.Millisecond)
+		}
+		require.Equal(t, Health{Writable: true}, store.health())
+
+		require.NoError(t, store.Add("a", &models.Numerical{Ts: 2000, Value: 2}))
+		require.NoError(t, store.Flush())
+		result, err := store.GetMeasurementsInTimeRange(1, 5000, models.FilterDefinition{})
+		require.NoError(t, err)
+		assert.Len(t, result["a"], 2)
+	})
+}
+
+func Test_FailedWritesAreReturned(t *testing.T) {
+	dataPath := "test_data"
+	defer os.RemoveAll(dataPath)
+
+	// every write is committed right away
+	store, err := NewDiskStore(dataPath, Options{MemorySize: 1024, DiskSize: 24 * 1024 * 1024, CommitSize: 1})
+	require.NoError(t, err)
+	defer store.Shutdown()
+	store.DiskWriter.beforeSync = func() error {
+		return errors.New("no space left on device")
+	}
+
+	err = store.Add("a", &models.Numerical{Ts: 1000, Value: 1})
+	assert.True(t, errors.Is(err, ErrStorage))
+	assert.False(t, store.health().Writable)
+}
+
+func Test_HealthWithoutDetails(t *testing.T) {
+	since := time.Now()
+	health := ServerHealth{
+		DB:      Health{Error: "open /data/current: no space left on device", DegradedSince: &since},
+		Tenants: map[string]Health{"team-a": {Writable: true}},
+	}
+	assert.Equal(t, ServerHealth{DB: Health{DegradedSince: &since}}, health.withoutDetails())
+}
+
+func Test_HealthService(t *testing.T) {
+	dataPath := "test_data"
+	defer os.RemoveAll(dataPath)
+
+	tokenFile := "test_health_tokens"
+	require.NoError(t, ioutil.WriteFile(tokenFile, []byte("root admin:*\n"), 0600))
+	defer os.Remove(tokenFile)
+
+	server, err := NewServer(ServerConfig{DataPath: dataPath, GrpcPort: 16760, Auth: &AuthConfig{TokenFile: tokenFile}})
+	require.NoError(t, err)
+	go server.grpcHandler.Run()
+	defer server.Shutdown()
+
+	conn, err := grpc.Dial("localhost:16760", grpc.WithInsecure())
+	require.NoError(t, err)
+	defer conn.Close()
+	client := healthpb.NewHealthClient(conn)
+
+	t.Run("health checks don't need a token", func(t *testing.T) {
+		response, err := client.Check(context.Background(), &healthpb.HealthCheckRequest{Service: mhistServiceName}, grpc.WaitForReady(true))
+		require.NoError(t, err)
+		assert.Equal(t, healthpb.HealthCheckResponse_SERVING, response.Status)
+	})
+
+	t.Run("the server reports the health of its DBs", func(t *testing.T) {
+		assert.Equal(t, ServerHealth{Writable: true, DB: Health{Writable: true}}, server.Health())
+	})
+}
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/alexmorten/mhist"
+	"github.com/alexmorten/mhist/models"
+	"github.com/alexmorten/mhist/proto"
+)
+
+const progressInterval = 10000
+
+type bulkFlags struct {
+	format        string
+	file          string
+	address       string
+	start         string
+	end           string
+	names         string
+	dataPath      string
+	memorySize    int
+	diskSize      int
+	reorderWindow time.Duration
+	client        clientFlags
+}
+
+func parseBulkFlags(command string, args []string) (*bulkFlags, mhist.TransferFilter) {
+	flags := &bulkFlags{}
+	set := flag.NewFlagSet(command, flag.ExitOnError)
+	set.StringVar(&flags.format, "format", mhist.FormatNDJSON, "format of the file, either csv or ndjson")
+	set.StringVar(&flags.file, "file", "-", "file to read from or write to, - for stdin or stdout")
+	set.StringVar(&flags.address, "address", "", "address of a running mhist (e.g. localhost:6666), if empty the data directory is accessed directly. Don't use the data directory of a running mhist directly")
+	set.StringVar(&flags.start, "start", "", "start of the time range, as unix nanoseconds or RFC3339")
+	set.StringVar(&flags.end, "end", "", "end of the time range, as unix nanoseconds or RFC3339")
+	set.StringVar(&flags.names, "names", "", "comma separated list of measurement names, all names if empty")
+	set.StringVar(&flags.dataPath, "data_path", "data", "same as the server flag, used when accessing the data directory directly")
+	set.IntVar(&flags.memorySize, "memory_size", 32*1024*1024, "same as the server flag, used when accessing the data directory directly")
+	set.IntVar(&flags.diskSize, "disk_size", 512*1024*1024, "same as the server flag, used when accessing the data directory directly")
+	set.DurationVar(&flags.reorderWindow, "reorder_window", 0, "same as the server flag, used when accessing the data directory directly")
+	flags.client.register(set)
+	set.Parse(args)
+
+	filter := mhist.TransferFilter{
+		// a start of 0 would mean "the last hour" to Retrieve, 1 includes everything
+		Start: parseTimeFlag(flags.start, 1),
+		End:   parseTimeFlag(flags.end, math.MaxInt64),
+	}
+	if flags.names != "" {
+		filter.Names = strings.Split(flags.names, ",")
+	}
+
+	return flags, filter
+}
+
+func parseTimeFlag(value string, defaultValue int64) int64 {
+	if value == "" {
+		return defaultValue
+	}
+	if ts, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return ts
+	}
+	t, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		log.Fatalf("couldn't parse time %q: %v", value, err)
+	}
+	return t.UnixNano()
+}
+
+func runImport(args []string) {
+	flags, filter := parseBulkFlags("import", args)
+
+	input := os.Stdin
+	if flags.file != "-" {
+		f, err := os.Open(flags.file)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer f.Close()
+		input = f
+	}
+
+	reader, err := mhist.NewMessageReader(flags.format, input)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var add func(name string, m models.Measurement) error
+	var finish func() error
+
+	if flags.address == "" {
+		db, err := openDB(flags)
+		if err != nil {
+			log.Fatal(err)
+		}
+		add = db.Write
+		finish = db.Close
+	} else {
+		conn, err := flags.client.dial(flags.address)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer conn.Close()
+
+		stream, err := proto.NewMhistClient(conn).StoreStream(context.Background())
+		if err != nil {
+			log.Fatal(err)
+		}
+		add = func(name string, m models.Measurement) error {
+			return stream.Send(&proto.MeasurementMessage{Name: name, Measurement: proto.MeasurementFromModel(m)})
+		}
+		finish = func() error {
+			_, err := stream.CloseAndRecv()
+			return err
+		}
+	}
+
+	start := time.Now()
+	imported, err := mhist.Import(reader, filter, add, reportProgress("imported", start))
+	if err != nil {
+		log.Printf("import failed after %v measurements: %v", imported, err)
+	}
+	if finishErr := finish(); finishErr != nil {
+		log.Fatal(finishErr)
+	}
+	if err != nil {
+		os.Exit(1)
+	}
+	log.Printf("imported %v measurements in %v", imported, time.Since(start))
+}
+
+func runExport(args []string) {
+	flags, filter := parseBulkFlags("export", args)
+
+	output := os.Stdout
+	if flags.file != "-" {
+		f, err := os.Create(flags.file)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer f.Close()
+		output = f
+	}
+
+	writer, err := mhist.NewMessageWriter(flags.format, output)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// the time range is queried in windows, so neither the memory nor the grpc message size limit everything at once
+	var query func(start, end int64) (map[string][]models.Measurement, error)
+	finish := func() error { return nil }
+	filterDefinition := models.FilterDefinition{Names: filter.Names}
+
+	if flags.address == "" {
+		db, err := openDB(flags)
+		if err != nil {
+			log.Fatal(err)
+		}
+		finish = db.Close
+		query = func(start, end int64) (map[string][]models.Measurement, error) {
+			return db.Query(start, end, filterDefinition)
+		}
+	} else {
+		conn, err := flags.client.dial(flags.address)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer conn.Close()
+
+		client := proto.NewMhistClient(conn)
+		query = func(start, end int64) (map[string][]models.Measurement, error) {
+			response, err := client.Retrieve(context.Background(), &proto.RetrieveRequest{
+				Start:  start,
+				End:    end,
+				Filter: &proto.Filter{Names: filter.Names},
+			})
+			if err != nil {
+				return nil, err
+			}
+			for _, peerError := range response.PeerErrors {
+				log.Printf("peer %v: %v", peerError.Peer, peerError.Error)
+			}
+			return response.ToMeasurementMap(), nil
+		}
+	}
+
+	start := time.Now()
+	exported, err := mhist.ExportInWindows(writer, filter, query, reportProgress("exported", start))
+	if err != nil {
+		log.Fatalf("export failed after %v measurements: %v", exported, err)
+	}
+	err = finish()
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("exported %v measurements in %v", exported, time.Since(start))
+}
+
+func openDB(flags *bulkFlags) (*mhist.DB, error) {
+	return mhist.Open(flags.dataPath, mhist.Options{
+		MemorySize:    flags.memorySize,
+		DiskSize:      flags.diskSize,
+		ReorderWindow: flags.reorderWindow,
+	})
+}
+
+func reportProgress(verb string, start time.Time) func(n int) {
+	return func(n int) {
+		if n%progressInterval != 0 {
+			return
+		}
+		elapsed := time.Since(start)
+		log.Printf("%s %v measurements (%.0f/s)", verb, n, float64(n)/elapsed.Seconds())
+	}
+}
@@ -0,0 +1,226 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/alexmorten/mhist"
+	"gopkg.in/yaml.v2"
+)
+
+// loadServerConfig from the flags in args, the environment and the config file, in this order of precedence.
+// It is called again on SIGHUP to reload the config
+func loadServerConfig(args []string, errorHandling flag.ErrorHandling) (mhist.ServerConfig, error) {
+	config := mhist.ServerConfig{}
+	set := flag.NewFlagSet("mhist", errorHandling)
+	configFile := set.String("config", "", "defines a YAML config file with settings named like the flags, flags and MHIST_<FLAG> environment variables override it. Reloaded on SIGHUP")
+	set.IntVar(&config.GrpcPort, "grpc_port", 6666, "defines the port on which the grpc handler operates")
+	set.IntVar(&config.DebugPort, "debug_port", 6667, "defines the port on which the debug handler operates")
+	set.IntVar(&config.MemorySize, "memory_size", 32*1024*1024, "defines the amount of memory the memory store and the cache of recently read files limit themselves to. Keep in mind that especially GET request can spike the actual memory usage of the process")
+	set.StringVar(&config.DataPath, "data_path", "data", "defines the directory the measurements are stored in")
+	set.StringVar(&config.Backend, "backend", mhist.BackendDisk, "defines where measurements are stored, either disk or memory (lost on shutdown, limited by disk_size)")
+	set.IntVar(&config.DiskSize, "disk_size", 512*1024*1024, "defines the amount of disk space mhist should occupy")
+	set.IntVar(&config.CommitSize, "commit_size", 128*1024, "defines the amount of bytes written before they are committed to disk")
+	set.DurationVar(&config.CommitInterval, "commit_interval", 20*time.Second, "defines the interval in which written measurements are committed to disk")
+	set.DurationVar(&config.ShutdownTimeout, "shutdown_timeout", 10*time.Second, "defines how long running requests are waited for on shutdown before they are cancelled, subscriptions and store streams are ended right away")
+	set.DurationVar(&config.RetrieveWindow, "retrieve_window", time.Hour, "defines how far back Retrieve reads if the request has no start. Reloaded on SIGHUP")
+
+	set.DurationVar(&config.ReorderWindow, "reorder_window", 0, "defines how long measurements are held back in memory to be written in timestamp order, older measurements are written to backfill files")
+
+	archivePath := set.String("archive_path", "", "defines a directory old rotated files are moved to, e.g. a mounted network drive")
+	s3Endpoint := set.String("archive_s3_endpoint", "", "defines an S3 compatible endpoint old rotated files are moved to, credentials are read from AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY")
+	s3Bucket := set.String("archive_s3_bucket", "", "defines the bucket old rotated files are moved to at archive_s3_endpoint")
+	s3Region := set.String("archive_s3_region", "us-east-1", "defines the region of archive_s3_bucket")
+	set.DurationVar(&config.ArchiveAfter, "archive_after", 0, "defines the age after which rotated files are moved to the archive, with 0 files are only archived when disk_size is exceeded")
+
+	set.StringVar(&config.Leader, "leader", "", "defines the grpc address of an mhist to replicate from, this mhist only serves reads then")
+	set.StringVar(&config.FollowerName, "follower_name", "", "defines the name this follower reports to its leader, the hostname by default")
+
+	peers := set.String("peers", "", "defines peers as comma separated name=address pairs, Retrieve, Subscribe and ListSeries are fanned out to them")
+	set.DurationVar(&config.PeerTimeout, "peer_timeout", 5*time.Second, "defines how long peers are waited for, results of slower peers are missing and reported as peer errors")
+	set.BoolVar(&config.PrefixPeers, "prefix_peers", false, "defines whether series of peers are prefixed with the peer name and a slash, otherwise series with the same name are merged")
+
+	tlsConfig := mhist.TLSConfig{}
+	set.StringVar(&tlsConfig.CertFile, "tls_cert", "", "defines the certificate file of the grpc and debug listeners, they use TLS if it is set. Reloaded on SIGHUP")
+	set.StringVar(&tlsConfig.KeyFile, "tls_key", "", "defines the key file of tls_cert")
+	set.StringVar(&tlsConfig.ClientCAFile, "tls_client_ca", "", "defines a CA certificate file clients certificates are verified with, clients need a certificate if it is set. Reloaded on SIGHUP")
+	set.StringVar(&tlsConfig.CAFile, "tls_ca", "", "defines a CA certificate file the certificates of peers, cluster nodes and the leader are verified with, the system roots by default")
+
+	authConfig := mhist.AuthConfig{}
+	set.StringVar(&authConfig.TokenFile, "auth_tokens", "", "defines a file of static tokens, one per line followed by its scopes, e.g. \"abc write:kitchen/* read:*\". Requests need a token if it or auth_secret is set")
+	set.StringVar(&authConfig.SecretFile, "auth_secret", "", "defines a file with the secret tokens created by the token command are verified with")
+	set.StringVar(&authConfig.PeerToken, "peer_token", "", "defines the token sent to peers, cluster nodes and the leader")
+
+	set.IntVar(&config.ConnectionRate, "connection_rate", 0, "defines how many measurements per second can be written over a connection, writes beyond it fail with ResourceExhausted and a retry delay. 0 doesn't limit them")
+	set.IntVar(&config.SeriesRate, "series_rate", 0, "defines how many measurements per second can be written to a series, 0 doesn't limit them")
+	set.DurationVar(&config.QueueTimeout, "queue_timeout", 0, "defines how long writes wait for the busy store before they fail with ResourceExhausted, 0 waits as long as it takes")
+	set.IntVar(&config.Cardinality.MaxSeries, "max_series", 0, "defines how many series can be created, 0 doesn't limit them")
+	set.IntVar(&config.Cardinality.MaxSeriesPerPrefix, "max_series_per_prefix", 0, "defines how many series can share the part of their name before the prefix separator, 0 doesn't limit them")
+	set.StringVar(&config.Cardinality.PrefixSeparator, "prefix_separator", mhist.DefaultPrefixSeparator, "defines the end of the prefix of series names for max_series_per_prefix")
+	set.IntVar(&config.Cardinality.MaxCategoricalValues, "max_categorical_values", 0, "defines how many distinct values a categorical series can have, 0 doesn't limit them")
+
+	tenants := set.Bool("tenants", false, "defines whether requests can select a tenant with the mhist-tenant header or a token bound to a tenant, each tenant is stored in data_path/tenants/<tenant>")
+	tenantConfig := mhist.TenantConfig{}
+	tenantNames := set.String("tenant_names", "", "defines the comma separated tenants that can be used in addition to the ones in tenant_quotas, requests for other tenants fail with NotFound")
+	set.IntVar(&tenantConfig.DefaultQuota.DiskSize, "tenant_disk_size", 0, "defines the disk_size of each tenant, the disk_size of the server by default")
+	set.IntVar(&tenantConfig.DefaultQuota.IngestRate, "tenant_ingest_rate", 0, "defines how many measurements per second each tenant can write, 0 doesn't limit writes")
+	tenantQuotas := set.String("tenant_quotas", "", "defines quotas of single tenants as comma separated name=disk_size:ingest_rate entries, overriding tenant_disk_size and tenant_ingest_rate")
+
+	clusterNodes := set.String("cluster_nodes", "", "defines all nodes of a cluster as comma separated name=address pairs, including this one. Series are sharded across them by name")
+	set.StringVar(&config.ClusterSelf, "cluster_self", "", "defines the name of this node in cluster_nodes")
+
+	err := parseSettings(set, args, configFile)
+	if err != nil {
+		return mhist.ServerConfig{}, err
+	}
+	config.Peers, err = mhist.ParsePeers(*peers)
+	if err != nil {
+		return mhist.ServerConfig{}, err
+	}
+	config.ClusterNodes, err = mhist.ParsePeers(*clusterNodes)
+	if err != nil {
+		return mhist.ServerConfig{}, err
+	}
+	if tlsConfig.CertFile != "" {
+		config.TLS = &tlsConfig
+	}
+	if authConfig.TokenFile != "" || authConfig.SecretFile != "" {
+		config.Auth = &authConfig
+	}
+	if *tenants {
+		tenantConfig.Quotas, err = mhist.ParseTenantQuotas(*tenantQuotas)
+		if err != nil {
+			return mhist.ServerConfig{}, err
+		}
+		if *tenantNames != "" {
+			tenantConfig.Names = strings.Split(*tenantNames, ",")
+		}
+		config.Tenants = &tenantConfig
+	}
+	switch {
+	case *archivePath != "":
+		archive, err := mhist.NewDirArchive(*archivePath)
+		if err != nil {
+			return mhist.ServerConfig{}, err
+		}
+		config.Archive = archive
+	case *s3Endpoint != "":
+		config.Archive = mhist.NewS3Archive(*s3Endpoint, *s3Bucket, *s3Region, os.Getenv("AWS_ACCESS_KEY_ID"), os.Getenv("AWS_SECRET_ACCESS_KEY"))
+	}
+
+	return config, nil
+}
+
+// envPrefix of the environment variables overriding the config file, e.g. MHIST_GRPC_PORT for -grpc_port
+const envPrefix = "MHIST_"
+
+// parseSettings of the command line, settings missing there are taken from the environment and then from the config file
+func parseSettings(set *flag.FlagSet, args []string, configFile *string) error {
+	err := set.Parse(args)
+	if err != nil {
+		return err
+	}
+	explicit := map[string]bool{}
+	set.Visit(func(f *flag.Flag) {
+		explicit[f.Name] = true
+	})
+
+	var envErr error
+	set.VisitAll(func(f *flag.Flag) {
+		value, ok := os.LookupEnv(envPrefix + strings.ToUpper(f.Name))
+		if !ok || explicit[f.Name] || envErr != nil {
+			return
+		}
+		if err := set.Set(f.Name, value); err != nil {
+			envErr = fmt.Errorf("%v%v=%q: %w", envPrefix, strings.ToUpper(f.Name), value, err)
+			return
+		}
+		explicit[f.Name] = true
+	})
+	if envErr != nil || *configFile == "" {
+		return envErr
+	}
+
+	values, err := readConfigFile(*configFile)
+	if err != nil {
+		return err
+	}
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if set.Lookup(name) == nil || name == "config" {
+			return fmt.Errorf("%v: unknown setting %q", *configFile, name)
+		}
+		if explicit[name] {
+			continue
+		}
+		if err := set.Set(name, values[name]); err != nil {
+			return fmt.Errorf("%v: %v: %w", *configFile, name, err)
+		}
+	}
+	return nil
+}
+
+// readConfigFile of settings named like the flags. They can be grouped in sections of any name, e.g. limits,
+// lists are joined with commas
+func readConfigFile(path string) (map[string]string, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	settings := map[interface{}]interface{}{}
+	err = yaml.Unmarshal(b, &settings)
+	if err != nil {
+		return nil, fmt.Errorf("%v: %w", path, err)
+	}
+	values := map[string]string{}
+	return values, flattenSettings(path, settings, values)
+}
+
+func flattenSettings(path string, settings map[interface{}]interface{}, values map[string]string) error {
+	for key, value := range settings {
+		name := fmt.Sprint(key)
+		if section, ok := value.(map[interface{}]interface{}); ok {
+			err := flattenSettings(path, section, values)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		if _, ok := values[name]; ok {
+			return fmt.Errorf("%v: %v is set more than once", path, name)
+		}
+
+		if list, ok := value.([]interface{}); ok {
+			items := make([]string, len(list))
+			for i, item := range list {
+				items[i] = settingValue(item)
+			}
+			values[name] = strings.Join(items, ",")
+			continue
+		}
+		values[name] = settingValue(value)
+	}
+	return nil
+}
+
+// settingValue as it would be written on the command line, large numbers aren't written in exponent notation
+func settingValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		return fmt.Sprint(v)
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"flag"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/alexmorten/mhist"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_LoadServerConfig(t *testing.T) {
+	configFile := "test_config.yaml"
+	defer os.Remove(configFile)
+	writeConfig := func(t *testing.T, content string) {
+		require.NoError(t, ioutil.WriteFile(configFile, []byte(content), 0600))
+	}
+
+	t.Run("sections and lists of the config file are flattened", func(t *testing.T) {
+		writeConfig(t, `
+grpc_port: 7000
+limits:
+  series_rate: 10
+  max_series: 10000000
+storage:
+  commit_interval: 5s
+peers:
+  - a=localhost:7001
+  - b=localhost:7002
+`)
+		config, err := loadServerConfig([]string{"-config", configFile}, flag.ContinueOnError)
+		require.NoError(t, err)
+		assert.Equal(t, 7000, config.GrpcPort)
+		assert.Equal(t, 10, config.SeriesRate)
+		assert.Equal(t, 10000000, config.Cardinality.MaxSeries)
+		assert.Equal(t, 5*time.Second, config.CommitInterval)
+		assert.Equal(t, []mhist.Peer{{Name: "a", Address: "localhost:7001"}, {Name: "b", Address: "localhost:7002"}}, config.Peers)
+	})
+
+	t.Run("flags override the environment, which overrides the config file", func(t *testing.T) {
+		writeConfig(t, "grpc_port: 7000\ndebug_port: 7001\nseries_rate: 10\n")
+		os.Setenv("MHIST_GRPC_PORT", "8000")
+		os.Setenv("MHIST_DEBUG_PORT", "8001")
+		defer os.Unsetenv("MHIST_GRPC_PORT")
+		defer os.Unsetenv("MHIST_DEBUG_PORT")
+
+		config, err := loadServerConfig([]string{"-config", configFile, "-grpc_port", "9000"}, flag.ContinueOnError)
+		require.NoError(t, err)
+		assert.Equal(t, 9000, config.GrpcPort)
+		assert.Equal(t, 8001, config.DebugPort)
+		assert.Equal(t, 10, config.SeriesRate)
+	})
+
+	t.Run("invalid environment variables are rejected", func(t *testing.T) {
+		os.Setenv("MHIST_GRPC_PORT", "not a port")
+		defer os.Unsetenv("MHIST_GRPC_PORT")
+
+		_, err := loadServerConfig(nil, flag.ContinueOnError)
+		assert.Error(t, err)
+	})
+
+	t.Run("unknown settings are rejected", func(t *testing.T) {
+		writeConfig(t, "limits:\n  series_rat: 10\n")
+		_, err := loadServerConfig([]string{"-config", configFile}, flag.ContinueOnError)
+		assert.EqualError(t, err, configFile+`: unknown setting "series_rat"`)
+
+		writeConfig(t, "config: other.yaml\n")
+		_, err = loadServerConfig([]string{"-config", configFile}, flag.ContinueOnError)
+		assert.Error(t, err)
+	})
+
+	t.Run("settings that are set more than once are rejected", func(t *testing.T) {
+		writeConfig(t, "series_rate: 10\nlimits:\n  series_rate: 20\n")
+		_, err := loadServerConfig([]string{"-config", configFile}, flag.ContinueOnError)
+		assert.Error(t, err)
+	})
+}
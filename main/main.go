@@ -2,20 +2,105 @@ package main
 
 import (
 	"flag"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
 
 	_ "net/http/pprof" //pprof for performance analysis
 
 	"github.com/alexmorten/mhist"
+	"google.golang.org/grpc"
 )
 
 func main() {
-	config := mhist.ServerConfig{}
-	flag.IntVar(&config.GrpcPort, "grpc_port", 6666, "defines the port on which the grpc handler operates")
-	flag.IntVar(&config.DebugPort, "debug_port", 6667, "defines the port on which the debug handler operates")
-	flag.IntVar(&config.MemorySize, "memory_size", 32*1024*1024, "defines the amount of memory the memory store limits itself to. Keep in mind that especially GET request can spike the actual memory usage of the process")
-	flag.IntVar(&config.DiskSize, "disk_size", 512*1024*1024, "defines the amount of disk space mhist should occupy")
-
-	flag.Parse()
-	server := mhist.NewServer(config)
-	server.Run()
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "import":
+			runImport(os.Args[2:])
+			return
+		case "export":
+			runExport(os.Args[2:])
+			return
+		case "snapshot":
+			runSnapshot(os.Args[2:])
+			return
+		case "restore":
+			runRestore(os.Args[2:])
+			return
+		case "rebalance":
+			runRebalance(os.Args[2:])
+			return
+		case "token":
+			runToken(os.Args[2:])
+			return
+		}
+	}
+
+	config, err := loadServerConfig(os.Args[1:], flag.ExitOnError)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	server, err := mhist.NewServer(config)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
+	go func() {
+		for signal := range signals {
+			if signal == syscall.SIGHUP {
+				reloadConfig(server)
+				continue
+			}
+			log.Printf("received %s, shutting down\n", signal)
+			server.Shutdown()
+			return
+		}
+	}()
+
+	err = server.Run()
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Println("shut down")
+}
+
+// reloadConfig from the same flags, the current environment and the config file
+func reloadConfig(server *mhist.Server) {
+	config, err := loadServerConfig(os.Args[1:], flag.ContinueOnError)
+	if err == nil {
+		err = server.Reload(config)
+	}
+	if err != nil {
+		log.Printf("couldn't reload the config: %v\n", err)
+		return
+	}
+	log.Println("reloaded the config")
+}
+
+// clientFlags of the commands connecting to a running mhist
+type clientFlags struct {
+	tls   mhist.ClientTLSConfig
+	token string
+}
+
+func (f *clientFlags) register(set *flag.FlagSet) {
+	f.tls.RegisterFlags(set)
+	set.StringVar(&f.token, "token", "", "token sent with every request, needed if the server requires authentication")
+}
+
+// dial a running mhist, with TLS and the token if they are configured
+func (f *clientFlags) dial(address string) (*grpc.ClientConn, error) {
+	option, err := f.tls.DialOption()
+	if err != nil {
+		return nil, err
+	}
+	options := []grpc.DialOption{option}
+	if f.token != "" {
+		options = append(options, grpc.WithPerRPCCredentials(mhist.TokenCredentials(f.token)))
+	}
+	return grpc.Dial(address, options...)
 }
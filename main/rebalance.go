@@ -0,0 +1,32 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"strings"
+
+	"github.com/alexmorten/mhist/proto"
+)
+
+func runRebalance(args []string) {
+	set := flag.NewFlagSet("rebalance", flag.ExitOnError)
+	addresses := set.String("addresses", "localhost:6666", "comma separated addresses of the cluster nodes to rebalance, all nodes after the cluster_nodes of every node were updated")
+	client := clientFlags{}
+	client.register(set)
+	set.Parse(args)
+
+	for _, address := range strings.Split(*addresses, ",") {
+		conn, err := client.dial(address)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		response, err := proto.NewMhistClient(conn).Rebalance(context.Background(), &proto.RebalanceRequest{})
+		conn.Close()
+		if err != nil {
+			log.Fatalf("rebalancing %v failed: %v", address, err)
+		}
+		log.Printf("moved %v series with %v measurements from %v", response.MovedSeries, response.MovedMeasurements, address)
+	}
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"io"
+	"log"
+	"os"
+
+	"github.com/alexmorten/mhist"
+	"github.com/alexmorten/mhist/proto"
+)
+
+func runSnapshot(args []string) {
+	set := flag.NewFlagSet("snapshot", flag.ExitOnError)
+	address := set.String("address", "localhost:6666", "address of the running mhist")
+	file := set.String("file", "-", "file the tar archive is written to, - for stdout")
+	dir := set.String("dir", "", "directory in <data_path>/snapshots on the server the snapshot is written to instead of streaming it, must not exist or be empty")
+	client := clientFlags{}
+	client.register(set)
+	set.Parse(args)
+
+	conn, err := client.dial(*address)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer conn.Close()
+
+	stream, err := proto.NewMhistClient(conn).Snapshot(context.Background(), &proto.SnapshotRequest{Dir: *dir})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	output := os.Stdout
+	if *file != "-" && *dir == "" {
+		f, err := os.Create(*file)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer f.Close()
+		output = f
+	}
+
+	written := 0
+	for {
+		chunk, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+		n, err := output.Write(chunk.Data)
+		if err != nil {
+			log.Fatal(err)
+		}
+		written += n
+	}
+
+	if *dir != "" {
+		log.Printf("wrote snapshot to <data_path>/snapshots/%v on the server", *dir)
+		return
+	}
+	err = output.Sync()
+	if err != nil && *file != "-" {
+		log.Fatal(err)
+	}
+	log.Printf("wrote snapshot of %v bytes", written)
+}
+
+func runRestore(args []string) {
+	set := flag.NewFlagSet("restore", flag.ExitOnError)
+	file := set.String("file", "-", "tar archive of a snapshot, - for stdin")
+	dir := set.String("dir", "", "snapshot directory to restore instead of a tar archive")
+	dataPath := set.String("data_path", "data", "data directory the snapshot is installed into, must not exist or be empty")
+	set.Parse(args)
+
+	var err error
+	switch {
+	case *dir != "":
+		err = mhist.RestoreSnapshotDir(*dir, *dataPath)
+	case *file == "-":
+		err = mhist.RestoreSnapshot(os.Stdin, *dataPath)
+	default:
+		f, openErr := os.Open(*file)
+		if openErr != nil {
+			log.Fatal(openErr)
+		}
+		err = mhist.RestoreSnapshot(f, *dataPath)
+		f.Close()
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("restored snapshot into %v", *dataPath)
+}
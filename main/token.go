@@ -0,0 +1,35 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/alexmorten/mhist"
+)
+
+func runToken(args []string) {
+	set := flag.NewFlagSet("token", flag.ExitOnError)
+	secretFile := set.String("auth_secret", "", "file with the secret the server verifies tokens with")
+	scopes := set.String("scopes", "", "comma separated scopes of the form permission:pattern, permission is read, write, subscribe or admin and * in the pattern matches any characters, e.g. write:kitchen/*,read:*")
+	ttl := set.Duration("ttl", 0, "time after which the token expires, it never expires with 0")
+	set.Parse(args)
+
+	secret, err := ioutil.ReadFile(*secretFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+	expires := time.Time{}
+	if *ttl > 0 {
+		expires = time.Now().Add(*ttl)
+	}
+
+	token, err := mhist.SignToken([]byte(strings.TrimSpace(string(secret))), strings.Split(*scopes, ","), expires)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(token)
+}
@@ -0,0 +1,167 @@
+package mhist
+
+import (
+	"errors"
+	"math"
+	"os"
+	"path/filepath"
+)
+
+//ErrMaintenanceNotSupported is returned for file maintenance of backends that don't store measurements in files
+var ErrMaintenanceNotSupported = errors.New("the backend doesn't store measurements in files")
+
+//DataFileInfo describes a data file with the time range of its measurements
+type DataFileInfo struct {
+	Name     string `json:"name"`
+	OldestTs int64  `json:"oldest_ts"`
+	LatestTs int64  `json:"latest_ts"`
+	// IndexSize and ValueLogSize in bytes, unknown for archived files
+	IndexSize    int64 `json:"index_size"`
+	ValueLogSize int64 `json:"value_log_size"`
+	// Current is set for the files that are still written to, the current and the backfill file
+	Current  bool `json:"current,omitempty"`
+	Archived bool `json:"archived,omitempty"`
+}
+
+//WriterState of the files that are written to
+type WriterState struct {
+	FirstWrittenTs int64 `json:"first_written_ts"`
+	LastWrittenTs  int64 `json:"last_written_ts"`
+	// BytesSinceCommit are written to the files but not synced to disk yet
+	BytesSinceCommit int64 `json:"bytes_since_commit"`
+	// Buffered measurements are held back by the reorder buffer
+	Buffered int    `json:"buffered"`
+	Health   Health `json:"health"`
+}
+
+// maintainer is implemented by backends storing their measurements in files
+type maintainer interface {
+	Rotate() error
+	Compact() error
+	DataFiles() ([]DataFileInfo, error)
+	WriterState() WriterState
+}
+
+// inspection of the writer taken in the Listen goroutine
+type inspection struct {
+	state WriterState
+	files FileInfoSlice
+	// current are the names of the files that are still written to
+	current map[string]bool
+	err     error
+}
+
+//Rotate the current and backfill files after committing everything, so they are compacted and archived like full ones
+func (s *DiskStore) Rotate() error {
+	done := make(chan error)
+	s.rotateChan <- done
+	return <-done
+}
+
+//WriterState of the files that are written to
+func (s *DiskStore) WriterState() WriterState {
+	return s.requestInspection().state
+}
+
+//DataFiles ordered by their time range, including archived ones and the ones that are still written to
+func (s *DiskStore) DataFiles() ([]DataFileInfo, error) {
+	result := s.requestInspection()
+	if result.err != nil {
+		return nil, result.err
+	}
+
+	files := make([]DataFileInfo, 0, len(result.files))
+	for _, file := range result.files {
+		info := DataFileInfo{
+			Name:     filepath.Base(file.indexName()),
+			OldestTs: file.oldestTs,
+			LatestTs: file.latestTs,
+			Current:  result.current[file.indexName()],
+			Archived: file.archived,
+		}
+		if !file.archived {
+			info.IndexSize = file.size
+			// the value log is read outside of the Listen goroutine, it might be removed by rotation in the meantime
+			if stat, err := os.Stat(file.valueLogName()); err == nil {
+				info.ValueLogSize = stat.Size()
+			}
+		}
+		files = append(files, info)
+	}
+	return files, nil
+}
+
+func (s *DiskStore) requestInspection() inspection {
+	resultChan := make(chan inspection, 1)
+	s.inspectChan <- resultChan
+	return <-resultChan
+}
+
+// inspect is called in the Listen goroutine
+func (s *DiskStore) inspect() inspection {
+	result := inspection{
+		state: WriterState{
+			FirstWrittenTs:   s.firstWrittenTs,
+			LastWrittenTs:    s.lastWrittenTs,
+			BytesSinceCommit: s.bytesWrittenSinceLastCommit,
+			Buffered:         len(s.reorderBuffer.messages),
+			Health:           s.health(),
+		},
+		current: map[string]bool{
+			s.dataFile.indexWriter.Name(): true,
+			s.backfill.indexWriter.Name(): true,
+		},
+	}
+	files, err := s.getFilesInTimeRange(math.MinInt64, math.MaxInt64)
+	if err != nil {
+		result.err = storageError(err)
+		return result
+	}
+	result.files = files
+	return result
+}
+
+// Rotate the files that are written to, so they are compacted and archived like full ones
+func (db *DB) Rotate() error {
+	return db.maintain(func(backend maintainer) error {
+		return backend.Rotate()
+	})
+}
+
+// Compact the data files now instead of waiting for the background compactor
+func (db *DB) Compact() error {
+	return db.maintain(func(backend maintainer) error {
+		return backend.Compact()
+	})
+}
+
+// DataFiles of the DB ordered by their time range
+func (db *DB) DataFiles() ([]DataFileInfo, error) {
+	var files []DataFileInfo
+	err := db.maintain(func(backend maintainer) error {
+		var err error
+		files, err = backend.DataFiles()
+		return err
+	})
+	return files, err
+}
+
+// WriterState of the files the DB writes to
+func (db *DB) WriterState() (WriterState, error) {
+	var state WriterState
+	err := db.maintain(func(backend maintainer) error {
+		state = backend.WriterState()
+		return nil
+	})
+	return state, err
+}
+
+func (db *DB) maintain(f func(backend maintainer) error) error {
+	return db.do(func() error {
+		backend, ok := db.store.backend.(maintainer)
+		if !ok {
+			return ErrMaintenanceNotSupported
+		}
+		return f(backend)
+	})
+}
@@ -0,0 +1,130 @@
+package mhist
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alexmorten/mhist/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Maintenance(t *testing.T) {
+	dataPath := "test_data"
+	defer os.RemoveAll(dataPath)
+
+	tokenFile := "test_maintenance_tokens"
+	require.NoError(t, ioutil.WriteFile(tokenFile, []byte("root admin:*\n"), 0600))
+	defer os.Remove(tokenFile)
+
+	server, err := NewServer(ServerConfig{DataPath: dataPath, GrpcPort: 16770, Auth: &AuthConfig{TokenFile: tokenFile}})
+	require.NoError(t, err)
+	defer server.Shutdown()
+	handler := server.debugHandler.handler()
+
+	request := func(method, path string, token string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, path, nil)
+		if token != "" {
+			r.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w
+	}
+
+	t.Run("the server is ready once the grpc port is listened on", func(t *testing.T) {
+		w := request(http.MethodGet, "/readyz", "")
+		assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+		readiness := Readiness{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &readiness))
+		assert.Equal(t, ReadinessCheck{Name: "grpc_listener", Error: "the grpc port isn't listened on"}, readiness.Checks[0])
+
+		go server.grpcHandler.Run()
+		for i := 0; i < 100 && atomic.LoadInt32(&server.grpcHandler.listening) == 0; i++ {
+			time.Sleep(10 * time.Millisecond)
+		}
+		assert.Equal(t, http.StatusOK, request(http.MethodGet, "/readyz", "").Code)
+		assert.Equal(t, http.StatusOK, request(http.MethodGet, "/healthz", "").Code)
+	})
+
+	t.Run("admin endpoints need an admin token", func(t *testing.T) {
+		assert.Equal(t, http.StatusUnauthorized, request(http.MethodGet, "/admin/files", "").Code)
+		assert.Equal(t, http.StatusMethodNotAllowed, request(http.MethodGet, "/admin/flush", "root").Code)
+	})
+
+	require.NoError(t, server.db.Write("temperature", &models.Numerical{Ts: 1000, Value: 21}))
+
+	t.Run("flushing commits everything written", func(t *testing.T) {
+		assert.Equal(t, http.StatusNoContent, request(http.MethodPost, "/admin/flush", "root").Code)
+
+		w := request(http.MethodGet, "/admin/writer", "root")
+		require.Equal(t, http.StatusOK, w.Code)
+		state := WriterState{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &state))
+		assert.Equal(t, WriterState{FirstWrittenTs: 1000, LastWrittenTs: 1000, Health: Health{Writable: true}}, state)
+	})
+
+	t.Run("rotated files are listed with their time range", func(t *testing.T) {
+		assert.Equal(t, http.StatusNoContent, request(http.MethodPost, "/admin/rotate", "root").Code)
+		assert.Equal(t, http.StatusNoContent, request(http.MethodPost, "/admin/compact", "root").Code)
+
+		w := request(http.MethodGet, "/admin/files", "root")
+		require.Equal(t, http.StatusOK, w.Code)
+		files := []DataFileInfo{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &files))
+		rotated := []DataFileInfo{}
+		for _, file := range files {
+			if !file.Current {
+				rotated = append(rotated, file)
+			}
+		}
+		require.Len(t, rotated, 1)
+		assert.Equal(t, int64(1000), rotated[0].OldestTs)
+		assert.Equal(t, int64(1000), rotated[0].LatestTs)
+		assert.Equal(t, int64(serializedMeasurementSize), rotated[0].IndexSize)
+
+		result, err := server.db.Query(0, 2000, models.FilterDefinition{})
+		require.NoError(t, err)
+		assert.Len(t, result["temperature"], 1)
+	})
+
+	t.Run("rotating a file with the time range of an earlier one keeps both", func(t *testing.T) {
+		require.NoError(t, server.db.Write("temperature", &models.Numerical{Ts: 1000, Value: 22}))
+		assert.Equal(t, http.StatusNoContent, request(http.MethodPost, "/admin/rotate", "root").Code)
+
+		files, err := server.db.DataFiles()
+		require.NoError(t, err)
+		rotated := 0
+		for _, file := range files {
+			if !file.Current {
+				rotated++
+			}
+		}
+		assert.Equal(t, 2, rotated)
+
+		result, err := server.db.Query(0, 2000, models.FilterDefinition{})
+		require.NoError(t, err)
+		assert.Len(t, result["temperature"], 2)
+	})
+
+	t.Run("the full health needs an admin token", func(t *testing.T) {
+		assert.Equal(t, http.StatusUnauthorized, request(http.MethodGet, "/admin/health", "").Code)
+		assert.Equal(t, http.StatusOK, request(http.MethodGet, "/admin/health", "root").Code)
+	})
+}
+
+func Test_MaintenanceOfMemoryBackend(t *testing.T) {
+	db, err := Open("", Options{Backend: BackendMemory})
+	require.NoError(t, err)
+	defer db.Close()
+
+	assert.Equal(t, ErrMaintenanceNotSupported, db.Rotate())
+	_, err = db.DataFiles()
+	assert.Equal(t, ErrMaintenanceNotSupported, err)
+}
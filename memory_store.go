@@ -0,0 +1,141 @@
+package mhist
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/alexmorten/mhist/models"
+)
+
+//MemoryStore is a Backend keeping all measurements in memory, e.g. for tests or ephemeral deployments.
+//The oldest measurements are dropped once maxSize bytes are used.
+type MemoryStore struct {
+	seriesAdministration
+
+	maxSize int64
+	size    int64
+	// measurements sorted by timestamp
+	measurements []addMessage
+
+	mutex sync.RWMutex
+}
+
+//NewMemoryStore that keeps up to maxSize bytes of measurements
+func NewMemoryStore(maxSize int) *MemoryStore {
+	return &MemoryStore{
+		seriesAdministration: seriesAdministration{meta: NewDiskMeta()},
+		maxSize:              int64(maxSize),
+	}
+}
+
+//Add measurement, fails if the type doesn't match the type of the series
+func (s *MemoryStore) Add(name string, measurement models.Measurement) error {
+	message, err := s.serialize(name, measurement)
+	if err != nil {
+		return err
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	ts := message.measurement.Ts
+	i := sort.Search(len(s.measurements), func(i int) bool { return s.measurements[i].measurement.Ts > ts })
+	s.measurements = append(s.measurements, addMessage{})
+	copy(s.measurements[i+1:], s.measurements[i:])
+	s.measurements[i] = message
+
+	s.size += messageSize(message)
+	dropped := 0
+	for s.size > s.maxSize && dropped < len(s.measurements) {
+		s.size -= messageSize(s.measurements[dropped])
+		dropped++
+	}
+	if dropped > 0 {
+		// the front is cut off without copying, the remaining measurements are only copied once append grows the slice
+		for j := 0; j < dropped; j++ {
+			s.measurements[j] = addMessage{}
+		}
+		s.measurements = s.measurements[dropped:]
+	}
+	return nil
+}
+
+//GetMeasurementsInTimeRange for all measurement names passing the filter
+func (s *MemoryStore) GetMeasurementsInTimeRange(start, end int64, filterDefinition models.FilterDefinition) (map[string][]models.Measurement, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	filter := models.NewFilterCollection(filterDefinition)
+	result := readResult{}
+	from := sort.Search(len(s.measurements), func(i int) bool { return s.measurements[i].measurement.Ts >= start })
+	to := sort.Search(len(s.measurements), func(i int) bool { return s.measurements[i].measurement.Ts > end })
+	if to < from {
+		to = from
+	}
+	inRange := s.measurements[from:to]
+	serialized := make([]SerializedMeasurement, len(inRange))
+	for i, message := range inRange {
+		serialized[i] = message.measurement
+	}
+
+	view := s.meta.view(serialized, filterDefinition)
+	for _, message := range inRange {
+		rawValue := message.rawValue
+		name, measurement, err := view.decode(message.measurement, func(SerializedMeasurement) ([]byte, error) { return rawValue, nil })
+		if err != nil {
+			return nil, err
+		}
+		if measurement != nil && filter.Passes(name, measurement) {
+			result[name] = append(result[name], measurement)
+		}
+	}
+	return result, nil
+}
+
+//DeleteSeries with all its measurements
+func (s *MemoryStore) DeleteSeries(names []string) error {
+	err := s.seriesAdministration.DeleteSeries(names)
+	if err != nil {
+		return err
+	}
+	return s.compact()
+}
+
+//DeleteRange of measurements of the named series, an end of 0 deletes everything from start on
+func (s *MemoryStore) DeleteRange(names []string, start, end int64) error {
+	err := s.seriesAdministration.DeleteRange(names, start, end)
+	if err != nil {
+		return err
+	}
+	return s.compact()
+}
+
+// compact drops deleted measurements right away, there is no need to wait for a background compactor
+func (s *MemoryStore) compact() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	generation := s.meta.GetDeletionGeneration()
+	remaining := s.measurements[:0]
+	for _, message := range s.measurements {
+		if s.meta.IsDeleted(message.measurement.ID, message.measurement.Ts) {
+			s.size -= messageSize(message)
+			continue
+		}
+		remaining = append(remaining, message)
+	}
+	s.measurements = remaining
+	return s.meta.CompactionDone(generation)
+}
+
+//Flush does nothing, measurements are readable as soon as they are added
+func (s *MemoryStore) Flush() error {
+	return nil
+}
+
+//Shutdown does nothing, the measurements are gone with the process
+func (s *MemoryStore) Shutdown() {}
+
+func messageSize(message addMessage) int64 {
+	return int64(serializedMeasurementSize + len(message.rawValue))
+}
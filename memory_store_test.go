@@ -0,0 +1,64 @@
+package mhist
+
+import (
+	"testing"
+
+	"github.com/alexmorten/mhist/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_MemoryStore(t *testing.T) {
+	store := NewMemoryStore(1024 * 1024)
+
+	require.NoError(t, store.Add("a", &models.Numerical{Ts: 3000, Value: 3}))
+	require.NoError(t, store.Add("a", &models.Numerical{Ts: 1000, Value: 1}))
+	require.NoError(t, store.Add("b", &models.Categorical{Ts: 2000, Value: "x"}))
+	require.NoError(t, store.Add("c", &models.Raw{Ts: 2000, Value: []byte("raw")}))
+	assert.Error(t, store.Add("a", &models.Categorical{Ts: 4000, Value: "y"}))
+
+	t.Run("reads measurements in timestamp order", func(t *testing.T) {
+		result, err := store.GetMeasurementsInTimeRange(1, 3000, models.FilterDefinition{})
+		require.NoError(t, err)
+		assert.Equal(t, []models.Measurement{&models.Numerical{Ts: 1000, Value: 1}, &models.Numerical{Ts: 3000, Value: 3}}, result["a"])
+		assert.Equal(t, []models.Measurement{&models.Categorical{Ts: 2000, Value: "x"}}, result["b"])
+		assert.Equal(t, []models.Measurement{&models.Raw{Ts: 2000, Value: []byte("raw")}}, result["c"])
+
+		result, err = store.GetMeasurementsInTimeRange(1500, 2500, models.FilterDefinition{Names: []string{"a", "b"}})
+		require.NoError(t, err)
+		assert.Len(t, result, 1)
+		assert.Len(t, result["b"], 1)
+	})
+
+	t.Run("administrates series like the disk store", func(t *testing.T) {
+		require.NoError(t, store.RenameSeries("b", "renamed"))
+		require.NoError(t, store.DeleteRange([]string{"a"}, 1, 1000))
+		require.NoError(t, store.DeleteSeries([]string{"c"}))
+
+		result, err := store.GetMeasurementsInTimeRange(1, 3000, models.FilterDefinition{})
+		require.NoError(t, err)
+		assert.Equal(t, []models.Measurement{&models.Numerical{Ts: 3000, Value: 3}}, result["a"])
+		assert.Len(t, result["renamed"], 1)
+		assert.NotContains(t, result, "c")
+		assert.Len(t, store.measurements, 2)
+		assert.Equal(t, int64(0), store.meta.GetDeletionGeneration())
+	})
+
+	t.Run("drops the oldest measurements when full", func(t *testing.T) {
+		small := NewMemoryStore(3 * serializedMeasurementSize)
+		for ts := int64(1); ts <= 5; ts++ {
+			require.NoError(t, small.Add("a", &models.Numerical{Ts: ts, Value: 1}))
+		}
+
+		result, err := small.GetMeasurementsInTimeRange(1, 5, models.FilterDefinition{})
+		require.NoError(t, err)
+		require.Len(t, result["a"], 3)
+		assert.Equal(t, int64(3), result["a"][0].Timestamp())
+
+		for ts := int64(6); ts <= 1000; ts++ {
+			require.NoError(t, small.Add("a", &models.Numerical{Ts: ts, Value: 1}))
+		}
+		assert.Len(t, small.measurements, 3)
+		assert.True(t, cap(small.measurements) < 16)
+	})
+}
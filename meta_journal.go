@@ -0,0 +1,282 @@
+package mhist
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"log"
+	"os"
+	"sync"
+
+	"github.com/alexmorten/mhist/models"
+)
+
+var metaJournalPath = "meta.journal"
+var metaSnapshotPath = "meta.snapshot"
+
+// entries in the journal until the meta is snapshotted and the journal is truncated
+const metaSnapshotInterval = 10000
+
+// size of the length and checksum in front of each journal entry
+const metaEntryHeaderSize = 8
+
+var errCorruptMetaEntry = errors.New("corrupt meta journal entry")
+
+type metaOp int
+
+const (
+	metaOpCreateSeries metaOp = iota + 1
+	metaOpCreateValue
+	metaOpDeleteSeries
+	metaOpDeleteRange
+	metaOpRenameSeries
+	metaOpMergeSeries
+	metaOpMigrateSeries
+	metaOpCompactionDone
+	metaOpBatch
+)
+
+// metaEntry describes a single change to the DiskMeta, which fields are used depends on the Op
+type metaEntry struct {
+	Seq        int64
+	Op         metaOp
+	Name       string
+	NewName    string
+	ID         int64
+	TargetID   int64
+	Type       models.MeasurementType
+	Value      string
+	ValueID    float64
+	Range      TimeRange
+	Generation int64
+	// Batch of entries that are applied together
+	Batch []metaEntry
+}
+
+// metaSnapshot contains all changes up to LastSeq
+type metaSnapshot struct {
+	LastSeq int64
+	Meta    *DiskMeta
+}
+
+// metaJournal appends checksummed entries to the journal file, each entry is
+// [4 byte length][4 byte crc32 of the payload][gob encoded metaEntry]
+type metaJournal struct {
+	file                 *os.File
+	seq                  int64
+	entriesSinceSnapshot int
+
+	mutex sync.Mutex
+}
+
+// openMetaJournal replays the journal onto the meta and opens it for appending,
+// a partially written entry at the end (e.g. after a crash) is cut off. A corrupt entry followed by
+// more entries is an error, cutting it off would lose them
+func openMetaJournal(path string, meta *DiskMeta, lastSeq int64) (*metaJournal, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, os.ModePerm)
+	if err != nil {
+		return nil, err
+	}
+
+	journal := &metaJournal{file: file, seq: lastSeq}
+	validSize, err := journal.replay(meta, lastSeq)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	if info.Size() != validSize {
+		log.Printf("meta journal ends with %v bytes of a partially written entry, truncating", info.Size()-validSize)
+		err = file.Truncate(validSize)
+		if err != nil {
+			file.Close()
+			return nil, err
+		}
+	}
+
+	_, err = file.Seek(validSize, io.SeekStart)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return journal, nil
+}
+
+func (j *metaJournal) replay(meta *DiskMeta, lastSeq int64) (validSize int64, err error) {
+	info, err := j.file.Stat()
+	if err != nil {
+		return 0, err
+	}
+	reader := bufio.NewReader(j.file)
+	for {
+		entry, size, err := readMetaEntry(reader)
+		if err == errCorruptMetaEntry && validSize+size < info.Size() {
+			return validSize, fmt.Errorf("%w at byte %v of %v, followed by %v more bytes",
+				err, validSize, j.file.Name(), info.Size()-validSize-size)
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF || err == errCorruptMetaEntry {
+			return validSize, nil
+		}
+		if err != nil {
+			return validSize, err
+		}
+
+		validSize += size
+		j.entriesSinceSnapshot++
+		if entry.Seq <= lastSeq {
+			// already contained in the snapshot
+			continue
+		}
+		meta.apply(entry)
+		j.seq = entry.Seq
+	}
+}
+
+func readMetaEntry(reader io.Reader) (*metaEntry, int64, error) {
+	header := make([]byte, metaEntryHeaderSize)
+	_, err := io.ReadFull(reader, header)
+	if err != nil {
+		return nil, 0, err
+	}
+	length := binary.BigEndian.Uint32(header[:4])
+	checksum := binary.BigEndian.Uint32(header[4:])
+
+	payload := make([]byte, length)
+	_, err = io.ReadFull(reader, payload)
+	if err != nil {
+		return nil, 0, err
+	}
+	// corrupt entries still report their size, so the caller knows whether more entries follow
+	size := int64(metaEntryHeaderSize + length)
+	if crc32.ChecksumIEEE(payload) != checksum {
+		return nil, size, errCorruptMetaEntry
+	}
+
+	entry := &metaEntry{}
+	err = gob.NewDecoder(bytes.NewReader(payload)).Decode(entry)
+	if err != nil {
+		return nil, size, errCorruptMetaEntry
+	}
+	return entry, size, nil
+}
+
+// append the entry to the journal, returns true if a snapshot is due.
+// A partially written entry is cut off again, so later entries aren't appended behind it
+func (j *metaJournal) append(entry metaEntry) (bool, error) {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+
+	entry.Seq = j.seq + 1
+
+	payload := &bytes.Buffer{}
+	err := gob.NewEncoder(payload).Encode(entry)
+	if err != nil {
+		return false, err
+	}
+
+	b := make([]byte, metaEntryHeaderSize, metaEntryHeaderSize+payload.Len())
+	binary.BigEndian.PutUint32(b[:4], uint32(payload.Len()))
+	binary.BigEndian.PutUint32(b[4:], crc32.ChecksumIEEE(payload.Bytes()))
+	b = append(b, payload.Bytes()...)
+
+	size, err := j.file.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return false, err
+	}
+	_, err = j.file.Write(b)
+	if err != nil {
+		truncateErr := j.file.Truncate(size)
+		if truncateErr == nil {
+			_, truncateErr = j.file.Seek(size, io.SeekStart)
+		}
+		if truncateErr != nil {
+			log.Println("couldn't cut off the partially written meta journal entry:", truncateErr)
+		}
+		return false, err
+	}
+
+	j.seq = entry.Seq
+	j.entriesSinceSnapshot++
+	return j.entriesSinceSnapshot >= metaSnapshotInterval, nil
+}
+
+func (j *metaJournal) lastSeq() int64 {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	return j.seq
+}
+
+// truncate the journal after a snapshot containing all entries up to seq was written
+func (j *metaJournal) truncate(seq int64) error {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+
+	if j.seq != seq {
+		// entries were appended in the meantime, they are replayed on top of the snapshot next time
+		return nil
+	}
+	err := j.file.Truncate(0)
+	if err != nil {
+		return err
+	}
+	_, err = j.file.Seek(0, io.SeekStart)
+	j.entriesSinceSnapshot = 0
+	return err
+}
+
+func (j *metaJournal) sync() error {
+	return j.file.Sync()
+}
+
+func (j *metaJournal) close() error {
+	return j.file.Close()
+}
+
+// writeFileAtomically by writing to a temporary file first, so the file is either completely written or not at all
+func writeFileAtomically(path string, write func(w io.Writer) error) error {
+	tmpPath := path + ".tmp"
+	file, err := os.Create(tmpPath)
+	if err != nil {
+		return err
+	}
+
+	err = write(file)
+	if err == nil {
+		err = file.Sync()
+	}
+	closeErr := file.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+
+	return os.Rename(tmpPath, path)
+}
+
+func readMetaSnapshot(path string) (*metaSnapshot, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	snapshot := &metaSnapshot{}
+	err = gob.NewDecoder(file).Decode(snapshot)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't read meta snapshot %v: %w", path, err)
+	}
+	return snapshot, nil
+}
@@ -0,0 +1,147 @@
+package mhist
+
+import (
+	"encoding/gob"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/alexmorten/mhist/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_MetaJournal(t *testing.T) {
+	dataPath := "test_data"
+	require.NoError(t, os.MkdirAll(dataPath, os.ModePerm))
+	defer os.RemoveAll(dataPath)
+
+	meta, err := InitMetaFromDisk(dataPath)
+	require.NoError(t, err)
+
+	numericalID, err := meta.GetOrCreateID("numerical", models.MeasurementNumerical)
+	require.NoError(t, err)
+	categoricalID, err := meta.GetOrCreateID("categorical", models.MeasurementCategorical)
+	require.NoError(t, err)
+	valueID, err := meta.GetValueIDForCategoricalValue(categoricalID, "on")
+	require.NoError(t, err)
+	require.NoError(t, meta.RenameSeries("numerical", "renamed"))
+	// several names are journaled as a single batch
+	require.NoError(t, meta.DeleteRange([]string{"renamed", "categorical"}, TimeRange{Start: 10, End: 20}))
+
+	assertRestored := func(t *testing.T, restored *DiskMeta) {
+		assert.Equal(t, "renamed", restored.GetNameForID(numericalID))
+		assert.Equal(t, "on", restored.GetCategoricalValue(categoricalID, valueID))
+		assert.True(t, restored.IsDeleted(numericalID, 15))
+		assert.False(t, restored.IsDeleted(numericalID, 25))
+		assert.True(t, restored.IsDeleted(categoricalID, 15))
+
+		id, err := restored.GetOrCreateID("new", models.MeasurementRaw)
+		require.NoError(t, err)
+		assert.True(t, id > categoricalID)
+	}
+
+	t.Run("changes are replayed from the journal", func(t *testing.T) {
+		restored, err := InitMetaFromDisk(dataPath)
+		require.NoError(t, err)
+		assertRestored(t, restored)
+	})
+
+	t.Run("an incomplete entry at the end of the journal is ignored", func(t *testing.T) {
+		journal, err := os.OpenFile(filepath.Join(dataPath, metaJournalPath), os.O_APPEND|os.O_WRONLY, os.ModePerm)
+		require.NoError(t, err)
+		_, err = journal.Write([]byte{0, 0, 1, 0, 42, 42})
+		require.NoError(t, err)
+		journal.Close()
+
+		restored, err := InitMetaFromDisk(dataPath)
+		require.NoError(t, err)
+		assert.Equal(t, "renamed", restored.GetNameForID(numericalID))
+		require.NoError(t, restored.Close())
+	})
+
+	t.Run("a corrupt entry followed by more entries is not cut off", func(t *testing.T) {
+		corruptPath := "test_corrupt_meta"
+		require.NoError(t, os.MkdirAll(corruptPath, os.ModePerm))
+		defer os.RemoveAll(corruptPath)
+
+		corrupted, err := InitMetaFromDisk(corruptPath)
+		require.NoError(t, err)
+		_, err = corrupted.GetOrCreateID("first", models.MeasurementNumerical)
+		require.NoError(t, err)
+		_, err = corrupted.GetOrCreateID("second", models.MeasurementNumerical)
+		require.NoError(t, err)
+		// without writing a snapshot
+		require.NoError(t, corrupted.journal.close())
+
+		journalPath := filepath.Join(corruptPath, metaJournalPath)
+		content, err := ioutil.ReadFile(journalPath)
+		require.NoError(t, err)
+		content[metaEntryHeaderSize] ^= 0xff
+		require.NoError(t, ioutil.WriteFile(journalPath, content, os.ModePerm))
+
+		_, err = InitMetaFromDisk(corruptPath)
+		assert.True(t, errors.Is(err, errCorruptMetaEntry))
+		unchanged, err := ioutil.ReadFile(journalPath)
+		require.NoError(t, err)
+		assert.Equal(t, content, unchanged)
+	})
+
+	t.Run("the snapshot written on close is loaded", func(t *testing.T) {
+		info, err := os.Stat(filepath.Join(dataPath, metaJournalPath))
+		require.NoError(t, err)
+		assert.Zero(t, info.Size())
+
+		restored, err := InitMetaFromDisk(dataPath)
+		require.NoError(t, err)
+		assert.Equal(t, "renamed", restored.GetNameForID(numericalID))
+		assert.Equal(t, "on", restored.GetCategoricalValue(categoricalID, valueID))
+	})
+
+	t.Run("changes that couldn't be journaled aren't applied", func(t *testing.T) {
+		restored, err := InitMetaFromDisk(dataPath)
+		require.NoError(t, err)
+		require.NoError(t, restored.journal.close())
+
+		_, err = restored.GetOrCreateID("unjournaled", models.MeasurementNumerical)
+		assert.True(t, errors.Is(err, ErrStorage))
+		assert.NotContains(t, restored.NameToID, "unjournaled")
+		assert.Equal(t, "renamed", restored.GetNameForID(numericalID))
+	})
+
+	t.Run("a meta.gob of older versions is migrated", func(t *testing.T) {
+		os.RemoveAll(dataPath)
+		require.NoError(t, os.MkdirAll(dataPath, os.ModePerm))
+
+		legacy := NewDiskMeta()
+		legacy.NameToID["legacy"] = 1
+		legacy.IDToName[1] = "legacy"
+		legacy.IDToType[1] = models.MeasurementNumerical
+		legacy.HighestID = 1
+		file, err := os.Create(filepath.Join(dataPath, legacyMetaFilePath))
+		require.NoError(t, err)
+		require.NoError(t, gob.NewEncoder(file).Encode(legacy))
+		file.Close()
+
+		migrated, err := InitMetaFromDisk(dataPath)
+		require.NoError(t, err)
+		assert.Equal(t, "legacy", migrated.GetNameForID(1))
+		_, err = os.Stat(filepath.Join(dataPath, legacyMetaFilePath))
+		assert.True(t, os.IsNotExist(err))
+		_, err = os.Stat(filepath.Join(dataPath, metaSnapshotPath))
+		assert.NoError(t, err)
+	})
+
+	t.Run("an unreadable meta.gob is not treated as missing", func(t *testing.T) {
+		os.RemoveAll(dataPath)
+		require.NoError(t, os.MkdirAll(dataPath, os.ModePerm))
+		file, err := os.Create(filepath.Join(dataPath, legacyMetaFilePath))
+		require.NoError(t, err)
+		file.Close()
+
+		_, err = InitMetaFromDisk(dataPath)
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,113 @@
+package mhist
+
+import (
+	"fmt"
+
+	"github.com/alexmorten/mhist/models"
+)
+
+// metaView is what decoding needs to know about the series of some measurements. It is taken under a single
+// read lock of the meta, so reads decoding many measurements in parallel don't contend for the lock
+type metaView struct {
+	series map[int64]*seriesView
+}
+
+// seriesView of a stored ID, name and seriesType are the ones of the series it was merged or migrated into.
+// The name is empty if the series was deleted or isn't in the filter of the view
+type seriesView struct {
+	name       string
+	storedType models.MeasurementType
+	seriesType models.MeasurementType
+	tombstones []TimeRange
+	// categoricalValues of the viewed measurements by value ID
+	categoricalValues map[float64]string
+}
+
+// view of the series of the measurements, values are only looked up for the names in the filter
+func (m *DiskMeta) view(measurements []SerializedMeasurement, filterDefinition models.FilterDefinition) *metaView {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	view := &metaView{series: map[int64]*seriesView{}}
+	for _, measurement := range measurements {
+		series, ok := view.series[measurement.ID]
+		if !ok {
+			series = m.seriesView(measurement.ID, filterDefinition)
+			view.series[measurement.ID] = series
+		}
+		if series.categoricalValues == nil {
+			continue
+		}
+		if _, ok := series.categoricalValues[measurement.Value]; !ok {
+			series.categoricalValues[measurement.Value] = m.categoricalValue(measurement.ID, measurement.Value)
+		}
+	}
+	return view
+}
+
+// seriesView of the ID, the caller has to hold the mutex
+func (m *DiskMeta) seriesView(id int64, filterDefinition models.FilterDefinition) *seriesView {
+	resolvedID := m.resolveID(id)
+	name := m.IDToName[resolvedID]
+	if name == "" || !filterDefinition.IsInNames(name) {
+		return &seriesView{}
+	}
+
+	series := &seriesView{name: name, storedType: m.IDToType[id], seriesType: m.IDToType[resolvedID]}
+	series.tombstones = append(series.tombstones, m.Tombstones[id]...)
+	if resolvedID != id {
+		series.tombstones = append(series.tombstones, m.Tombstones[resolvedID]...)
+	}
+	if series.storedType == models.MeasurementCategorical {
+		series.categoricalValues = map[float64]string{}
+	}
+	return series
+}
+
+// decode the measurement into the current name and type of its series, returns a nil measurement if it was deleted,
+// can't be decoded or its name isn't in the filter of the view. Raw values are only read for the names in the filter
+func (v *metaView) decode(serializedMeasurement SerializedMeasurement, readRawValue func(SerializedMeasurement) ([]byte, error)) (string, models.Measurement, error) {
+	series := v.series[serializedMeasurement.ID]
+	if series == nil || series.name == "" || series.isDeleted(serializedMeasurement.Ts) {
+		return "", nil, nil
+	}
+
+	var measurement models.Measurement
+	switch series.storedType {
+	case models.MeasurementNumerical:
+		measurement = &models.Numerical{Ts: serializedMeasurement.Ts, Value: serializedMeasurement.Value}
+	case models.MeasurementCategorical:
+		measurement = &models.Categorical{
+			Ts:    serializedMeasurement.Ts,
+			Value: series.categoricalValues[serializedMeasurement.Value],
+		}
+	case models.MeasurementRaw:
+		value, err := readRawValue(serializedMeasurement)
+		if err != nil {
+			return "", nil, storageError(fmt.Errorf("couldn't read the raw value of %v: %w", series.name, err))
+		}
+		measurement = &models.Raw{Ts: serializedMeasurement.Ts, Value: value}
+	default:
+		return "", nil, nil
+	}
+
+	if series.seriesType != measurement.Type() {
+		converted, err := models.Convert(measurement, series.seriesType)
+		if err != nil {
+			// not every value of a migrated series is convertible, e.g. categorical values to numerical ones
+			return "", nil, nil
+		}
+		measurement = converted
+	}
+
+	return series.name, measurement, nil
+}
+
+func (s *seriesView) isDeleted(ts int64) bool {
+	for _, timeRange := range s.tombstones {
+		if timeRange.Contains(ts) {
+			return true
+		}
+	}
+	return false
+}
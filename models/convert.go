@@ -0,0 +1,42 @@
+package models
+
+import (
+	"fmt"
+	"strconv"
+)
+
+//Convert the measurement to another type, numerical values are stringified for categorical and raw measurements
+//and parsed from categorical and raw values
+func Convert(measurement Measurement, t MeasurementType) (Measurement, error) {
+	if measurement.Type() == t {
+		return measurement, nil
+	}
+
+	var stringValue string
+	switch m := measurement.(type) {
+	case *Numerical:
+		stringValue = strconv.FormatFloat(m.Value, 'g', -1, 64)
+	case *Categorical:
+		stringValue = m.Value
+	case *Raw:
+		stringValue = string(m.Value)
+	default:
+		return nil, fmt.Errorf("can't convert %T", measurement)
+	}
+
+	ts := measurement.Timestamp()
+	switch t {
+	case MeasurementNumerical:
+		value, err := strconv.ParseFloat(stringValue, 64)
+		if err != nil {
+			return nil, err
+		}
+		return &Numerical{Ts: ts, Value: value}, nil
+	case MeasurementCategorical:
+		return &Categorical{Ts: ts, Value: stringValue}, nil
+	case MeasurementRaw:
+		return &Raw{Ts: ts, Value: []byte(stringValue)}, nil
+	}
+
+	return nil, fmt.Errorf("can't convert to %v", t)
+}
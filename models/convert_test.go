@@ -0,0 +1,36 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Convert(t *testing.T) {
+	t.Run("numerical values are stringified", func(t *testing.T) {
+		converted, err := Convert(&Numerical{Ts: 1, Value: 2.5}, MeasurementCategorical)
+		require.NoError(t, err)
+		assert.Equal(t, &Categorical{Ts: 1, Value: "2.5"}, converted)
+
+		converted, err = Convert(&Numerical{Ts: 1, Value: 3}, MeasurementRaw)
+		require.NoError(t, err)
+		assert.Equal(t, &Raw{Ts: 1, Value: []byte("3")}, converted)
+	})
+
+	t.Run("numerical values are parsed", func(t *testing.T) {
+		converted, err := Convert(&Categorical{Ts: 1, Value: "42"}, MeasurementNumerical)
+		require.NoError(t, err)
+		assert.Equal(t, &Numerical{Ts: 1, Value: 42}, converted)
+
+		_, err = Convert(&Raw{Ts: 1, Value: []byte("not a number")}, MeasurementNumerical)
+		assert.Error(t, err)
+	})
+
+	t.Run("same types are not converted", func(t *testing.T) {
+		m := &Categorical{Ts: 1, Value: "a"}
+		converted, err := Convert(m, MeasurementCategorical)
+		require.NoError(t, err)
+		assert.True(t, m == converted)
+	})
+}
@@ -1,5 +1,7 @@
 package models
 
+import "fmt"
+
 //Measurement interface
 type Measurement interface {
 	Type() MeasurementType
@@ -67,3 +69,27 @@ const (
 	// MeasurementRaw for measurements that can only be represented as raw bytes
 	MeasurementRaw
 )
+
+var measurementTypeNames = map[MeasurementType]string{
+	MeasurementNumerical:   "numerical",
+	MeasurementCategorical: "categorical",
+	MeasurementRaw:         "raw",
+}
+
+//String representation of the MeasurementType
+func (t MeasurementType) String() string {
+	if name, ok := measurementTypeNames[t]; ok {
+		return name
+	}
+	return fmt.Sprintf("MeasurementType(%d)", int(t))
+}
+
+//MeasurementTypeFromString is the inverse of MeasurementType.String, returns 0 for unknown names
+func MeasurementTypeFromString(name string) MeasurementType {
+	for t, typeName := range measurementTypeNames {
+		if typeName == name {
+			return t
+		}
+	}
+	return 0
+}
@@ -1,10 +1,17 @@
 package models
 
+import (
+	"encoding/base64"
+	"fmt"
+)
+
 //Message represents events sent to and from the server
 type Message struct {
 	Name      string      `json:"name"`
 	Timestamp int64       `json:"timestamp"`
 	Value     interface{} `json:"value"`
+	//Type is optional, without it numbers are numerical and strings are categorical measurements
+	Type string `json:"type,omitempty"`
 }
 
 //Reset message to zero value
@@ -12,4 +19,56 @@ func (m *Message) Reset() {
 	m.Name = ""
 	m.Timestamp = 0
 	m.Value = nil
+	m.Type = ""
+}
+
+//MessageFromMeasurement builds the Message representation of a named measurement
+//raw values are base64 encoded, so the message stays printable
+func MessageFromMeasurement(name string, measurement Measurement) *Message {
+	message := &Message{
+		Name:      name,
+		Timestamp: measurement.Timestamp(),
+		Type:      measurement.Type().String(),
+	}
+
+	switch m := measurement.(type) {
+	case *Numerical:
+		message.Value = m.Value
+	case *Categorical:
+		message.Value = m.Value
+	case *Raw:
+		message.Value = base64.StdEncoding.EncodeToString(m.Value)
+	}
+
+	return message
+}
+
+//ToMeasurement converts the message to the internal measurement representation
+func (m *Message) ToMeasurement() (Measurement, error) {
+	measurementType := MeasurementTypeFromString(m.Type)
+	if m.Type != "" && measurementType == 0 {
+		return nil, fmt.Errorf("unknown measurement type %q", m.Type)
+	}
+
+	switch value := m.Value.(type) {
+	case float64:
+		if measurementType != 0 && measurementType != MeasurementNumerical {
+			return nil, fmt.Errorf("value %v is not of type %v", value, measurementType)
+		}
+		return &Numerical{Ts: m.Timestamp, Value: value}, nil
+	case string:
+		switch measurementType {
+		case MeasurementRaw:
+			raw, err := base64.StdEncoding.DecodeString(value)
+			if err != nil {
+				return nil, err
+			}
+			return &Raw{Ts: m.Timestamp, Value: raw}, nil
+		case MeasurementNumerical:
+			return nil, fmt.Errorf("value %q is not of type %v", value, measurementType)
+		}
+		return &Categorical{Ts: m.Timestamp, Value: value}, nil
+	}
+
+	return nil, fmt.Errorf("unsupported value %v for %q", m.Value, m.Name)
 }
@@ -26,3 +26,22 @@ func RetrieveResponseFromMeasurementMap(m map[string][]models.Measurement) *Retr
 		Histories: histories,
 	}
 }
+
+// ToMeasurementMap converts the response back to the internal measurement map
+func (r *RetrieveResponse) ToMeasurementMap() map[string][]models.Measurement {
+	m := make(map[string][]models.Measurement, len(r.Histories))
+
+	for name, list := range r.Histories {
+		measurements := make([]models.Measurement, 0, len(list.Measurements))
+		for _, pM := range list.Measurements {
+			measurement := pM.ToModelWithDefinedTs()
+			if measurement == nil {
+				continue
+			}
+			measurements = append(measurements, measurement)
+		}
+		m[name] = measurements
+	}
+
+	return m
+}
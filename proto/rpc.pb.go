@@ -3,13 +3,14 @@
 
 package proto
 
-import proto "github.com/golang/protobuf/proto"
-import fmt "fmt"
-import math "math"
-
 import (
-	context "golang.org/x/net/context"
+	context "context"
+	fmt "fmt"
+	proto "github.com/golang/protobuf/proto"
 	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+	math "math"
 )
 
 // Reference imports to suppress errors if they are not otherwise used.
@@ -21,7 +22,38 @@ var _ = math.Inf
 // is compatible with the proto package it is being compiled against.
 // A compilation error at this line likely means your copy of the
 // proto package needs to be updated.
-const _ = proto.ProtoPackageIsVersion2 // please upgrade the proto package
+const _ = proto.ProtoPackageIsVersion3 // please upgrade the proto package
+
+type MeasurementType int32
+
+const (
+	MeasurementType_UNKNOWN     MeasurementType = 0
+	MeasurementType_NUMERICAL   MeasurementType = 1
+	MeasurementType_CATEGORICAL MeasurementType = 2
+	MeasurementType_RAW         MeasurementType = 3
+)
+
+var MeasurementType_name = map[int32]string{
+	0: "UNKNOWN",
+	1: "NUMERICAL",
+	2: "CATEGORICAL",
+	3: "RAW",
+}
+
+var MeasurementType_value = map[string]int32{
+	"UNKNOWN":     0,
+	"NUMERICAL":   1,
+	"CATEGORICAL": 2,
+	"RAW":         3,
+}
+
+func (x MeasurementType) String() string {
+	return proto.EnumName(MeasurementType_name, int32(x))
+}
+
+func (MeasurementType) EnumDescriptor() ([]byte, []int) {
+	return fileDescriptor_d74a5129edc93dca, []int{0}
+}
 
 type Numerical struct {
 	Ts                   int64    `protobuf:"varint,1,opt,name=ts,proto3" json:"ts,omitempty"`
@@ -35,16 +67,17 @@ func (m *Numerical) Reset()         { *m = Numerical{} }
 func (m *Numerical) String() string { return proto.CompactTextString(m) }
 func (*Numerical) ProtoMessage()    {}
 func (*Numerical) Descriptor() ([]byte, []int) {
-	return fileDescriptor_rpc_99588602b150c724, []int{0}
+	return fileDescriptor_d74a5129edc93dca, []int{0}
 }
+
 func (m *Numerical) XXX_Unmarshal(b []byte) error {
 	return xxx_messageInfo_Numerical.Unmarshal(m, b)
 }
 func (m *Numerical) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
 	return xxx_messageInfo_Numerical.Marshal(b, m, deterministic)
 }
-func (dst *Numerical) XXX_Merge(src proto.Message) {
-	xxx_messageInfo_Numerical.Merge(dst, src)
+func (m *Numerical) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Numerical.Merge(m, src)
 }
 func (m *Numerical) XXX_Size() int {
 	return xxx_messageInfo_Numerical.Size(m)
@@ -81,16 +114,17 @@ func (m *Categorical) Reset()         { *m = Categorical{} }
 func (m *Categorical) String() string { return proto.CompactTextString(m) }
 func (*Categorical) ProtoMessage()    {}
 func (*Categorical) Descriptor() ([]byte, []int) {
-	return fileDescriptor_rpc_99588602b150c724, []int{1}
+	return fileDescriptor_d74a5129edc93dca, []int{1}
 }
+
 func (m *Categorical) XXX_Unmarshal(b []byte) error {
 	return xxx_messageInfo_Categorical.Unmarshal(m, b)
 }
 func (m *Categorical) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
 	return xxx_messageInfo_Categorical.Marshal(b, m, deterministic)
 }
-func (dst *Categorical) XXX_Merge(src proto.Message) {
-	xxx_messageInfo_Categorical.Merge(dst, src)
+func (m *Categorical) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Categorical.Merge(m, src)
 }
 func (m *Categorical) XXX_Size() int {
 	return xxx_messageInfo_Categorical.Size(m)
@@ -127,16 +161,17 @@ func (m *Raw) Reset()         { *m = Raw{} }
 func (m *Raw) String() string { return proto.CompactTextString(m) }
 func (*Raw) ProtoMessage()    {}
 func (*Raw) Descriptor() ([]byte, []int) {
-	return fileDescriptor_rpc_99588602b150c724, []int{2}
+	return fileDescriptor_d74a5129edc93dca, []int{2}
 }
+
 func (m *Raw) XXX_Unmarshal(b []byte) error {
 	return xxx_messageInfo_Raw.Unmarshal(m, b)
 }
 func (m *Raw) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
 	return xxx_messageInfo_Raw.Marshal(b, m, deterministic)
 }
-func (dst *Raw) XXX_Merge(src proto.Message) {
-	xxx_messageInfo_Raw.Merge(dst, src)
+func (m *Raw) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Raw.Merge(m, src)
 }
 func (m *Raw) XXX_Size() int {
 	return xxx_messageInfo_Raw.Size(m)
@@ -176,16 +211,17 @@ func (m *Measurement) Reset()         { *m = Measurement{} }
 func (m *Measurement) String() string { return proto.CompactTextString(m) }
 func (*Measurement) ProtoMessage()    {}
 func (*Measurement) Descriptor() ([]byte, []int) {
-	return fileDescriptor_rpc_99588602b150c724, []int{3}
+	return fileDescriptor_d74a5129edc93dca, []int{3}
 }
+
 func (m *Measurement) XXX_Unmarshal(b []byte) error {
 	return xxx_messageInfo_Measurement.Unmarshal(m, b)
 }
 func (m *Measurement) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
 	return xxx_messageInfo_Measurement.Marshal(b, m, deterministic)
 }
-func (dst *Measurement) XXX_Merge(src proto.Message) {
-	xxx_messageInfo_Measurement.Merge(dst, src)
+func (m *Measurement) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Measurement.Merge(m, src)
 }
 func (m *Measurement) XXX_Size() int {
 	return xxx_messageInfo_Measurement.Size(m)
@@ -246,99 +282,15 @@ func (m *Measurement) GetRaw() *Raw {
 	return nil
 }
 
-// XXX_OneofFuncs is for the internal use of the proto package.
-func (*Measurement) XXX_OneofFuncs() (func(msg proto.Message, b *proto.Buffer) error, func(msg proto.Message, tag, wire int, b *proto.Buffer) (bool, error), func(msg proto.Message) (n int), []interface{}) {
-	return _Measurement_OneofMarshaler, _Measurement_OneofUnmarshaler, _Measurement_OneofSizer, []interface{}{
+// XXX_OneofWrappers is for the internal use of the proto package.
+func (*Measurement) XXX_OneofWrappers() []interface{} {
+	return []interface{}{
 		(*Measurement_Numerical)(nil),
 		(*Measurement_Categorical)(nil),
 		(*Measurement_Raw)(nil),
 	}
 }
 
-func _Measurement_OneofMarshaler(msg proto.Message, b *proto.Buffer) error {
-	m := msg.(*Measurement)
-	// type
-	switch x := m.Type.(type) {
-	case *Measurement_Numerical:
-		b.EncodeVarint(1<<3 | proto.WireBytes)
-		if err := b.EncodeMessage(x.Numerical); err != nil {
-			return err
-		}
-	case *Measurement_Categorical:
-		b.EncodeVarint(2<<3 | proto.WireBytes)
-		if err := b.EncodeMessage(x.Categorical); err != nil {
-			return err
-		}
-	case *Measurement_Raw:
-		b.EncodeVarint(3<<3 | proto.WireBytes)
-		if err := b.EncodeMessage(x.Raw); err != nil {
-			return err
-		}
-	case nil:
-	default:
-		return fmt.Errorf("Measurement.Type has unexpected type %T", x)
-	}
-	return nil
-}
-
-func _Measurement_OneofUnmarshaler(msg proto.Message, tag, wire int, b *proto.Buffer) (bool, error) {
-	m := msg.(*Measurement)
-	switch tag {
-	case 1: // type.numerical
-		if wire != proto.WireBytes {
-			return true, proto.ErrInternalBadWireType
-		}
-		msg := new(Numerical)
-		err := b.DecodeMessage(msg)
-		m.Type = &Measurement_Numerical{msg}
-		return true, err
-	case 2: // type.categorical
-		if wire != proto.WireBytes {
-			return true, proto.ErrInternalBadWireType
-		}
-		msg := new(Categorical)
-		err := b.DecodeMessage(msg)
-		m.Type = &Measurement_Categorical{msg}
-		return true, err
-	case 3: // type.raw
-		if wire != proto.WireBytes {
-			return true, proto.ErrInternalBadWireType
-		}
-		msg := new(Raw)
-		err := b.DecodeMessage(msg)
-		m.Type = &Measurement_Raw{msg}
-		return true, err
-	default:
-		return false, nil
-	}
-}
-
-func _Measurement_OneofSizer(msg proto.Message) (n int) {
-	m := msg.(*Measurement)
-	// type
-	switch x := m.Type.(type) {
-	case *Measurement_Numerical:
-		s := proto.Size(x.Numerical)
-		n += 1 // tag and wire
-		n += proto.SizeVarint(uint64(s))
-		n += s
-	case *Measurement_Categorical:
-		s := proto.Size(x.Categorical)
-		n += 1 // tag and wire
-		n += proto.SizeVarint(uint64(s))
-		n += s
-	case *Measurement_Raw:
-		s := proto.Size(x.Raw)
-		n += 1 // tag and wire
-		n += proto.SizeVarint(uint64(s))
-		n += s
-	case nil:
-	default:
-		panic(fmt.Sprintf("proto: unexpected type %T in oneof", x))
-	}
-	return n
-}
-
 type MeasurementMessage struct {
 	Name                 string       `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
 	Measurement          *Measurement `protobuf:"bytes,2,opt,name=measurement,proto3" json:"measurement,omitempty"`
@@ -351,16 +303,17 @@ func (m *MeasurementMessage) Reset()         { *m = MeasurementMessage{} }
 func (m *MeasurementMessage) String() string { return proto.CompactTextString(m) }
 func (*MeasurementMessage) ProtoMessage()    {}
 func (*MeasurementMessage) Descriptor() ([]byte, []int) {
-	return fileDescriptor_rpc_99588602b150c724, []int{4}
+	return fileDescriptor_d74a5129edc93dca, []int{4}
 }
+
 func (m *MeasurementMessage) XXX_Unmarshal(b []byte) error {
 	return xxx_messageInfo_MeasurementMessage.Unmarshal(m, b)
 }
 func (m *MeasurementMessage) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
 	return xxx_messageInfo_MeasurementMessage.Marshal(b, m, deterministic)
 }
-func (dst *MeasurementMessage) XXX_Merge(src proto.Message) {
-	xxx_messageInfo_MeasurementMessage.Merge(dst, src)
+func (m *MeasurementMessage) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_MeasurementMessage.Merge(m, src)
 }
 func (m *MeasurementMessage) XXX_Size() int {
 	return xxx_messageInfo_MeasurementMessage.Size(m)
@@ -398,16 +351,17 @@ func (m *RetrieveRequest) Reset()         { *m = RetrieveRequest{} }
 func (m *RetrieveRequest) String() string { return proto.CompactTextString(m) }
 func (*RetrieveRequest) ProtoMessage()    {}
 func (*RetrieveRequest) Descriptor() ([]byte, []int) {
-	return fileDescriptor_rpc_99588602b150c724, []int{5}
+	return fileDescriptor_d74a5129edc93dca, []int{5}
 }
+
 func (m *RetrieveRequest) XXX_Unmarshal(b []byte) error {
 	return xxx_messageInfo_RetrieveRequest.Unmarshal(m, b)
 }
 func (m *RetrieveRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
 	return xxx_messageInfo_RetrieveRequest.Marshal(b, m, deterministic)
 }
-func (dst *RetrieveRequest) XXX_Merge(src proto.Message) {
-	xxx_messageInfo_RetrieveRequest.Merge(dst, src)
+func (m *RetrieveRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_RetrieveRequest.Merge(m, src)
 }
 func (m *RetrieveRequest) XXX_Size() int {
 	return xxx_messageInfo_RetrieveRequest.Size(m)
@@ -450,16 +404,17 @@ func (m *MeasurementList) Reset()         { *m = MeasurementList{} }
 func (m *MeasurementList) String() string { return proto.CompactTextString(m) }
 func (*MeasurementList) ProtoMessage()    {}
 func (*MeasurementList) Descriptor() ([]byte, []int) {
-	return fileDescriptor_rpc_99588602b150c724, []int{6}
+	return fileDescriptor_d74a5129edc93dca, []int{6}
 }
+
 func (m *MeasurementList) XXX_Unmarshal(b []byte) error {
 	return xxx_messageInfo_MeasurementList.Unmarshal(m, b)
 }
 func (m *MeasurementList) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
 	return xxx_messageInfo_MeasurementList.Marshal(b, m, deterministic)
 }
-func (dst *MeasurementList) XXX_Merge(src proto.Message) {
-	xxx_messageInfo_MeasurementList.Merge(dst, src)
+func (m *MeasurementList) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_MeasurementList.Merge(m, src)
 }
 func (m *MeasurementList) XXX_Size() int {
 	return xxx_messageInfo_MeasurementList.Size(m)
@@ -479,6 +434,7 @@ func (m *MeasurementList) GetMeasurements() []*Measurement {
 
 type RetrieveResponse struct {
 	Histories            map[string]*MeasurementList `protobuf:"bytes,1,rep,name=histories,proto3" json:"histories,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+	PeerErrors           []*PeerError                `protobuf:"bytes,2,rep,name=peer_errors,json=peerErrors,proto3" json:"peer_errors,omitempty"`
 	XXX_NoUnkeyedLiteral struct{}                    `json:"-"`
 	XXX_unrecognized     []byte                      `json:"-"`
 	XXX_sizecache        int32                       `json:"-"`
@@ -488,16 +444,17 @@ func (m *RetrieveResponse) Reset()         { *m = RetrieveResponse{} }
 func (m *RetrieveResponse) String() string { return proto.CompactTextString(m) }
 func (*RetrieveResponse) ProtoMessage()    {}
 func (*RetrieveResponse) Descriptor() ([]byte, []int) {
-	return fileDescriptor_rpc_99588602b150c724, []int{7}
+	return fileDescriptor_d74a5129edc93dca, []int{7}
 }
+
 func (m *RetrieveResponse) XXX_Unmarshal(b []byte) error {
 	return xxx_messageInfo_RetrieveResponse.Unmarshal(m, b)
 }
 func (m *RetrieveResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
 	return xxx_messageInfo_RetrieveResponse.Marshal(b, m, deterministic)
 }
-func (dst *RetrieveResponse) XXX_Merge(src proto.Message) {
-	xxx_messageInfo_RetrieveResponse.Merge(dst, src)
+func (m *RetrieveResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_RetrieveResponse.Merge(m, src)
 }
 func (m *RetrieveResponse) XXX_Size() int {
 	return xxx_messageInfo_RetrieveResponse.Size(m)
@@ -515,6 +472,60 @@ func (m *RetrieveResponse) GetHistories() map[string]*MeasurementList {
 	return nil
 }
 
+func (m *RetrieveResponse) GetPeerErrors() []*PeerError {
+	if m != nil {
+		return m.PeerErrors
+	}
+	return nil
+}
+
+type PeerError struct {
+	Peer                 string   `protobuf:"bytes,1,opt,name=peer,proto3" json:"peer,omitempty"`
+	Error                string   `protobuf:"bytes,2,opt,name=error,proto3" json:"error,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *PeerError) Reset()         { *m = PeerError{} }
+func (m *PeerError) String() string { return proto.CompactTextString(m) }
+func (*PeerError) ProtoMessage()    {}
+func (*PeerError) Descriptor() ([]byte, []int) {
+	return fileDescriptor_d74a5129edc93dca, []int{8}
+}
+
+func (m *PeerError) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_PeerError.Unmarshal(m, b)
+}
+func (m *PeerError) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_PeerError.Marshal(b, m, deterministic)
+}
+func (m *PeerError) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_PeerError.Merge(m, src)
+}
+func (m *PeerError) XXX_Size() int {
+	return xxx_messageInfo_PeerError.Size(m)
+}
+func (m *PeerError) XXX_DiscardUnknown() {
+	xxx_messageInfo_PeerError.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_PeerError proto.InternalMessageInfo
+
+func (m *PeerError) GetPeer() string {
+	if m != nil {
+		return m.Peer
+	}
+	return ""
+}
+
+func (m *PeerError) GetError() string {
+	if m != nil {
+		return m.Error
+	}
+	return ""
+}
+
 type Filter struct {
 	GranularityNanos     int64    `protobuf:"varint,1,opt,name=granularity_nanos,json=granularityNanos,proto3" json:"granularity_nanos,omitempty"`
 	Names                []string `protobuf:"bytes,2,rep,name=names,proto3" json:"names,omitempty"`
@@ -527,16 +538,17 @@ func (m *Filter) Reset()         { *m = Filter{} }
 func (m *Filter) String() string { return proto.CompactTextString(m) }
 func (*Filter) ProtoMessage()    {}
 func (*Filter) Descriptor() ([]byte, []int) {
-	return fileDescriptor_rpc_99588602b150c724, []int{8}
+	return fileDescriptor_d74a5129edc93dca, []int{9}
 }
+
 func (m *Filter) XXX_Unmarshal(b []byte) error {
 	return xxx_messageInfo_Filter.Unmarshal(m, b)
 }
 func (m *Filter) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
 	return xxx_messageInfo_Filter.Marshal(b, m, deterministic)
 }
-func (dst *Filter) XXX_Merge(src proto.Message) {
-	xxx_messageInfo_Filter.Merge(dst, src)
+func (m *Filter) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Filter.Merge(m, src)
 }
 func (m *Filter) XXX_Size() int {
 	return xxx_messageInfo_Filter.Size(m)
@@ -571,16 +583,17 @@ func (m *Nothing) Reset()         { *m = Nothing{} }
 func (m *Nothing) String() string { return proto.CompactTextString(m) }
 func (*Nothing) ProtoMessage()    {}
 func (*Nothing) Descriptor() ([]byte, []int) {
-	return fileDescriptor_rpc_99588602b150c724, []int{9}
+	return fileDescriptor_d74a5129edc93dca, []int{10}
 }
+
 func (m *Nothing) XXX_Unmarshal(b []byte) error {
 	return xxx_messageInfo_Nothing.Unmarshal(m, b)
 }
 func (m *Nothing) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
 	return xxx_messageInfo_Nothing.Marshal(b, m, deterministic)
 }
-func (dst *Nothing) XXX_Merge(src proto.Message) {
-	xxx_messageInfo_Nothing.Merge(dst, src)
+func (m *Nothing) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Nothing.Merge(m, src)
 }
 func (m *Nothing) XXX_Size() int {
 	return xxx_messageInfo_Nothing.Size(m)
@@ -591,187 +604,1437 @@ func (m *Nothing) XXX_DiscardUnknown() {
 
 var xxx_messageInfo_Nothing proto.InternalMessageInfo
 
-func init() {
-	proto.RegisterType((*Numerical)(nil), "proto.Numerical")
-	proto.RegisterType((*Categorical)(nil), "proto.Categorical")
-	proto.RegisterType((*Raw)(nil), "proto.Raw")
-	proto.RegisterType((*Measurement)(nil), "proto.Measurement")
-	proto.RegisterType((*MeasurementMessage)(nil), "proto.MeasurementMessage")
-	proto.RegisterType((*RetrieveRequest)(nil), "proto.RetrieveRequest")
-	proto.RegisterType((*MeasurementList)(nil), "proto.MeasurementList")
-	proto.RegisterType((*RetrieveResponse)(nil), "proto.RetrieveResponse")
-	proto.RegisterMapType((map[string]*MeasurementList)(nil), "proto.RetrieveResponse.HistoriesEntry")
-	proto.RegisterType((*Filter)(nil), "proto.Filter")
-	proto.RegisterType((*Nothing)(nil), "proto.Nothing")
+type DeleteSeriesRequest struct {
+	Names                []string `protobuf:"bytes,1,rep,name=names,proto3" json:"names,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
 }
 
-// Reference imports to suppress errors if they are not otherwise used.
-var _ context.Context
-var _ grpc.ClientConn
+func (m *DeleteSeriesRequest) Reset()         { *m = DeleteSeriesRequest{} }
+func (m *DeleteSeriesRequest) String() string { return proto.CompactTextString(m) }
+func (*DeleteSeriesRequest) ProtoMessage()    {}
+func (*DeleteSeriesRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_d74a5129edc93dca, []int{11}
+}
 
-// This is a compile-time assertion to ensure that this generated file
-// is compatible with the grpc package it is being compiled against.
-const _ = grpc.SupportPackageIsVersion4
+func (m *DeleteSeriesRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_DeleteSeriesRequest.Unmarshal(m, b)
+}
+func (m *DeleteSeriesRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_DeleteSeriesRequest.Marshal(b, m, deterministic)
+}
+func (m *DeleteSeriesRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_DeleteSeriesRequest.Merge(m, src)
+}
+func (m *DeleteSeriesRequest) XXX_Size() int {
+	return xxx_messageInfo_DeleteSeriesRequest.Size(m)
+}
+func (m *DeleteSeriesRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_DeleteSeriesRequest.DiscardUnknown(m)
+}
 
-// MhistClient is the client API for Mhist service.
-//
-// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://godoc.org/google.golang.org/grpc#ClientConn.NewStream.
-type MhistClient interface {
-	Store(ctx context.Context, in *MeasurementMessage, opts ...grpc.CallOption) (*Nothing, error)
-	StoreStream(ctx context.Context, opts ...grpc.CallOption) (Mhist_StoreStreamClient, error)
-	Retrieve(ctx context.Context, in *RetrieveRequest, opts ...grpc.CallOption) (*RetrieveResponse, error)
-	Subscribe(ctx context.Context, in *Filter, opts ...grpc.CallOption) (Mhist_SubscribeClient, error)
+var xxx_messageInfo_DeleteSeriesRequest proto.InternalMessageInfo
+
+func (m *DeleteSeriesRequest) GetNames() []string {
+	if m != nil {
+		return m.Names
+	}
+	return nil
 }
 
-type mhistClient struct {
-	cc *grpc.ClientConn
+type DeleteRangeRequest struct {
+	Names                []string `protobuf:"bytes,1,rep,name=names,proto3" json:"names,omitempty"`
+	Start                int64    `protobuf:"varint,2,opt,name=start,proto3" json:"start,omitempty"`
+	End                  int64    `protobuf:"varint,3,opt,name=end,proto3" json:"end,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
 }
 
-func NewMhistClient(cc *grpc.ClientConn) MhistClient {
-	return &mhistClient{cc}
+func (m *DeleteRangeRequest) Reset()         { *m = DeleteRangeRequest{} }
+func (m *DeleteRangeRequest) String() string { return proto.CompactTextString(m) }
+func (*DeleteRangeRequest) ProtoMessage()    {}
+func (*DeleteRangeRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_d74a5129edc93dca, []int{12}
 }
 
-func (c *mhistClient) Store(ctx context.Context, in *MeasurementMessage, opts ...grpc.CallOption) (*Nothing, error) {
-	out := new(Nothing)
-	err := c.cc.Invoke(ctx, "/proto.Mhist/Store", in, out, opts...)
-	if err != nil {
-		return nil, err
+func (m *DeleteRangeRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_DeleteRangeRequest.Unmarshal(m, b)
+}
+func (m *DeleteRangeRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_DeleteRangeRequest.Marshal(b, m, deterministic)
+}
+func (m *DeleteRangeRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_DeleteRangeRequest.Merge(m, src)
+}
+func (m *DeleteRangeRequest) XXX_Size() int {
+	return xxx_messageInfo_DeleteRangeRequest.Size(m)
+}
+func (m *DeleteRangeRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_DeleteRangeRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_DeleteRangeRequest proto.InternalMessageInfo
+
+func (m *DeleteRangeRequest) GetNames() []string {
+	if m != nil {
+		return m.Names
 	}
-	return out, nil
+	return nil
 }
 
-func (c *mhistClient) StoreStream(ctx context.Context, opts ...grpc.CallOption) (Mhist_StoreStreamClient, error) {
-	stream, err := c.cc.NewStream(ctx, &_Mhist_serviceDesc.Streams[0], "/proto.Mhist/StoreStream", opts...)
-	if err != nil {
-		return nil, err
+func (m *DeleteRangeRequest) GetStart() int64 {
+	if m != nil {
+		return m.Start
 	}
-	x := &mhistStoreStreamClient{stream}
-	return x, nil
+	return 0
 }
 
-type Mhist_StoreStreamClient interface {
-	Send(*MeasurementMessage) error
-	CloseAndRecv() (*Nothing, error)
-	grpc.ClientStream
+func (m *DeleteRangeRequest) GetEnd() int64 {
+	if m != nil {
+		return m.End
+	}
+	return 0
 }
 
-type mhistStoreStreamClient struct {
-	grpc.ClientStream
+type RenameSeriesRequest struct {
+	Name                 string   `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	NewName              string   `protobuf:"bytes,2,opt,name=new_name,json=newName,proto3" json:"new_name,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
 }
 
-func (x *mhistStoreStreamClient) Send(m *MeasurementMessage) error {
-	return x.ClientStream.SendMsg(m)
+func (m *RenameSeriesRequest) Reset()         { *m = RenameSeriesRequest{} }
+func (m *RenameSeriesRequest) String() string { return proto.CompactTextString(m) }
+func (*RenameSeriesRequest) ProtoMessage()    {}
+func (*RenameSeriesRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_d74a5129edc93dca, []int{13}
 }
 
-func (x *mhistStoreStreamClient) CloseAndRecv() (*Nothing, error) {
-	if err := x.ClientStream.CloseSend(); err != nil {
-		return nil, err
-	}
-	m := new(Nothing)
-	if err := x.ClientStream.RecvMsg(m); err != nil {
-		return nil, err
-	}
-	return m, nil
+func (m *RenameSeriesRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_RenameSeriesRequest.Unmarshal(m, b)
+}
+func (m *RenameSeriesRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_RenameSeriesRequest.Marshal(b, m, deterministic)
+}
+func (m *RenameSeriesRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_RenameSeriesRequest.Merge(m, src)
+}
+func (m *RenameSeriesRequest) XXX_Size() int {
+	return xxx_messageInfo_RenameSeriesRequest.Size(m)
+}
+func (m *RenameSeriesRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_RenameSeriesRequest.DiscardUnknown(m)
 }
 
-func (c *mhistClient) Retrieve(ctx context.Context, in *RetrieveRequest, opts ...grpc.CallOption) (*RetrieveResponse, error) {
-	out := new(RetrieveResponse)
-	err := c.cc.Invoke(ctx, "/proto.Mhist/Retrieve", in, out, opts...)
-	if err != nil {
-		return nil, err
+var xxx_messageInfo_RenameSeriesRequest proto.InternalMessageInfo
+
+func (m *RenameSeriesRequest) GetName() string {
+	if m != nil {
+		return m.Name
 	}
-	return out, nil
+	return ""
 }
 
-func (c *mhistClient) Subscribe(ctx context.Context, in *Filter, opts ...grpc.CallOption) (Mhist_SubscribeClient, error) {
-	stream, err := c.cc.NewStream(ctx, &_Mhist_serviceDesc.Streams[1], "/proto.Mhist/Subscribe", opts...)
-	if err != nil {
-		return nil, err
-	}
-	x := &mhistSubscribeClient{stream}
-	if err := x.ClientStream.SendMsg(in); err != nil {
-		return nil, err
-	}
-	if err := x.ClientStream.CloseSend(); err != nil {
-		return nil, err
+func (m *RenameSeriesRequest) GetNewName() string {
+	if m != nil {
+		return m.NewName
 	}
-	return x, nil
+	return ""
 }
 
-type Mhist_SubscribeClient interface {
-	Recv() (*MeasurementMessage, error)
-	grpc.ClientStream
+type MergeSeriesRequest struct {
+	Name                 string   `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Into                 string   `protobuf:"bytes,2,opt,name=into,proto3" json:"into,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
 }
 
-type mhistSubscribeClient struct {
-	grpc.ClientStream
+func (m *MergeSeriesRequest) Reset()         { *m = MergeSeriesRequest{} }
+func (m *MergeSeriesRequest) String() string { return proto.CompactTextString(m) }
+func (*MergeSeriesRequest) ProtoMessage()    {}
+func (*MergeSeriesRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_d74a5129edc93dca, []int{14}
 }
 
-func (x *mhistSubscribeClient) Recv() (*MeasurementMessage, error) {
-	m := new(MeasurementMessage)
-	if err := x.ClientStream.RecvMsg(m); err != nil {
-		return nil, err
-	}
-	return m, nil
+func (m *MergeSeriesRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_MergeSeriesRequest.Unmarshal(m, b)
 }
-
-// MhistServer is the server API for Mhist service.
-type MhistServer interface {
-	Store(context.Context, *MeasurementMessage) (*Nothing, error)
-	StoreStream(Mhist_StoreStreamServer) error
-	Retrieve(context.Context, *RetrieveRequest) (*RetrieveResponse, error)
-	Subscribe(*Filter, Mhist_SubscribeServer) error
+func (m *MergeSeriesRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_MergeSeriesRequest.Marshal(b, m, deterministic)
 }
-
-func RegisterMhistServer(s *grpc.Server, srv MhistServer) {
-	s.RegisterService(&_Mhist_serviceDesc, srv)
+func (m *MergeSeriesRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_MergeSeriesRequest.Merge(m, src)
+}
+func (m *MergeSeriesRequest) XXX_Size() int {
+	return xxx_messageInfo_MergeSeriesRequest.Size(m)
+}
+func (m *MergeSeriesRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_MergeSeriesRequest.DiscardUnknown(m)
 }
 
-func _Mhist_Store_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(MeasurementMessage)
-	if err := dec(in); err != nil {
-		return nil, err
-	}
-	if interceptor == nil {
-		return srv.(MhistServer).Store(ctx, in)
-	}
-	info := &grpc.UnaryServerInfo{
-		Server:     srv,
-		FullMethod: "/proto.Mhist/Store",
+var xxx_messageInfo_MergeSeriesRequest proto.InternalMessageInfo
+
+func (m *MergeSeriesRequest) GetName() string {
+	if m != nil {
+		return m.Name
 	}
-	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(MhistServer).Store(ctx, req.(*MeasurementMessage))
+	return ""
+}
+
+func (m *MergeSeriesRequest) GetInto() string {
+	if m != nil {
+		return m.Into
 	}
-	return interceptor(ctx, in, info, handler)
+	return ""
 }
 
-func _Mhist_StoreStream_Handler(srv interface{}, stream grpc.ServerStream) error {
-	return srv.(MhistServer).StoreStream(&mhistStoreStreamServer{stream})
+type MigrateSeriesRequest struct {
+	Name                 string          `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Type                 MeasurementType `protobuf:"varint,2,opt,name=type,proto3,enum=proto.MeasurementType" json:"type,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}        `json:"-"`
+	XXX_unrecognized     []byte          `json:"-"`
+	XXX_sizecache        int32           `json:"-"`
 }
 
-type Mhist_StoreStreamServer interface {
-	SendAndClose(*Nothing) error
-	Recv() (*MeasurementMessage, error)
-	grpc.ServerStream
+func (m *MigrateSeriesRequest) Reset()         { *m = MigrateSeriesRequest{} }
+func (m *MigrateSeriesRequest) String() string { return proto.CompactTextString(m) }
+func (*MigrateSeriesRequest) ProtoMessage()    {}
+func (*MigrateSeriesRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_d74a5129edc93dca, []int{15}
 }
 
-type mhistStoreStreamServer struct {
-	grpc.ServerStream
+func (m *MigrateSeriesRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_MigrateSeriesRequest.Unmarshal(m, b)
+}
+func (m *MigrateSeriesRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_MigrateSeriesRequest.Marshal(b, m, deterministic)
+}
+func (m *MigrateSeriesRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_MigrateSeriesRequest.Merge(m, src)
+}
+func (m *MigrateSeriesRequest) XXX_Size() int {
+	return xxx_messageInfo_MigrateSeriesRequest.Size(m)
+}
+func (m *MigrateSeriesRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_MigrateSeriesRequest.DiscardUnknown(m)
 }
 
-func (x *mhistStoreStreamServer) SendAndClose(m *Nothing) error {
-	return x.ServerStream.SendMsg(m)
+var xxx_messageInfo_MigrateSeriesRequest proto.InternalMessageInfo
+
+func (m *MigrateSeriesRequest) GetName() string {
+	if m != nil {
+		return m.Name
+	}
+	return ""
 }
 
-func (x *mhistStoreStreamServer) Recv() (*MeasurementMessage, error) {
-	m := new(MeasurementMessage)
-	if err := x.ServerStream.RecvMsg(m); err != nil {
-		return nil, err
+func (m *MigrateSeriesRequest) GetType() MeasurementType {
+	if m != nil {
+		return m.Type
 	}
-	return m, nil
+	return MeasurementType_UNKNOWN
 }
 
-func _Mhist_Retrieve_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+type LatestRequest struct {
+	Names                []string `protobuf:"bytes,1,rep,name=names,proto3" json:"names,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *LatestRequest) Reset()         { *m = LatestRequest{} }
+func (m *LatestRequest) String() string { return proto.CompactTextString(m) }
+func (*LatestRequest) ProtoMessage()    {}
+func (*LatestRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_d74a5129edc93dca, []int{16}
+}
+
+func (m *LatestRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_LatestRequest.Unmarshal(m, b)
+}
+func (m *LatestRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_LatestRequest.Marshal(b, m, deterministic)
+}
+func (m *LatestRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_LatestRequest.Merge(m, src)
+}
+func (m *LatestRequest) XXX_Size() int {
+	return xxx_messageInfo_LatestRequest.Size(m)
+}
+func (m *LatestRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_LatestRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_LatestRequest proto.InternalMessageInfo
+
+func (m *LatestRequest) GetNames() []string {
+	if m != nil {
+		return m.Names
+	}
+	return nil
+}
+
+type LatestResponse struct {
+	Latest               map[string]*Measurement `protobuf:"bytes,1,rep,name=latest,proto3" json:"latest,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+	PeerErrors           []*PeerError            `protobuf:"bytes,2,rep,name=peer_errors,json=peerErrors,proto3" json:"peer_errors,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}                `json:"-"`
+	XXX_unrecognized     []byte                  `json:"-"`
+	XXX_sizecache        int32                   `json:"-"`
+}
+
+func (m *LatestResponse) Reset()         { *m = LatestResponse{} }
+func (m *LatestResponse) String() string { return proto.CompactTextString(m) }
+func (*LatestResponse) ProtoMessage()    {}
+func (*LatestResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_d74a5129edc93dca, []int{17}
+}
+
+func (m *LatestResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_LatestResponse.Unmarshal(m, b)
+}
+func (m *LatestResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_LatestResponse.Marshal(b, m, deterministic)
+}
+func (m *LatestResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_LatestResponse.Merge(m, src)
+}
+func (m *LatestResponse) XXX_Size() int {
+	return xxx_messageInfo_LatestResponse.Size(m)
+}
+func (m *LatestResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_LatestResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_LatestResponse proto.InternalMessageInfo
+
+func (m *LatestResponse) GetLatest() map[string]*Measurement {
+	if m != nil {
+		return m.Latest
+	}
+	return nil
+}
+
+func (m *LatestResponse) GetPeerErrors() []*PeerError {
+	if m != nil {
+		return m.PeerErrors
+	}
+	return nil
+}
+
+type ListSeriesRequest struct {
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *ListSeriesRequest) Reset()         { *m = ListSeriesRequest{} }
+func (m *ListSeriesRequest) String() string { return proto.CompactTextString(m) }
+func (*ListSeriesRequest) ProtoMessage()    {}
+func (*ListSeriesRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_d74a5129edc93dca, []int{18}
+}
+
+func (m *ListSeriesRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ListSeriesRequest.Unmarshal(m, b)
+}
+func (m *ListSeriesRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ListSeriesRequest.Marshal(b, m, deterministic)
+}
+func (m *ListSeriesRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ListSeriesRequest.Merge(m, src)
+}
+func (m *ListSeriesRequest) XXX_Size() int {
+	return xxx_messageInfo_ListSeriesRequest.Size(m)
+}
+func (m *ListSeriesRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_ListSeriesRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ListSeriesRequest proto.InternalMessageInfo
+
+type SeriesInfo struct {
+	Name                 string          `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Type                 MeasurementType `protobuf:"varint,2,opt,name=type,proto3,enum=proto.MeasurementType" json:"type,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}        `json:"-"`
+	XXX_unrecognized     []byte          `json:"-"`
+	XXX_sizecache        int32           `json:"-"`
+}
+
+func (m *SeriesInfo) Reset()         { *m = SeriesInfo{} }
+func (m *SeriesInfo) String() string { return proto.CompactTextString(m) }
+func (*SeriesInfo) ProtoMessage()    {}
+func (*SeriesInfo) Descriptor() ([]byte, []int) {
+	return fileDescriptor_d74a5129edc93dca, []int{19}
+}
+
+func (m *SeriesInfo) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_SeriesInfo.Unmarshal(m, b)
+}
+func (m *SeriesInfo) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_SeriesInfo.Marshal(b, m, deterministic)
+}
+func (m *SeriesInfo) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_SeriesInfo.Merge(m, src)
+}
+func (m *SeriesInfo) XXX_Size() int {
+	return xxx_messageInfo_SeriesInfo.Size(m)
+}
+func (m *SeriesInfo) XXX_DiscardUnknown() {
+	xxx_messageInfo_SeriesInfo.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_SeriesInfo proto.InternalMessageInfo
+
+func (m *SeriesInfo) GetName() string {
+	if m != nil {
+		return m.Name
+	}
+	return ""
+}
+
+func (m *SeriesInfo) GetType() MeasurementType {
+	if m != nil {
+		return m.Type
+	}
+	return MeasurementType_UNKNOWN
+}
+
+type ListSeriesResponse struct {
+	Series               []*SeriesInfo `protobuf:"bytes,1,rep,name=series,proto3" json:"series,omitempty"`
+	PeerErrors           []*PeerError  `protobuf:"bytes,2,rep,name=peer_errors,json=peerErrors,proto3" json:"peer_errors,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}      `json:"-"`
+	XXX_unrecognized     []byte        `json:"-"`
+	XXX_sizecache        int32         `json:"-"`
+}
+
+func (m *ListSeriesResponse) Reset()         { *m = ListSeriesResponse{} }
+func (m *ListSeriesResponse) String() string { return proto.CompactTextString(m) }
+func (*ListSeriesResponse) ProtoMessage()    {}
+func (*ListSeriesResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_d74a5129edc93dca, []int{20}
+}
+
+func (m *ListSeriesResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ListSeriesResponse.Unmarshal(m, b)
+}
+func (m *ListSeriesResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ListSeriesResponse.Marshal(b, m, deterministic)
+}
+func (m *ListSeriesResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ListSeriesResponse.Merge(m, src)
+}
+func (m *ListSeriesResponse) XXX_Size() int {
+	return xxx_messageInfo_ListSeriesResponse.Size(m)
+}
+func (m *ListSeriesResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_ListSeriesResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ListSeriesResponse proto.InternalMessageInfo
+
+func (m *ListSeriesResponse) GetSeries() []*SeriesInfo {
+	if m != nil {
+		return m.Series
+	}
+	return nil
+}
+
+func (m *ListSeriesResponse) GetPeerErrors() []*PeerError {
+	if m != nil {
+		return m.PeerErrors
+	}
+	return nil
+}
+
+type SnapshotRequest struct {
+	Dir                  string   `protobuf:"bytes,1,opt,name=dir,proto3" json:"dir,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *SnapshotRequest) Reset()         { *m = SnapshotRequest{} }
+func (m *SnapshotRequest) String() string { return proto.CompactTextString(m) }
+func (*SnapshotRequest) ProtoMessage()    {}
+func (*SnapshotRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_d74a5129edc93dca, []int{21}
+}
+
+func (m *SnapshotRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_SnapshotRequest.Unmarshal(m, b)
+}
+func (m *SnapshotRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_SnapshotRequest.Marshal(b, m, deterministic)
+}
+func (m *SnapshotRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_SnapshotRequest.Merge(m, src)
+}
+func (m *SnapshotRequest) XXX_Size() int {
+	return xxx_messageInfo_SnapshotRequest.Size(m)
+}
+func (m *SnapshotRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_SnapshotRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_SnapshotRequest proto.InternalMessageInfo
+
+func (m *SnapshotRequest) GetDir() string {
+	if m != nil {
+		return m.Dir
+	}
+	return ""
+}
+
+type SnapshotChunk struct {
+	Data                 []byte   `protobuf:"bytes,1,opt,name=data,proto3" json:"data,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *SnapshotChunk) Reset()         { *m = SnapshotChunk{} }
+func (m *SnapshotChunk) String() string { return proto.CompactTextString(m) }
+func (*SnapshotChunk) ProtoMessage()    {}
+func (*SnapshotChunk) Descriptor() ([]byte, []int) {
+	return fileDescriptor_d74a5129edc93dca, []int{22}
+}
+
+func (m *SnapshotChunk) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_SnapshotChunk.Unmarshal(m, b)
+}
+func (m *SnapshotChunk) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_SnapshotChunk.Marshal(b, m, deterministic)
+}
+func (m *SnapshotChunk) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_SnapshotChunk.Merge(m, src)
+}
+func (m *SnapshotChunk) XXX_Size() int {
+	return xxx_messageInfo_SnapshotChunk.Size(m)
+}
+func (m *SnapshotChunk) XXX_DiscardUnknown() {
+	xxx_messageInfo_SnapshotChunk.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_SnapshotChunk proto.InternalMessageInfo
+
+func (m *SnapshotChunk) GetData() []byte {
+	if m != nil {
+		return m.Data
+	}
+	return nil
+}
+
+type RebalanceRequest struct {
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *RebalanceRequest) Reset()         { *m = RebalanceRequest{} }
+func (m *RebalanceRequest) String() string { return proto.CompactTextString(m) }
+func (*RebalanceRequest) ProtoMessage()    {}
+func (*RebalanceRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_d74a5129edc93dca, []int{23}
+}
+
+func (m *RebalanceRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_RebalanceRequest.Unmarshal(m, b)
+}
+func (m *RebalanceRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_RebalanceRequest.Marshal(b, m, deterministic)
+}
+func (m *RebalanceRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_RebalanceRequest.Merge(m, src)
+}
+func (m *RebalanceRequest) XXX_Size() int {
+	return xxx_messageInfo_RebalanceRequest.Size(m)
+}
+func (m *RebalanceRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_RebalanceRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_RebalanceRequest proto.InternalMessageInfo
+
+type RebalanceResponse struct {
+	MovedSeries          int64    `protobuf:"varint,1,opt,name=moved_series,json=movedSeries,proto3" json:"moved_series,omitempty"`
+	MovedMeasurements    int64    `protobuf:"varint,2,opt,name=moved_measurements,json=movedMeasurements,proto3" json:"moved_measurements,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *RebalanceResponse) Reset()         { *m = RebalanceResponse{} }
+func (m *RebalanceResponse) String() string { return proto.CompactTextString(m) }
+func (*RebalanceResponse) ProtoMessage()    {}
+func (*RebalanceResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_d74a5129edc93dca, []int{24}
+}
+
+func (m *RebalanceResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_RebalanceResponse.Unmarshal(m, b)
+}
+func (m *RebalanceResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_RebalanceResponse.Marshal(b, m, deterministic)
+}
+func (m *RebalanceResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_RebalanceResponse.Merge(m, src)
+}
+func (m *RebalanceResponse) XXX_Size() int {
+	return xxx_messageInfo_RebalanceResponse.Size(m)
+}
+func (m *RebalanceResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_RebalanceResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_RebalanceResponse proto.InternalMessageInfo
+
+func (m *RebalanceResponse) GetMovedSeries() int64 {
+	if m != nil {
+		return m.MovedSeries
+	}
+	return 0
+}
+
+func (m *RebalanceResponse) GetMovedMeasurements() int64 {
+	if m != nil {
+		return m.MovedMeasurements
+	}
+	return 0
+}
+
+type ReplicationPosition struct {
+	Epoch                int64    `protobuf:"varint,1,opt,name=epoch,proto3" json:"epoch,omitempty"`
+	Seq                  int64    `protobuf:"varint,2,opt,name=seq,proto3" json:"seq,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *ReplicationPosition) Reset()         { *m = ReplicationPosition{} }
+func (m *ReplicationPosition) String() string { return proto.CompactTextString(m) }
+func (*ReplicationPosition) ProtoMessage()    {}
+func (*ReplicationPosition) Descriptor() ([]byte, []int) {
+	return fileDescriptor_d74a5129edc93dca, []int{25}
+}
+
+func (m *ReplicationPosition) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ReplicationPosition.Unmarshal(m, b)
+}
+func (m *ReplicationPosition) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ReplicationPosition.Marshal(b, m, deterministic)
+}
+func (m *ReplicationPosition) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ReplicationPosition.Merge(m, src)
+}
+func (m *ReplicationPosition) XXX_Size() int {
+	return xxx_messageInfo_ReplicationPosition.Size(m)
+}
+func (m *ReplicationPosition) XXX_DiscardUnknown() {
+	xxx_messageInfo_ReplicationPosition.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ReplicationPosition proto.InternalMessageInfo
+
+func (m *ReplicationPosition) GetEpoch() int64 {
+	if m != nil {
+		return m.Epoch
+	}
+	return 0
+}
+
+func (m *ReplicationPosition) GetSeq() int64 {
+	if m != nil {
+		return m.Seq
+	}
+	return 0
+}
+
+type ReplicationAck struct {
+	Follower             string               `protobuf:"bytes,1,opt,name=follower,proto3" json:"follower,omitempty"`
+	Position             *ReplicationPosition `protobuf:"bytes,2,opt,name=position,proto3" json:"position,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}             `json:"-"`
+	XXX_unrecognized     []byte               `json:"-"`
+	XXX_sizecache        int32                `json:"-"`
+}
+
+func (m *ReplicationAck) Reset()         { *m = ReplicationAck{} }
+func (m *ReplicationAck) String() string { return proto.CompactTextString(m) }
+func (*ReplicationAck) ProtoMessage()    {}
+func (*ReplicationAck) Descriptor() ([]byte, []int) {
+	return fileDescriptor_d74a5129edc93dca, []int{26}
+}
+
+func (m *ReplicationAck) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ReplicationAck.Unmarshal(m, b)
+}
+func (m *ReplicationAck) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ReplicationAck.Marshal(b, m, deterministic)
+}
+func (m *ReplicationAck) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ReplicationAck.Merge(m, src)
+}
+func (m *ReplicationAck) XXX_Size() int {
+	return xxx_messageInfo_ReplicationAck.Size(m)
+}
+func (m *ReplicationAck) XXX_DiscardUnknown() {
+	xxx_messageInfo_ReplicationAck.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ReplicationAck proto.InternalMessageInfo
+
+func (m *ReplicationAck) GetFollower() string {
+	if m != nil {
+		return m.Follower
+	}
+	return ""
+}
+
+func (m *ReplicationAck) GetPosition() *ReplicationPosition {
+	if m != nil {
+		return m.Position
+	}
+	return nil
+}
+
+type ReplicatedChange struct {
+	Seq int64 `protobuf:"varint,1,opt,name=seq,proto3" json:"seq,omitempty"`
+	// Types that are valid to be assigned to Change:
+	//	*ReplicatedChange_Measurement
+	//	*ReplicatedChange_DeleteSeries
+	//	*ReplicatedChange_DeleteRange
+	//	*ReplicatedChange_RenameSeries
+	//	*ReplicatedChange_MergeSeries
+	//	*ReplicatedChange_MigrateSeries
+	Change               isReplicatedChange_Change `protobuf_oneof:"change"`
+	XXX_NoUnkeyedLiteral struct{}                  `json:"-"`
+	XXX_unrecognized     []byte                    `json:"-"`
+	XXX_sizecache        int32                     `json:"-"`
+}
+
+func (m *ReplicatedChange) Reset()         { *m = ReplicatedChange{} }
+func (m *ReplicatedChange) String() string { return proto.CompactTextString(m) }
+func (*ReplicatedChange) ProtoMessage()    {}
+func (*ReplicatedChange) Descriptor() ([]byte, []int) {
+	return fileDescriptor_d74a5129edc93dca, []int{27}
+}
+
+func (m *ReplicatedChange) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ReplicatedChange.Unmarshal(m, b)
+}
+func (m *ReplicatedChange) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ReplicatedChange.Marshal(b, m, deterministic)
+}
+func (m *ReplicatedChange) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ReplicatedChange.Merge(m, src)
+}
+func (m *ReplicatedChange) XXX_Size() int {
+	return xxx_messageInfo_ReplicatedChange.Size(m)
+}
+func (m *ReplicatedChange) XXX_DiscardUnknown() {
+	xxx_messageInfo_ReplicatedChange.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ReplicatedChange proto.InternalMessageInfo
+
+func (m *ReplicatedChange) GetSeq() int64 {
+	if m != nil {
+		return m.Seq
+	}
+	return 0
+}
+
+type isReplicatedChange_Change interface {
+	isReplicatedChange_Change()
+}
+
+type ReplicatedChange_Measurement struct {
+	Measurement *MeasurementMessage `protobuf:"bytes,2,opt,name=measurement,proto3,oneof"`
+}
+
+type ReplicatedChange_DeleteSeries struct {
+	DeleteSeries *DeleteSeriesRequest `protobuf:"bytes,3,opt,name=delete_series,json=deleteSeries,proto3,oneof"`
+}
+
+type ReplicatedChange_DeleteRange struct {
+	DeleteRange *DeleteRangeRequest `protobuf:"bytes,4,opt,name=delete_range,json=deleteRange,proto3,oneof"`
+}
+
+type ReplicatedChange_RenameSeries struct {
+	RenameSeries *RenameSeriesRequest `protobuf:"bytes,5,opt,name=rename_series,json=renameSeries,proto3,oneof"`
+}
+
+type ReplicatedChange_MergeSeries struct {
+	MergeSeries *MergeSeriesRequest `protobuf:"bytes,6,opt,name=merge_series,json=mergeSeries,proto3,oneof"`
+}
+
+type ReplicatedChange_MigrateSeries struct {
+	MigrateSeries *MigrateSeriesRequest `protobuf:"bytes,7,opt,name=migrate_series,json=migrateSeries,proto3,oneof"`
+}
+
+func (*ReplicatedChange_Measurement) isReplicatedChange_Change() {}
+
+func (*ReplicatedChange_DeleteSeries) isReplicatedChange_Change() {}
+
+func (*ReplicatedChange_DeleteRange) isReplicatedChange_Change() {}
+
+func (*ReplicatedChange_RenameSeries) isReplicatedChange_Change() {}
+
+func (*ReplicatedChange_MergeSeries) isReplicatedChange_Change() {}
+
+func (*ReplicatedChange_MigrateSeries) isReplicatedChange_Change() {}
+
+func (m *ReplicatedChange) GetChange() isReplicatedChange_Change {
+	if m != nil {
+		return m.Change
+	}
+	return nil
+}
+
+func (m *ReplicatedChange) GetMeasurement() *MeasurementMessage {
+	if x, ok := m.GetChange().(*ReplicatedChange_Measurement); ok {
+		return x.Measurement
+	}
+	return nil
+}
+
+func (m *ReplicatedChange) GetDeleteSeries() *DeleteSeriesRequest {
+	if x, ok := m.GetChange().(*ReplicatedChange_DeleteSeries); ok {
+		return x.DeleteSeries
+	}
+	return nil
+}
+
+func (m *ReplicatedChange) GetDeleteRange() *DeleteRangeRequest {
+	if x, ok := m.GetChange().(*ReplicatedChange_DeleteRange); ok {
+		return x.DeleteRange
+	}
+	return nil
+}
+
+func (m *ReplicatedChange) GetRenameSeries() *RenameSeriesRequest {
+	if x, ok := m.GetChange().(*ReplicatedChange_RenameSeries); ok {
+		return x.RenameSeries
+	}
+	return nil
+}
+
+func (m *ReplicatedChange) GetMergeSeries() *MergeSeriesRequest {
+	if x, ok := m.GetChange().(*ReplicatedChange_MergeSeries); ok {
+		return x.MergeSeries
+	}
+	return nil
+}
+
+func (m *ReplicatedChange) GetMigrateSeries() *MigrateSeriesRequest {
+	if x, ok := m.GetChange().(*ReplicatedChange_MigrateSeries); ok {
+		return x.MigrateSeries
+	}
+	return nil
+}
+
+// XXX_OneofWrappers is for the internal use of the proto package.
+func (*ReplicatedChange) XXX_OneofWrappers() []interface{} {
+	return []interface{}{
+		(*ReplicatedChange_Measurement)(nil),
+		(*ReplicatedChange_DeleteSeries)(nil),
+		(*ReplicatedChange_DeleteRange)(nil),
+		(*ReplicatedChange_RenameSeries)(nil),
+		(*ReplicatedChange_MergeSeries)(nil),
+		(*ReplicatedChange_MigrateSeries)(nil),
+	}
+}
+
+type ReplicationMessage struct {
+	// Types that are valid to be assigned to Message:
+	//	*ReplicationMessage_SnapshotChunk
+	//	*ReplicationMessage_SnapshotDone
+	//	*ReplicationMessage_Change
+	Message              isReplicationMessage_Message `protobuf_oneof:"message"`
+	XXX_NoUnkeyedLiteral struct{}                     `json:"-"`
+	XXX_unrecognized     []byte                       `json:"-"`
+	XXX_sizecache        int32                        `json:"-"`
+}
+
+func (m *ReplicationMessage) Reset()         { *m = ReplicationMessage{} }
+func (m *ReplicationMessage) String() string { return proto.CompactTextString(m) }
+func (*ReplicationMessage) ProtoMessage()    {}
+func (*ReplicationMessage) Descriptor() ([]byte, []int) {
+	return fileDescriptor_d74a5129edc93dca, []int{28}
+}
+
+func (m *ReplicationMessage) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ReplicationMessage.Unmarshal(m, b)
+}
+func (m *ReplicationMessage) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ReplicationMessage.Marshal(b, m, deterministic)
+}
+func (m *ReplicationMessage) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ReplicationMessage.Merge(m, src)
+}
+func (m *ReplicationMessage) XXX_Size() int {
+	return xxx_messageInfo_ReplicationMessage.Size(m)
+}
+func (m *ReplicationMessage) XXX_DiscardUnknown() {
+	xxx_messageInfo_ReplicationMessage.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ReplicationMessage proto.InternalMessageInfo
+
+type isReplicationMessage_Message interface {
+	isReplicationMessage_Message()
+}
+
+type ReplicationMessage_SnapshotChunk struct {
+	SnapshotChunk []byte `protobuf:"bytes,1,opt,name=snapshot_chunk,json=snapshotChunk,proto3,oneof"`
+}
+
+type ReplicationMessage_SnapshotDone struct {
+	SnapshotDone *ReplicationPosition `protobuf:"bytes,2,opt,name=snapshot_done,json=snapshotDone,proto3,oneof"`
+}
+
+type ReplicationMessage_Change struct {
+	Change *ReplicatedChange `protobuf:"bytes,3,opt,name=change,proto3,oneof"`
+}
+
+func (*ReplicationMessage_SnapshotChunk) isReplicationMessage_Message() {}
+
+func (*ReplicationMessage_SnapshotDone) isReplicationMessage_Message() {}
+
+func (*ReplicationMessage_Change) isReplicationMessage_Message() {}
+
+func (m *ReplicationMessage) GetMessage() isReplicationMessage_Message {
+	if m != nil {
+		return m.Message
+	}
+	return nil
+}
+
+func (m *ReplicationMessage) GetSnapshotChunk() []byte {
+	if x, ok := m.GetMessage().(*ReplicationMessage_SnapshotChunk); ok {
+		return x.SnapshotChunk
+	}
+	return nil
+}
+
+func (m *ReplicationMessage) GetSnapshotDone() *ReplicationPosition {
+	if x, ok := m.GetMessage().(*ReplicationMessage_SnapshotDone); ok {
+		return x.SnapshotDone
+	}
+	return nil
+}
+
+func (m *ReplicationMessage) GetChange() *ReplicatedChange {
+	if x, ok := m.GetMessage().(*ReplicationMessage_Change); ok {
+		return x.Change
+	}
+	return nil
+}
+
+// XXX_OneofWrappers is for the internal use of the proto package.
+func (*ReplicationMessage) XXX_OneofWrappers() []interface{} {
+	return []interface{}{
+		(*ReplicationMessage_SnapshotChunk)(nil),
+		(*ReplicationMessage_SnapshotDone)(nil),
+		(*ReplicationMessage_Change)(nil),
+	}
+}
+
+func init() {
+	proto.RegisterEnum("proto.MeasurementType", MeasurementType_name, MeasurementType_value)
+	proto.RegisterType((*Numerical)(nil), "proto.Numerical")
+	proto.RegisterType((*Categorical)(nil), "proto.Categorical")
+	proto.RegisterType((*Raw)(nil), "proto.Raw")
+	proto.RegisterType((*Measurement)(nil), "proto.Measurement")
+	proto.RegisterType((*MeasurementMessage)(nil), "proto.MeasurementMessage")
+	proto.RegisterType((*RetrieveRequest)(nil), "proto.RetrieveRequest")
+	proto.RegisterType((*MeasurementList)(nil), "proto.MeasurementList")
+	proto.RegisterType((*RetrieveResponse)(nil), "proto.RetrieveResponse")
+	proto.RegisterMapType((map[string]*MeasurementList)(nil), "proto.RetrieveResponse.HistoriesEntry")
+	proto.RegisterType((*PeerError)(nil), "proto.PeerError")
+	proto.RegisterType((*Filter)(nil), "proto.Filter")
+	proto.RegisterType((*Nothing)(nil), "proto.Nothing")
+	proto.RegisterType((*DeleteSeriesRequest)(nil), "proto.DeleteSeriesRequest")
+	proto.RegisterType((*DeleteRangeRequest)(nil), "proto.DeleteRangeRequest")
+	proto.RegisterType((*RenameSeriesRequest)(nil), "proto.RenameSeriesRequest")
+	proto.RegisterType((*MergeSeriesRequest)(nil), "proto.MergeSeriesRequest")
+	proto.RegisterType((*MigrateSeriesRequest)(nil), "proto.MigrateSeriesRequest")
+	proto.RegisterType((*LatestRequest)(nil), "proto.LatestRequest")
+	proto.RegisterType((*LatestResponse)(nil), "proto.LatestResponse")
+	proto.RegisterMapType((map[string]*Measurement)(nil), "proto.LatestResponse.LatestEntry")
+	proto.RegisterType((*ListSeriesRequest)(nil), "proto.ListSeriesRequest")
+	proto.RegisterType((*SeriesInfo)(nil), "proto.SeriesInfo")
+	proto.RegisterType((*ListSeriesResponse)(nil), "proto.ListSeriesResponse")
+	proto.RegisterType((*SnapshotRequest)(nil), "proto.SnapshotRequest")
+	proto.RegisterType((*SnapshotChunk)(nil), "proto.SnapshotChunk")
+	proto.RegisterType((*RebalanceRequest)(nil), "proto.RebalanceRequest")
+	proto.RegisterType((*RebalanceResponse)(nil), "proto.RebalanceResponse")
+	proto.RegisterType((*ReplicationPosition)(nil), "proto.ReplicationPosition")
+	proto.RegisterType((*ReplicationAck)(nil), "proto.ReplicationAck")
+	proto.RegisterType((*ReplicatedChange)(nil), "proto.ReplicatedChange")
+	proto.RegisterType((*ReplicationMessage)(nil), "proto.ReplicationMessage")
+}
+
+func init() { proto.RegisterFile("proto/rpc.proto", fileDescriptor_d74a5129edc93dca) }
+
+var fileDescriptor_d74a5129edc93dca = []byte{
+	// 1304 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xa4, 0x56, 0xe1, 0x72, 0xd3, 0x46,
+	0x10, 0xb6, 0xac, 0xc4, 0x89, 0x56, 0xb6, 0xe3, 0x2c, 0x01, 0x1c, 0x77, 0xa6, 0x03, 0x62, 0x68,
+	0x53, 0x68, 0x69, 0x08, 0x85, 0x52, 0x0a, 0xcc, 0x98, 0x24, 0xc5, 0x0c, 0xb1, 0x61, 0x2e, 0x50,
+	0xfe, 0xd5, 0x55, 0xec, 0xc3, 0xd1, 0xc4, 0x96, 0xcc, 0xe9, 0x4c, 0x26, 0x4f, 0xd3, 0xe7, 0xe8,
+	0x9f, 0xbe, 0x41, 0x67, 0xfa, 0x0a, 0x7d, 0x93, 0xce, 0x9d, 0xee, 0xe4, 0x93, 0xad, 0xb8, 0x29,
+	0xfd, 0xa5, 0xbb, 0xbd, 0xdd, 0xbd, 0xef, 0x76, 0xf7, 0xdb, 0x15, 0xac, 0x8d, 0x59, 0xc4, 0xa3,
+	0x6f, 0xd9, 0xb8, 0x77, 0x47, 0xae, 0x70, 0x59, 0x7e, 0xbc, 0xbb, 0xe0, 0x74, 0x26, 0x23, 0xca,
+	0x82, 0x9e, 0x3f, 0xc4, 0x2a, 0x14, 0x79, 0x5c, 0xb7, 0xae, 0x59, 0x5b, 0x36, 0x29, 0xf2, 0x18,
+	0x37, 0x60, 0xf9, 0xa3, 0x3f, 0x9c, 0xd0, 0x7a, 0xf1, 0x9a, 0xb5, 0x65, 0x91, 0x64, 0xe3, 0xdd,
+	0x03, 0x77, 0xd7, 0xe7, 0x74, 0x10, 0x5d, 0xc0, 0xc8, 0xd1, 0x46, 0xb7, 0xc1, 0x26, 0xfe, 0xe9,
+	0x62, 0xe5, 0xb2, 0x56, 0xfe, 0xcd, 0x02, 0xb7, 0x4d, 0xfd, 0x78, 0xc2, 0xe8, 0x88, 0x86, 0x1c,
+	0xb7, 0xc1, 0x09, 0x35, 0x48, 0x69, 0xec, 0xee, 0xd4, 0x92, 0x67, 0xdc, 0x49, 0xc1, 0xb7, 0x0a,
+	0x64, 0xaa, 0x84, 0x0f, 0xc0, 0xed, 0x4d, 0x31, 0x4a, 0xef, 0xee, 0x0e, 0x2a, 0x1b, 0x03, 0x7d,
+	0xab, 0x40, 0x4c, 0x45, 0xfc, 0x1c, 0x6c, 0xe6, 0x9f, 0xd6, 0x6d, 0xa9, 0x0f, 0x4a, 0x9f, 0xf8,
+	0xa7, 0xad, 0x02, 0x11, 0x07, 0xcf, 0x4a, 0xb0, 0xc4, 0xcf, 0xc6, 0xd4, 0xfb, 0x05, 0xd0, 0x00,
+	0xd8, 0xa6, 0x71, 0xec, 0x0f, 0x28, 0x22, 0x2c, 0x85, 0xfe, 0x88, 0x4a, 0x88, 0x0e, 0x91, 0x6b,
+	0xfc, 0x0e, 0xdc, 0xd1, 0x54, 0x73, 0x06, 0x89, 0xe1, 0x83, 0x98, 0x6a, 0xde, 0xaf, 0xb0, 0x46,
+	0x28, 0x67, 0x01, 0xfd, 0x48, 0x09, 0xfd, 0x30, 0xa1, 0x31, 0x17, 0xa1, 0x8a, 0xb9, 0xcf, 0xb8,
+	0x8a, 0x5e, 0xb2, 0xc1, 0x1a, 0xd8, 0x34, 0xec, 0x4b, 0xb7, 0x36, 0x11, 0x4b, 0xbc, 0x09, 0xa5,
+	0xf7, 0xc1, 0x90, 0x53, 0xa6, 0x5e, 0x51, 0x51, 0x77, 0xfd, 0x24, 0x85, 0x44, 0x1d, 0x7a, 0x2f,
+	0x60, 0xcd, 0xb8, 0xfd, 0x20, 0x88, 0x39, 0x3e, 0x80, 0xb2, 0x81, 0x41, 0xa4, 0xc9, 0x3e, 0x07,
+	0x6b, 0x46, 0xcf, 0xfb, 0xdb, 0x82, 0xda, 0x14, 0x6d, 0x3c, 0x8e, 0xc2, 0x98, 0xe2, 0x1e, 0x38,
+	0xc7, 0x41, 0xcc, 0x23, 0x16, 0x50, 0xed, 0xe9, 0x0b, 0x1d, 0xcf, 0x19, 0xdd, 0x3b, 0x2d, 0xad,
+	0xb8, 0x1f, 0x72, 0x76, 0x46, 0xa6, 0x86, 0x78, 0x17, 0xdc, 0x31, 0xa5, 0xac, 0x4b, 0x19, 0x8b,
+	0x58, 0x5c, 0x2f, 0x5e, 0xb3, 0x8d, 0xdc, 0xbf, 0xa6, 0x94, 0xed, 0x8b, 0x03, 0x02, 0x63, 0xbd,
+	0x8c, 0x1b, 0x6f, 0xa0, 0x9a, 0xf5, 0x27, 0x62, 0x74, 0x42, 0xcf, 0x54, 0x56, 0xc4, 0x12, 0xbf,
+	0x36, 0xcb, 0xce, 0xdd, 0xb9, 0x32, 0xff, 0x44, 0x11, 0x10, 0x55, 0x8e, 0x8f, 0x8a, 0x0f, 0x2d,
+	0xef, 0x3e, 0x38, 0xe9, 0x75, 0x22, 0xcf, 0xe2, 0x42, 0x9d, 0x67, 0xb1, 0x16, 0xe9, 0x91, 0x20,
+	0x75, 0xd9, 0xcb, 0x8d, 0xf7, 0x12, 0x4a, 0x49, 0xdc, 0xf1, 0x36, 0xac, 0x0f, 0x98, 0x1f, 0x4e,
+	0x86, 0x3e, 0x0b, 0xf8, 0x59, 0x37, 0xf4, 0xc3, 0x48, 0x13, 0xa1, 0x66, 0x1c, 0x74, 0x84, 0x5c,
+	0x38, 0x13, 0xc5, 0x93, 0x3c, 0xd8, 0x21, 0xc9, 0xc6, 0x73, 0x60, 0xa5, 0x13, 0xf1, 0xe3, 0x20,
+	0x1c, 0x78, 0xb7, 0xe1, 0xd2, 0x1e, 0x1d, 0x52, 0x4e, 0x0f, 0xa9, 0x78, 0xa7, 0x51, 0x23, 0x89,
+	0x9d, 0x65, 0xda, 0x11, 0xc0, 0x44, 0x99, 0xf8, 0xe1, 0x80, 0x2e, 0xd4, 0x9d, 0x56, 0x59, 0x31,
+	0xa7, 0xca, 0xec, 0xb4, 0xca, 0xbc, 0x3d, 0xb8, 0x44, 0xa8, 0x30, 0xc9, 0x02, 0xc8, 0x63, 0xc0,
+	0x26, 0xac, 0x86, 0xf4, 0xb4, 0x2b, 0xe5, 0x49, 0x70, 0x56, 0x42, 0x7a, 0xda, 0xf1, 0x47, 0xd4,
+	0x7b, 0x2c, 0x68, 0xc4, 0x06, 0x17, 0x70, 0x82, 0xb0, 0x14, 0x84, 0x3c, 0x52, 0x0e, 0xe4, 0xda,
+	0xfb, 0x19, 0x36, 0xda, 0xc1, 0x80, 0xf9, 0xfc, 0x02, 0xf6, 0xb7, 0x12, 0xe2, 0x4a, 0xfb, 0x6a,
+	0x5e, 0xc2, 0xdf, 0x9c, 0x8d, 0x29, 0x49, 0xc8, 0x7d, 0x13, 0x2a, 0x07, 0x3e, 0xa7, 0x31, 0x5f,
+	0x1c, 0xd6, 0x3f, 0x2d, 0xa8, 0x6a, 0x3d, 0x55, 0xf4, 0x3f, 0x40, 0x69, 0x28, 0x25, 0xaa, 0xe2,
+	0xaf, 0xab, 0x7b, 0xb2, 0x6a, 0x6a, 0x9b, 0x14, 0xbb, 0x32, 0xf8, 0x94, 0x4a, 0x6f, 0x83, 0x6b,
+	0x78, 0xca, 0x29, 0xf3, 0xad, 0x6c, 0x99, 0xe7, 0x31, 0xd9, 0x28, 0xf1, 0x4b, 0xb0, 0x2e, 0xaa,
+	0x3e, 0x13, 0x4b, 0xef, 0x00, 0x20, 0x11, 0xbc, 0x08, 0xdf, 0x47, 0xff, 0x3b, 0xb2, 0x0c, 0xd0,
+	0xbc, 0x42, 0x45, 0xed, 0x2b, 0x28, 0xc5, 0xd4, 0xe8, 0x13, 0xeb, 0xca, 0xc7, 0xf4, 0x62, 0xa2,
+	0x14, 0x3e, 0x21, 0x4a, 0xde, 0x0d, 0x58, 0x3b, 0x0c, 0xfd, 0x71, 0x7c, 0x1c, 0xa5, 0xf9, 0xac,
+	0x81, 0xdd, 0x0f, 0x34, 0x7d, 0xc5, 0xd2, 0xbb, 0x01, 0x15, 0xad, 0xb4, 0x7b, 0x3c, 0x09, 0x4f,
+	0xc4, 0x4b, 0xfb, 0x3e, 0xf7, 0xa5, 0x4e, 0x99, 0xc8, 0xb5, 0x87, 0xa2, 0xcd, 0x1d, 0xf9, 0x43,
+	0x3f, 0xec, 0x69, 0x16, 0x79, 0x14, 0xd6, 0x0d, 0x99, 0x7a, 0xd0, 0x75, 0x28, 0x8f, 0xa2, 0x8f,
+	0xb4, 0xdf, 0x4d, 0x9f, 0x25, 0x78, 0xe3, 0x4a, 0x59, 0xf2, 0x28, 0xfc, 0x06, 0x30, 0x51, 0xc9,
+	0x74, 0xdc, 0x84, 0x74, 0xeb, 0xf2, 0xa4, 0x6d, 0xb6, 0xd8, 0x27, 0x82, 0x6e, 0xe3, 0x61, 0xd0,
+	0xf3, 0x79, 0x10, 0x85, 0xaf, 0xa3, 0x38, 0x10, 0x5f, 0xd9, 0x74, 0xc6, 0x51, 0xef, 0x58, 0xcf,
+	0x04, 0xb9, 0x11, 0xcf, 0x8b, 0xe9, 0x07, 0x3d, 0x13, 0x62, 0xfa, 0xc1, 0xeb, 0x43, 0xd5, 0x30,
+	0x6f, 0xf6, 0x4e, 0xb0, 0x01, 0xab, 0xef, 0xa3, 0xe1, 0x30, 0x3a, 0x4d, 0xdb, 0x58, 0xba, 0xc7,
+	0x07, 0xb0, 0x3a, 0x56, 0x37, 0xa8, 0xca, 0x69, 0xa4, 0x9d, 0x7b, 0x0e, 0x03, 0x49, 0x75, 0xbd,
+	0xdf, 0x6d, 0xa8, 0x69, 0x0d, 0xda, 0xdf, 0x3d, 0x16, 0xdd, 0x46, 0x83, 0xb1, 0x52, 0x30, 0xf8,
+	0x24, 0x6f, 0x22, 0x6e, 0xce, 0xd7, 0x8d, 0x9a, 0xaa, 0x62, 0x44, 0x1b, 0xfa, 0xd8, 0x84, 0x4a,
+	0x5f, 0x76, 0x33, 0x1d, 0x5d, 0x3b, 0x03, 0x31, 0xa7, 0x2d, 0xb6, 0x0a, 0xa4, 0xdc, 0x37, 0xc4,
+	0xf8, 0x14, 0xd4, 0xbe, 0xcb, 0x04, 0xc6, 0xfa, 0x52, 0x06, 0xc2, 0x7c, 0xaf, 0x14, 0x10, 0xfa,
+	0x53, 0xa9, 0x80, 0xc0, 0x64, 0xf3, 0xd3, 0x10, 0x96, 0x67, 0xa2, 0x34, 0xd7, 0x18, 0x05, 0x04,
+	0x66, 0x88, 0x05, 0x84, 0x11, 0x65, 0x83, 0xd4, 0x43, 0x69, 0x26, 0x0a, 0xb3, 0x4d, 0x31, 0x89,
+	0x42, 0x2a, 0xc5, 0x3d, 0xa8, 0x8e, 0x92, 0xde, 0xa7, 0x3d, 0xac, 0x48, 0x0f, 0x9f, 0x69, 0x0f,
+	0x39, 0x8d, 0xb1, 0x55, 0x20, 0x95, 0x91, 0x29, 0x7f, 0xb6, 0x0a, 0xa5, 0x9e, 0x4c, 0x93, 0xf7,
+	0x87, 0x05, 0x68, 0x64, 0x57, 0xff, 0xd1, 0x7c, 0x09, 0xd5, 0x58, 0xf1, 0xa2, 0xdb, 0x13, 0xc4,
+	0x48, 0x08, 0x21, 0x3c, 0xc5, 0x19, 0xbe, 0x34, 0x21, 0x15, 0x74, 0xfb, 0x51, 0x48, 0xff, 0xbd,
+	0x70, 0x44, 0x48, 0xb4, 0xc9, 0x5e, 0x14, 0x52, 0xbc, 0xab, 0xc1, 0xa8, 0x8c, 0x5e, 0x9d, 0xb1,
+	0xd5, 0x25, 0xd5, 0x2a, 0x10, 0xa5, 0xf8, 0xcc, 0x81, 0x95, 0x51, 0x82, 0xf4, 0xd6, 0x73, 0x58,
+	0x9b, 0xe9, 0x39, 0xe8, 0xc2, 0xca, 0xdb, 0xce, 0xcb, 0xce, 0xab, 0x77, 0x9d, 0x5a, 0x01, 0x2b,
+	0xe0, 0x74, 0xde, 0xb6, 0xf7, 0xc9, 0x8b, 0xdd, 0xe6, 0x41, 0xcd, 0xc2, 0x35, 0x70, 0x77, 0x9b,
+	0x6f, 0xf6, 0x9f, 0xbf, 0x4a, 0x04, 0x45, 0x5c, 0x01, 0x9b, 0x34, 0xdf, 0xd5, 0xec, 0x9d, 0xbf,
+	0x4a, 0xb0, 0xdc, 0x16, 0x7f, 0x20, 0xb8, 0x03, 0xcb, 0x87, 0x3c, 0x62, 0x14, 0xcf, 0x2f, 0xce,
+	0x46, 0x55, 0xff, 0x87, 0x26, 0x83, 0x19, 0x1f, 0x81, 0x2b, 0x6d, 0x0e, 0x39, 0xa3, 0xfe, 0xe8,
+	0x3f, 0x58, 0x6e, 0x59, 0xf8, 0x23, 0xac, 0xea, 0x5f, 0x23, 0xbc, 0x32, 0xf7, 0xaf, 0x24, 0x53,
+	0xd8, 0xb8, 0x7a, 0xce, 0x3f, 0x14, 0x7e, 0x0f, 0xce, 0xe1, 0xe4, 0x28, 0xee, 0xb1, 0xe0, 0x88,
+	0x62, 0xf6, 0x9f, 0xaf, 0x71, 0x3e, 0x8a, 0x6d, 0x0b, 0xef, 0x43, 0x29, 0x99, 0x22, 0xb8, 0x31,
+	0x33, 0xad, 0x92, 0x1b, 0x2f, 0xe7, 0xce, 0x30, 0x6c, 0x02, 0x4c, 0x5b, 0x39, 0xd6, 0xb5, 0xd2,
+	0xec, 0x00, 0x69, 0x6c, 0xe6, 0x9c, 0x28, 0x17, 0x8f, 0xa0, 0x6c, 0xb2, 0x15, 0x17, 0x50, 0x78,
+	0x2e, 0xce, 0x0f, 0xc1, 0x35, 0x78, 0x8a, 0xe7, 0x73, 0x37, 0x27, 0x43, 0x65, 0x93, 0xa0, 0xb8,
+	0x80, 0xb5, 0x79, 0xb7, 0x1a, 0xd4, 0xc4, 0xf3, 0xe9, 0x3a, 0x67, 0xf9, 0x18, 0x2a, 0x19, 0x4a,
+	0xe2, 0x22, 0xa2, 0xe6, 0x60, 0x5e, 0xd5, 0xe3, 0x29, 0xad, 0x8c, 0x99, 0xa1, 0xd6, 0xd8, 0x98,
+	0x91, 0x4b, 0x5e, 0x6e, 0x5b, 0xf8, 0x14, 0x9c, 0x74, 0x42, 0xe1, 0xb4, 0x7c, 0xb2, 0x73, 0xac,
+	0x51, 0x9f, 0x3f, 0x48, 0x13, 0xed, 0xa4, 0x0c, 0xc4, 0xcb, 0xf3, 0x7c, 0x6e, 0xf6, 0x4e, 0x1a,
+	0x9b, 0xf3, 0x62, 0x55, 0x60, 0x5b, 0xd6, 0xb6, 0x75, 0x54, 0x92, 0xa7, 0xf7, 0xfe, 0x19, 0x00,
+	0xd7, 0x92, 0xcf, 0xaa, 0x85, 0x0e, 0x00, 0x00,
+}
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ context.Context
+var _ grpc.ClientConn
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+const _ = grpc.SupportPackageIsVersion4
+
+// MhistClient is the client API for Mhist service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://godoc.org/google.golang.org/grpc#ClientConn.NewStream.
+type MhistClient interface {
+	Store(ctx context.Context, in *MeasurementMessage, opts ...grpc.CallOption) (*Nothing, error)
+	StoreStream(ctx context.Context, opts ...grpc.CallOption) (Mhist_StoreStreamClient, error)
+	Retrieve(ctx context.Context, in *RetrieveRequest, opts ...grpc.CallOption) (*RetrieveResponse, error)
+	Subscribe(ctx context.Context, in *Filter, opts ...grpc.CallOption) (Mhist_SubscribeClient, error)
+	Latest(ctx context.Context, in *LatestRequest, opts ...grpc.CallOption) (*LatestResponse, error)
+	ListSeries(ctx context.Context, in *ListSeriesRequest, opts ...grpc.CallOption) (*ListSeriesResponse, error)
+	DeleteSeries(ctx context.Context, in *DeleteSeriesRequest, opts ...grpc.CallOption) (*Nothing, error)
+	DeleteRange(ctx context.Context, in *DeleteRangeRequest, opts ...grpc.CallOption) (*Nothing, error)
+	RenameSeries(ctx context.Context, in *RenameSeriesRequest, opts ...grpc.CallOption) (*Nothing, error)
+	MergeSeries(ctx context.Context, in *MergeSeriesRequest, opts ...grpc.CallOption) (*Nothing, error)
+	MigrateSeries(ctx context.Context, in *MigrateSeriesRequest, opts ...grpc.CallOption) (*Nothing, error)
+	Snapshot(ctx context.Context, in *SnapshotRequest, opts ...grpc.CallOption) (Mhist_SnapshotClient, error)
+	Rebalance(ctx context.Context, in *RebalanceRequest, opts ...grpc.CallOption) (*RebalanceResponse, error)
+	Replicate(ctx context.Context, opts ...grpc.CallOption) (Mhist_ReplicateClient, error)
+}
+
+type mhistClient struct {
+	cc *grpc.ClientConn
+}
+
+func NewMhistClient(cc *grpc.ClientConn) MhistClient {
+	return &mhistClient{cc}
+}
+
+func (c *mhistClient) Store(ctx context.Context, in *MeasurementMessage, opts ...grpc.CallOption) (*Nothing, error) {
+	out := new(Nothing)
+	err := c.cc.Invoke(ctx, "/proto.Mhist/Store", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *mhistClient) StoreStream(ctx context.Context, opts ...grpc.CallOption) (Mhist_StoreStreamClient, error) {
+	stream, err := c.cc.NewStream(ctx, &_Mhist_serviceDesc.Streams[0], "/proto.Mhist/StoreStream", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &mhistStoreStreamClient{stream}
+	return x, nil
+}
+
+type Mhist_StoreStreamClient interface {
+	Send(*MeasurementMessage) error
+	CloseAndRecv() (*Nothing, error)
+	grpc.ClientStream
+}
+
+type mhistStoreStreamClient struct {
+	grpc.ClientStream
+}
+
+func (x *mhistStoreStreamClient) Send(m *MeasurementMessage) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *mhistStoreStreamClient) CloseAndRecv() (*Nothing, error) {
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	m := new(Nothing)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *mhistClient) Retrieve(ctx context.Context, in *RetrieveRequest, opts ...grpc.CallOption) (*RetrieveResponse, error) {
+	out := new(RetrieveResponse)
+	err := c.cc.Invoke(ctx, "/proto.Mhist/Retrieve", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *mhistClient) Subscribe(ctx context.Context, in *Filter, opts ...grpc.CallOption) (Mhist_SubscribeClient, error) {
+	stream, err := c.cc.NewStream(ctx, &_Mhist_serviceDesc.Streams[1], "/proto.Mhist/Subscribe", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &mhistSubscribeClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Mhist_SubscribeClient interface {
+	Recv() (*MeasurementMessage, error)
+	grpc.ClientStream
+}
+
+type mhistSubscribeClient struct {
+	grpc.ClientStream
+}
+
+func (x *mhistSubscribeClient) Recv() (*MeasurementMessage, error) {
+	m := new(MeasurementMessage)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *mhistClient) Latest(ctx context.Context, in *LatestRequest, opts ...grpc.CallOption) (*LatestResponse, error) {
+	out := new(LatestResponse)
+	err := c.cc.Invoke(ctx, "/proto.Mhist/Latest", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *mhistClient) ListSeries(ctx context.Context, in *ListSeriesRequest, opts ...grpc.CallOption) (*ListSeriesResponse, error) {
+	out := new(ListSeriesResponse)
+	err := c.cc.Invoke(ctx, "/proto.Mhist/ListSeries", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *mhistClient) DeleteSeries(ctx context.Context, in *DeleteSeriesRequest, opts ...grpc.CallOption) (*Nothing, error) {
+	out := new(Nothing)
+	err := c.cc.Invoke(ctx, "/proto.Mhist/DeleteSeries", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *mhistClient) DeleteRange(ctx context.Context, in *DeleteRangeRequest, opts ...grpc.CallOption) (*Nothing, error) {
+	out := new(Nothing)
+	err := c.cc.Invoke(ctx, "/proto.Mhist/DeleteRange", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *mhistClient) RenameSeries(ctx context.Context, in *RenameSeriesRequest, opts ...grpc.CallOption) (*Nothing, error) {
+	out := new(Nothing)
+	err := c.cc.Invoke(ctx, "/proto.Mhist/RenameSeries", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *mhistClient) MergeSeries(ctx context.Context, in *MergeSeriesRequest, opts ...grpc.CallOption) (*Nothing, error) {
+	out := new(Nothing)
+	err := c.cc.Invoke(ctx, "/proto.Mhist/MergeSeries", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *mhistClient) MigrateSeries(ctx context.Context, in *MigrateSeriesRequest, opts ...grpc.CallOption) (*Nothing, error) {
+	out := new(Nothing)
+	err := c.cc.Invoke(ctx, "/proto.Mhist/MigrateSeries", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *mhistClient) Snapshot(ctx context.Context, in *SnapshotRequest, opts ...grpc.CallOption) (Mhist_SnapshotClient, error) {
+	stream, err := c.cc.NewStream(ctx, &_Mhist_serviceDesc.Streams[2], "/proto.Mhist/Snapshot", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &mhistSnapshotClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Mhist_SnapshotClient interface {
+	Recv() (*SnapshotChunk, error)
+	grpc.ClientStream
+}
+
+type mhistSnapshotClient struct {
+	grpc.ClientStream
+}
+
+func (x *mhistSnapshotClient) Recv() (*SnapshotChunk, error) {
+	m := new(SnapshotChunk)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *mhistClient) Rebalance(ctx context.Context, in *RebalanceRequest, opts ...grpc.CallOption) (*RebalanceResponse, error) {
+	out := new(RebalanceResponse)
+	err := c.cc.Invoke(ctx, "/proto.Mhist/Rebalance", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *mhistClient) Replicate(ctx context.Context, opts ...grpc.CallOption) (Mhist_ReplicateClient, error) {
+	stream, err := c.cc.NewStream(ctx, &_Mhist_serviceDesc.Streams[3], "/proto.Mhist/Replicate", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &mhistReplicateClient{stream}
+	return x, nil
+}
+
+type Mhist_ReplicateClient interface {
+	Send(*ReplicationAck) error
+	Recv() (*ReplicationMessage, error)
+	grpc.ClientStream
+}
+
+type mhistReplicateClient struct {
+	grpc.ClientStream
+}
+
+func (x *mhistReplicateClient) Send(m *ReplicationAck) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *mhistReplicateClient) Recv() (*ReplicationMessage, error) {
+	m := new(ReplicationMessage)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// MhistServer is the server API for Mhist service.
+type MhistServer interface {
+	Store(context.Context, *MeasurementMessage) (*Nothing, error)
+	StoreStream(Mhist_StoreStreamServer) error
+	Retrieve(context.Context, *RetrieveRequest) (*RetrieveResponse, error)
+	Subscribe(*Filter, Mhist_SubscribeServer) error
+	Latest(context.Context, *LatestRequest) (*LatestResponse, error)
+	ListSeries(context.Context, *ListSeriesRequest) (*ListSeriesResponse, error)
+	DeleteSeries(context.Context, *DeleteSeriesRequest) (*Nothing, error)
+	DeleteRange(context.Context, *DeleteRangeRequest) (*Nothing, error)
+	RenameSeries(context.Context, *RenameSeriesRequest) (*Nothing, error)
+	MergeSeries(context.Context, *MergeSeriesRequest) (*Nothing, error)
+	MigrateSeries(context.Context, *MigrateSeriesRequest) (*Nothing, error)
+	Snapshot(*SnapshotRequest, Mhist_SnapshotServer) error
+	Rebalance(context.Context, *RebalanceRequest) (*RebalanceResponse, error)
+	Replicate(Mhist_ReplicateServer) error
+}
+
+// UnimplementedMhistServer can be embedded to have forward compatible implementations.
+type UnimplementedMhistServer struct {
+}
+
+func (*UnimplementedMhistServer) Store(ctx context.Context, req *MeasurementMessage) (*Nothing, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Store not implemented")
+}
+func (*UnimplementedMhistServer) StoreStream(srv Mhist_StoreStreamServer) error {
+	return status.Errorf(codes.Unimplemented, "method StoreStream not implemented")
+}
+func (*UnimplementedMhistServer) Retrieve(ctx context.Context, req *RetrieveRequest) (*RetrieveResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Retrieve not implemented")
+}
+func (*UnimplementedMhistServer) Subscribe(req *Filter, srv Mhist_SubscribeServer) error {
+	return status.Errorf(codes.Unimplemented, "method Subscribe not implemented")
+}
+func (*UnimplementedMhistServer) Latest(ctx context.Context, req *LatestRequest) (*LatestResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Latest not implemented")
+}
+func (*UnimplementedMhistServer) ListSeries(ctx context.Context, req *ListSeriesRequest) (*ListSeriesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListSeries not implemented")
+}
+func (*UnimplementedMhistServer) DeleteSeries(ctx context.Context, req *DeleteSeriesRequest) (*Nothing, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteSeries not implemented")
+}
+func (*UnimplementedMhistServer) DeleteRange(ctx context.Context, req *DeleteRangeRequest) (*Nothing, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteRange not implemented")
+}
+func (*UnimplementedMhistServer) RenameSeries(ctx context.Context, req *RenameSeriesRequest) (*Nothing, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RenameSeries not implemented")
+}
+func (*UnimplementedMhistServer) MergeSeries(ctx context.Context, req *MergeSeriesRequest) (*Nothing, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method MergeSeries not implemented")
+}
+func (*UnimplementedMhistServer) MigrateSeries(ctx context.Context, req *MigrateSeriesRequest) (*Nothing, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method MigrateSeries not implemented")
+}
+func (*UnimplementedMhistServer) Snapshot(req *SnapshotRequest, srv Mhist_SnapshotServer) error {
+	return status.Errorf(codes.Unimplemented, "method Snapshot not implemented")
+}
+func (*UnimplementedMhistServer) Rebalance(ctx context.Context, req *RebalanceRequest) (*RebalanceResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Rebalance not implemented")
+}
+func (*UnimplementedMhistServer) Replicate(srv Mhist_ReplicateServer) error {
+	return status.Errorf(codes.Unimplemented, "method Replicate not implemented")
+}
+
+func RegisterMhistServer(s *grpc.Server, srv MhistServer) {
+	s.RegisterService(&_Mhist_serviceDesc, srv)
+}
+
+func _Mhist_Store_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(MeasurementMessage)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MhistServer).Store(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/proto.Mhist/Store",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MhistServer).Store(ctx, req.(*MeasurementMessage))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Mhist_StoreStream_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(MhistServer).StoreStream(&mhistStoreStreamServer{stream})
+}
+
+type Mhist_StoreStreamServer interface {
+	SendAndClose(*Nothing) error
+	Recv() (*MeasurementMessage, error)
+	grpc.ServerStream
+}
+
+type mhistStoreStreamServer struct {
+	grpc.ServerStream
+}
+
+func (x *mhistStoreStreamServer) SendAndClose(m *Nothing) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *mhistStoreStreamServer) Recv() (*MeasurementMessage, error) {
+	m := new(MeasurementMessage)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func _Mhist_Retrieve_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(RetrieveRequest)
 	if err := dec(in); err != nil {
 		return nil, err
@@ -810,6 +2073,197 @@ func (x *mhistSubscribeServer) Send(m *MeasurementMessage) error {
 	return x.ServerStream.SendMsg(m)
 }
 
+func _Mhist_Latest_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(LatestRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MhistServer).Latest(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/proto.Mhist/Latest",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MhistServer).Latest(ctx, req.(*LatestRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Mhist_ListSeries_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListSeriesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MhistServer).ListSeries(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/proto.Mhist/ListSeries",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MhistServer).ListSeries(ctx, req.(*ListSeriesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Mhist_DeleteSeries_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteSeriesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MhistServer).DeleteSeries(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/proto.Mhist/DeleteSeries",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MhistServer).DeleteSeries(ctx, req.(*DeleteSeriesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Mhist_DeleteRange_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteRangeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MhistServer).DeleteRange(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/proto.Mhist/DeleteRange",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MhistServer).DeleteRange(ctx, req.(*DeleteRangeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Mhist_RenameSeries_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RenameSeriesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MhistServer).RenameSeries(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/proto.Mhist/RenameSeries",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MhistServer).RenameSeries(ctx, req.(*RenameSeriesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Mhist_MergeSeries_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(MergeSeriesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MhistServer).MergeSeries(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/proto.Mhist/MergeSeries",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MhistServer).MergeSeries(ctx, req.(*MergeSeriesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Mhist_MigrateSeries_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(MigrateSeriesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MhistServer).MigrateSeries(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/proto.Mhist/MigrateSeries",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MhistServer).MigrateSeries(ctx, req.(*MigrateSeriesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Mhist_Snapshot_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(SnapshotRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(MhistServer).Snapshot(m, &mhistSnapshotServer{stream})
+}
+
+type Mhist_SnapshotServer interface {
+	Send(*SnapshotChunk) error
+	grpc.ServerStream
+}
+
+type mhistSnapshotServer struct {
+	grpc.ServerStream
+}
+
+func (x *mhistSnapshotServer) Send(m *SnapshotChunk) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _Mhist_Rebalance_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RebalanceRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MhistServer).Rebalance(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/proto.Mhist/Rebalance",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MhistServer).Rebalance(ctx, req.(*RebalanceRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Mhist_Replicate_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(MhistServer).Replicate(&mhistReplicateServer{stream})
+}
+
+type Mhist_ReplicateServer interface {
+	Send(*ReplicationMessage) error
+	Recv() (*ReplicationAck, error)
+	grpc.ServerStream
+}
+
+type mhistReplicateServer struct {
+	grpc.ServerStream
+}
+
+func (x *mhistReplicateServer) Send(m *ReplicationMessage) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *mhistReplicateServer) Recv() (*ReplicationAck, error) {
+	m := new(ReplicationAck)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
 var _Mhist_serviceDesc = grpc.ServiceDesc{
 	ServiceName: "proto.Mhist",
 	HandlerType: (*MhistServer)(nil),
@@ -822,6 +2276,38 @@ var _Mhist_serviceDesc = grpc.ServiceDesc{
 			MethodName: "Retrieve",
 			Handler:    _Mhist_Retrieve_Handler,
 		},
+		{
+			MethodName: "Latest",
+			Handler:    _Mhist_Latest_Handler,
+		},
+		{
+			MethodName: "ListSeries",
+			Handler:    _Mhist_ListSeries_Handler,
+		},
+		{
+			MethodName: "DeleteSeries",
+			Handler:    _Mhist_DeleteSeries_Handler,
+		},
+		{
+			MethodName: "DeleteRange",
+			Handler:    _Mhist_DeleteRange_Handler,
+		},
+		{
+			MethodName: "RenameSeries",
+			Handler:    _Mhist_RenameSeries_Handler,
+		},
+		{
+			MethodName: "MergeSeries",
+			Handler:    _Mhist_MergeSeries_Handler,
+		},
+		{
+			MethodName: "MigrateSeries",
+			Handler:    _Mhist_MigrateSeries_Handler,
+		},
+		{
+			MethodName: "Rebalance",
+			Handler:    _Mhist_Rebalance_Handler,
+		},
 	},
 	Streams: []grpc.StreamDesc{
 		{
@@ -834,45 +2320,17 @@ var _Mhist_serviceDesc = grpc.ServiceDesc{
 			Handler:       _Mhist_Subscribe_Handler,
 			ServerStreams: true,
 		},
+		{
+			StreamName:    "Snapshot",
+			Handler:       _Mhist_Snapshot_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "Replicate",
+			Handler:       _Mhist_Replicate_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
 	},
 	Metadata: "proto/rpc.proto",
 }
-
-func init() { proto.RegisterFile("proto/rpc.proto", fileDescriptor_rpc_99588602b150c724) }
-
-var fileDescriptor_rpc_99588602b150c724 = []byte{
-	// 515 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x94, 0x52, 0x5d, 0x8f, 0xd2, 0x40,
-	0x14, 0x65, 0xe8, 0x82, 0xf6, 0x76, 0x05, 0xbc, 0x31, 0x2b, 0xf2, 0x60, 0x48, 0x13, 0x0d, 0xc9,
-	0x1a, 0x5c, 0x59, 0xb3, 0x9a, 0xf5, 0xcd, 0xaf, 0x60, 0x14, 0x1e, 0x06, 0x9f, 0xd5, 0x01, 0xaf,
-	0x6c, 0x23, 0x4c, 0x71, 0x66, 0xba, 0x84, 0x5f, 0xe3, 0x3f, 0xf0, 0x9f, 0xf9, 0x1f, 0xcc, 0x4c,
-	0x5b, 0x5b, 0x96, 0x60, 0xdc, 0xa7, 0xde, 0xe9, 0x3d, 0xe7, 0xce, 0xb9, 0x67, 0x0e, 0x34, 0x57,
-	0x2a, 0x36, 0xf1, 0x63, 0xb5, 0x9a, 0xf5, 0x5d, 0x85, 0x35, 0xf7, 0x09, 0x9f, 0x80, 0x3f, 0x4e,
-	0x96, 0xa4, 0xa2, 0x99, 0x58, 0x60, 0x03, 0xaa, 0x46, 0xb7, 0x59, 0x97, 0xf5, 0x3c, 0x5e, 0x35,
-	0x1a, 0xef, 0x40, 0xed, 0x52, 0x2c, 0x12, 0x6a, 0x57, 0xbb, 0xac, 0xc7, 0x78, 0x7a, 0x08, 0x4f,
-	0x21, 0x78, 0x25, 0x0c, 0xcd, 0xe3, 0xff, 0x20, 0xf9, 0x39, 0xe9, 0x18, 0x3c, 0x2e, 0xd6, 0xff,
-	0x06, 0x1f, 0xe6, 0xe0, 0x9f, 0x0c, 0x82, 0x11, 0x09, 0x9d, 0x28, 0x5a, 0x92, 0x34, 0x78, 0x02,
-	0xbe, 0xcc, 0x45, 0x3a, 0x72, 0x30, 0x68, 0xa5, 0x6b, 0xf4, 0xff, 0x8a, 0x1f, 0x56, 0x78, 0x01,
-	0xc2, 0x33, 0x08, 0x66, 0x85, 0x46, 0x37, 0x3d, 0x18, 0x60, 0xc6, 0x29, 0xa9, 0x1f, 0x56, 0x78,
-	0x19, 0x88, 0xf7, 0xc1, 0x53, 0x62, 0xdd, 0xf6, 0x1c, 0x1e, 0x32, 0x3c, 0x17, 0xeb, 0x61, 0x85,
-	0xdb, 0xc6, 0xcb, 0x3a, 0x1c, 0x98, 0xcd, 0x8a, 0xc2, 0x4f, 0x80, 0x25, 0x81, 0x23, 0xd2, 0x5a,
-	0xcc, 0x09, 0x11, 0x0e, 0xa4, 0x58, 0x92, 0x93, 0xe8, 0x73, 0x57, 0xe3, 0x53, 0x08, 0x96, 0x05,
-	0xf2, 0x8a, 0x92, 0xd2, 0x0c, 0x5e, 0x86, 0x85, 0x5f, 0xa0, 0xc9, 0xc9, 0xa8, 0x88, 0x2e, 0x89,
-	0xd3, 0x8f, 0x84, 0xb4, 0xb1, 0x56, 0x69, 0x23, 0x94, 0xc9, 0xdc, 0x4b, 0x0f, 0xd8, 0x02, 0x8f,
-	0xe4, 0x57, 0x37, 0xd6, 0xe3, 0xb6, 0xc4, 0x07, 0x50, 0xff, 0x16, 0x2d, 0x0c, 0xa9, 0x6c, 0x8b,
-	0x5b, 0xd9, 0x5d, 0x6f, 0xdd, 0x4f, 0x9e, 0x35, 0xc3, 0x77, 0xd0, 0x2c, 0xdd, 0xfe, 0x21, 0xd2,
-	0x06, 0xcf, 0xe0, 0xb0, 0xa4, 0xc1, 0x3e, 0x93, 0xb7, 0x47, 0xeb, 0x16, 0x2e, 0xfc, 0xc5, 0xa0,
-	0x55, 0xa8, 0xd5, 0xab, 0x58, 0x6a, 0xc2, 0xd7, 0xe0, 0x5f, 0x44, 0xda, 0xc4, 0x2a, 0xa2, 0x7c,
-	0xd2, 0xc3, 0xdc, 0xcf, 0x2b, 0xd8, 0xfe, 0x30, 0x07, 0xbe, 0x91, 0x46, 0x6d, 0x78, 0x41, 0xec,
-	0x7c, 0x84, 0xc6, 0x76, 0xd3, 0x2e, 0xfc, 0x9d, 0x36, 0x99, 0xc5, 0xb6, 0xc4, 0x47, 0xe5, 0x0c,
-	0x05, 0x83, 0xa3, 0x5d, 0xbd, 0x76, 0xbb, 0x2c, 0x5b, 0xe7, 0xd5, 0xe7, 0x2c, 0x7c, 0x0f, 0xf5,
-	0xd4, 0x0d, 0x3c, 0x86, 0xdb, 0x73, 0x25, 0x64, 0xb2, 0x10, 0x2a, 0x32, 0x9b, 0xcf, 0x52, 0xc8,
-	0x38, 0x8f, 0x67, 0xab, 0xd4, 0x18, 0xdb, 0xff, 0xf6, 0x05, 0xec, 0x93, 0xea, 0x76, 0xb5, 0xeb,
-	0xd9, 0x64, 0xbb, 0x43, 0xe8, 0xc3, 0x8d, 0x71, 0x6c, 0x2e, 0x22, 0x39, 0x1f, 0xfc, 0x66, 0x50,
-	0x1b, 0x59, 0xf1, 0x38, 0x80, 0xda, 0xc4, 0xc4, 0x8a, 0xf0, 0xde, 0xae, 0x9a, 0x2c, 0x2d, 0x9d,
-	0x46, 0x1e, 0xe1, 0x94, 0x8d, 0xe7, 0x10, 0x38, 0xce, 0xc4, 0x28, 0x12, 0xcb, 0x6b, 0x30, 0x7b,
-	0x0c, 0x5f, 0xc0, 0xcd, 0xdc, 0x55, 0x3c, 0xda, 0xb1, 0xd9, 0x05, 0xa8, 0x73, 0x77, 0x8f, 0xfd,
-	0xf8, 0x0c, 0xfc, 0x49, 0x32, 0xd5, 0x33, 0x15, 0x4d, 0x09, 0xb7, 0xe3, 0xd2, 0xd9, 0xaf, 0xe2,
-	0x84, 0x4d, 0xeb, 0xae, 0x77, 0xfa, 0x27, 0x00, 0x00, 0xff, 0xff, 0xf6, 0x4b, 0xb7, 0x29, 0x5d,
-	0x04, 0x00, 0x00,
-}
@@ -0,0 +1,162 @@
+package mhist
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/golang/protobuf/ptypes"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+//ErrRateLimited is returned for writes beyond the rate limit of a connection or a series
+var ErrRateLimited = errors.New("rate limited")
+
+//ErrOverloaded is returned if a write couldn't be queued in time because the store is busy
+var ErrOverloaded = errors.New("overloaded")
+
+// rejectedWrites counts the rejected writes by reason, published on the debug port under /debug/vars
+var rejectedWrites = expvar.NewMap("mhist_rejected_writes")
+
+// reasons writes are rejected for in rejectedWrites
+const (
+	rejectedConnection = "connection_rate"
+	rejectedSeries     = "series_rate"
+	rejectedQuota      = "tenant_quota"
+	rejectedQueue      = "queue_timeout"
+)
+
+// retryableError tells clients when they can retry, it becomes a ResourceExhausted status with RetryInfo
+type retryableError struct {
+	err        error
+	retryAfter time.Duration
+}
+
+func (e *retryableError) Error() string {
+	return e.err.Error()
+}
+
+func (e *retryableError) Unwrap() error {
+	return e.err
+}
+
+//RetryDelay of a write rejected with ResourceExhausted, false if the error doesn't ask to retry
+func RetryDelay(err error) (time.Duration, bool) {
+	s, ok := status.FromError(err)
+	if !ok || s.Code() != codes.ResourceExhausted {
+		return 0, false
+	}
+	for _, detail := range s.Details() {
+		if retryInfo, ok := detail.(*errdetails.RetryInfo); ok {
+			delay, err := ptypes.Duration(retryInfo.RetryDelay)
+			return delay, err == nil
+		}
+	}
+	return 0, false
+}
+
+// limiters that weren't used for this long are dropped
+const limiterIdleTimeout = time.Minute
+
+// rateLimiter limits the rate of every key on its own, e.g. of every connection
+type rateLimiter struct {
+	rate float64
+
+	mutex     sync.Mutex
+	buckets   map[string]*tokenBucket
+	lastSweep time.Time
+}
+
+func newRateLimiter(rate int) *rateLimiter {
+	if rate <= 0 {
+		return nil
+	}
+	return &rateLimiter{rate: float64(rate), buckets: map[string]*tokenBucket{}, lastSweep: time.Now()}
+}
+
+// take one token of the key, returns the time until it is available if there is none
+func (l *rateLimiter) take(key string, now time.Time) (bool, time.Duration) {
+	l.mutex.Lock()
+	bucket, ok := l.buckets[key]
+	if !ok {
+		// bursts of up to one second are accepted
+		bucket = newTokenBucket(l.rate, l.rate)
+		l.buckets[key] = bucket
+	}
+	if now.Sub(l.lastSweep) > limiterIdleTimeout {
+		l.sweep(now)
+	}
+	l.mutex.Unlock()
+
+	return bucket.take(1, now)
+}
+
+// sweep the idle buckets, they are full again anyway
+func (l *rateLimiter) sweep(now time.Time) {
+	for key, bucket := range l.buckets {
+		bucket.mutex.Lock()
+		idle := now.Sub(bucket.last) > limiterIdleTimeout
+		bucket.mutex.Unlock()
+		if idle {
+			delete(l.buckets, key)
+		}
+	}
+	l.lastSweep = now
+}
+
+// writeLimits of the server, nil limiters don't limit
+type writeLimits struct {
+	mutex       sync.RWMutex
+	connections *rateLimiter
+	series      *rateLimiter
+}
+
+// set the rates, the limiters are replaced if their rate changed
+func (l *writeLimits) set(connectionRate, seriesRate int) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if rateOf(l.connections) != connectionRate {
+		l.connections = newRateLimiter(connectionRate)
+	}
+	if rateOf(l.series) != seriesRate {
+		l.series = newRateLimiter(seriesRate)
+	}
+}
+
+func rateOf(l *rateLimiter) int {
+	if l == nil {
+		return 0
+	}
+	return int(l.rate)
+}
+
+// admit a write of the named series by the request
+func (l *writeLimits) admit(ctx context.Context, name string) error {
+	now := time.Now()
+	l.mutex.RLock()
+	defer l.mutex.RUnlock()
+
+	if l.connections != nil {
+		connection := ""
+		if p, ok := peer.FromContext(ctx); ok {
+			connection = p.Addr.String()
+		}
+		if ok, wait := l.connections.take(connection, now); !ok {
+			rejectedWrites.Add(rejectedConnection, 1)
+			return &retryableError{err: fmt.Errorf("%w: more than %v measurements per second on this connection", ErrRateLimited, l.connections.rate), retryAfter: wait}
+		}
+	}
+	if l.series != nil {
+		if ok, wait := l.series.take(name, now); !ok {
+			rejectedWrites.Add(rejectedSeries, 1)
+			return &retryableError{err: fmt.Errorf("%w: more than %v measurements per second of %v", ErrRateLimited, l.series.rate, name), retryAfter: wait}
+		}
+	}
+	return nil
+}
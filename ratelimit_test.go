@@ -0,0 +1,83 @@
+package mhist
+
+import (
+	"context"
+	"os"
+	"strconv"
+	"testing"
+
+	"github.com/alexmorten/mhist/models"
+	"github.com/alexmorten/mhist/proto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func Test_RateLimits(t *testing.T) {
+	dataPath := "test_data"
+	defer os.RemoveAll(dataPath)
+
+	server, err := NewServer(ServerConfig{DataPath: dataPath, GrpcPort: 16730, ConnectionRate: 5, SeriesRate: 2})
+	require.NoError(t, err)
+	go server.grpcHandler.Run()
+	defer server.Shutdown()
+
+	conn, err := grpc.Dial("localhost:16730", grpc.WithInsecure())
+	require.NoError(t, err)
+	defer conn.Close()
+	client := proto.NewMhistClient(conn)
+	store := func(name string) error {
+		_, err := client.Store(context.Background(), &proto.MeasurementMessage{
+			Name:        name,
+			Measurement: proto.MeasurementFromModel(&models.Numerical{Ts: 1, Value: 1}),
+		}, grpc.WaitForReady(true))
+		return err
+	}
+	rejected := func(reason string) int64 {
+		value := rejectedWrites.Get(reason)
+		if value == nil {
+			return 0
+		}
+		count, _ := strconv.ParseInt(value.String(), 10, 64)
+		return count
+	}
+
+	t.Run("writes beyond the rate of a series are rejected with a retry delay", func(t *testing.T) {
+		before := rejected(rejectedSeries)
+		require.NoError(t, store("temperature"))
+		require.NoError(t, store("temperature"))
+
+		err := store("temperature")
+		assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+		delay, ok := RetryDelay(err)
+		assert.True(t, ok)
+		assert.True(t, delay > 0)
+		assert.Equal(t, before+1, rejected(rejectedSeries))
+	})
+
+	t.Run("writes beyond the rate of a connection are rejected", func(t *testing.T) {
+		before := rejected(rejectedConnection)
+		require.NoError(t, store("humidity"))
+		require.NoError(t, store("humidity"))
+
+		err := store("pressure")
+		assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+		assert.Equal(t, before+1, rejected(rejectedConnection))
+	})
+
+	t.Run("writes claiming to be forwarded by a cluster node are limited outside of a cluster", func(t *testing.T) {
+		_, err := client.Store(metadata.AppendToOutgoingContext(context.Background(), federatedHeader, "true"), &proto.MeasurementMessage{
+			Name:        "wind",
+			Measurement: proto.MeasurementFromModel(&models.Numerical{Ts: 1, Value: 1}),
+		})
+		assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+	})
+
+	t.Run("other errors don't ask to retry", func(t *testing.T) {
+		_, ok := RetryDelay(status.Error(codes.InvalidArgument, "invalid"))
+		assert.False(t, ok)
+	})
+}
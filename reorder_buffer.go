@@ -0,0 +1,68 @@
+package mhist
+
+import (
+	"sort"
+	"time"
+)
+
+// reorderBuffer holds back measurements for a tolerance window, so measurements that arrive slightly out of order
+// are still written in order
+type reorderBuffer struct {
+	window time.Duration
+	// messages sorted by timestamp
+	messages []addMessage
+	// highestSeenTs of all measurements that were added
+	highestSeenTs int64
+	// watermark is the highest timestamp that was released from the buffer
+	watermark int64
+}
+
+func newReorderBuffer(window time.Duration) *reorderBuffer {
+	return &reorderBuffer{window: window}
+}
+
+// add the message to the buffer, returns false if the message is older than what was already released
+func (b *reorderBuffer) add(m addMessage) bool {
+	ts := m.measurement.Ts
+	if ts < b.watermark {
+		return false
+	}
+	if ts > b.highestSeenTs {
+		b.highestSeenTs = ts
+	}
+
+	i := sort.Search(len(b.messages), func(i int) bool { return b.messages[i].measurement.Ts > ts })
+	b.messages = append(b.messages, addMessage{})
+	copy(b.messages[i+1:], b.messages[i:])
+	b.messages[i] = m
+	return true
+}
+
+// release all messages that are older than the window, relative to the newest measurement or the current time
+func (b *reorderBuffer) release(now time.Time) []addMessage {
+	limit := b.highestSeenTs
+	if nowTs := now.UnixNano(); nowTs > limit {
+		limit = nowTs
+	}
+	return b.releaseUntil(limit - b.window.Nanoseconds())
+}
+
+// releaseAll messages in the buffer, regardless of the window
+func (b *reorderBuffer) releaseAll() []addMessage {
+	return b.releaseUntil(b.highestSeenTs)
+}
+
+func (b *reorderBuffer) releaseUntil(ts int64) []addMessage {
+	i := sort.Search(len(b.messages), func(i int) bool { return b.messages[i].measurement.Ts > ts })
+	if i == 0 {
+		return nil
+	}
+
+	released := make([]addMessage, i)
+	copy(released, b.messages[:i])
+	b.messages = b.messages[i:]
+	if lastTs := released[i-1].measurement.Ts; lastTs > b.watermark {
+		b.watermark = lastTs
+	}
+	return released
+}
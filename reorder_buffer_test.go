@@ -0,0 +1,45 @@
+package mhist
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_ReorderBuffer(t *testing.T) {
+	messageAt := func(ts int64) addMessage {
+		return addMessage{measurement: SerializedMeasurement{ID: 1, Ts: ts}}
+	}
+	timestamps := func(messages []addMessage) (ts []int64) {
+		for _, m := range messages {
+			ts = append(ts, m.measurement.Ts)
+		}
+		return
+	}
+	epoch := time.Unix(0, 0)
+
+	t.Run("releases measurements in order once they are older than the window", func(t *testing.T) {
+		b := newReorderBuffer(100)
+		assert.True(t, b.add(messageAt(1000)))
+		assert.True(t, b.add(messageAt(950)))
+		assert.Empty(t, b.release(epoch))
+
+		assert.True(t, b.add(messageAt(1050)))
+		assert.Equal(t, []int64{950}, timestamps(b.release(epoch)))
+
+		assert.True(t, b.add(messageAt(1100)))
+		assert.Equal(t, []int64{1000}, timestamps(b.release(epoch)))
+
+		assert.False(t, b.add(messageAt(990)), "older than what was already released")
+		assert.True(t, b.add(messageAt(1020)))
+		assert.Equal(t, []int64{1020, 1050, 1100}, timestamps(b.releaseAll()))
+	})
+
+	t.Run("without a window measurements pass through directly", func(t *testing.T) {
+		b := newReorderBuffer(0)
+		assert.True(t, b.add(messageAt(1000)))
+		assert.Equal(t, []int64{1000}, timestamps(b.release(epoch)))
+		assert.False(t, b.add(messageAt(999)))
+	})
+}
@@ -0,0 +1,353 @@
+package mhist
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/alexmorten/mhist/models"
+	"github.com/alexmorten/mhist/proto"
+	"google.golang.org/grpc"
+)
+
+//ErrReadOnly is returned for writes to a follower, they have to go to its leader
+var ErrReadOnly = errors.New("read-only follower, writes have to go to the leader")
+
+// file in the data directory a follower persists its replication position in
+var replicationPositionPath = "replication.position"
+
+// interval in which a follower acknowledges the position it persisted last
+const replicationAckInterval = time.Second
+
+// time a follower waits before reconnecting to its leader
+const replicationRetryInterval = time.Second
+
+// follower replicates all changes of a leader into its DB
+type follower struct {
+	db     *DB
+	leader string
+	name   string
+	// dialOptions secure and authenticate the connection to the leader
+	dialOptions []grpc.DialOption
+	// position up to which all changes were applied
+	position *proto.ReplicationPosition
+	// persisted is the position last persisted, it is acknowledged to the leader
+	persisted *proto.ReplicationPosition
+	// persistInterval in which the position is persisted, the DB is flushed each time.
+	// It is the commit interval of the DB, so followers don't commit more often than leaders
+	persistInterval time.Duration
+	// mutex guards the positions, it is held while the position is persisted or a snapshot is installed
+	mutex sync.Mutex
+
+	stopChan  chan struct{}
+	waitGroup sync.WaitGroup
+}
+
+func newFollower(db *DB, leader, name string, dialOptions []grpc.DialOption) (*follower, error) {
+	position, err := readReplicationPosition(db.dir)
+	if err != nil {
+		return nil, err
+	}
+	persistInterval := db.options.CommitInterval
+	if persistInterval == 0 {
+		persistInterval = timeBetweenWrites
+	}
+	return &follower{
+		db:              db,
+		leader:          leader,
+		name:            name,
+		dialOptions:     dialOptions,
+		position:        position,
+		persisted:       position,
+		persistInterval: persistInterval,
+		stopChan:        make(chan struct{}),
+	}, nil
+}
+
+// start replicating in the background until shutdown
+func (f *follower) start() {
+	f.waitGroup.Add(1)
+	go func() {
+		defer f.waitGroup.Done()
+		f.run()
+	}()
+}
+
+// shutdown replication after the position was persisted
+func (f *follower) shutdown() {
+	close(f.stopChan)
+	f.waitGroup.Wait()
+}
+
+func (f *follower) run() {
+	for {
+		err := f.replicate()
+		persistErr := f.persistPosition()
+		if persistErr != nil {
+			log.Println(persistErr)
+		}
+
+		select {
+		case <-f.stopChan:
+			return
+		default:
+		}
+		log.Printf("replication from %v stopped: %v", f.leader, err)
+
+		select {
+		case <-f.stopChan:
+			return
+		case <-time.After(replicationRetryInterval):
+		}
+	}
+}
+
+// replicate until the connection fails or replication is stopped
+func (f *follower) replicate() error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-f.stopChan:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	conn, err := grpc.DialContext(ctx, f.leader, f.dialOptions...)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	stream, err := proto.NewMhistClient(conn).Replicate(ctx)
+	if err != nil {
+		return err
+	}
+	err = stream.Send(&proto.ReplicationAck{Follower: f.name, Position: f.currentPosition()})
+	if err != nil {
+		return err
+	}
+
+	// acks are sent by a single goroutine, concurrently to receiving changes
+	go func() {
+		ticker := time.NewTicker(replicationAckInterval)
+		defer ticker.Stop()
+		lastPersisted := time.Now()
+		for {
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				return
+			}
+
+			var err error
+			if time.Since(lastPersisted) >= f.persistInterval {
+				err = f.persistPosition()
+				lastPersisted = time.Now()
+			}
+			if err == nil {
+				err = stream.Send(&proto.ReplicationAck{Follower: f.name, Position: f.persistedPosition()})
+			}
+			if err != nil {
+				log.Println(err)
+				cancel()
+				return
+			}
+		}
+	}()
+
+	var snapshot *snapshotInstallation
+	defer func() {
+		if snapshot != nil {
+			snapshot.abort()
+		}
+	}()
+	for {
+		message, err := stream.Recv()
+		if err != nil {
+			return err
+		}
+
+		switch m := message.Message.(type) {
+		case *proto.ReplicationMessage_SnapshotChunk:
+			if snapshot == nil {
+				snapshot = f.installSnapshot()
+			}
+			_, err = snapshot.writer.Write(m.SnapshotChunk)
+		case *proto.ReplicationMessage_SnapshotDone:
+			if snapshot == nil {
+				return errors.New("leader completed a snapshot it didn't send")
+			}
+			err = snapshot.finish(m.SnapshotDone)
+			snapshot = nil
+		case *proto.ReplicationMessage_Change:
+			err = f.apply(m.Change)
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// snapshotInstallation receives the chunks of a snapshot while it is installed
+type snapshotInstallation struct {
+	follower *follower
+	writer   *io.PipeWriter
+	result   chan error
+}
+
+// installSnapshot from the chunks written to the returned installation, the position can't change until it is finished or aborted
+func (f *follower) installSnapshot() *snapshotInstallation {
+	f.mutex.Lock()
+
+	reader, writer := io.Pipe()
+	installation := &snapshotInstallation{follower: f, writer: writer, result: make(chan error, 1)}
+	go func() {
+		err := f.db.installSnapshot(reader)
+		if err != nil {
+			reader.CloseWithError(err)
+		} else {
+			// the padding after the end of the tar archive
+			io.Copy(ioutil.Discard, reader)
+		}
+		installation.result <- err
+	}()
+	return installation
+}
+
+func (i *snapshotInstallation) finish(position *proto.ReplicationPosition) error {
+	defer i.follower.mutex.Unlock()
+
+	i.writer.Close()
+	err := <-i.result
+	if err != nil {
+		return fmt.Errorf("couldn't install snapshot: %w", err)
+	}
+
+	i.follower.position = position
+	err = writeReplicationPosition(i.follower.db.dir, position)
+	if err != nil {
+		return err
+	}
+	i.follower.persisted = position
+	return nil
+}
+
+func (i *snapshotInstallation) abort() {
+	defer i.follower.mutex.Unlock()
+
+	i.writer.CloseWithError(errors.New("replication stopped"))
+	<-i.result
+}
+
+// apply the change to the DB, changes the leader accepted but the follower doesn't are skipped.
+// This happens to changes applied a second time after a crash, which also duplicates measurements.
+func (f *follower) apply(change *proto.ReplicatedChange) error {
+	expected := f.currentPosition().Seq + 1
+	if change.Seq != expected {
+		return fmt.Errorf("expected change %v but got %v", expected, change.Seq)
+	}
+
+	var err error
+	switch c := change.Change.(type) {
+	case *proto.ReplicatedChange_Measurement:
+		measurement := c.Measurement.Measurement.ToModelWithDefinedTs()
+		if measurement == nil {
+			err = ErrMeasurementMissingType
+			break
+		}
+		err = f.db.Write(c.Measurement.Name, measurement)
+	case *proto.ReplicatedChange_DeleteSeries:
+		err = f.db.DeleteSeries(c.DeleteSeries.Names)
+	case *proto.ReplicatedChange_DeleteRange:
+		err = f.db.DeleteRange(c.DeleteRange.Names, c.DeleteRange.Start, c.DeleteRange.End)
+	case *proto.ReplicatedChange_RenameSeries:
+		err = f.db.RenameSeries(c.RenameSeries.Name, c.RenameSeries.NewName)
+	case *proto.ReplicatedChange_MergeSeries:
+		err = f.db.MergeSeries(c.MergeSeries.Name, c.MergeSeries.Into)
+	case *proto.ReplicatedChange_MigrateSeries:
+		err = f.db.MigrateSeries(c.MigrateSeries.Name, models.MeasurementType(c.MigrateSeries.Type))
+	}
+	if errors.Is(err, ErrClosed) {
+		return err
+	}
+	if err != nil {
+		log.Printf("skipping replicated change %v: %v", change.Seq, err)
+	}
+
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.position = &proto.ReplicationPosition{Epoch: f.position.Epoch, Seq: change.Seq}
+	return nil
+}
+
+func (f *follower) currentPosition() *proto.ReplicationPosition {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return f.position
+}
+
+func (f *follower) persistedPosition() *proto.ReplicationPosition {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return f.persisted
+}
+
+// persistPosition once all changes up to it are on disk
+func (f *follower) persistPosition() error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	position := f.position
+	err := f.db.Flush()
+	if err != nil {
+		return err
+	}
+	err = writeReplicationPosition(f.db.dir, position)
+	if err != nil {
+		return err
+	}
+	f.persisted = position
+	return nil
+}
+
+func (f *follower) status() *leaderStatus {
+	position := f.currentPosition()
+	return &leaderStatus{Address: f.leader, Epoch: position.Epoch, Seq: position.Seq}
+}
+
+func readReplicationPosition(dir string) (*proto.ReplicationPosition, error) {
+	position := &proto.ReplicationPosition{}
+	b, err := ioutil.ReadFile(filepath.Join(dir, replicationPositionPath))
+	if os.IsNotExist(err) {
+		return position, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	err = json.Unmarshal(b, position)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't read replication position: %w", err)
+	}
+	return position, nil
+}
+
+func writeReplicationPosition(dir string, position *proto.ReplicationPosition) error {
+	b, err := json.Marshal(position)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomically(filepath.Join(dir, replicationPositionPath), func(w io.Writer) error {
+		_, err := w.Write(b)
+		return err
+	})
+}
@@ -0,0 +1,315 @@
+package mhist
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/alexmorten/mhist/models"
+	"github.com/alexmorten/mhist/proto"
+	protobuf "github.com/golang/protobuf/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// changes kept in the replication log, followers that fall further behind catch up with a snapshot
+const replicationLogSize = 64 * 1024
+
+// bytes of the changes kept in the replication log, raw values can make changes large
+const replicationLogBytes = 32 * 1024 * 1024
+
+// changes sent to a follower before the log is checked again
+const replicationBatchSize = 1024
+
+// file in the data directory of a leader the position of its log is kept in across a clean restart
+var replicationLogPositionPath = "replication.log.position"
+
+// replicationLog keeps the latest changes of a DB in memory, so followers can tail them.
+// It is a Subscriber for the written measurements, the other changes are appended by the DB.
+type replicationLog struct {
+	// epoch changes whenever the sequence numbers start over, e.g. after a crash
+	epoch   int64
+	lastSeq int64
+	// entries are the changes after startSeq, the oldest ones are dropped once maxEntries or maxBytes are exceeded
+	entries    []*proto.ReplicatedChange
+	startSeq   int64
+	size       int
+	maxEntries int
+	maxBytes   int
+	// appended is closed and replaced whenever a change is appended
+	appended chan struct{}
+	acks     map[string]followerStatus
+
+	mutex sync.Mutex
+}
+
+type followerStatus struct {
+	Epoch     int64     `json:"epoch"`
+	Seq       int64     `json:"seq"`
+	Lag       int64     `json:"lag"`
+	AckedAt   time.Time `json:"acked_at"`
+	Connected bool      `json:"connected"`
+}
+
+// replicationStatus as shown on the debug port
+type replicationStatus struct {
+	Epoch     int64                     `json:"epoch"`
+	Seq       int64                     `json:"seq"`
+	Followers map[string]followerStatus `json:"followers"`
+	// Leader is only set on followers
+	Leader *leaderStatus `json:"leader,omitempty"`
+}
+
+type leaderStatus struct {
+	Address string `json:"address"`
+	Epoch   int64  `json:"epoch"`
+	Seq     int64  `json:"seq"`
+}
+
+func newReplicationLog(maxEntries, maxBytes int) *replicationLog {
+	l := &replicationLog{
+		maxEntries: maxEntries,
+		maxBytes:   maxBytes,
+		appended:   make(chan struct{}),
+		acks:       map[string]followerStatus{},
+	}
+	l.reset()
+	return l
+}
+
+// openReplicationLog continuing the epoch and sequence numbers the log in dir was closed with,
+// so followers that were up to date can resume. After a crash the log starts with a new epoch.
+func openReplicationLog(dir string, maxEntries, maxBytes int) (*replicationLog, error) {
+	l := newReplicationLog(maxEntries, maxBytes)
+	path := filepath.Join(dir, replicationLogPositionPath)
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return l, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	position := &proto.ReplicationPosition{}
+	err = json.Unmarshal(b, position)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't read replication log position: %w", err)
+	}
+	// the position is only valid until the first change, it mustn't be continued after a crash
+	err = os.Remove(path)
+	if err != nil {
+		return nil, err
+	}
+
+	l.epoch = position.Epoch
+	l.lastSeq = position.Seq
+	l.startSeq = position.Seq
+	return l, nil
+}
+
+// close the log, the position is kept in dir for the next time it is opened
+func (l *replicationLog) close(dir string) error {
+	b, err := json.Marshal(l.position())
+	if err != nil {
+		return err
+	}
+	return writeFileAtomically(filepath.Join(dir, replicationLogPositionPath), func(w io.Writer) error {
+		_, err := w.Write(b)
+		return err
+	})
+}
+
+// reset the log with a new epoch, followers have to catch up with a snapshot afterwards
+func (l *replicationLog) reset() {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.epoch = time.Now().UnixNano()
+	l.lastSeq = 0
+	l.startSeq = 0
+	l.entries = nil
+	l.size = 0
+}
+
+// Notify for the Subscriber interface
+func (l *replicationLog) Notify(name string, measurement models.Measurement) {
+	l.append(&proto.ReplicatedChange{Change: &proto.ReplicatedChange_Measurement{Measurement: &proto.MeasurementMessage{
+		Name:        name,
+		Measurement: proto.MeasurementFromModel(measurement),
+	}}})
+}
+
+func (l *replicationLog) append(change *proto.ReplicatedChange) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.lastSeq++
+	change.Seq = l.lastSeq
+	l.entries = append(l.entries, change)
+	l.size += protobuf.Size(change)
+
+	dropped := 0
+	for dropped < len(l.entries) && (len(l.entries)-dropped > l.maxEntries || l.size > l.maxBytes) {
+		l.size -= protobuf.Size(l.entries[dropped])
+		l.entries[dropped] = nil
+		dropped++
+	}
+	l.entries = l.entries[dropped:]
+	l.startSeq += int64(dropped)
+
+	close(l.appended)
+	l.appended = make(chan struct{})
+}
+
+func (l *replicationLog) position() *proto.ReplicationPosition {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	return &proto.ReplicationPosition{Epoch: l.epoch, Seq: l.lastSeq}
+}
+
+// changesAfter position, at most max of them. Returns false if the changes aren't in the log (anymore),
+// the returned channel is closed once further changes are appended
+func (l *replicationLog) changesAfter(position *proto.ReplicationPosition, max int) ([]*proto.ReplicatedChange, <-chan struct{}, bool) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	seq := position.Seq
+	if position.Epoch != l.epoch || seq > l.lastSeq || seq < l.startSeq {
+		return nil, nil, false
+	}
+
+	from := int(seq - l.startSeq)
+	to := len(l.entries)
+	if to-from > max {
+		to = from + max
+	}
+	// the entries are cleared when they are dropped, while the changes are still sent
+	changes := append([]*proto.ReplicatedChange{}, l.entries[from:to]...)
+	return changes, l.appended, true
+}
+
+// acknowledge the position a connected follower persisted
+func (l *replicationLog) acknowledge(follower string, position *proto.ReplicationPosition) {
+	if position == nil {
+		position = &proto.ReplicationPosition{}
+	}
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.acks[follower] = followerStatus{
+		Epoch:     position.Epoch,
+		Seq:       position.Seq,
+		AckedAt:   time.Now(),
+		Connected: true,
+	}
+}
+
+func (l *replicationLog) disconnected(follower string) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	ack := l.acks[follower]
+	ack.Connected = false
+	l.acks[follower] = ack
+}
+
+func (l *replicationLog) status() replicationStatus {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	followers := map[string]followerStatus{}
+	for name, ack := range l.acks {
+		ack.Lag = -1
+		if ack.Epoch == l.epoch {
+			ack.Lag = l.lastSeq - ack.Seq
+		}
+		followers[name] = ack
+	}
+	return replicationStatus{Epoch: l.epoch, Seq: l.lastSeq, Followers: followers}
+}
+
+// Replicate streams all changes to a follower, preceded by a snapshot if it can't resume from the position in its first ack
+func (h *GrpcHandler) Replicate(stream proto.Mhist_ReplicateServer) error {
+	// followers receive every change
+	err := h.authorizeAll(stream.Context(), PermissionAdmin)
+	if err != nil {
+		return err
+	}
+	ack, err := stream.Recv()
+	if err != nil {
+		return err
+	}
+	replication := h.server.db.replication
+	follower := ack.Follower
+
+	position := ack.Position
+	if position == nil {
+		position = &proto.ReplicationPosition{}
+	}
+	if _, _, ok := replication.changesAfter(position, 0); !ok {
+		position, err = h.sendSnapshot(stream)
+		if err != nil {
+			return statusFromError(err)
+		}
+	}
+	replication.acknowledge(follower, ack.Position)
+	defer replication.disconnected(follower)
+
+	go func() {
+		for {
+			ack, err := stream.Recv()
+			if err != nil {
+				return
+			}
+			replication.acknowledge(follower, ack.Position)
+		}
+	}()
+
+	for {
+		changes, appended, ok := replication.changesAfter(position, replicationBatchSize)
+		if !ok {
+			return status.Error(codes.OutOfRange, "the follower fell too far behind and has to catch up with a snapshot")
+		}
+		for _, change := range changes {
+			err := stream.Send(&proto.ReplicationMessage{Message: &proto.ReplicationMessage_Change{Change: change}})
+			if err != nil {
+				return err
+			}
+			position = &proto.ReplicationPosition{Epoch: position.Epoch, Seq: change.Seq}
+		}
+		if len(changes) > 0 {
+			continue
+		}
+
+		select {
+		case <-appended:
+		case <-stream.Context().Done():
+			return stream.Context().Err()
+		}
+	}
+}
+
+// sendSnapshot of the DB, it contains at least all changes up to the returned position.
+// Changes written while the snapshot was taken might be contained as well and are applied twice by the follower.
+func (h *GrpcHandler) sendSnapshot(stream proto.Mhist_ReplicateServer) (*proto.ReplicationPosition, error) {
+	writer := bufio.NewWriterSize(&chunkWriter{send: func(chunk []byte) error {
+		return stream.Send(&proto.ReplicationMessage{Message: &proto.ReplicationMessage_SnapshotChunk{SnapshotChunk: chunk}})
+	}}, snapshotChunkSize)
+	position, err := h.server.db.writeReplicationSnapshot(writer)
+	if err == nil {
+		err = writer.Flush()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	err = stream.Send(&proto.ReplicationMessage{Message: &proto.ReplicationMessage_SnapshotDone{SnapshotDone: position}})
+	return position, err
+}
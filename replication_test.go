@@ -0,0 +1,171 @@
+package mhist
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/alexmorten/mhist/models"
+	"github.com/alexmorten/mhist/proto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func Test_ReplicationLog(t *testing.T) {
+	log := newReplicationLog(4, 1024)
+	for i := 1; i <= 6; i++ {
+		log.Notify("temperature", &models.Numerical{Ts: int64(i), Value: float64(i)})
+	}
+	position := log.position()
+	assert.Equal(t, int64(6), position.Seq)
+
+	t.Run("changes are read after a position", func(t *testing.T) {
+		changes, _, ok := log.changesAfter(&proto.ReplicationPosition{Epoch: position.Epoch, Seq: 3}, 2)
+		require.True(t, ok)
+		require.Len(t, changes, 2)
+		assert.Equal(t, int64(4), changes[0].Seq)
+		assert.Equal(t, int64(5), changes[1].Seq)
+	})
+
+	t.Run("overwritten changes and other epochs can't be resumed from", func(t *testing.T) {
+		_, _, ok := log.changesAfter(&proto.ReplicationPosition{Epoch: position.Epoch, Seq: 1}, 10)
+		assert.False(t, ok)
+		_, _, ok = log.changesAfter(&proto.ReplicationPosition{Epoch: position.Epoch + 1, Seq: 5}, 10)
+		assert.False(t, ok)
+	})
+
+	t.Run("readers are woken up by new changes", func(t *testing.T) {
+		changes, appended, ok := log.changesAfter(position, 10)
+		require.True(t, ok)
+		assert.Empty(t, changes)
+
+		log.append(&proto.ReplicatedChange{Change: &proto.ReplicatedChange_DeleteSeries{DeleteSeries: &proto.DeleteSeriesRequest{Names: []string{"temperature"}}}})
+		<-appended
+		changes, _, _ = log.changesAfter(position, 10)
+		require.Len(t, changes, 1)
+		assert.Equal(t, []string{"temperature"}, changes[0].GetDeleteSeries().Names)
+	})
+}
+
+func Test_ReplicationLogBytes(t *testing.T) {
+	log := newReplicationLog(100, 100)
+	for i := 1; i <= 3; i++ {
+		log.Notify("raw", &models.Raw{Ts: int64(i), Value: make([]byte, 60)})
+	}
+
+	_, _, ok := log.changesAfter(&proto.ReplicationPosition{Epoch: log.position().Epoch, Seq: 1}, 10)
+	assert.False(t, ok)
+	changes, _, ok := log.changesAfter(&proto.ReplicationPosition{Epoch: log.position().Epoch, Seq: 2}, 10)
+	require.True(t, ok)
+	require.Len(t, changes, 1)
+	assert.Equal(t, int64(3), changes[0].Seq)
+}
+
+func Test_ReplicationLogAcrossRestarts(t *testing.T) {
+	dataPath := "test_data"
+	defer os.RemoveAll(dataPath)
+
+	db, err := Open(dataPath, Options{})
+	require.NoError(t, err)
+	require.NoError(t, db.Write("temperature", &models.Numerical{Ts: 1, Value: 1}))
+	position := db.replication.position()
+	require.NoError(t, db.Close())
+
+	db, err = Open(dataPath, Options{})
+	require.NoError(t, err)
+	defer db.Close()
+
+	t.Run("followers that were up to date can resume after a clean restart", func(t *testing.T) {
+		assert.Equal(t, position, db.replication.position())
+		_, _, ok := db.replication.changesAfter(position, 10)
+		assert.True(t, ok)
+		_, _, ok = db.replication.changesAfter(&proto.ReplicationPosition{Epoch: position.Epoch, Seq: 0}, 10)
+		assert.False(t, ok)
+	})
+
+	t.Run("the position isn't continued after a crash", func(t *testing.T) {
+		_, err := os.Stat(filepath.Join(dataPath, replicationLogPositionPath))
+		assert.True(t, os.IsNotExist(err))
+	})
+}
+
+func Test_Replication(t *testing.T) {
+	leaderPath := "test_data"
+	followerPath := "test_follower_data"
+	defer os.RemoveAll(leaderPath)
+	defer os.RemoveAll(followerPath)
+
+	leader, err := NewServer(ServerConfig{DataPath: leaderPath, GrpcPort: 16666})
+	require.NoError(t, err)
+	go leader.grpcHandler.Run()
+	defer leader.Shutdown()
+
+	for i := 1; i <= 10; i++ {
+		require.NoError(t, leader.db.Write("temperature", &models.Numerical{Ts: int64(i), Value: float64(i)}))
+	}
+
+	startFollower := func() *Server {
+		follower, err := NewServer(ServerConfig{DataPath: followerPath, Leader: "localhost:16666", FollowerName: "follower"})
+		require.NoError(t, err)
+		follower.follower.start()
+		return follower
+	}
+	eventuallyReplicated := func(t *testing.T, follower *Server, expected map[string][]models.Measurement) {
+		var result map[string][]models.Measurement
+		var err error
+		for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+			result, err = follower.db.Query(1, 100, models.FilterDefinition{})
+			if err == nil && assert.ObjectsAreEqual(expected, result) {
+				break
+			}
+		}
+		assert.Equal(t, expected, result)
+	}
+
+	follower := startFollower()
+
+	t.Run("followers catch up with a snapshot", func(t *testing.T) {
+		expected, err := leader.db.Query(1, 100, models.FilterDefinition{})
+		require.NoError(t, err)
+		eventuallyReplicated(t, follower, expected)
+	})
+
+	t.Run("followers tail new changes", func(t *testing.T) {
+		require.NoError(t, leader.db.Write("humidity", &models.Numerical{Ts: 11, Value: 60}))
+		require.NoError(t, leader.db.RenameSeries("temperature", "temp"))
+		require.NoError(t, leader.db.DeleteRange([]string{"temp"}, 1, 5))
+
+		expected, err := leader.db.Query(1, 100, models.FilterDefinition{})
+		require.NoError(t, err)
+		eventuallyReplicated(t, follower, expected)
+	})
+
+	t.Run("followers are read-only", func(t *testing.T) {
+		_, err := follower.grpcHandler.Store(context.Background(), &proto.MeasurementMessage{
+			Name:        "humidity",
+			Measurement: proto.MeasurementFromModel(&models.Numerical{Ts: 12, Value: 61}),
+		})
+		assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+	})
+
+	t.Run("restarted followers resume from their position", func(t *testing.T) {
+		follower.Shutdown()
+		require.NoError(t, leader.db.Write("humidity", &models.Numerical{Ts: 12, Value: 61}))
+
+		position, err := readReplicationPosition(followerPath)
+		require.NoError(t, err)
+		assert.Equal(t, leader.db.replication.position().Epoch, position.Epoch)
+
+		follower = startFollower()
+		expected, err := leader.db.Query(1, 100, models.FilterDefinition{})
+		require.NoError(t, err)
+		eventuallyReplicated(t, follower, expected)
+		assert.True(t, leader.db.replication.status().Followers["follower"].Connected)
+	})
+
+	follower.Shutdown()
+}
@@ -1,84 +1,364 @@
 package mhist
 
 import (
+	"context"
+	"errors"
 	"log"
 	"os"
-	"os/signal"
 	"sync"
-	"syscall"
+	"time"
+
+	"github.com/alexmorten/mhist/proto"
+	"google.golang.org/grpc"
 )
 
 //Server is the handler for requests
 type Server struct {
-	store        *Store
+	db           *DB
 	grpcHandler  *GrpcHandler
 	debugHandler *DebugHandler
-	waitGroup    *sync.WaitGroup
+	// follower is nil unless the server replicates from a leader
+	follower *follower
+	// federation is nil unless the server has peers or is part of a cluster
+	federation *federation
+	// certificates are nil unless the server uses TLS
+	certificates *certificates
+	// authenticator is nil unless requests need a token
+	authenticator *authenticator
+	// limits of writes, in addition to the ingest rates of tenants
+	limits writeLimits
+	// tenants is nil unless the server hosts several tenants
+	tenants *tenants
+	// cluster is nil unless series are sharded across several servers
+	cluster *cluster
+	// config the server runs with, changes of settings that can't be reloaded are compared to it
+	config      ServerConfig
+	reloadMutex sync.Mutex
+
+	shutdownOnce sync.Once
+	// shutdownDone is closed once everything is persisted
+	shutdownDone chan struct{}
 }
 
+// defaultShutdownTimeout is how long running requests are waited for on shutdown by default
+const defaultShutdownTimeout = 10 * time.Second
+
 //ServerConfig ...
 type ServerConfig struct {
 	GrpcPort   int
 	DebugPort  int
 	MemorySize int
 	DiskSize   int
+	// DataPath is the directory the measurements are stored in
+	DataPath string
+	// ReorderWindow is how long measurements are held back to be written in order
+	ReorderWindow time.Duration
+	// Backend is either BackendDisk (the default) or BackendMemory
+	Backend string
+	// Archive receives old rotated files, optional
+	Archive Archive
+	// ArchiveAfter is the age after which rotated files are moved to the Archive
+	ArchiveAfter time.Duration
+	// Leader is the grpc address of the mhist to replicate from, the server is a read-only follower if it is set
+	Leader string
+	// FollowerName identifies the follower in the replication status of the leader, the hostname by default
+	FollowerName string
+	// Peers that Retrieve, Subscribe and ListSeries are fanned out to
+	Peers []Peer
+	// PeerTimeout bounds how long peers are waited for, 5 seconds by default
+	PeerTimeout time.Duration
+	// PrefixPeers prefixes the series of peers with the peer name and a slash, otherwise series of the same name are merged
+	PrefixPeers bool
+	// ClusterNodes are all servers of the cluster including this one, series are sharded across them by name
+	ClusterNodes []Peer
+	// ClusterSelf is the name of this server in ClusterNodes
+	ClusterSelf string
+	// TLS secures the listeners and the connections to other servers, plain text if nil
+	TLS *TLSConfig
+	// Auth requires tokens for all requests, anyone can read and write if nil
+	Auth *AuthConfig
+	// Tenants enables the tenant header and tokens bound to tenants, requests for tenants are rejected if nil
+	Tenants *TenantConfig
+	// ConnectionRate bounds the measurements per second written over a connection, 0 doesn't limit them
+	ConnectionRate int
+	// SeriesRate bounds the measurements per second written to a series, 0 doesn't limit them
+	SeriesRate int
+	// QueueTimeout bounds how long writes wait for the busy store before they are rejected, 0 waits as long as it takes
+	QueueTimeout time.Duration
+	// Cardinality limits the number of series and categorical values of the DB and of every tenant
+	Cardinality CardinalityLimits
+	// RetrieveWindow is how far back Retrieve reads if the request has no start, 1 hour by default
+	RetrieveWindow time.Duration
+	// CommitSize is the amount of bytes written before they are committed to disk, 128KiB by default
+	CommitSize int
+	// CommitInterval in which written measurements are committed to disk, 20 seconds by default
+	CommitInterval time.Duration
+	// ShutdownTimeout bounds how long running requests are waited for on shutdown, 10 seconds by default
+	ShutdownTimeout time.Duration
 }
 
-//NewServer returns a new Server
-func NewServer(config ServerConfig) *Server {
-	diskStore, err := NewDiskStore(config.MemorySize, config.DiskSize)
+//NewServer opens the DB in the configured data path and returns a new Server serving it
+func NewServer(config ServerConfig) (*Server, error) {
+	err := config.validate()
 	if err != nil {
-		panic(err)
+		return nil, err
+	}
+	if config.Leader != "" && config.Backend == BackendMemory {
+		return nil, errors.New("followers need the disk backend to install snapshots of the leader")
+	}
+	if config.Leader != "" && config.Tenants != nil {
+		return nil, errors.New("followers only replicate the default tenant, they can't host tenants")
+	}
+	var certificates *certificates
+	if config.TLS != nil {
+		var err error
+		certificates, err = newCertificates(*config.TLS)
+		if err != nil {
+			return nil, err
+		}
+	}
+	dialOptions := []grpc.DialOption{certificates.dialOption()}
+	var authenticator *authenticator
+	if config.Auth != nil {
+		var err error
+		authenticator, err = newAuthenticator(*config.Auth)
+		if err != nil {
+			return nil, err
+		}
+		if config.Auth.PeerToken != "" {
+			dialOptions = append(dialOptions, grpc.WithPerRPCCredentials(TokenCredentials(config.Auth.PeerToken)))
+		}
 	}
 
-	store := NewStore(diskStore)
+	options := Options{
+		MemorySize:     config.MemorySize,
+		DiskSize:       config.DiskSize,
+		ReorderWindow:  config.ReorderWindow,
+		Backend:        config.Backend,
+		Archive:        config.Archive,
+		ArchiveAfter:   config.ArchiveAfter,
+		QueueTimeout:   config.QueueTimeout,
+		Cardinality:    config.Cardinality,
+		CommitSize:     config.CommitSize,
+		CommitInterval: config.CommitInterval,
+	}
+	db, err := Open(config.DataPath, options)
+	if err != nil {
+		return nil, err
+	}
 
 	server := &Server{
-		store:     store,
-		waitGroup: &sync.WaitGroup{},
+		db:            db,
+		certificates:  certificates,
+		authenticator: authenticator,
+		limits: writeLimits{
+			connections: newRateLimiter(config.ConnectionRate),
+			series:      newRateLimiter(config.SeriesRate),
+		},
+		config:       config,
+		shutdownDone: make(chan struct{}),
+	}
+
+	if config.Tenants != nil {
+		server.tenants = newTenants(config.DataPath, options, *config.Tenants)
 	}
 
 	grpcHandler := NewGrpcHandler(server, config.GrpcPort)
 	server.grpcHandler = grpcHandler
-	store.AddSubscriber(grpcHandler)
+	grpcHandler.setRetrieveWindow(config.RetrieveWindow)
+	db.addSubscriber(grpcHandler)
 
 	server.debugHandler = &DebugHandler{
 		Port:   config.DebugPort,
 		server: server,
 	}
 
-	return server
-}
+	timeout := config.PeerTimeout
+	if timeout == 0 {
+		timeout = defaultPeerTimeout
+	}
+	switch {
+	case len(config.ClusterNodes) > 0 && len(config.Peers) > 0:
+		db.Close()
+		return nil, errors.New("a cluster node can't have federated peers")
+	case len(config.ClusterNodes) > 0:
+		// reads are fanned out to the other nodes, series of the same name are merged
+		others := []Peer{}
+		for _, node := range config.ClusterNodes {
+			if node.Name != config.ClusterSelf {
+				others = append(others, node)
+			}
+		}
+		server.federation, err = newFederation(others, timeout, false, dialOptions...)
+		if err == nil {
+			server.cluster, err = newCluster(config.ClusterSelf, config.ClusterNodes, server.federation)
+		}
+		if err != nil {
+			if server.federation != nil {
+				server.federation.close()
+			}
+			db.Close()
+			return nil, err
+		}
+	case len(config.Peers) > 0:
+		server.federation, err = newFederation(config.Peers, timeout, config.PrefixPeers, dialOptions...)
+		if err != nil {
+			db.Close()
+			return nil, err
+		}
+	}
 
-//Run the server
-func (s *Server) Run() {
-	signals := make(chan os.Signal, 1)
-	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	if config.Leader != "" {
+		name := config.FollowerName
+		if name == "" {
+			name, _ = os.Hostname()
+		}
+		server.follower, err = newFollower(db, config.Leader, name, dialOptions)
+		if err != nil {
+			if server.federation != nil {
+				server.federation.close()
+			}
+			db.Close()
+			return nil, err
+		}
+	}
 
-	go func() {
-		signal := <-signals
-		log.Printf("received %s, shutting down\n", signal)
-		s.Shutdown()
-	}()
+	return server, nil
+}
 
-	wg := &sync.WaitGroup{}
-	wg.Add(2)
+//Run the server, blocks until it is shut down and everything is persisted.
+//If a listener fails the server is shut down and the error is returned
+func (s *Server) Run() error {
+	if s.follower != nil {
+		s.follower.start()
+	}
+
+	errs := make(chan error, 2)
 	go func() {
-		s.grpcHandler.Run()
-		wg.Done()
+		errs <- s.grpcHandler.Run()
 	}()
 	go func() {
-		s.debugHandler.Run()
-		wg.Done()
+		errs <- s.debugHandler.Run()
 	}()
 
-	wg.Wait()
+	var firstErr error
+	for i := 0; i < 2; i++ {
+		err := <-errs
+		if err != nil && firstErr == nil {
+			firstErr = err
+			go s.Shutdown()
+		}
+	}
+	<-s.shutdownDone
+	return firstErr
+}
+
+//ReloadCertificates from the files of the TLS config, used for new connections
+func (s *Server) ReloadCertificates() error {
+	if s.certificates == nil {
+		return errors.New("the server doesn't use TLS")
+	}
+	return s.certificates.reload()
+}
+
+// replicationStatus of the server as a leader, and as a follower if it is one
+func (s *Server) replicationStatus() replicationStatus {
+	status := s.db.replication.status()
+	if s.follower != nil {
+		status.Leader = s.follower.status()
+	}
+	return status
+}
+
+// federationFor the request, nil if the server has no peers or the request came from a federating server
+func (s *Server) federationFor(ctx context.Context) *federation {
+	if s.federation == nil || isFederatedRequest(ctx) {
+		return nil
+	}
+	return s.federation
+}
+
+// clusterFor the request, nil if the server isn't part of a cluster or the request came from another node
+func (s *Server) clusterFor(ctx context.Context) *cluster {
+	if s.cluster == nil || isFederatedRequest(ctx) {
+		return nil
+	}
+	return s.cluster
+}
+
+// isForwardedWrite of another cluster node. The header marking it is only trusted in cluster mode
+// and from tokens granting admin on all series, like the peer tokens of the nodes
+func (s *Server) isForwardedWrite(ctx context.Context, grant *grant) bool {
+	return s.cluster != nil && isFederatedRequest(ctx) && grant.allowsAll(PermissionAdmin)
+}
+
+// listSeries of the DB and of the peers, if the request isn't from a federating server.
+// Only series the request may read are listed
+func (s *Server) listSeries(ctx context.Context) ([]MeasurementTypeInfo, []*proto.PeerError, error) {
+	grant, err := s.grantFor(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	db, err := s.dbFor(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	infos, err := db.Series()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var peerErrors []*proto.PeerError
+	if federation := s.federationFor(ctx); federation != nil {
+		infos, peerErrors = federation.listSeries(ctx, infos)
+	}
+
+	readable := []MeasurementTypeInfo{}
+	for _, info := range infos {
+		if grant.allows(PermissionRead, info.Name) {
+			readable = append(readable, info)
+		}
+	}
+	return readable, peerErrors, nil
 }
 
-//Shutdown all goroutines and connections
+//Shutdown all goroutines and connections. Open streams are ended, running requests can finish until the
+//ShutdownTimeout and everything written is committed to disk before it returns. It can be called several times
 func (s *Server) Shutdown() {
-	s.grpcHandler.Shutdown()
-	s.debugHandler.Shutdown()
+	s.shutdownOnce.Do(s.shutdown)
+	<-s.shutdownDone
+}
 
-	s.store.Shutdown()
+func (s *Server) shutdown() {
+	defer close(s.shutdownDone)
+
+	timeout := s.config.ShutdownTimeout
+	if timeout == 0 {
+		timeout = defaultShutdownTimeout
+	}
+	stopped := make(chan struct{})
+	go func() {
+		s.debugHandler.Shutdown(timeout)
+		close(stopped)
+	}()
+	s.grpcHandler.Shutdown(timeout)
+	<-stopped
+
+	if s.follower != nil {
+		s.follower.shutdown()
+	}
+	if s.federation != nil {
+		s.federation.close()
+	}
+	if s.tenants != nil {
+		err := s.tenants.close()
+		if err != nil {
+			log.Println(err)
+		}
+	}
+
+	err := s.db.Close()
+	if err != nil {
+		log.Println(err)
+	}
 }
@@ -2,25 +2,28 @@ package mhist
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"io"
 	"log"
 	"os"
 	"testing"
 
 	"github.com/alexmorten/mhist/models"
 	"github.com/alexmorten/mhist/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
 func Test_Server(t *testing.T) {
-	formerDataPath := dataPath
-	dataPath = "test_data"
-	defer func() {
-		os.RemoveAll(dataPath)
-		dataPath = formerDataPath
-	}()
-	server := NewServer(ServerConfig{MemorySize: 2 * 1024, DiskSize: 24 * 1024 * 1024})
+	dataPath := "test_data"
+	defer os.RemoveAll(dataPath)
+	server, err := NewServer(ServerConfig{DataPath: dataPath, MemorySize: 2 * 1024, DiskSize: 24 * 1024 * 1024})
+	require.NoError(t, err)
 
 	t.Run("GrpcHandler", func(t *testing.T) {
 		t.Run("Storing and Retrieving measurements without filters", func(t *testing.T) {
@@ -28,7 +31,7 @@ func Test_Server(t *testing.T) {
 			categoricalValues := []string{"a", "b", "a", "de", "c", "b", "a"}
 			rawValues := [][]byte{[]byte("some_raw_value idk"), []byte("some_raw_value i still dont know"), []byte("some"), []byte("thing")}
 			serverTestSetup(t, server, numericalValues, categoricalValues, rawValues, func(_ int) int64 { return 0 })
-			server.store.diskStore.commit()
+			require.NoError(t, server.db.Flush())
 			request := &proto.RetrieveRequest{}
 			response, err := server.grpcHandler.Retrieve(context.Background(), request)
 			require.NoError(t, err)
@@ -60,13 +63,10 @@ func Test_Server(t *testing.T) {
 }
 
 func Test_ServerFilter(t *testing.T) {
-	formerDataPath := dataPath
-	dataPath = "test_data"
-	defer func() {
-		os.RemoveAll(dataPath)
-		dataPath = formerDataPath
-	}()
-	server := NewServer(ServerConfig{MemorySize: 24 * 1024 * 1024, DiskSize: 24 * 1024 * 1024})
+	dataPath := "test_data"
+	defer os.RemoveAll(dataPath)
+	server, err := NewServer(ServerConfig{DataPath: dataPath, MemorySize: 24 * 1024 * 1024, DiskSize: 24 * 1024 * 1024})
+	require.NoError(t, err)
 
 	t.Run("GrpcHandler", func(t *testing.T) {
 		t.Run("Storing and Retrieving measurements with filters", func(t *testing.T) {
@@ -108,13 +108,10 @@ func Test_ServerFilter(t *testing.T) {
 }
 
 func Test_ServerFineWithMultipleCommits(t *testing.T) {
-	formerDataPath := dataPath
-	dataPath = "test_data"
-	defer func() {
-		os.RemoveAll(dataPath)
-		dataPath = formerDataPath
-	}()
-	server := NewServer(ServerConfig{MemorySize: 64 * 1024, DiskSize: 24 * 1024 * 1024})
+	dataPath := "test_data"
+	defer os.RemoveAll(dataPath)
+	server, err := NewServer(ServerConfig{DataPath: dataPath, MemorySize: 64 * 1024, DiskSize: 24 * 1024 * 1024})
+	require.NoError(t, err)
 
 	t.Run("GrpcHandler", func(t *testing.T) {
 		t.Run("Storing and Retrieving measurements with filters", func(t *testing.T) {
@@ -171,3 +168,67 @@ func serverTestSetup(t *testing.T, server *Server, numericalValues []float64, ca
 
 	}
 }
+
+func Test_Shutdown(t *testing.T) {
+	dataPath := "test_data"
+	defer os.RemoveAll(dataPath)
+
+	server, err := NewServer(ServerConfig{DataPath: dataPath, GrpcPort: 16750})
+	require.NoError(t, err)
+	go server.grpcHandler.Run()
+
+	conn, err := grpc.Dial("localhost:16750", grpc.WithInsecure())
+	require.NoError(t, err)
+	defer conn.Close()
+	client := proto.NewMhistClient(conn)
+
+	subscription, err := client.Subscribe(context.Background(), &proto.Filter{}, grpc.WaitForReady(true))
+	require.NoError(t, err)
+	_, err = subscription.Header()
+	require.NoError(t, err)
+
+	stream, err := client.StoreStream(context.Background())
+	require.NoError(t, err)
+	require.NoError(t, stream.Send(&proto.MeasurementMessage{
+		Name:        "temperature",
+		Measurement: proto.MeasurementFromModel(&models.Numerical{Ts: 1000, Value: 21}),
+	}))
+	// the measurement reached the store once subscribers are notified about it
+	_, err = subscription.Recv()
+	require.NoError(t, err)
+
+	server.Shutdown()
+	server.Shutdown()
+
+	t.Run("subscriptions end cleanly", func(t *testing.T) {
+		_, err := subscription.Recv()
+		assert.Equal(t, io.EOF, err)
+	})
+
+	t.Run("store streams are told to retry elsewhere", func(t *testing.T) {
+		err := stream.RecvMsg(&proto.Nothing{})
+		assert.Equal(t, codes.Unavailable, status.Code(err))
+	})
+
+	t.Run("everything written is persisted", func(t *testing.T) {
+		db, err := Open(dataPath, Options{})
+		require.NoError(t, err)
+		defer db.Close()
+
+		result, err := db.Query(0, 2000, models.FilterDefinition{})
+		require.NoError(t, err)
+		assert.Equal(t, []models.Measurement{&models.Numerical{Ts: 1000, Value: 21}}, result["temperature"])
+	})
+}
+
+func Test_StatusFromError(t *testing.T) {
+	for err, code := range map[error]codes.Code{
+		ErrMeasurementMissingType:                                    codes.InvalidArgument,
+		fmt.Errorf("%w: start 2 is after end 1", ErrInvalidArgument): codes.InvalidArgument,
+		fmt.Errorf("%w: a", ErrSeriesNotFound):                       codes.NotFound,
+		ErrClosed:                                                    codes.Unavailable,
+		errors.New("connection reset by peer"):                       codes.Internal,
+	} {
+		assert.Equal(t, code, status.Code(statusFromError(err)), err.Error())
+	}
+}
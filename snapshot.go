@@ -0,0 +1,366 @@
+package mhist
+
+import (
+	"archive/tar"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/alexmorten/mhist/models"
+)
+
+// prefix of the temporary directories snapshots are written to before they are streamed
+const snapshotTmpPrefix = ".snapshot"
+
+// snapshotsDir in the data path snapshots requested over grpc are written to
+const snapshotsDir = "snapshots"
+
+//ErrSnapshotNotSupported is returned when a snapshot of a backend without files is requested
+var ErrSnapshotNotSupported = errors.New("the backend doesn't support snapshots")
+
+//ErrInvalidSnapshot is returned when a snapshot is incomplete or doesn't belong together
+var ErrInvalidSnapshot = errors.New("invalid snapshot")
+
+type snapshotMessage struct {
+	dir string
+	// taken is called once the content of the snapshot is fixed, it can be nil
+	taken      func()
+	resultChan chan error
+}
+
+//Snapshot writes a consistent copy of all local data files and the meta to dir, which must not exist or be empty.
+//Rotated files are hard linked (copied if dir is on another filesystem), archived files stay in the archive.
+func (s *DiskStore) Snapshot(dir string) error {
+	return s.snapshot(dir, nil)
+}
+
+func (s *DiskStore) snapshot(dir string, taken func()) error {
+	_, err := os.Stat(dir)
+	created := os.IsNotExist(err)
+	err = ensureEmptyDir(dir)
+	if err != nil {
+		return err
+	}
+
+	message := snapshotMessage{dir: dir, taken: taken, resultChan: make(chan error, 1)}
+	s.snapshotChan <- message
+	err = <-message.resultChan
+	if err != nil {
+		// a partial snapshot must not be mistaken for a complete one
+		removeSnapshot(dir, created)
+	}
+	return err
+}
+
+// removeSnapshot removes the files of a failed snapshot, and dir itself if the snapshot created it
+func removeSnapshot(dir string, created bool) {
+	if created {
+		os.RemoveAll(dir)
+		return
+	}
+	files, _ := ioutil.ReadDir(dir)
+	for _, f := range files {
+		os.RemoveAll(filepath.Join(dir, f.Name()))
+	}
+}
+
+// snapshotDirIn the snapshots directory of dataPath for the dir of a SnapshotRequest,
+// absolute dirs and dirs outside of it are rejected
+func snapshotDirIn(dataPath, dir string) (string, error) {
+	cleaned := filepath.Clean(dir)
+	if filepath.IsAbs(dir) || cleaned == "." || cleaned == ".." || strings.HasPrefix(cleaned, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("%w: the snapshot dir %v has to be relative to %v", ErrInvalidArgument, dir, filepath.Join(dataPath, snapshotsDir))
+	}
+	return filepath.Join(dataPath, snapshotsDir, cleaned), nil
+}
+
+//WriteSnapshot writes a tar archive of a consistent snapshot to w. taken is called in the Listen goroutine
+//once the content of the snapshot is fixed, no measurement is written until it returns. It can be nil
+func (s *DiskStore) WriteSnapshot(w io.Writer, taken func()) error {
+	// hard links only work on the same filesystem
+	dir, err := ioutil.TempDir(s.dir, snapshotTmpPrefix)
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	err = s.snapshot(dir, taken)
+	if err != nil {
+		return err
+	}
+	return writeTar(dir, w)
+}
+
+// handleSnapshot in the Listen goroutine, so no file is rotated, compacted or removed while it is linked
+func (s *DiskStore) handleSnapshot(message snapshotMessage) error {
+	if message.taken != nil {
+		message.taken()
+	}
+	dir := message.dir
+	err := s.flush()
+	if err != nil {
+		s.check(err)
+		return err
+	}
+
+	files, err := GetSortedFileList(s.dir)
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		for _, name := range []string{file.indexName(), file.valueLogName()} {
+			err = linkOrCopy(name, filepath.Join(dir, filepath.Base(name)))
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	// the current and backfill files are still appended to, only the committed part is copied
+	for _, f := range []*dataFile{s.dataFile, s.backfill} {
+		err = copyFilePrefix(f.indexWriter.Name(), filepath.Join(dir, filepath.Base(f.indexWriter.Name())), f.indexSize)
+		if err != nil {
+			return err
+		}
+		err = copyFilePrefix(f.valueLogWriter.Name(), filepath.Join(dir, filepath.Base(f.valueLogWriter.Name())), f.currentPos)
+		if err != nil {
+			return err
+		}
+	}
+
+	// the meta comes last, a snapshot without it is incomplete
+	return s.meta.copySnapshotTo(dir)
+}
+
+//RestoreSnapshot validates the tar archive of a snapshot read from r and installs it into dir, which must not exist or be empty
+func RestoreSnapshot(r io.Reader, dir string) error {
+	return restoreSnapshot(dir, func(staging string) error {
+		return extractTar(r, staging)
+	})
+}
+
+//RestoreSnapshotDir validates the snapshot in snapshotDir and installs a copy of it into dir, which must not exist or be empty
+func RestoreSnapshotDir(snapshotDir, dir string) error {
+	return restoreSnapshot(dir, func(staging string) error {
+		files, err := ioutil.ReadDir(snapshotDir)
+		if err != nil {
+			return err
+		}
+		for _, f := range files {
+			if !f.Mode().IsRegular() {
+				return fmt.Errorf("%w: %v is not a regular file", ErrInvalidSnapshot, f.Name())
+			}
+			err = copyFilePrefix(filepath.Join(snapshotDir, f.Name()), filepath.Join(staging, f.Name()), f.Size())
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// restoreSnapshot fills a staging directory next to dir and moves it into place once it is validated,
+// so dir is left untouched if anything goes wrong
+func restoreSnapshot(dir string, fill func(staging string) error) error {
+	err := checkEmptyDir(dir)
+	if err != nil {
+		return err
+	}
+
+	staging := strings.TrimSuffix(dir, string(filepath.Separator)) + ".restoring"
+	err = os.RemoveAll(staging)
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(staging, os.ModePerm)
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(staging)
+
+	err = fill(staging)
+	if err != nil {
+		return err
+	}
+	err = ValidateSnapshot(staging)
+	if err != nil {
+		return err
+	}
+
+	err = os.Remove(dir)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return os.Rename(staging, dir)
+}
+
+//ValidateSnapshot checks that the snapshot in dir has a readable meta and complete data files that only reference known series
+func ValidateSnapshot(dir string) error {
+	snapshot, err := readMetaSnapshot(filepath.Join(dir, metaSnapshotPath))
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidSnapshot, err)
+	}
+	meta := snapshot.Meta
+	meta.initMaps()
+
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	for _, f := range files {
+		name := f.Name()
+		if name == metaSnapshotPath || name == metaJournalPath || strings.HasSuffix(name, "_values") {
+			continue
+		}
+		if _, err := timestampsFromFileName(name); err != nil && name != "current" && name != "backfill" {
+			return fmt.Errorf("%w: unexpected file %v", ErrInvalidSnapshot, name)
+		}
+
+		err = validateDataFile(meta, filepath.Join(dir, name))
+		if err != nil {
+			return fmt.Errorf("%w: %v: %v", ErrInvalidSnapshot, name, err)
+		}
+	}
+	return nil
+}
+
+func validateDataFile(meta *DiskMeta, path string) error {
+	valueLog, err := os.Stat(path + "_values")
+	if err != nil {
+		return err
+	}
+	byteSlice, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if len(byteSlice)%serializedMeasurementSize != 0 {
+		return errors.New("index ends with a partial measurement")
+	}
+
+	for _, measurement := range BlockFromByteSlice(byteSlice) {
+		if measurement.ID <= 0 || measurement.ID > meta.HighestID {
+			return fmt.Errorf("measurement of unknown series %v", measurement.ID)
+		}
+		if meta.IDToType[measurement.ID] == models.MeasurementRaw && int64(measurement.Value)+measurement.Size > valueLog.Size() {
+			return errors.New("raw value is outside of the value log")
+		}
+	}
+	return nil
+}
+
+// removeSnapshotLeftovers of streamed snapshots that were interrupted by a crash
+func removeSnapshotLeftovers(dir string) {
+	leftovers, _ := filepath.Glob(filepath.Join(dir, snapshotTmpPrefix+"*"))
+	for _, leftover := range leftovers {
+		os.RemoveAll(leftover)
+	}
+}
+
+// ensureEmptyDir creates dir if it doesn't exist, fails if it contains anything
+func ensureEmptyDir(dir string) error {
+	err := checkEmptyDir(dir)
+	if err != nil {
+		return err
+	}
+	return os.MkdirAll(dir, os.ModePerm)
+}
+
+// checkEmptyDir fails if dir contains anything
+func checkEmptyDir(dir string) error {
+	files, err := ioutil.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if len(files) > 0 {
+		return fmt.Errorf("%w: %v is not empty", ErrInvalidArgument, dir)
+	}
+	return nil
+}
+
+func linkOrCopy(source, target string) error {
+	err := os.Link(source, target)
+	if err == nil {
+		return nil
+	}
+	info, err := os.Stat(source)
+	if err != nil {
+		return err
+	}
+	return copyFilePrefix(source, target, info.Size())
+}
+
+// copyFilePrefix copies the first size bytes of source to target
+func copyFilePrefix(source, target string, size int64) error {
+	in, err := os.Open(source)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	return writeFileAtomically(target, func(w io.Writer) error {
+		_, err := io.CopyN(w, in, size)
+		return err
+	})
+}
+
+func writeTar(dir string, w io.Writer) error {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+
+	tarWriter := tar.NewWriter(w)
+	for _, f := range files {
+		header, err := tar.FileInfoHeader(f, "")
+		if err != nil {
+			return err
+		}
+		err = tarWriter.WriteHeader(header)
+		if err != nil {
+			return err
+		}
+
+		in, err := os.Open(filepath.Join(dir, f.Name()))
+		if err != nil {
+			return err
+		}
+		_, err = io.CopyN(tarWriter, in, f.Size())
+		in.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return tarWriter.Close()
+}
+
+// extractTar into dir, snapshots are flat so only regular files without directories are accepted
+func extractTar(r io.Reader, dir string) error {
+	tarReader := tar.NewReader(r)
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidSnapshot, err)
+		}
+		if header.Typeflag != tar.TypeReg || filepath.Base(header.Name) != header.Name || header.Name == ".." {
+			return fmt.Errorf("%w: unexpected entry %v", ErrInvalidSnapshot, header.Name)
+		}
+
+		err = writeFileAtomically(filepath.Join(dir, header.Name), func(w io.Writer) error {
+			_, err := io.Copy(w, tarReader)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+	}
+}
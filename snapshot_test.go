@@ -0,0 +1,120 @@
+package mhist
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/alexmorten/mhist/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Snapshot(t *testing.T) {
+	dataPath := "test_data"
+	snapshotPath := "test_snapshot"
+	restorePath := "test_restore"
+	defer os.RemoveAll(dataPath)
+	defer os.RemoveAll(snapshotPath)
+	defer os.RemoveAll(restorePath)
+
+	// small files, so the snapshot contains rotated files as well as the current one
+	db, err := Open(dataPath, Options{MemorySize: 1024, DiskSize: 24 * 1024 * 1024})
+	require.NoError(t, err)
+	for i := 1; i <= 200; i++ {
+		require.NoError(t, db.Write("temperature", &models.Numerical{Ts: int64(i), Value: float64(i)}))
+		if i%50 == 0 {
+			require.NoError(t, db.Flush())
+		}
+	}
+	require.NoError(t, db.Write("log", &models.Raw{Ts: 201, Value: []byte("started")}))
+
+	expected, err := db.Query(1, 201, models.FilterDefinition{})
+	require.NoError(t, err)
+
+	restoreAndQuery := func(t *testing.T, restore func() error) map[string][]models.Measurement {
+		defer os.RemoveAll(restorePath)
+		require.NoError(t, restore())
+
+		restored, err := Open(restorePath, Options{})
+		require.NoError(t, err)
+		defer restored.Close()
+		result, err := restored.Query(1, 201, models.FilterDefinition{})
+		require.NoError(t, err)
+		return result
+	}
+
+	t.Run("a streamed snapshot can be restored", func(t *testing.T) {
+		buffer := &bytes.Buffer{}
+		require.NoError(t, db.WriteSnapshot(buffer))
+
+		result := restoreAndQuery(t, func() error { return RestoreSnapshot(buffer, restorePath) })
+		assert.Equal(t, expected, result)
+	})
+
+	t.Run("a snapshot directory can be restored", func(t *testing.T) {
+		require.NoError(t, db.Snapshot(snapshotPath))
+		assert.Error(t, db.Snapshot(snapshotPath), "the directory isn't empty anymore")
+		// measurements written afterwards are not part of the snapshot
+		require.NoError(t, db.Write("temperature", &models.Numerical{Ts: 202, Value: 202}))
+
+		result := restoreAndQuery(t, func() error { return RestoreSnapshotDir(snapshotPath, restorePath) })
+		assert.Equal(t, expected, result)
+	})
+
+	t.Run("snapshots are only restored into empty directories", func(t *testing.T) {
+		require.NoError(t, os.MkdirAll(restorePath, os.ModePerm))
+		defer os.RemoveAll(restorePath)
+		require.NoError(t, ioutil.WriteFile(filepath.Join(restorePath, "something"), nil, os.ModePerm))
+
+		assert.Error(t, RestoreSnapshotDir(snapshotPath, restorePath))
+	})
+
+	t.Run("incomplete snapshots are rejected", func(t *testing.T) {
+		require.NoError(t, os.Remove(filepath.Join(snapshotPath, metaSnapshotPath)))
+
+		err := RestoreSnapshotDir(snapshotPath, restorePath)
+		assert.True(t, errors.Is(err, ErrInvalidSnapshot))
+		_, err = os.Stat(restorePath)
+		assert.True(t, os.IsNotExist(err))
+	})
+
+	t.Run("failed snapshots are removed", func(t *testing.T) {
+		failedPath := "test_failed_snapshot"
+		defer os.RemoveAll(failedPath)
+		store := db.store.backend.(*DiskStore)
+		// the meta can't be written over a directory
+		blockMeta := func() {
+			require.NoError(t, os.MkdirAll(filepath.Join(failedPath, metaSnapshotPath), os.ModePerm))
+		}
+
+		assert.Error(t, store.snapshot(failedPath, blockMeta))
+		_, err := os.Stat(failedPath)
+		assert.True(t, os.IsNotExist(err), "the directory was created by the snapshot")
+
+		require.NoError(t, os.MkdirAll(failedPath, os.ModePerm))
+		assert.Error(t, store.snapshot(failedPath, blockMeta))
+		files, err := ioutil.ReadDir(failedPath)
+		require.NoError(t, err)
+		assert.Empty(t, files, "the directory existed before")
+	})
+
+	require.NoError(t, db.Close())
+}
+
+func Test_SnapshotDirIn(t *testing.T) {
+	dir, err := snapshotDirIn("data", "backup/monday")
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join("data", "snapshots", "backup", "monday"), dir)
+	dir, err = snapshotDirIn("data", "backup/../monday")
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join("data", "snapshots", "monday"), dir)
+
+	for _, invalid := range []string{"/tmp/backup", "..", "../tenants", "backup/../../meta", "."} {
+		_, err := snapshotDirIn("data", invalid)
+		assert.True(t, errors.Is(err, ErrInvalidArgument), invalid)
+	}
+}
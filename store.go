@@ -1,45 +1,122 @@
 package mhist
 
 import (
+	"math"
+	"sync"
+
 	"github.com/alexmorten/mhist/models"
 )
 
 //Store is responsible for handling Storage of different kinds of measurements
 type Store struct {
 	subscribers SubscriberSlice
-	diskStore   *DiskStore
+	backend     Backend
+	tailCache   *TailCache
+
+	// subscribersMutex is held for reading while subscribers are notified
+	subscribersMutex sync.RWMutex
 }
 
-//NewStore from diskstore, that handles subscribers
-func NewStore(diskStore *DiskStore) *Store {
+//NewStore from backend, that handles subscribers and serves recent measurements from the tailCache
+func NewStore(backend Backend, tailCache *TailCache) *Store {
 	store := &Store{
-		diskStore: diskStore,
+		backend:   backend,
+		tailCache: tailCache,
 	}
-	store.AddSubscriber(diskStore)
+	store.AddSubscriber(tailCache)
 	return store
 }
 
 //AddSubscriber to Store
 func (s *Store) AddSubscriber(sub Subscriber) {
+	s.subscribersMutex.Lock()
+	defer s.subscribersMutex.Unlock()
+
 	s.subscribers = append(s.subscribers, sub)
 }
 
-//Add named measurement
-func (s *Store) Add(name string, m models.Measurement) {
+//RemoveSubscriber from Store, once it returns the subscriber won't be notified anymore
+func (s *Store) RemoveSubscriber(sub Subscriber) {
+	s.subscribersMutex.Lock()
+	defer s.subscribersMutex.Unlock()
+
+	remaining := make(SubscriberSlice, 0, len(s.subscribers))
+	for _, subscriber := range s.subscribers {
+		if subscriber != sub {
+			remaining = append(remaining, subscriber)
+		}
+	}
+	s.subscribers = remaining
+}
+
+//Add named measurement, subscribers are only notified if the backend accepted it
+func (s *Store) Add(name string, m models.Measurement) error {
+	err := s.backend.Add(name, m)
+	if err != nil {
+		return err
+	}
+	s.subscribersMutex.RLock()
+	defer s.subscribersMutex.RUnlock()
 	s.subscribers.NotifyAll(name, m)
+	return nil
+}
+
+//GetMeasurementsInTimeRange from tail cache if it covers the time range, from backend otherwise
+func (s *Store) GetMeasurementsInTimeRange(start, end int64, filterDefinition models.FilterDefinition) (map[string][]models.Measurement, error) {
+	if result, ok := s.tailCache.GetMeasurementsInTimeRange(start, end, filterDefinition); ok {
+		return result, nil
+	}
+	return s.backend.GetMeasurementsInTimeRange(start, end, filterDefinition)
 }
 
-//GetMeasurementsInTimeRange from disk store
-func (s *Store) GetMeasurementsInTimeRange(start, end int64, filterDefinition models.FilterDefinition) map[string][]models.Measurement {
-	return s.diskStore.GetMeasurementsInTimeRange(start, end, filterDefinition)
+//Latest measurement of each named series from tail cache
+func (s *Store) Latest(names []string) map[string]models.Measurement {
+	return s.tailCache.Latest(names)
 }
 
-//GetStoredMetaInfo from Diskstore
+//GetStoredMetaInfo from backend
 func (s *Store) GetStoredMetaInfo() []MeasurementTypeInfo {
-	return s.diskStore.GetAllStoredInfos()
+	return s.backend.GetAllStoredInfos()
+}
+
+//DeleteSeries from backend, the tail cache forgets the series as it might contain their measurements
+func (s *Store) DeleteSeries(names []string) error {
+	defer s.tailCache.Forget(names...)
+	return s.backend.DeleteSeries(names)
+}
+
+//DeleteRange from backend and the tail cache
+func (s *Store) DeleteRange(names []string, start, end int64) error {
+	err := s.backend.DeleteRange(names, start, end)
+	if err != nil {
+		return err
+	}
+	if end == 0 {
+		end = math.MaxInt64
+	}
+	s.tailCache.DeleteRange(names, start, end)
+	return nil
+}
+
+//RenameSeries in backend
+func (s *Store) RenameSeries(name, newName string) error {
+	defer s.tailCache.Forget(name, newName)
+	return s.backend.RenameSeries(name, newName)
+}
+
+//MergeSeries in backend
+func (s *Store) MergeSeries(name, into string) error {
+	defer s.tailCache.Forget(name, into)
+	return s.backend.MergeSeries(name, into)
+}
+
+//MigrateSeries in backend
+func (s *Store) MigrateSeries(name string, t models.MeasurementType) error {
+	defer s.tailCache.Forget(name)
+	return s.backend.MigrateSeries(name, t)
 }
 
-//Shutdown diskStore
+//Shutdown backend
 func (s *Store) Shutdown() {
-	s.diskStore.Shutdown()
+	s.backend.Shutdown()
 }
@@ -0,0 +1,302 @@
+package mhist
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"github.com/alexmorten/mhist/models"
+)
+
+// measurements kept per series in the tail cache
+const tailCacheSeriesCapacity = 1024
+
+// rough amount of memory a cached measurement takes up, without the payload of raw values
+const tailCacheMeasurementSize = 64
+
+// how far back the tail cache is filled from disk on startup
+const tailCacheWarmupWindow = 15 * time.Minute
+
+// TailCache keeps the most recent measurements of each series in memory,
+// so recent time ranges and latest values can be served without reading from disk.
+// It is a Subscriber and has to be notified about every stored measurement.
+type TailCache struct {
+	maxSeries int
+	// maxSize bounds the memory of all cached measurements including raw payloads, size is what they take up
+	maxSize int
+	size    int
+	series  map[string]*tailSeries
+	// coveredFrom is the timestamp from which on the cache contains all measurements of series it hasn't seen yet
+	coveredFrom int64
+	// rejected series didn't fit into the cache, reads involving them go to disk
+	rejected map[string]bool
+
+	mutex sync.RWMutex
+}
+
+// tailSeries is a ring buffer of the last measurements of a series in the order they were added
+type tailSeries struct {
+	measurements []models.Measurement
+	next         int
+	latest       models.Measurement
+	// coveredFrom is the timestamp from which on the ring contains all measurements of the series
+	coveredFrom int64
+}
+
+// NewTailCache with room for as many series as fit into memorySize bytes.
+// Series whose raw values don't fit anymore are dropped from the cache
+func NewTailCache(memorySize int) *TailCache {
+	maxSeries := memorySize / (tailCacheSeriesCapacity * tailCacheMeasurementSize)
+	if maxSeries < 1 {
+		maxSeries = 1
+	}
+	c := &TailCache{maxSeries: maxSeries, maxSize: memorySize}
+	c.Reset()
+	return c
+}
+
+// Warm the cache with the recent measurements already on disk, has to happen before it is notified about new ones
+func (c *TailCache) Warm(backend Backend) {
+	now := time.Now().UnixNano()
+	from := now - tailCacheWarmupWindow.Nanoseconds()
+	result, err := backend.GetMeasurementsInTimeRange(from, now, models.FilterDefinition{})
+	if err != nil {
+		// reads of the covered range fall back to the backend until the cache covers it on its own
+		log.Printf("couldn't warm the tail cache: %v\n", err)
+		return
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.coveredFrom = from
+	for name, measurements := range result {
+		for _, measurement := range measurements {
+			c.add(name, measurement)
+		}
+	}
+}
+
+// Reset the cache, e.g. after series were deleted or renamed. Only measurements from now on are covered afterwards.
+func (c *TailCache) Reset() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.series = map[string]*tailSeries{}
+	c.rejected = map[string]bool{}
+	c.size = 0
+	c.coveredFrom = time.Now().UnixNano()
+}
+
+// Forget the named series, e.g. after they were renamed. Only their measurements from now on, and after the latest one
+// the cache has seen, are covered afterwards. The other series are kept.
+func (c *TailCache) Forget(names ...string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	coveredFrom := time.Now().UnixNano()
+	for _, name := range names {
+		// measurements with future timestamps might have moved between the series
+		if series, ok := c.series[name]; ok && series.latest != nil && series.latest.Timestamp() >= coveredFrom {
+			coveredFrom = series.latest.Timestamp() + 1
+		}
+	}
+	for _, name := range names {
+		c.drop(name)
+		delete(c.rejected, name)
+		if len(c.series) >= c.maxSeries {
+			c.rejected[name] = true
+			continue
+		}
+		c.series[name] = newTailSeries(coveredFrom)
+	}
+}
+
+// DeleteRange of the named series from the cache, which still covers the same time range afterwards
+func (c *TailCache) DeleteRange(names []string, start, end int64) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	for _, name := range names {
+		series, ok := c.series[name]
+		if !ok {
+			continue
+		}
+		c.size += series.deleteRange(start, end)
+	}
+}
+
+// drop the series and its measurements from the cache
+func (c *TailCache) drop(name string) {
+	series, ok := c.series[name]
+	if !ok {
+		return
+	}
+	for _, measurement := range series.measurements {
+		c.size -= tailCacheSizeOf(measurement)
+	}
+	delete(c.series, name)
+}
+
+// Notify for the Subscriber interface
+func (c *TailCache) Notify(name string, measurement models.Measurement) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.add(name, measurement)
+}
+
+func (c *TailCache) add(name string, measurement models.Measurement) {
+	if c.rejected[name] {
+		return
+	}
+
+	series, ok := c.series[name]
+	if !ok {
+		if len(c.series) >= c.maxSeries {
+			c.rejected[name] = true
+			return
+		}
+		series = newTailSeries(c.coveredFrom)
+		c.series[name] = series
+	}
+	c.size += series.add(measurement)
+	if c.size > c.maxSize {
+		// the raw values of the series take up too much memory
+		c.drop(name)
+		c.rejected[name] = true
+	}
+}
+
+func newTailSeries(coveredFrom int64) *tailSeries {
+	return &tailSeries{
+		measurements: make([]models.Measurement, 0, tailCacheSeriesCapacity),
+		coveredFrom:  coveredFrom,
+	}
+}
+
+// add the measurement, returns by how much the memory of the series grew
+func (s *tailSeries) add(measurement models.Measurement) int {
+	ts := measurement.Timestamp()
+	if s.latest == nil || ts >= s.latest.Timestamp() {
+		s.latest = measurement
+	}
+	if ts < s.coveredFrom {
+		// the cache can't serve reads this far back anyway
+		return 0
+	}
+
+	if len(s.measurements) < cap(s.measurements) {
+		s.measurements = append(s.measurements, measurement)
+		return tailCacheSizeOf(measurement)
+	}
+
+	evicted := s.measurements[s.next]
+	if evicted.Timestamp() >= s.coveredFrom {
+		s.coveredFrom = evicted.Timestamp() + 1
+	}
+	s.measurements[s.next] = measurement
+	s.next = (s.next + 1) % len(s.measurements)
+	return tailCacheSizeOf(measurement) - tailCacheSizeOf(evicted)
+}
+
+// deleteRange from start to end, returns by how much the memory of the series grew
+func (s *tailSeries) deleteRange(start, end int64) int {
+	delta := 0
+	remaining := make([]models.Measurement, 0, cap(s.measurements))
+	// oldest first, the ring starts at next once it is full
+	for i := range s.measurements {
+		measurement := s.measurements[(s.next+i)%len(s.measurements)]
+		if ts := measurement.Timestamp(); ts >= start && ts <= end {
+			delta -= tailCacheSizeOf(measurement)
+			continue
+		}
+		remaining = append(remaining, measurement)
+	}
+	s.measurements = remaining
+	s.next = 0
+
+	if s.latest != nil && s.latest.Timestamp() >= start && s.latest.Timestamp() <= end {
+		s.latest = nil
+		for _, measurement := range s.measurements {
+			if s.latest == nil || measurement.Timestamp() >= s.latest.Timestamp() {
+				s.latest = measurement
+			}
+		}
+	}
+	return delta
+}
+
+// tailCacheSizeOf the measurement in memory, including the payload of raw values
+func tailCacheSizeOf(measurement models.Measurement) int {
+	if raw, ok := measurement.(*models.Raw); ok {
+		return tailCacheMeasurementSize + len(raw.Value)
+	}
+	return tailCacheMeasurementSize
+}
+
+// GetMeasurementsInTimeRange from memory, returns false if the cache doesn't contain all measurements in the range
+func (c *TailCache) GetMeasurementsInTimeRange(start, end int64, filterDefinition models.FilterDefinition) (map[string][]models.Measurement, bool) {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	if start < c.coveredFrom {
+		return nil, false
+	}
+
+	names := filterDefinition.Names
+	if len(names) == 0 {
+		if len(c.rejected) > 0 {
+			return nil, false
+		}
+		for name := range c.series {
+			names = append(names, name)
+		}
+	}
+
+	result := readResult{}
+	for _, name := range names {
+		if c.rejected[name] {
+			return nil, false
+		}
+		series, ok := c.series[name]
+		if !ok {
+			continue
+		}
+		if start < series.coveredFrom {
+			return nil, false
+		}
+
+		for _, measurement := range series.measurements {
+			ts := measurement.Timestamp()
+			if ts >= start && ts <= end {
+				result[name] = append(result[name], measurement)
+			}
+		}
+	}
+
+	return applyFilter(result, filterDefinition), true
+}
+
+// Latest measurement of each of the named series, of all cached series if no names are given
+func (c *TailCache) Latest(names []string) map[string]models.Measurement {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	latest := map[string]models.Measurement{}
+	if len(names) == 0 {
+		for name, series := range c.series {
+			if series.latest != nil {
+				latest[name] = series.latest
+			}
+		}
+		return latest
+	}
+
+	for _, name := range names {
+		if series, ok := c.series[name]; ok && series.latest != nil {
+			latest[name] = series.latest
+		}
+	}
+	return latest
+}
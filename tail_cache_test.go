@@ -0,0 +1,123 @@
+package mhist
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alexmorten/mhist/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_TailCache(t *testing.T) {
+	now := time.Now().UnixNano()
+	cache := NewTailCache(2 * tailCacheSeriesCapacity * tailCacheMeasurementSize)
+	cache.coveredFrom = now
+
+	for i := int64(1); i <= 10; i++ {
+		cache.Notify("a", &models.Numerical{Ts: now + i, Value: float64(i)})
+	}
+	cache.Notify("b", &models.Categorical{Ts: now + 5, Value: "x"})
+
+	t.Run("serves covered time ranges sorted by timestamp", func(t *testing.T) {
+		cache.Notify("a", &models.Numerical{Ts: now + 3, Value: 3.5})
+
+		result, ok := cache.GetMeasurementsInTimeRange(now+2, now+4, models.FilterDefinition{Names: []string{"a"}})
+		assert.True(t, ok)
+		assert.Equal(t, []models.Measurement{
+			&models.Numerical{Ts: now + 2, Value: 2},
+			&models.Numerical{Ts: now + 3, Value: 3},
+			&models.Numerical{Ts: now + 3, Value: 3.5},
+			&models.Numerical{Ts: now + 4, Value: 4},
+		}, result["a"])
+		assert.NotContains(t, result, "b")
+	})
+
+	t.Run("doesn't serve time ranges before the covered one", func(t *testing.T) {
+		_, ok := cache.GetMeasurementsInTimeRange(now-1, now+4, models.FilterDefinition{})
+		assert.False(t, ok)
+	})
+
+	t.Run("returns the latest measurement per series", func(t *testing.T) {
+		latest := cache.Latest(nil)
+		assert.Equal(t, &models.Numerical{Ts: now + 10, Value: 10}, latest["a"])
+		assert.Equal(t, &models.Categorical{Ts: now + 5, Value: "x"}, latest["b"])
+
+		latest = cache.Latest([]string{"b", "unknown"})
+		assert.Len(t, latest, 1)
+	})
+
+	t.Run("coverage shrinks when measurements are evicted", func(t *testing.T) {
+		for i := int64(11); i <= tailCacheSeriesCapacity+20; i++ {
+			cache.Notify("a", &models.Numerical{Ts: now + i, Value: float64(i)})
+		}
+
+		_, ok := cache.GetMeasurementsInTimeRange(now+1, now+100, models.FilterDefinition{Names: []string{"a"}})
+		assert.False(t, ok)
+		_, ok = cache.GetMeasurementsInTimeRange(now+1, now+100, models.FilterDefinition{Names: []string{"b"}})
+		assert.True(t, ok)
+		result, ok := cache.GetMeasurementsInTimeRange(now+100, now+200, models.FilterDefinition{Names: []string{"a"}})
+		assert.True(t, ok)
+		assert.Len(t, result["a"], 101)
+	})
+
+	t.Run("series that don't fit are read from disk", func(t *testing.T) {
+		cache.Notify("c", &models.Numerical{Ts: now + 1, Value: 1})
+
+		_, ok := cache.GetMeasurementsInTimeRange(now+1, now+2, models.FilterDefinition{Names: []string{"c"}})
+		assert.False(t, ok)
+		_, ok = cache.GetMeasurementsInTimeRange(now+1, now+2, models.FilterDefinition{})
+		assert.False(t, ok)
+	})
+
+	t.Run("deleting a range keeps the coverage", func(t *testing.T) {
+		size := cache.size
+		cache.DeleteRange([]string{"a"}, now+150, now+tailCacheSeriesCapacity+20)
+
+		result, ok := cache.GetMeasurementsInTimeRange(now+100, now+200, models.FilterDefinition{Names: []string{"a"}})
+		assert.True(t, ok)
+		assert.Len(t, result["a"], 50)
+		assert.Equal(t, &models.Numerical{Ts: now + 149, Value: 149}, cache.Latest([]string{"a"})["a"])
+		assert.Equal(t, size-(tailCacheSeriesCapacity-129)*tailCacheMeasurementSize, cache.size)
+	})
+
+	t.Run("forgetting a series keeps the others", func(t *testing.T) {
+		cache.Forget("a", "c")
+
+		_, ok := cache.GetMeasurementsInTimeRange(now+100, now+200, models.FilterDefinition{Names: []string{"a"}})
+		assert.False(t, ok)
+		_, ok = cache.GetMeasurementsInTimeRange(now+1, now+2, models.FilterDefinition{Names: []string{"b"}})
+		assert.True(t, ok)
+		assert.Equal(t, map[string]models.Measurement{"b": &models.Categorical{Ts: now + 5, Value: "x"}}, cache.Latest(nil))
+		assert.Equal(t, tailCacheMeasurementSize, cache.size)
+	})
+
+	t.Run("reset drops everything", func(t *testing.T) {
+		cache.Reset()
+
+		assert.Empty(t, cache.Latest(nil))
+		_, ok := cache.GetMeasurementsInTimeRange(now+1, now+2, models.FilterDefinition{})
+		assert.False(t, ok)
+	})
+}
+
+func Test_TailCacheRawValues(t *testing.T) {
+	now := time.Now().UnixNano()
+	cache := NewTailCache(2 * tailCacheSeriesCapacity * tailCacheMeasurementSize)
+	cache.coveredFrom = now
+
+	cache.Notify("small", &models.Raw{Ts: now + 1, Value: make([]byte, 1024)})
+	assert.Equal(t, tailCacheMeasurementSize+1024, cache.size)
+
+	t.Run("series whose raw values don't fit are dropped", func(t *testing.T) {
+		for i := int64(1); i <= 200; i++ {
+			cache.Notify("large", &models.Raw{Ts: now + i, Value: make([]byte, 1024)})
+		}
+
+		_, ok := cache.GetMeasurementsInTimeRange(now+1, now+2, models.FilterDefinition{Names: []string{"large"}})
+		assert.False(t, ok)
+		result, ok := cache.GetMeasurementsInTimeRange(now+1, now+2, models.FilterDefinition{Names: []string{"small"}})
+		assert.True(t, ok)
+		assert.Len(t, result["small"], 1)
+		assert.Equal(t, tailCacheMeasurementSize+1024, cache.size)
+	})
+}
@@ -0,0 +1,247 @@
+package mhist
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/metadata"
+)
+
+// tenantHeader selects the tenant of a request, tokens bound to a tenant can't select another one
+const tenantHeader = "mhist-tenant"
+
+// tenants are stored in directories named after them in this directory of the data path
+const tenantsDir = "tenants"
+
+var validTenantName = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]*$`)
+
+//ErrQuotaExceeded is returned for writes beyond the ingest rate of a tenant
+var ErrQuotaExceeded = errors.New("quota exceeded")
+
+//ErrTenantsDisabled is returned for requests of a tenant if the server has no tenants
+var ErrTenantsDisabled = errors.New("tenants are not enabled")
+
+//ErrUnknownTenant is returned for requests of a tenant that isn't configured
+var ErrUnknownTenant = errors.New("unknown tenant")
+
+//TenantQuota limits the resources of a tenant
+type TenantQuota struct {
+	// DiskSize of the tenant, its oldest data is removed when it is exceeded. The DiskSize of the server by default
+	DiskSize int
+	// IngestRate in measurements per second, writes beyond it fail with ErrQuotaExceeded. 0 doesn't limit writes
+	IngestRate int
+}
+
+//TenantConfig of a server hosting several tenants, each of them in its own directory under the data path.
+//Only the tenants in Names and Quotas can be used
+type TenantConfig struct {
+	// Names of the tenants without their own quota
+	Names []string
+	// DefaultQuota of tenants without a quota in Quotas
+	DefaultQuota TenantQuota
+	// Quotas by tenant name
+	Quotas map[string]TenantQuota
+}
+
+// isConfigured if the tenant is in Names or Quotas
+func (c TenantConfig) isConfigured(name string) bool {
+	if _, ok := c.Quotas[name]; ok {
+		return true
+	}
+	for _, configured := range c.Names {
+		if configured == name {
+			return true
+		}
+	}
+	return false
+}
+
+//ParseTenantQuotas from a comma separated list of name=disk_size:ingest_rate entries, e.g. team-a=1073741824:1000
+func ParseTenantQuotas(s string) (map[string]TenantQuota, error) {
+	quotas := map[string]TenantQuota{}
+	if s == "" {
+		return quotas, nil
+	}
+	for _, entry := range strings.Split(s, ",") {
+		parts := strings.SplitN(entry, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("tenant quota %q is not of the form name=disk_size:ingest_rate", entry)
+		}
+		limits := strings.SplitN(parts[1], ":", 2)
+		if len(limits) != 2 {
+			return nil, fmt.Errorf("tenant quota %q is not of the form name=disk_size:ingest_rate", entry)
+		}
+		diskSize, err := strconv.Atoi(limits[0])
+		if err != nil {
+			return nil, fmt.Errorf("tenant quota %q: %w", entry, err)
+		}
+		ingestRate, err := strconv.Atoi(limits[1])
+		if err != nil {
+			return nil, fmt.Errorf("tenant quota %q: %w", entry, err)
+		}
+		quotas[parts[0]] = TenantQuota{DiskSize: diskSize, IngestRate: ingestRate}
+	}
+	return quotas, nil
+}
+
+// tenants opens the DB of a tenant when it is first used
+type tenants struct {
+	dataPath string
+	// options of the server, the quota of the tenant is applied to them
+	options Options
+	config  TenantConfig
+
+	mutex  sync.Mutex
+	dbs    map[string]*DB
+	closed bool
+}
+
+func newTenants(dataPath string, options Options, config TenantConfig) *tenants {
+	return &tenants{dataPath: dataPath, options: options, config: config, dbs: map[string]*DB{}}
+}
+
+func (t *tenants) get(name string) (*DB, error) {
+	if !validTenantName.MatchString(name) {
+		return nil, fmt.Errorf("%w: invalid tenant name %q", ErrInvalidArgument, name)
+	}
+	if !t.config.isConfigured(name) {
+		return nil, fmt.Errorf("%w: %v", ErrUnknownTenant, name)
+	}
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if t.closed {
+		return nil, ErrClosed
+	}
+	if db, ok := t.dbs[name]; ok {
+		return db, nil
+	}
+
+	quota, ok := t.config.Quotas[name]
+	if !ok {
+		quota = t.config.DefaultQuota
+	}
+	options := t.options
+	if quota.DiskSize > 0 {
+		options.DiskSize = quota.DiskSize
+	}
+	options.IngestRate = quota.IngestRate
+	// archived files of tenants would mix in the archive
+	options.Archive = nil
+
+	db, err := Open(filepath.Join(t.dataPath, tenantsDir, name), options)
+	if err != nil {
+		return nil, err
+	}
+	t.dbs[name] = db
+	return db, nil
+}
+
+// setCardinalityLimits of all tenants, including the ones opened later
+func (t *tenants) setCardinalityLimits(limits CardinalityLimits) error {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	t.options.Cardinality = limits
+	for _, db := range t.dbs {
+		err := db.SetCardinalityLimits(limits)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t *tenants) close() error {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.closed = true
+
+	var firstErr error
+	for _, db := range t.dbs {
+		err := db.Close()
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// tenantOf the request, the tenant of its token or else the tenant header. "" is the default tenant of the server.
+// With authentication only tokens with admin on all series, like peer tokens, can select a tenant with the header
+func tenantOf(ctx context.Context) (string, error) {
+	requested := ""
+	md, _ := metadata.FromIncomingContext(ctx)
+	if values := md.Get(tenantHeader); len(values) > 0 {
+		requested = values[0]
+	}
+
+	grant, _ := ctx.Value(grantKey{}).(*grant)
+	if grant == nil {
+		return requested, nil
+	}
+	if grant.tenant == "" {
+		if requested != "" && !grant.allowsAll(PermissionAdmin) {
+			return "", fmt.Errorf("%w: the token isn't bound to the tenant %v", ErrPermissionDenied, requested)
+		}
+		return requested, nil
+	}
+	if requested != "" && requested != grant.tenant {
+		return "", fmt.Errorf("%w: the token is bound to the tenant %v", ErrPermissionDenied, grant.tenant)
+	}
+	return grant.tenant, nil
+}
+
+// dbFor the tenant of the request
+func (s *Server) dbFor(ctx context.Context) (*DB, error) {
+	tenant, err := tenantOf(ctx)
+	if err != nil || tenant == "" {
+		return s.db, err
+	}
+	if s.tenants == nil {
+		return nil, ErrTenantsDisabled
+	}
+	return s.tenants.get(tenant)
+}
+
+// tokenBucket admits rate events per second on average, with bursts of up to burst events
+type tokenBucket struct {
+	rate  float64
+	burst float64
+
+	mutex  sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(rate, burst float64) *tokenBucket {
+	return &tokenBucket{rate: rate, burst: burst, tokens: burst, last: time.Now()}
+}
+
+// take n tokens if they are available, otherwise returns how long it takes until they are
+func (b *tokenBucket) take(n float64, now time.Time) (bool, time.Duration) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	// now can be before last if it was taken before the bucket was created
+	if elapsed := now.Sub(b.last); elapsed > 0 {
+		b.tokens += elapsed.Seconds() * b.rate
+		if b.tokens > b.burst {
+			b.tokens = b.burst
+		}
+		b.last = now
+	}
+
+	if b.tokens < n {
+		return false, time.Duration((n - b.tokens) / b.rate * float64(time.Second))
+	}
+	b.tokens -= n
+	return true, 0
+}
@@ -0,0 +1,130 @@
+package mhist
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/alexmorten/mhist/models"
+	"github.com/alexmorten/mhist/proto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func Test_TokenBucket(t *testing.T) {
+	now := time.Now()
+	bucket := newTokenBucket(2, 2)
+	bucket.last = now
+
+	ok, _ := bucket.take(2, now)
+	assert.True(t, ok)
+	ok, wait := bucket.take(1, now)
+	assert.False(t, ok)
+	assert.Equal(t, 500*time.Millisecond, wait)
+
+	ok, _ = bucket.take(1, now.Add(500*time.Millisecond))
+	assert.True(t, ok)
+}
+
+func Test_Tenants(t *testing.T) {
+	dataPath := "test_data"
+	dir := "test_auth"
+	defer os.RemoveAll(dataPath)
+	defer os.RemoveAll(dir)
+	require.NoError(t, os.MkdirAll(dir, 0700))
+	tokens := "root admin:*\nteam-a-token tenant:team-a admin:*\nwriter write:*\n"
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "tokens"), []byte(tokens), 0600))
+
+	server, err := NewServer(ServerConfig{
+		DataPath: dataPath,
+		GrpcPort: 16720,
+		Auth:     &AuthConfig{TokenFile: filepath.Join(dir, "tokens")},
+		Tenants: &TenantConfig{
+			Names:  []string{"team-a", "team-b"},
+			Quotas: map[string]TenantQuota{"limited": {IngestRate: 3}},
+		},
+	})
+	require.NoError(t, err)
+	go server.grpcHandler.Run()
+	defer server.Shutdown()
+
+	conns := []*grpc.ClientConn{}
+	defer func() {
+		for _, conn := range conns {
+			conn.Close()
+		}
+	}()
+	clientWithToken := func(token string) proto.MhistClient {
+		conn, err := grpc.Dial("localhost:16720", grpc.WithInsecure(), grpc.WithPerRPCCredentials(TokenCredentials(token)))
+		require.NoError(t, err)
+		conns = append(conns, conn)
+		return proto.NewMhistClient(conn)
+	}
+	root := clientWithToken("root")
+	teamA := clientWithToken("team-a-token")
+	tenantContext := func(tenant string) context.Context {
+		return metadata.AppendToOutgoingContext(context.Background(), tenantHeader, tenant)
+	}
+	store := func(ctx context.Context, client proto.MhistClient, value float64) error {
+		_, err := client.Store(ctx, &proto.MeasurementMessage{
+			Name:        "temperature",
+			Measurement: proto.MeasurementFromModel(&models.Numerical{Ts: 1, Value: value}),
+		}, grpc.WaitForReady(true))
+		return err
+	}
+	retrieve := func(ctx context.Context, client proto.MhistClient) map[string][]models.Measurement {
+		response, err := client.Retrieve(ctx, &proto.RetrieveRequest{Start: 1, End: 10})
+		require.NoError(t, err)
+		return response.ToMeasurementMap()
+	}
+
+	t.Run("tenants have their own series", func(t *testing.T) {
+		require.NoError(t, store(context.Background(), root, 1))
+		require.NoError(t, store(tenantContext("team-a"), root, 2))
+		require.NoError(t, store(context.Background(), teamA, 3))
+
+		assert.Equal(t, map[string][]models.Measurement{"temperature": {&models.Numerical{Ts: 1, Value: 1}}}, retrieve(context.Background(), root))
+		assert.Equal(t, map[string][]models.Measurement{"temperature": {
+			&models.Numerical{Ts: 1, Value: 2},
+			&models.Numerical{Ts: 1, Value: 3},
+		}}, retrieve(context.Background(), teamA))
+		assert.DirExists(t, filepath.Join(dataPath, tenantsDir, "team-a"))
+	})
+
+	t.Run("tokens bound to a tenant can't select another one", func(t *testing.T) {
+		err := store(tenantContext("team-b"), teamA, 4)
+		assert.Equal(t, codes.PermissionDenied, status.Code(err))
+		err = store(tenantContext("../escape"), root, 4)
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	})
+
+	t.Run("only admin tokens select tenants with the header", func(t *testing.T) {
+		writer := clientWithToken("writer")
+		require.NoError(t, store(context.Background(), writer, 4))
+		err := store(tenantContext("team-a"), writer, 4)
+		assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	})
+
+	t.Run("tenants that aren't configured don't exist", func(t *testing.T) {
+		err := store(tenantContext("unknown"), root, 4)
+		assert.Equal(t, codes.NotFound, status.Code(err))
+		_, err = os.Stat(filepath.Join(dataPath, tenantsDir, "unknown"))
+		assert.True(t, os.IsNotExist(err))
+	})
+
+	t.Run("writes beyond the ingest rate are rejected", func(t *testing.T) {
+		var err error
+		for i := 0; i < 10 && err == nil; i++ {
+			err = store(tenantContext("limited"), root, float64(i))
+		}
+		assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+		assert.NoError(t, store(tenantContext("team-a"), root, 5), "other tenants are not limited")
+	})
+}
@@ -2,8 +2,12 @@ package main
 
 import (
 	"context"
+	"flag"
+	"log"
 	"math/rand"
+	"time"
 
+	"github.com/alexmorten/mhist"
 	"github.com/alexmorten/mhist/models"
 	"github.com/alexmorten/mhist/proto"
 	"google.golang.org/grpc"
@@ -12,7 +16,17 @@ import (
 var raw []byte = []byte("abcdefghijklmnopqrstuvwxyz")
 
 func main() {
-	conn, err := grpc.Dial("localhost:6666", grpc.WithInsecure())
+	address := flag.String("address", "localhost:6666", "address of the running mhist")
+	tls := mhist.ClientTLSConfig{}
+	tls.RegisterFlags(flag.CommandLine)
+	token := flag.String("token", "", "token sent with every request")
+	flag.Parse()
+
+	option, err := tls.DialOption()
+	if err != nil {
+		panic(err)
+	}
+	conn, err := grpc.Dial(*address, option, grpc.WithPerRPCCredentials(mhist.TokenCredentials(*token)))
 	if err != nil {
 		panic(err)
 	}
@@ -40,7 +54,18 @@ func main() {
 		}
 		err := stream.Send(m)
 		if err != nil {
-			panic(err)
+			// the status of the stream tells whether it was closed to slow down
+			_, err = stream.CloseAndRecv()
+			delay, ok := mhist.RetryDelay(err)
+			if !ok {
+				panic(err)
+			}
+			log.Printf("%v, retrying in %v", err, delay)
+			time.Sleep(delay)
+			stream, err = c.StoreStream(context.Background())
+			if err != nil {
+				panic(err)
+			}
 		}
 	}
 }
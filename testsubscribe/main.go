@@ -2,15 +2,27 @@ package main
 
 import (
 	"context"
+	"flag"
 	"log"
 	"time"
 
+	"github.com/alexmorten/mhist"
 	"github.com/alexmorten/mhist/proto"
 	"google.golang.org/grpc"
 )
 
 func main() {
-	conn, err := grpc.Dial("localhost:6666", grpc.WithInsecure())
+	address := flag.String("address", "localhost:6666", "address of the running mhist")
+	tls := mhist.ClientTLSConfig{}
+	tls.RegisterFlags(flag.CommandLine)
+	token := flag.String("token", "", "token sent with every request")
+	flag.Parse()
+
+	option, err := tls.DialOption()
+	if err != nil {
+		panic(err)
+	}
+	conn, err := grpc.Dial(*address, option, grpc.WithPerRPCCredentials(mhist.TokenCredentials(*token)))
 	if err != nil {
 		panic(err)
 	}
@@ -0,0 +1,185 @@
+package mhist
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"sync"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+)
+
+//TLSConfig of the grpc and debug listeners, also used for the connections to peers, cluster nodes and the leader
+type TLSConfig struct {
+	// CertFile and KeyFile are the PEM encoded certificate and key of the server, reloaded by Server.ReloadCertificates
+	CertFile string
+	KeyFile  string
+	// ClientCAFile enables mutual TLS, clients need a certificate signed by one of its CAs. Reloaded with the certificate
+	ClientCAFile string
+	// CAFile verifies the certificates of other servers, the system roots by default. Read at startup
+	CAFile string
+}
+
+// certificates of a server that can be swapped while it is running
+type certificates struct {
+	config TLSConfig
+	// rootCAs verify other servers, nil for the system roots
+	rootCAs *x509.CertPool
+
+	mutex       sync.RWMutex
+	certificate *tls.Certificate
+	// clientCAs is nil without mutual TLS
+	clientCAs *x509.CertPool
+}
+
+func newCertificates(config TLSConfig) (*certificates, error) {
+	if config.CertFile == "" || config.KeyFile == "" {
+		return nil, errors.New("TLS needs a certificate and a key")
+	}
+
+	c := &certificates{config: config}
+	if config.CAFile != "" {
+		var err error
+		c.rootCAs, err = readCertPool(config.CAFile)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return c, c.reload()
+}
+
+// reload the certificate and the client CAs, connections established afterwards use them
+func (c *certificates) reload() error {
+	certificate, clientCAs, err := c.load()
+	if err != nil {
+		return err
+	}
+	c.set(certificate, clientCAs)
+	return nil
+}
+
+// load the certificate and the client CAs from their files
+func (c *certificates) load() (*tls.Certificate, *x509.CertPool, error) {
+	certificate, err := tls.LoadX509KeyPair(c.config.CertFile, c.config.KeyFile)
+	if err != nil {
+		return nil, nil, err
+	}
+	var clientCAs *x509.CertPool
+	if c.config.ClientCAFile != "" {
+		clientCAs, err = readCertPool(c.config.ClientCAFile)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	return &certificate, clientCAs, nil
+}
+
+func (c *certificates) set(certificate *tls.Certificate, clientCAs *x509.CertPool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.certificate = certificate
+	c.clientCAs = clientCAs
+}
+
+// serverConfig picks up reloaded certificates for every handshake
+func (c *certificates) serverConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			c.mutex.RLock()
+			defer c.mutex.RUnlock()
+
+			config := &tls.Config{
+				MinVersion:   tls.VersionTLS12,
+				Certificates: []tls.Certificate{*c.certificate},
+				// grpc needs http2, the debug handler also serves http 1
+				NextProtos: []string{"h2", "http/1.1"},
+			}
+			if c.clientCAs != nil {
+				config.ClientCAs = c.clientCAs
+				config.ClientAuth = tls.RequireAndVerifyClientCert
+			}
+			return config, nil
+		},
+	}
+}
+
+// clientConfig for connections to other servers, which are presented the server certificate if they require one
+func (c *certificates) clientConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		RootCAs:    c.rootCAs,
+		GetClientCertificate: func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			c.mutex.RLock()
+			defer c.mutex.RUnlock()
+			return c.certificate, nil
+		},
+	}
+}
+
+// dialOption for connections to other servers, insecure without certificates
+func (c *certificates) dialOption() grpc.DialOption {
+	if c == nil {
+		return grpc.WithInsecure()
+	}
+	return grpc.WithTransportCredentials(credentials.NewTLS(c.clientConfig()))
+}
+
+func readCertPool(file string) (*x509.CertPool, error) {
+	pem, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificates found in %v", file)
+	}
+	return pool, nil
+}
+
+//ClientTLSConfig of clients connecting to an mhist
+type ClientTLSConfig struct {
+	// Enabled connects with TLS, implied by the other fields
+	Enabled bool
+	// CAFile verifies the server certificate, the system roots by default
+	CAFile string
+	// CertFile and KeyFile are the client certificate for servers requiring mutual TLS
+	CertFile string
+	KeyFile  string
+}
+
+//RegisterFlags of the config on the flag set
+func (c *ClientTLSConfig) RegisterFlags(set *flag.FlagSet) {
+	set.BoolVar(&c.Enabled, "tls", false, "connect with TLS, implied by the other tls flags")
+	set.StringVar(&c.CAFile, "tls_ca", "", "CA certificate file the server certificate is verified with, the system roots by default")
+	set.StringVar(&c.CertFile, "tls_cert", "", "client certificate file for servers requiring mutual TLS")
+	set.StringVar(&c.KeyFile, "tls_key", "", "key file of tls_cert")
+}
+
+//DialOption for grpc.Dial, an insecure connection if TLS isn't enabled
+func (c ClientTLSConfig) DialOption() (grpc.DialOption, error) {
+	if !c.Enabled && c.CAFile == "" && c.CertFile == "" {
+		return grpc.WithInsecure(), nil
+	}
+
+	config := &tls.Config{MinVersion: tls.VersionTLS12}
+	if c.CAFile != "" {
+		var err error
+		config.RootCAs, err = readCertPool(c.CAFile)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if c.CertFile != "" {
+		certificate, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		config.Certificates = []tls.Certificate{certificate}
+	}
+	return grpc.WithTransportCredentials(credentials.NewTLS(config)), nil
+}
@@ -0,0 +1,127 @@
+package mhist
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/alexmorten/mhist/proto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+)
+
+// writeCertificate signed by the parent to dir, self signed CA certificates have no parent
+func writeCertificate(t *testing.T, dir, name string, serial int64, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		DNSNames:     []string{"localhost"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+	}
+	if parent == nil {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+		template.KeyUsage |= x509.KeyUsageCertSign
+		parent, parentKey = template, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	require.NoError(t, err)
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, name+".crt"), pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, name+".key"), pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600))
+	certificate, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return certificate, key
+}
+
+func Test_TLS(t *testing.T) {
+	dataPath := "test_data"
+	dir := "test_tls"
+	defer os.RemoveAll(dataPath)
+	defer os.RemoveAll(dir)
+	require.NoError(t, os.MkdirAll(dir, 0700))
+
+	ca, caKey := writeCertificate(t, dir, "ca", 1, nil, nil)
+	writeCertificate(t, dir, "server", 2, ca, caKey)
+	writeCertificate(t, dir, "client", 3, ca, caKey)
+
+	server, err := NewServer(ServerConfig{DataPath: dataPath, GrpcPort: 16700, TLS: &TLSConfig{
+		CertFile:     filepath.Join(dir, "server.crt"),
+		KeyFile:      filepath.Join(dir, "server.key"),
+		ClientCAFile: filepath.Join(dir, "ca.crt"),
+	}})
+	require.NoError(t, err)
+	go server.grpcHandler.Run()
+	defer server.Shutdown()
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		conn, err := net.Dial("tcp", "localhost:16700")
+		if err == nil {
+			conn.Close()
+			break
+		}
+	}
+
+	listSeries := func(config ClientTLSConfig) error {
+		option, err := config.DialOption()
+		require.NoError(t, err)
+		conn, err := grpc.Dial("localhost:16700", option)
+		require.NoError(t, err)
+		defer conn.Close()
+
+		_, err = proto.NewMhistClient(conn).ListSeries(context.Background(), &proto.ListSeriesRequest{})
+		return err
+	}
+
+	t.Run("clients with a certificate are accepted", func(t *testing.T) {
+		assert.NoError(t, listSeries(ClientTLSConfig{
+			CAFile:   filepath.Join(dir, "ca.crt"),
+			CertFile: filepath.Join(dir, "client.crt"),
+			KeyFile:  filepath.Join(dir, "client.key"),
+		}))
+	})
+
+	t.Run("clients without a certificate are rejected", func(t *testing.T) {
+		assert.Error(t, listSeries(ClientTLSConfig{CAFile: filepath.Join(dir, "ca.crt")}))
+		assert.Error(t, listSeries(ClientTLSConfig{}))
+	})
+
+	t.Run("reloaded certificates are used for new connections", func(t *testing.T) {
+		writeCertificate(t, dir, "server", 4, ca, caKey)
+		require.NoError(t, server.ReloadCertificates())
+
+		clientCertificate, err := tls.LoadX509KeyPair(filepath.Join(dir, "client.crt"), filepath.Join(dir, "client.key"))
+		require.NoError(t, err)
+		roots := x509.NewCertPool()
+		roots.AddCert(ca)
+		conn, err := tls.Dial("tcp", "localhost:16700", &tls.Config{
+			RootCAs:      roots,
+			Certificates: []tls.Certificate{clientCertificate},
+			NextProtos:   []string{"h2"},
+		})
+		require.NoError(t, err)
+		defer conn.Close()
+		assert.Equal(t, big.NewInt(4), conn.ConnectionState().PeerCertificates[0].SerialNumber)
+	})
+}
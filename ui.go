@@ -0,0 +1,291 @@
+package mhist
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/alexmorten/mhist/models"
+	"github.com/alexmorten/mhist/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// uiDefaultPoints is the number of buckets a history is downsampled to without a step
+	uiDefaultPoints = 500
+	// uiMaxPoints bounds the buckets of a history, smaller steps are rejected
+	uiMaxPoints = 10000
+	// uiMaxRawValues of a history, only the most recent ones are returned
+	uiMaxRawValues = 200
+)
+
+// uiHistory of a series downsampled for plotting, depending on the type of the series one of
+// Buckets, Segments and Raw is set
+type uiHistory struct {
+	Name string `json:"name"`
+	Type string `json:"type,omitempty"`
+	// Step is the width of the buckets in nanoseconds
+	Step     int64                `json:"step"`
+	Buckets  []uiNumericalBucket  `json:"buckets,omitempty"`
+	Segments []uiCategoricalRange `json:"segments,omitempty"`
+	Raw      []uiMeasurement      `json:"raw,omitempty"`
+	// Truncated is set if older raw values were left out
+	Truncated  bool     `json:"truncated,omitempty"`
+	PeerErrors []string `json:"peer_errors,omitempty"`
+}
+
+// uiNumericalBucket summarizes the numerical measurements from Ts on for the width of a step
+type uiNumericalBucket struct {
+	Ts    int64   `json:"ts"`
+	Min   float64 `json:"min"`
+	Max   float64 `json:"max"`
+	Mean  float64 `json:"mean"`
+	Count int     `json:"count"`
+}
+
+// uiCategoricalRange is the time range in which a categorical series kept its value
+type uiCategoricalRange struct {
+	Start int64  `json:"start"`
+	End   int64  `json:"end"`
+	Value string `json:"value"`
+}
+
+// uiMeasurement is a single measurement as the ui shows it, raw values are base64 encoded
+type uiMeasurement struct {
+	Name  string      `json:"name,omitempty"`
+	Ts    int64       `json:"ts"`
+	Type  string      `json:"type"`
+	Value interface{} `json:"value"`
+}
+
+func uiMeasurementFrom(name string, measurement models.Measurement) uiMeasurement {
+	m := uiMeasurement{Name: name, Ts: measurement.Timestamp(), Type: measurement.Type().String()}
+	switch measurement := measurement.(type) {
+	case *models.Numerical:
+		m.Value = measurement.Value
+	case *models.Categorical:
+		m.Value = measurement.Value
+	case *models.Raw:
+		m.Value = measurement.Value
+	}
+	return m
+}
+
+// historyOf the measurements of a series in timestamp order, numerical ones are summarized in buckets of step
+func historyOf(name string, measurements []models.Measurement, start, step int64) uiHistory {
+	history := uiHistory{Name: name, Step: step}
+	if len(measurements) == 0 {
+		return history
+	}
+	// measurements of federated peers are appended to the local ones
+	sort.SliceStable(measurements, func(i, j int) bool {
+		return measurements[i].Timestamp() < measurements[j].Timestamp()
+	})
+	history.Type = measurements[0].Type().String()
+
+	for i, measurement := range measurements {
+		switch measurement := measurement.(type) {
+		case *models.Numerical:
+			ts := start + (measurement.Ts-start)/step*step
+			last := len(history.Buckets) - 1
+			if last < 0 || history.Buckets[last].Ts != ts {
+				history.Buckets = append(history.Buckets, uiNumericalBucket{Ts: ts, Min: measurement.Value, Max: measurement.Value})
+				last++
+			}
+			bucket := &history.Buckets[last]
+			if measurement.Value < bucket.Min {
+				bucket.Min = measurement.Value
+			}
+			if measurement.Value > bucket.Max {
+				bucket.Max = measurement.Value
+			}
+			bucket.Mean += (measurement.Value - bucket.Mean) / float64(bucket.Count+1)
+			bucket.Count++
+		case *models.Categorical:
+			last := len(history.Segments) - 1
+			if last >= 0 {
+				history.Segments[last].End = measurement.Ts
+				if history.Segments[last].Value == measurement.Value {
+					continue
+				}
+			}
+			history.Segments = append(history.Segments, uiCategoricalRange{Start: measurement.Ts, End: measurement.Ts, Value: measurement.Value})
+		case *models.Raw:
+			if len(measurements)-i > uiMaxRawValues {
+				history.Truncated = true
+				continue
+			}
+			history.Raw = append(history.Raw, uiMeasurementFrom("", measurement))
+		}
+	}
+	return history
+}
+
+// uiRange of a history request, start and end are unix nanoseconds and step a duration or empty for a default
+func uiRange(r *http.Request) (start, end, step int64, err error) {
+	query := r.URL.Query()
+	start, err = strconv.ParseInt(query.Get("start"), 10, 64)
+	if err != nil {
+		return 0, 0, 0, fmt.Errorf("start has to be in unix nanoseconds: %w", err)
+	}
+	end, err = strconv.ParseInt(query.Get("end"), 10, 64)
+	if err != nil {
+		return 0, 0, 0, fmt.Errorf("end has to be in unix nanoseconds: %w", err)
+	}
+	if end <= start {
+		return 0, 0, 0, fmt.Errorf("end has to be after start")
+	}
+
+	step = (end - start) / uiDefaultPoints
+	if s := query.Get("step"); s != "" && s != "auto" {
+		duration, err := time.ParseDuration(s)
+		if err != nil {
+			return 0, 0, 0, fmt.Errorf("step has to be a duration: %w", err)
+		}
+		step = duration.Nanoseconds()
+	}
+	if step < 1 {
+		step = 1
+	}
+	if (end-start)/step > uiMaxPoints {
+		return 0, 0, 0, fmt.Errorf("the step is too small, the range would have more than %v points", uiMaxPoints)
+	}
+	return start, end, step, nil
+}
+
+// handleUIHistory retrieves a series like Retrieve does, including the series of federated peers
+func (h *DebugHandler) handleUIHistory(w http.ResponseWriter, r *http.Request) {
+	name := r.URL.Query().Get("name")
+	if name == "" {
+		http.Error(w, "name is missing", http.StatusBadRequest)
+		return
+	}
+	start, end, step, err := uiRange(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	response, err := h.server.grpcHandler.Retrieve(tenantContext(r), &proto.RetrieveRequest{
+		Start:  start,
+		End:    end,
+		Filter: &proto.Filter{Names: []string{name}},
+	})
+	if err != nil {
+		uiError(w, err)
+		return
+	}
+
+	history := historyOf(name, response.ToMeasurementMap()[name], start, step)
+	for _, peerError := range response.PeerErrors {
+		history.PeerErrors = append(history.PeerErrors, peerError.Peer+": "+peerError.Error)
+	}
+	writeJSON(w, http.StatusOK, history)
+}
+
+// handleUITail streams the measurements of the named series as server-sent events, like Subscribe does
+func (h *DebugHandler) handleUITail(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming isn't supported", http.StatusInternalServerError)
+		return
+	}
+	filter := &proto.Filter{}
+	if names := r.URL.Query().Get("names"); names != "" {
+		filter.Names = strings.Split(names, ",")
+	}
+
+	stream := &sseSubscribeStream{ctx: tenantContext(r), w: w, flusher: flusher}
+	err := h.server.grpcHandler.Subscribe(filter, stream)
+	if err != nil && !stream.started {
+		uiError(w, err)
+		return
+	}
+	if err != nil && r.Context().Err() == nil {
+		log.Println("ui tail ended:", err)
+	}
+}
+
+// uiError answers with the http status matching the grpc status of the error
+func uiError(w http.ResponseWriter, err error) {
+	code := http.StatusInternalServerError
+	switch status.Code(err) {
+	case codes.InvalidArgument:
+		code = http.StatusBadRequest
+	case codes.Unauthenticated:
+		code = http.StatusUnauthorized
+	case codes.PermissionDenied:
+		code = http.StatusForbidden
+	case codes.NotFound:
+		code = http.StatusNotFound
+	case codes.ResourceExhausted:
+		code = http.StatusTooManyRequests
+	case codes.Unavailable:
+		code = http.StatusServiceUnavailable
+	}
+	http.Error(w, status.Convert(err).Message(), code)
+}
+
+// sseSubscribeStream lets the Subscribe handler send its measurements as server-sent events.
+// Only the methods Subscribe uses are implemented
+type sseSubscribeStream struct {
+	grpc.ServerStream
+	ctx     context.Context
+	w       http.ResponseWriter
+	flusher http.Flusher
+	// started once the header is sent, errors can't be answered with a status afterwards
+	started bool
+}
+
+func (s *sseSubscribeStream) Context() context.Context {
+	return s.ctx
+}
+
+// SendHeader starts the event stream, the peers that couldn't be subscribed to are sent as a peer_errors event
+func (s *sseSubscribeStream) SendHeader(header metadata.MD) error {
+	s.w.Header().Set("Content-Type", "text/event-stream")
+	s.w.Header().Set("Cache-Control", "no-cache")
+	s.w.WriteHeader(http.StatusOK)
+	s.started = true
+	if peerErrors := header.Get(peerErrorsHeader); len(peerErrors) > 0 {
+		return s.sendEvent("peer_errors", peerErrors)
+	}
+	_, err := fmt.Fprint(s.w, ": subscribed\n\n")
+	s.flusher.Flush()
+	return err
+}
+
+func (s *sseSubscribeStream) Send(message *proto.MeasurementMessage) error {
+	return s.SendMsg(message)
+}
+
+func (s *sseSubscribeStream) SendMsg(m interface{}) error {
+	message, ok := m.(*proto.MeasurementMessage)
+	if !ok {
+		return fmt.Errorf("unexpected message %T", m)
+	}
+	err := s.ctx.Err()
+	if err != nil {
+		return err
+	}
+	return s.sendEvent("measurement", uiMeasurementFrom(message.Name, message.Measurement.ToModelWithDefinedTs()))
+}
+
+func (s *sseSubscribeStream) sendEvent(event string, data interface{}) error {
+	b, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(s.w, "event: %v\ndata: %s\n\n", event, b)
+	s.flusher.Flush()
+	return err
+}
@@ -0,0 +1,428 @@
+package mhist
+
+// uiPage is the single page of the ui served at /ui. It lists the series from /meta, plots their history from
+// /ui/history and tails them with the server-sent events of /ui/tail
+const uiPage = `<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>mhist</title>
+<style>
+  * { box-sizing: border-box; }
+  body { margin: 0; font: 14px/1.4 system-ui, sans-serif; color: #222; display: flex; height: 100vh; }
+  aside { width: 280px; border-right: 1px solid #ddd; display: flex; flex-direction: column; }
+  aside header, main header { padding: 8px; border-bottom: 1px solid #ddd; display: flex; gap: 6px; flex-wrap: wrap; align-items: center; }
+  aside input { width: 100%; }
+  #series { list-style: none; margin: 0; padding: 0; overflow-y: auto; flex: 1; }
+  #series li { padding: 4px 8px; cursor: pointer; display: flex; justify-content: space-between; gap: 8px; }
+  #series li:hover { background: #f2f2f2; }
+  #series li.selected { background: #dde8f7; }
+  .type { color: #888; font-size: 12px; }
+  main { flex: 1; display: flex; flex-direction: column; min-width: 0; }
+  #title { font-weight: bold; margin-right: auto; }
+  #view { flex: 1; overflow: auto; padding: 8px; }
+  #status { padding: 4px 8px; border-top: 1px solid #ddd; color: #666; min-height: 26px; }
+  #status.error { color: #b00020; }
+  svg text { font-size: 11px; fill: #555; }
+  .axis { stroke: #bbb; }
+  .band { fill: #4a7fc1; opacity: .2; }
+  .line { fill: none; stroke: #4a7fc1; stroke-width: 1.5; }
+  .legend span { display: inline-block; margin-right: 12px; }
+  .legend i { display: inline-block; width: 10px; height: 10px; margin-right: 4px; }
+  .raw { margin-bottom: 12px; }
+  .raw pre { margin: 4px 0; padding: 6px; background: #f6f6f6; overflow-x: auto; }
+  #live { font-family: monospace; font-size: 12px; max-height: 160px; overflow-y: auto; border-top: 1px solid #ddd; padding: 4px 8px; }
+</style>
+</head>
+<body>
+<aside>
+  <header>
+    <input id="token" type="password" placeholder="token (optional)">
+    <input id="tenant" placeholder="tenant (optional)">
+    <input id="filter" placeholder="filter series">
+    <button id="reload">reload series</button>
+  </header>
+  <ul id="series"></ul>
+</aside>
+<main>
+  <header>
+    <span id="title">select a series</span>
+    <select id="range">
+      <option value="900000">last 15 minutes</option>
+      <option value="3600000" selected>last hour</option>
+      <option value="21600000">last 6 hours</option>
+      <option value="86400000">last day</option>
+      <option value="604800000">last week</option>
+      <option value="custom">custom</option>
+    </select>
+    <input id="from" type="datetime-local" step="1" hidden>
+    <input id="to" type="datetime-local" step="1" hidden>
+    <select id="step">
+      <option value="auto">auto granularity</option>
+      <option value="1s">1s</option>
+      <option value="10s">10s</option>
+      <option value="1m">1m</option>
+      <option value="5m">5m</option>
+      <option value="1h">1h</option>
+    </select>
+    <button id="refresh">refresh</button>
+    <button id="tail">tail live</button>
+  </header>
+  <div id="view"></div>
+  <div id="live" hidden></div>
+  <div id="status"></div>
+</main>
+<script>
+"use strict";
+var $ = function (id) { return document.getElementById(id); };
+var state = { series: [], selected: null, history: null, start: 0, end: 0, tail: null, renderQueued: false };
+var palette = ["#4a7fc1", "#e3823b", "#5aa55a", "#c94f4f", "#8c6bb1", "#8c564b", "#d67ab1", "#7f7f7f", "#bcbd22", "#17becf"];
+
+$("token").value = sessionStorage.getItem("mhist-token") || "";
+$("tenant").value = sessionStorage.getItem("mhist-tenant") || "";
+
+function headers() {
+  var h = {};
+  if ($("token").value) { h["Authorization"] = "Bearer " + $("token").value; }
+  if ($("tenant").value) { h["Mhist-Tenant"] = $("tenant").value; }
+  return h;
+}
+
+function setStatus(text, isError) {
+  $("status").textContent = text;
+  $("status").className = isError ? "error" : "";
+}
+
+function api(path) {
+  return fetch(path, { headers: headers() }).then(function (response) {
+    if (!response.ok) {
+      return response.text().then(function (text) { throw new Error(response.status + ": " + text); });
+    }
+    return response.json();
+  });
+}
+
+// nanoseconds are sent as strings, they don't fit into the numbers of javascript
+function ns(ms) { return String(Math.floor(ms)) + "000000"; }
+function ms(ts) { return ts / 1e6; }
+function formatTime(ts) { return new Date(ms(ts)).toLocaleString(); }
+
+function loadSeries() {
+  sessionStorage.setItem("mhist-token", $("token").value);
+  sessionStorage.setItem("mhist-tenant", $("tenant").value);
+  api("/meta").then(function (series) {
+    state.series = (series || []).sort(function (a, b) { return a.name < b.name ? -1 : 1; });
+    renderSeries();
+    setStatus(state.series.length + " series");
+  }).catch(function (err) { setStatus(err.message, true); });
+}
+
+function typeName(t) { return { 1: "numerical", 2: "categorical", 3: "raw" }[t] || "unknown"; }
+
+function renderSeries() {
+  var list = $("series");
+  var filter = $("filter").value.toLowerCase();
+  list.innerHTML = "";
+  state.series.forEach(function (s) {
+    if (filter && s.name.toLowerCase().indexOf(filter) < 0) { return; }
+    var li = document.createElement("li");
+    li.className = state.selected && state.selected.name === s.name ? "selected" : "";
+    var name = document.createElement("span");
+    name.textContent = s.name;
+    var type = document.createElement("span");
+    type.className = "type";
+    type.textContent = typeName(s.type);
+    li.appendChild(name);
+    li.appendChild(type);
+    li.onclick = function () { select(s); };
+    list.appendChild(li);
+  });
+}
+
+function select(s) {
+  stopTail();
+  state.selected = s;
+  $("title").textContent = s.name;
+  renderSeries();
+  loadHistory();
+}
+
+function currentRange() {
+  if ($("range").value === "custom") {
+    return { start: new Date($("from").value).getTime(), end: new Date($("to").value).getTime() };
+  }
+  var end = Date.now();
+  return { start: end - Number($("range").value), end: end };
+}
+
+function loadHistory() {
+  if (!state.selected) { return; }
+  var range = currentRange();
+  if (!(range.end > range.start)) {
+    setStatus("the range has to end after it starts", true);
+    return;
+  }
+  var path = "/ui/history?name=" + encodeURIComponent(state.selected.name) + "&start=" + ns(range.start) +
+    "&end=" + ns(range.end) + "&step=" + encodeURIComponent($("step").value);
+  setStatus("loading...");
+  api(path).then(function (history) {
+    state.history = history;
+    state.start = range.start;
+    state.end = range.end;
+    render();
+    var text = "step " + (history.step / 1e9) + "s";
+    if (history.truncated) { text += ", only the most recent raw values are shown"; }
+    if (history.peer_errors) { text += ", peers failed: " + history.peer_errors.join("; "); }
+    setStatus(text, !!history.peer_errors);
+  }).catch(function (err) { setStatus(err.message, true); });
+}
+
+function render() {
+  state.renderQueued = false;
+  var view = $("view");
+  view.innerHTML = "";
+  var history = state.history;
+  if (!history) { return; }
+  var type = typeName(state.selected.type);
+  if (type === "numerical") {
+    view.appendChild(plotNumerical(history.buckets || []));
+  } else if (type === "categorical") {
+    plotTimeline(view, history.segments || []);
+  } else {
+    renderRaw(view, history.raw || []);
+  }
+}
+
+function queueRender() {
+  if (state.renderQueued) { return; }
+  state.renderQueued = true;
+  requestAnimationFrame(render);
+}
+
+var svgNS = "http://www.w3.org/2000/svg";
+function svgElement(name, attributes, text) {
+  var e = document.createElementNS(svgNS, name);
+  Object.keys(attributes).forEach(function (k) { e.setAttribute(k, attributes[k]); });
+  if (text !== undefined) { e.textContent = text; }
+  return e;
+}
+
+function plotFrame(height) {
+  var width = Math.max($("view").clientWidth - 16, 300);
+  var svg = svgElement("svg", { width: width, height: height });
+  var frame = { svg: svg, left: 60, right: width - 10, top: 10, bottom: height - 24 };
+  frame.x = function (ts) { return frame.left + (ms(ts) - state.start) / (state.end - state.start) * (frame.right - frame.left); };
+  svg.appendChild(svgElement("line", { x1: frame.left, x2: frame.right, y1: frame.bottom, y2: frame.bottom, "class": "axis" }));
+  for (var i = 0; i <= 4; i++) {
+    var t = state.start + (state.end - state.start) * i / 4;
+    var x = frame.left + (frame.right - frame.left) * i / 4;
+    svg.appendChild(svgElement("text", { x: x, y: height - 6, "text-anchor": i === 0 ? "start" : i === 4 ? "end" : "middle" },
+      new Date(t).toLocaleString()));
+  }
+  return frame;
+}
+
+function plotNumerical(buckets) {
+  var frame = plotFrame(320);
+  if (buckets.length === 0) {
+    frame.svg.appendChild(svgElement("text", { x: frame.left + 10, y: 30 }, "no measurements in this range"));
+    return frame.svg;
+  }
+  var min = Infinity, max = -Infinity;
+  buckets.forEach(function (b) { min = Math.min(min, b.min); max = Math.max(max, b.max); });
+  if (min === max) { min -= 1; max += 1; }
+  var y = function (v) { return frame.bottom - (v - min) / (max - min) * (frame.bottom - frame.top); };
+  for (var i = 0; i <= 4; i++) {
+    var v = min + (max - min) * i / 4;
+    frame.svg.appendChild(svgElement("line", { x1: frame.left, x2: frame.right, y1: y(v), y2: y(v), "class": "axis", opacity: 0.3 }));
+    frame.svg.appendChild(svgElement("text", { x: frame.left - 4, y: y(v) + 4, "text-anchor": "end" }, Number(v.toPrecision(4))));
+  }
+  var width = (state.history.step / 1e6) / (state.end - state.start) * (frame.right - frame.left);
+  var upper = buckets.map(function (b) { return (frame.x(b.ts) + width / 2) + "," + y(b.max); });
+  var lower = buckets.map(function (b) { return (frame.x(b.ts) + width / 2) + "," + y(b.min); }).reverse();
+  frame.svg.appendChild(svgElement("polygon", { points: upper.concat(lower).join(" "), "class": "band" }));
+  var line = buckets.map(function (b) { return (frame.x(b.ts) + width / 2) + "," + y(b.mean); });
+  frame.svg.appendChild(svgElement("polyline", { points: line.join(" "), "class": "line" }));
+  buckets.forEach(function (b) {
+    var point = svgElement("circle", { cx: frame.x(b.ts) + width / 2, cy: y(b.mean), r: buckets.length < 100 ? 3 : 1.5, fill: "#4a7fc1" });
+    point.appendChild(svgElement("title", {}, formatTime(b.ts) + "\nmean " + b.mean + "\nmin " + b.min + "\nmax " + b.max + "\n" + b.count + " measurements"));
+    frame.svg.appendChild(point);
+  });
+  return frame.svg;
+}
+
+function plotTimeline(view, segments) {
+  var colors = {};
+  segments.forEach(function (s) {
+    if (!(s.value in colors)) { colors[s.value] = palette[Object.keys(colors).length % palette.length]; }
+  });
+  var frame = plotFrame(90);
+  segments.forEach(function (s, i) {
+    // a value lasts until the next one starts, the last one until the end of the range
+    var end = i + 1 < segments.length ? segments[i + 1].start : s.end;
+    var x = frame.x(s.start);
+    var rect = svgElement("rect", { x: x, y: frame.top, width: Math.max(frame.x(end) - x, 1), height: frame.bottom - frame.top - 4, fill: colors[s.value] });
+    rect.appendChild(svgElement("title", {}, s.value + "\n" + formatTime(s.start) + " - " + formatTime(s.end)));
+    frame.svg.appendChild(rect);
+  });
+  view.appendChild(frame.svg);
+  var legend = document.createElement("div");
+  legend.className = "legend";
+  Object.keys(colors).forEach(function (value) {
+    var entry = document.createElement("span");
+    var swatch = document.createElement("i");
+    swatch.style.background = colors[value];
+    entry.appendChild(swatch);
+    entry.appendChild(document.createTextNode(value));
+    legend.appendChild(entry);
+  });
+  if (segments.length === 0) { legend.textContent = "no measurements in this range"; }
+  view.appendChild(legend);
+}
+
+function decodeBase64(value) {
+  var binary = atob(value || "");
+  var bytes = new Uint8Array(binary.length);
+  for (var i = 0; i < binary.length; i++) { bytes[i] = binary.charCodeAt(i); }
+  return bytes;
+}
+
+function hexDump(bytes) {
+  var lines = [];
+  for (var offset = 0; offset < bytes.length; offset += 16) {
+    var hex = "", ascii = "";
+    for (var i = offset; i < offset + 16; i++) {
+      if (i < bytes.length) {
+        hex += ("0" + bytes[i].toString(16)).slice(-2) + " ";
+        ascii += bytes[i] >= 32 && bytes[i] < 127 ? String.fromCharCode(bytes[i]) : ".";
+      } else {
+        hex += "   ";
+      }
+      if (i === offset + 7) { hex += " "; }
+    }
+    lines.push(("0000000" + offset.toString(16)).slice(-8) + "  " + hex + " |" + ascii + "|");
+  }
+  return lines.join("\n");
+}
+
+function renderRaw(view, raw) {
+  if (raw.length === 0) {
+    view.textContent = "no measurements in this range";
+    return;
+  }
+  // the most recent value first
+  raw.slice().reverse().forEach(function (m) {
+    var bytes = decodeBase64(m.value);
+    var entry = document.createElement("div");
+    entry.className = "raw";
+    entry.textContent = formatTime(m.ts) + ", " + bytes.length + " bytes";
+    var pre = document.createElement("pre");
+    pre.textContent = hexDump(bytes);
+    entry.appendChild(pre);
+    view.appendChild(entry);
+  });
+}
+
+function appendLive(m) {
+  var history = state.history;
+  var line = document.createElement("div");
+  var value = m.type === "raw" ? decodeBase64(m.value).length + " bytes" : m.value;
+  line.textContent = formatTime(m.ts) + "  " + m.name + "  " + value;
+  $("live").insertBefore(line, $("live").firstChild);
+  while ($("live").childNodes.length > 200) { $("live").removeChild($("live").lastChild); }
+  if (!history || !state.selected || m.name !== state.selected.name) { return; }
+
+  // the range follows the live measurements
+  state.end = Math.max(state.end, ms(m.ts));
+  if (m.type === "numerical") {
+    history.buckets = history.buckets || [];
+    history.buckets.push({ ts: m.ts, min: m.value, max: m.value, mean: m.value, count: 1 });
+  } else if (m.type === "categorical") {
+    history.segments = history.segments || [];
+    var last = history.segments[history.segments.length - 1];
+    if (last) { last.end = m.ts; }
+    if (!last || last.value !== m.value) { history.segments.push({ start: m.ts, end: m.ts, value: m.value }); }
+  } else {
+    history.raw = history.raw || [];
+    history.raw.push(m);
+    if (history.raw.length > 200) { history.raw.shift(); }
+  }
+  queueRender();
+}
+
+function startTail() {
+  if (!state.selected) { return; }
+  var controller = new AbortController();
+  state.tail = controller;
+  $("tail").textContent = "stop tailing";
+  $("live").hidden = false;
+  fetch("/ui/tail?names=" + encodeURIComponent(state.selected.name), { headers: headers(), signal: controller.signal })
+    .then(function (response) {
+      if (!response.ok) {
+        return response.text().then(function (text) { throw new Error(response.status + ": " + text); });
+      }
+      setStatus("tailing " + state.selected.name);
+      var reader = response.body.getReader();
+      var decoder = new TextDecoder();
+      var buffer = "";
+      var read = function () {
+        return reader.read().then(function (chunk) {
+          if (chunk.done) {
+            setStatus("the server ended the tail");
+            stopTail();
+            return;
+          }
+          buffer += decoder.decode(chunk.value, { stream: true });
+          var events = buffer.split("\n\n");
+          buffer = events.pop();
+          events.forEach(handleEvent);
+          return read();
+        });
+      };
+      return read();
+    })
+    .catch(function (err) {
+      if (err.name !== "AbortError") { setStatus(err.message, true); }
+      stopTail();
+    });
+}
+
+function handleEvent(text) {
+  var event = "message", data = "";
+  text.split("\n").forEach(function (line) {
+    if (line.indexOf("event: ") === 0) { event = line.slice(7); }
+    if (line.indexOf("data: ") === 0) { data += line.slice(6); }
+  });
+  if (event === "measurement") {
+    appendLive(JSON.parse(data));
+  } else if (event === "peer_errors") {
+    setStatus("peers failed: " + JSON.parse(data).join("; "), true);
+  }
+}
+
+function stopTail() {
+  if (state.tail) { state.tail.abort(); }
+  state.tail = null;
+  $("tail").textContent = "tail live";
+}
+
+$("reload").onclick = loadSeries;
+$("filter").oninput = renderSeries;
+$("refresh").onclick = loadHistory;
+$("step").onchange = loadHistory;
+$("range").onchange = function () {
+  var custom = $("range").value === "custom";
+  $("from").hidden = !custom;
+  $("to").hidden = !custom;
+  if (!custom) { loadHistory(); }
+};
+$("from").onchange = loadHistory;
+$("to").onchange = loadHistory;
+$("tail").onclick = function () { if (state.tail) { stopTail(); } else { startTail(); } };
+window.onresize = queueRender;
+loadSeries();
+</script>
+</body>
+</html>
+`
@@ -0,0 +1,116 @@
+package mhist
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/alexmorten/mhist/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_HistoryOf(t *testing.T) {
+	t.Run("numerical measurements are summarized in buckets", func(t *testing.T) {
+		history := historyOf("n", []models.Measurement{
+			&models.Numerical{Ts: 1005, Value: 3},
+			&models.Numerical{Ts: 1000, Value: 1},
+			&models.Numerical{Ts: 1025, Value: 4},
+		}, 1000, 10)
+		assert.Equal(t, "numerical", history.Type)
+		assert.Equal(t, []uiNumericalBucket{
+			{Ts: 1000, Min: 1, Max: 3, Mean: 2, Count: 2},
+			{Ts: 1020, Min: 4, Max: 4, Mean: 4, Count: 1},
+		}, history.Buckets)
+	})
+
+	t.Run("categorical measurements become the ranges of their values", func(t *testing.T) {
+		history := historyOf("c", []models.Measurement{
+			&models.Categorical{Ts: 1, Value: "on"},
+			&models.Categorical{Ts: 2, Value: "on"},
+			&models.Categorical{Ts: 3, Value: "off"},
+		}, 0, 1)
+		assert.Equal(t, []uiCategoricalRange{
+			{Start: 1, End: 3, Value: "on"},
+			{Start: 3, End: 3, Value: "off"},
+		}, history.Segments)
+	})
+
+	t.Run("only the most recent raw values are kept", func(t *testing.T) {
+		measurements := []models.Measurement{}
+		for ts := int64(1); ts <= uiMaxRawValues+1; ts++ {
+			measurements = append(measurements, &models.Raw{Ts: ts, Value: []byte{byte(ts)}})
+		}
+		history := historyOf("r", measurements, 0, 1)
+		assert.True(t, history.Truncated)
+		require.Len(t, history.Raw, uiMaxRawValues)
+		assert.Equal(t, uiMeasurement{Ts: 2, Type: "raw", Value: []byte{2}}, history.Raw[0])
+	})
+}
+
+func Test_UI(t *testing.T) {
+	dataPath := "test_data"
+	defer os.RemoveAll(dataPath)
+
+	server, err := NewServer(ServerConfig{DataPath: dataPath, MemorySize: 1024 * 1024, DiskSize: 24 * 1024 * 1024})
+	require.NoError(t, err)
+	defer server.Shutdown()
+	httpServer := httptest.NewServer(server.debugHandler.handler())
+	defer httpServer.Close()
+
+	require.NoError(t, server.db.Write("temperature", &models.Numerical{Ts: 1000, Value: 20}))
+	require.NoError(t, server.db.Write("temperature", &models.Numerical{Ts: 2000, Value: 22}))
+
+	t.Run("the page is served", func(t *testing.T) {
+		response, err := http.Get(httpServer.URL + "/ui")
+		require.NoError(t, err)
+		defer response.Body.Close()
+		assert.Equal(t, http.StatusOK, response.StatusCode)
+		assert.Equal(t, "text/html; charset=utf-8", response.Header.Get("Content-Type"))
+	})
+
+	t.Run("the history of a series is downsampled", func(t *testing.T) {
+		response, err := http.Get(httpServer.URL + "/ui/history?name=temperature&start=0&end=10000&step=10us")
+		require.NoError(t, err)
+		defer response.Body.Close()
+		require.Equal(t, http.StatusOK, response.StatusCode)
+
+		history := uiHistory{}
+		require.NoError(t, json.NewDecoder(response.Body).Decode(&history))
+		assert.Equal(t, []uiNumericalBucket{{Ts: 0, Min: 20, Max: 22, Mean: 21, Count: 2}}, history.Buckets)
+	})
+
+	t.Run("invalid ranges are rejected", func(t *testing.T) {
+		response, err := http.Get(httpServer.URL + "/ui/history?name=temperature&start=10000&end=0")
+		require.NoError(t, err)
+		response.Body.Close()
+		assert.Equal(t, http.StatusBadRequest, response.StatusCode)
+	})
+
+	t.Run("live measurements are streamed as events", func(t *testing.T) {
+		response, err := http.Get(httpServer.URL + "/ui/tail?names=temperature")
+		require.NoError(t, err)
+		defer response.Body.Close()
+		require.Equal(t, "text/event-stream", response.Header.Get("Content-Type"))
+
+		reader := bufio.NewReader(response.Body)
+		line, err := reader.ReadString('\n')
+		require.NoError(t, err)
+		assert.Equal(t, ": subscribed\n", line)
+
+		require.NoError(t, server.db.Write("humidity", &models.Numerical{Ts: 3000, Value: 60}))
+		require.NoError(t, server.db.Write("temperature", &models.Numerical{Ts: 3000, Value: 23}))
+		event := ""
+		for !strings.HasPrefix(event, "data: ") {
+			event, err = reader.ReadString('\n')
+			require.NoError(t, err)
+		}
+		m := uiMeasurement{}
+		require.NoError(t, json.Unmarshal([]byte(strings.TrimPrefix(event, "data: ")), &m))
+		assert.Equal(t, uiMeasurement{Name: "temperature", Ts: 3000, Type: "numerical", Value: 23.0}, m)
+	})
+}